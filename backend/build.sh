@@ -17,7 +17,7 @@ CGO_ENABLED=1 \
 GOOS=linux \
 GOARCH=amd64 \
 go build \
-    -tags 'sqlite_omit_load_extension' \
+    -tags 'sqlite_omit_load_extension sqlite_fts5' \
     -ldflags='-w -s' \
     -o imagebed-server \
     .
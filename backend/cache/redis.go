@@ -152,6 +152,29 @@ func Expire(key string, expiration time.Duration) error {
 	return redisClient.Expire(ctx, key, expiration).Err()
 }
 
+// LPush 将元素推入列表头部
+func LPush(key string, values ...interface{}) error {
+	if !IsEnabled() {
+		return fmt.Errorf("redis not enabled")
+	}
+
+	return redisClient.LPush(ctx, key, values...).Err()
+}
+
+// BRPop 阻塞地从列表尾部取出元素，超时返回 redis.Nil
+func BRPop(timeout time.Duration, key string) (string, error) {
+	if !IsEnabled() {
+		return "", fmt.Errorf("redis not enabled")
+	}
+
+	result, err := redisClient.BRPop(ctx, timeout, key).Result()
+	if err != nil {
+		return "", err
+	}
+	// 返回值为 [key, value]
+	return result[1], nil
+}
+
 // Close 关闭Redis连接
 func Close() error {
 	if redisClient != nil {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/models"
+	"log"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// legacyAlbum 旧版相册的共享字段：共享给的用户ID列表，逗号分隔
+type legacyAlbum struct {
+	ID          uint
+	OwnerID     uint
+	SharedUsers string
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只输出将要迁移的数据，不写入数据库")
+	dropColumn := flag.Bool("drop-column", false, "迁移完成后删除 albums.shared_users 列")
+	flag.Parse()
+
+	// 使用与服务相同的配置连接数据库，会自动创建 album_members 表
+	config.LoadConfig()
+	if err := database.InitDatabase(); err != nil {
+		log.Fatal("连接数据库失败:", err)
+	}
+	db := database.GetDB()
+
+	if !db.Migrator().HasColumn(&models.Album{}, "shared_users") {
+		fmt.Println("albums 表没有 shared_users 列，无需迁移")
+		return
+	}
+
+	var albums []legacyAlbum
+	if err := db.Table("albums").Select("id, owner_id, shared_users").
+		Where("shared_users IS NOT NULL AND shared_users <> ''").Find(&albums).Error; err != nil {
+		log.Fatal("读取相册共享数据失败:", err)
+	}
+	fmt.Printf("找到 %d 个共享相册\n", len(albums))
+
+	created, skipped := 0, 0
+	for _, album := range albums {
+		for _, userID := range splitToUints(album.SharedUsers) {
+			// 所有者本身不需要成员记录
+			if userID == album.OwnerID {
+				skipped++
+				continue
+			}
+
+			var user models.User
+			if err := db.Select("id").First(&user, userID).Error; err != nil {
+				fmt.Printf("   - 相册 %d: 用户 %d 不存在，跳过\n", album.ID, userID)
+				skipped++
+				continue
+			}
+
+			var existing models.AlbumMember
+			err := db.Where("album_id = ? AND user_id = ?", album.ID, userID).First(&existing).Error
+			if err == nil {
+				skipped++
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Fatalf("查询相册 %d 的成员失败: %v", album.ID, err)
+			}
+
+			// 旧版共享只有查看权限，对应 viewer 角色
+			fmt.Printf("   ✓ 相册 %d: 添加成员 %d (viewer)\n", album.ID, userID)
+			created++
+			if *dryRun {
+				continue
+			}
+			member := models.AlbumMember{
+				AlbumID: album.ID,
+				UserID:  userID,
+				Role:    models.AlbumRoleViewer,
+				AddedBy: album.OwnerID,
+			}
+			if err := db.Create(&member).Error; err != nil {
+				log.Fatalf("创建相册 %d 的成员失败: %v", album.ID, err)
+			}
+		}
+	}
+
+	fmt.Printf("\n迁移完成: 新增 %d 个成员，跳过 %d 个\n", created, skipped)
+
+	if *dryRun {
+		fmt.Println("dry-run 模式，未写入数据库")
+		return
+	}
+
+	if *dropColumn {
+		// Album 模型已没有该字段，直接执行 SQL 删除
+		if err := db.Exec("ALTER TABLE albums DROP COLUMN shared_users").Error; err != nil {
+			log.Fatal("删除 shared_users 列失败:", err)
+		}
+		fmt.Println("✓ 已删除 albums.shared_users 列")
+	} else {
+		fmt.Println("shared_users 列已保留，确认无误后可使用 -drop-column 删除")
+	}
+}
+
+// splitToUints 将逗号分隔的字符串转换为uint数组，忽略无效的ID
+func splitToUints(s string) []uint {
+	parts := strings.Split(s, ",")
+	result := make([]uint, 0, len(parts))
+	seen := make(map[uint]bool, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || id == 0 || seen[uint(id)] {
+			continue
+		}
+		seen[uint(id)] = true
+		result = append(result, uint(id))
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"log"
+)
+
+func main() {
+	// 使用与服务相同的配置连接数据库，会自动创建 tags 和 image_tags 表
+	config.LoadConfig()
+	if err := database.InitDatabase(); err != nil {
+		log.Fatal("连接数据库失败:", err)
+	}
+
+	fmt.Println("开始将 images.tags 转换为标签表...")
+	migrated, err := database.MigrateImageTags(database.GetDB())
+	if err != nil {
+		log.Fatalf("转换标签失败（已处理 %d 张图片）: %v", migrated, err)
+	}
+	fmt.Printf("✅ 转换完成，共处理 %d 张图片\n", migrated)
+}
@@ -27,15 +27,55 @@ type Config struct {
 	ServerMode string // debug, release
 
 	// JWT 配置
-	JWTSecret     string
-	JWTExpiration time.Duration
+	JWTSecret              string
+	JWTExpiration          time.Duration // 访问令牌有效期
+	RefreshTokenExpiration time.Duration // 刷新令牌有效期
+	RefreshTokenCookie     bool          // 是否同时通过 HttpOnly Cookie 下发刷新令牌
 
 	// 用户管理配置
 	AllowRegistration bool // 是否允许用户注册
 
 	// 文件上传配置
-	UploadPath  string
-	MaxFileSize int64 // MB
+	UploadPath     string
+	MaxFileSize    int64 // MB
+	MaxImagePixels int64 // 上传图片的最大像素数（宽 x 高），防止解码时占用过多内存
+
+	// 分片上传配置
+	UploadChunkSize  int64         // 分片大小 MB
+	UploadSessionTTL time.Duration // 分片上传会话在最后一次收到分片后的保留时间
+
+	// 远程导入配置
+	ImportURLTimeout time.Duration // 下载单个远程图片的超时时间
+
+	// 用户默认配额（0 表示不限制）
+	DefaultStorageQuota int64 // MB
+	DefaultImageQuota   int64 // 张
+	DefaultUserFileSize int64 // 单文件上限 MB，0 表示使用 MaxFileSize
+
+	// 回收站配置
+	TrashRetentionDays int // 删除的图片和相册在回收站保留的天数，0 表示直接彻底删除
+
+	// 后台任务配置
+	JobWorkers     int           // 并发执行任务的 worker 数量
+	JobMaxAttempts int           // 任务最大尝试次数，超过后进入死信列表
+	JobTimeout     time.Duration // 单个任务的执行超时，超时未完成的任务会被重新调度
+
+	// 图片变换配置（/i/:uuid?w=&h=...）
+	TransformEnabled          bool   // 是否允许通过 URL 参数变换图片
+	TransformMaxWidth         int    // 输出最大宽度
+	TransformMaxHeight        int    // 输出最大高度
+	TransformMaxBlur          int    // 最大模糊半径
+	TransformMaxSourcePixels  int64  // 允许变换的原图最大像素数
+	TransformConcurrency      int    // 同时进行的变换数量
+	TransformRequireSignature bool   // 是否要求变换参数带签名
+	TransformSecret           string // 签名密钥，为空时使用 JWT_SECRET
+
+	// 动图处理配置（GIF/WebP 逐帧处理的上限，超过时缩略图使用第一帧）
+	AnimationMaxFrames int   // 最大帧数
+	AnimationMaxPixels int64 // 全部帧的像素总数（宽 x 高 x 帧数）
+
+	// 水印配置
+	WatermarkFontPath string // 文字水印使用的 TTF/OTF 字体，为空时使用内置字体（不包含中文字形）
 
 	// 存储配置
 	StorageType string // local, oss, cos, qiniu, s3, webdav, sftp
@@ -170,15 +210,55 @@ func LoadConfig() *Config {
 		ServerMode: getEnv("SERVER_MODE", "debug"),
 
 		// JWT 配置
-		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-		JWTExpiration: getEnvAsDuration("JWT_EXPIRATION", "24h"),
+		JWTSecret:              getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
+		JWTExpiration:          getEnvAsDuration("JWT_EXPIRATION", "15m"),
+		RefreshTokenExpiration: getEnvAsDuration("REFRESH_TOKEN_EXPIRATION", "720h"),
+		RefreshTokenCookie:     getEnvAsBool("REFRESH_TOKEN_COOKIE", true),
 
 		// 用户管理配置
 		AllowRegistration: getEnvAsBool("ALLOW_REGISTRATION", true),
 
 		// 文件上传配置
-		UploadPath:  uploadPath,
-		MaxFileSize: getEnvAsInt64("MAX_FILE_SIZE", 100),
+		UploadPath:     uploadPath,
+		MaxFileSize:    getEnvAsInt64("MAX_FILE_SIZE", 100),
+		MaxImagePixels: getEnvAsInt64("MAX_IMAGE_PIXELS", 100000000),
+
+		// 分片上传配置
+		UploadChunkSize:  getEnvAsInt64("UPLOAD_CHUNK_SIZE", 5),
+		UploadSessionTTL: getEnvAsDuration("UPLOAD_SESSION_TTL", "24h"),
+
+		// 远程导入配置
+		ImportURLTimeout: getEnvAsDuration("IMPORT_URL_TIMEOUT", "30s"),
+
+		// 用户默认配额
+		DefaultStorageQuota: getEnvAsInt64("DEFAULT_STORAGE_QUOTA", 0),
+		DefaultImageQuota:   getEnvAsInt64("DEFAULT_IMAGE_QUOTA", 0),
+		DefaultUserFileSize: getEnvAsInt64("DEFAULT_USER_FILE_SIZE", 0),
+
+		// 回收站配置
+		TrashRetentionDays: getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+
+		// 后台任务配置
+		JobWorkers:     getEnvAsInt("JOB_WORKERS", 4),
+		JobMaxAttempts: getEnvAsInt("JOB_MAX_ATTEMPTS", 3),
+		JobTimeout:     getEnvAsDuration("JOB_TIMEOUT", "10m"),
+
+		// 图片变换配置
+		TransformEnabled:          getEnvAsBool("TRANSFORM_ENABLED", true),
+		TransformMaxWidth:         getEnvAsInt("TRANSFORM_MAX_WIDTH", 4096),
+		TransformMaxHeight:        getEnvAsInt("TRANSFORM_MAX_HEIGHT", 4096),
+		TransformMaxBlur:          getEnvAsInt("TRANSFORM_MAX_BLUR", 50),
+		TransformMaxSourcePixels:  getEnvAsInt64("TRANSFORM_MAX_SOURCE_PIXELS", 50000000),
+		TransformConcurrency:      getEnvAsInt("TRANSFORM_CONCURRENCY", 4),
+		TransformRequireSignature: getEnvAsBool("TRANSFORM_REQUIRE_SIGNATURE", false),
+		TransformSecret:           getEnv("TRANSFORM_SECRET", ""),
+
+		// 动图处理配置
+		AnimationMaxFrames: getEnvAsInt("ANIMATION_MAX_FRAMES", 1000),
+		AnimationMaxPixels: getEnvAsInt64("ANIMATION_MAX_PIXELS", 50000000),
+
+		// 水印配置
+		WatermarkFontPath: getEnv("WATERMARK_FONT_PATH", ""),
 
 		// 存储配置
 		StorageType:      getEnv("STORAGE_TYPE", "local"),
@@ -252,7 +332,7 @@ func LoadConfig() *Config {
 		CORSEnabled:      getEnvAsBool("CORS_ENABLED", true),
 		CORSAllowOrigins: getEnvAsSlice("CORS_ALLOW_ORIGINS", "http://localhost:5173,http://localhost:5174,http://localhost:3000,http://127.0.0.1:5173,http://127.0.0.1:5174,http://127.0.0.1:3000"),
 		CORSAllowMethods: getEnvAsSlice("CORS_ALLOW_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS"),
-		CORSAllowHeaders: getEnvAsSlice("CORS_ALLOW_HEADERS", "Origin,Content-Type,Accept,Authorization"),
+		CORSAllowHeaders: getEnvAsSlice("CORS_ALLOW_HEADERS", "Origin,Content-Type,Accept,Authorization,X-Chunk-Checksum"),
 		CORSMaxAge:       getEnvAsInt("CORS_MAX_AGE", 12),
 
 		// 默认管理员账户配置
@@ -335,6 +415,24 @@ func (c *Config) Validate() error {
 		log.Printf("⚠️  警告: MAX_FILE_SIZE=%dMB 过大，可能导致内存问题", c.MaxFileSize)
 	}
 
+	if c.MaxImagePixels <= 0 {
+		log.Println("⚠️  警告: MAX_IMAGE_PIXELS 应该大于0，已设置为默认值100000000")
+		c.MaxImagePixels = 100000000
+	}
+
+	if c.UploadChunkSize <= 0 {
+		log.Println("⚠️  警告: UPLOAD_CHUNK_SIZE 应该大于0，已设置为默认值5MB")
+		c.UploadChunkSize = 5
+	}
+	if c.UploadSessionTTL <= 0 {
+		log.Println("⚠️  警告: UPLOAD_SESSION_TTL 应该大于0，已设置为默认值24h")
+		c.UploadSessionTTL = 24 * time.Hour
+	}
+	if c.ImportURLTimeout <= 0 {
+		log.Println("⚠️  警告: IMPORT_URL_TIMEOUT 应该大于0，已设置为默认值30s")
+		c.ImportURLTimeout = 30 * time.Second
+	}
+
 	return nil
 }
 
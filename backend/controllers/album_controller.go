@@ -3,10 +3,12 @@ package controllers
 import (
 	"imagebed/database"
 	"imagebed/models"
+	"imagebed/search"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetAlbums 获取所有相册
@@ -26,14 +28,22 @@ func GetAlbums(c *gin.Context) {
 
 	query := db.Preload("Owner")
 
-	// 如果不是管理员，只显示：1. 自己的相册 2. 公开的相册 3. 共享给自己的相册
-	if !isAdmin {
+	// shared=true 时只列出共享给自己的相册
+	if c.Query("shared") == "true" {
+		if !userExists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
+			return
+		}
+		query = query.Where("id IN (?)", db.Model(&models.AlbumMember{}).
+			Select("album_id").Where("user_id = ?", userID.(uint)))
+	} else if !isAdmin {
+		// 如果不是管理员，只显示：1. 自己的相册 2. 公开的相册 3. 共享给自己的相册
 		if userExists {
 			uid := userID.(uint)
 			query = query.Where(
 				db.Where("owner_id = ?", uid).
 					Or("(is_public = ? AND is_private = ?)", true, false).
-					Or("shared_users LIKE ?", "%"+strconv.Itoa(int(uid))+"%"),
+					Or("id IN (?)", db.Model(&models.AlbumMember{}).Select("album_id").Where("user_id = ?", uid)),
 			)
 		} else {
 			// 未登录用户只能看到公开相册
@@ -46,11 +56,27 @@ func GetAlbums(c *gin.Context) {
 		return
 	}
 
+	// 标记当前用户在共享相册中的角色
+	if userExists {
+		var memberships []models.AlbumMember
+		db.Where("user_id = ?", userID.(uint)).Find(&memberships)
+		roles := make(map[uint]string, len(memberships))
+		for _, m := range memberships {
+			roles[m.AlbumID] = m.Role
+		}
+		for i := range albums {
+			albums[i].MyRole = roles[albums[i].ID]
+		}
+	}
+
 	// 同步更新每个相册的图片数量
 	for i := range albums {
 		var count int64
 		db.Model(&models.Image{}).Where("album_id = ?", albums[i].ID).Count(&count)
 		albums[i].ImageCount = int(count)
+
+		db.Model(&models.AlbumMember{}).Where("album_id = ?", albums[i].ID).Count(&count)
+		albums[i].MemberCount = int(count)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": albums})
@@ -62,11 +88,20 @@ func GetAlbum(c *gin.Context) {
 	var album models.Album
 	db := database.GetDB()
 
-	if err := db.Preload("Images").First(&album, id).Error; err != nil {
+	if err := db.Preload("Images").Preload("Members").First(&album, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
 		return
 	}
 
+	userID, isAdmin := requestUser(c)
+	if !album.CanAccess(userID, isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此相册"})
+		return
+	}
+	if member := album.Member(userID); member != nil {
+		album.MyRole = member.Role
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": album})
 }
 
@@ -81,6 +116,8 @@ func CreateAlbum(c *gin.Context) {
 	// 设置所有者为当前用户
 	userID, _ := c.Get("userID")
 	album.OwnerID = userID.(uint)
+	// 成员通过成员接口添加
+	album.Members = nil
 
 	// 如果没有设置权限字段，使用默认值
 	if !c.Request.URL.Query().Has("isPrivate") {
@@ -92,6 +129,17 @@ func CreateAlbum(c *gin.Context) {
 	if !c.Request.URL.Query().Has("allowShare") {
 		album.AllowShare = true
 	}
+	if album.ProcessingProfileID != nil {
+		if err := validateAlbumProfile(*album.ProcessingProfileID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	_, isAdmin := requestUser(c)
+	if err := validateWatermarkSettings(album.Watermark, album.OwnerID, isAdmin); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	db := database.GetDB()
 	if err := db.Create(&album).Error; err != nil {
@@ -102,6 +150,40 @@ func CreateAlbum(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"data": album})
 }
 
+// albumManagedFields 只有相册所有者和管理员可以修改的字段
+var albumManagedFields = []string{
+	"ownerId", "owner_id", "OwnerID",
+	"isPrivate", "is_private", "IsPrivate",
+	"isPublic", "is_public", "IsPublic",
+	"allowShare", "allow_share", "AllowShare",
+	"stripMetadata", "strip_metadata", "StripMetadata",
+	"processingProfileId", "processing_profile_id", "ProcessingProfileID",
+	"watermark", "Watermark",
+}
+
+// albumUpdateColumns 将请求中的字段名（驼峰形式或列名）转换为相册表的列名
+// 主键、时间戳、关联字段和不存在的字段被忽略
+func albumUpdateColumns(db *gorm.DB, updateData map[string]interface{}) (map[string]interface{}, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&models.Album{}); err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]interface{}, len(updateData))
+	for key, value := range updateData {
+		field := stmt.Schema.LookUpField(key)
+		if field == nil {
+			field = stmt.Schema.LookUpField(db.NamingStrategy.ColumnName("", key))
+		}
+		if field == nil || field.DBName == "" || field.PrimaryKey || !field.Updatable ||
+			field.AutoCreateTime != 0 || field.AutoUpdateTime != 0 || field.DBName == "deleted_at" {
+			continue
+		}
+		columns[field.DBName] = value
+	}
+	return columns, nil
+}
+
 // UpdateAlbum 更新相册
 func UpdateAlbum(c *gin.Context) {
 	id := c.Param("id")
@@ -119,15 +201,64 @@ func UpdateAlbum(c *gin.Context) {
 		return
 	}
 
-	if err := db.Model(&album).Updates(updateData).Error; err != nil {
+	// 编辑者只能修改相册信息，所有权和可见性只有所有者可以修改
+	userID, isAdmin := requestUser(c)
+	oldName := album.Name
+	if !album.CanManage(userID, isAdmin) {
+		for _, key := range albumManagedFields {
+			delete(updateData, key)
+		}
+	}
+
+	columns, err := albumUpdateColumns(db, updateData)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
 		return
 	}
+	if profileID, ok := columns["processing_profile_id"]; ok {
+		if err := validateAlbumProfile(profileID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 水印设置是 JSON 列，校验后按结构单独更新
+	value, updateWatermark := columns["watermark"]
+	delete(columns, "watermark")
+	var watermark *models.WatermarkSettings
+	if updateWatermark {
+		if watermark, err = parseWatermarkSettings(value); err == nil {
+			err = validateWatermarkSettings(watermark, album.OwnerID, isAdmin)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if len(columns) > 0 {
+		if err := db.Model(&album).Updates(columns).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+			return
+		}
+	}
+	if updateWatermark {
+		if err := db.Model(&album).Select("watermark").Updates(&models.Album{Watermark: watermark}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+			return
+		}
+		album.Watermark = watermark
+	}
+
+	// 相册名称参与图片搜索
+	if album.Name != oldName {
+		search.IndexAlbum(album.ID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"data": album})
 }
 
-// DeleteAlbum 删除相册
+// DeleteAlbum 删除相册（连同图片移入回收站）
 func DeleteAlbum(c *gin.Context) {
 	id := c.Param("id")
 	idInt, _ := strconv.Atoi(id)
@@ -146,11 +277,12 @@ func DeleteAlbum(c *gin.Context) {
 		return
 	}
 
-	// 软删除相册
-	if err := db.Delete(&album).Error; err != nil {
+	// 相册连同其中的图片一起移入回收站
+	if err := trashAlbum(&album); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除相册失败"})
 		return
 	}
+	clearImageListCache(uint64(album.ID))
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"errors"
+	"imagebed/database"
+	"imagebed/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AddAlbumMemberRequest 添加相册成员请求，userId 和 username 二选一
+type AddAlbumMemberRequest struct {
+	UserID   uint   `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"` // viewer / contributor / editor，默认 viewer
+}
+
+// UpdateAlbumMemberRequest 修改成员角色请求
+type UpdateAlbumMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// contextAlbum 由相册权限中间件存入上下文的相册
+func contextAlbum(c *gin.Context) *models.Album {
+	album, _ := c.Get("album")
+	return album.(*models.Album)
+}
+
+// findAlbumMember 查找相册成员，路由参数 userId 为成员的用户ID
+func findAlbumMember(c *gin.Context, albumID uint) (*models.AlbumMember, bool) {
+	memberUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return nil, false
+	}
+
+	var member models.AlbumMember
+	if err := database.GetDB().Where("album_id = ? AND user_id = ?", albumID, memberUserID).
+		First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "成员不存在"})
+		return nil, false
+	}
+	return &member, true
+}
+
+// GetAlbumMembers 获取相册成员列表（相册所有者、管理员和成员可见）
+func GetAlbumMembers(c *gin.Context) {
+	album := contextAlbum(c)
+	userID, isAdmin := requestUser(c)
+	if !album.CanManage(userID, isAdmin) && album.Member(userID) == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限查看相册成员"})
+		return
+	}
+
+	var members []models.AlbumMember
+	if err := database.GetDB().Preload("User").
+		Where("album_id = ?", album.ID).Order("created_at ASC").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取相册成员失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// AddAlbumMember 添加相册成员，成员已存在时更新角色
+func AddAlbumMember(c *gin.Context) {
+	album := contextAlbum(c)
+
+	var req AddAlbumMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.AlbumRoleViewer
+	}
+	if !models.IsValidAlbumRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的成员角色"})
+		return
+	}
+
+	db := database.GetDB()
+	var user models.User
+	var err error
+	switch {
+	case req.UserID > 0:
+		err = db.First(&user, req.UserID).Error
+	case req.Username != "":
+		err = db.Where("username = ?", req.Username).First(&user).Error
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定用户"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if user.ID == album.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能将相册所有者添加为成员"})
+		return
+	}
+
+	userID, _ := requestUser(c)
+	status := http.StatusOK
+	var member models.AlbumMember
+	err = db.Where("album_id = ? AND user_id = ?", album.ID, user.ID).First(&member).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.AlbumMember{
+			AlbumID: album.ID,
+			UserID:  user.ID,
+			Role:    req.Role,
+			AddedBy: userID,
+		}
+		if err := db.Create(&member).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "添加相册成员失败"})
+			return
+		}
+		status = http.StatusCreated
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加相册成员失败"})
+		return
+	default:
+		if err := db.Model(&member).Update("role", req.Role).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "添加相册成员失败"})
+			return
+		}
+	}
+
+	member.User = &models.MemberUser{ID: user.ID, Username: user.Username, Avatar: user.Avatar}
+	clearImageListCache(uint64(album.ID))
+
+	c.JSON(status, gin.H{"data": member})
+}
+
+// UpdateAlbumMember 修改相册成员角色
+func UpdateAlbumMember(c *gin.Context) {
+	album := contextAlbum(c)
+
+	var req UpdateAlbumMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if !models.IsValidAlbumRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的成员角色"})
+		return
+	}
+
+	member, ok := findAlbumMember(c, album.ID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Model(member).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改成员角色失败"})
+		return
+	}
+	clearImageListCache(uint64(album.ID))
+
+	c.JSON(http.StatusOK, gin.H{"data": member})
+}
+
+// RemoveAlbumMember 移除相册成员，成员也可以自己退出相册
+func RemoveAlbumMember(c *gin.Context) {
+	album := contextAlbum(c)
+
+	member, ok := findAlbumMember(c, album.ID)
+	if !ok {
+		return
+	}
+
+	userID, isAdmin := requestUser(c)
+	if !album.CanManage(userID, isAdmin) && member.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限移除此成员"})
+		return
+	}
+
+	if err := database.GetDB().Delete(member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除成员失败"})
+		return
+	}
+	clearImageListCache(uint64(album.ID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "成员已移除"})
+}
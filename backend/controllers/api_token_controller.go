@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPITokenRequest 创建访问令牌请求
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays"` // 有效天数，0 表示永不过期
+}
+
+// UpdateAPITokenRequest 更新访问令牌请求
+type UpdateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"omitempty,max=100"`
+	Scopes []string `json:"scopes"`
+}
+
+// normalizeScopes 校验并去重权限范围
+func normalizeScopes(scopes []string) (string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !models.IsValidScope(scope) {
+			return "", fmt.Errorf("无效的权限范围: %s，可选值: %v", scope, models.ValidScopes)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return strings.Join(result, ","), nil
+}
+
+// GetAPITokens 获取当前用户的访问令牌列表
+func GetAPITokens(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var tokens []models.APIToken
+	if err := database.GetDB().Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// CreateAPIToken 创建访问令牌，明文令牌只在此时返回一次
+func CreateAPIToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效天数不能为负数"})
+		return
+	}
+
+	plain, hash, prefix, err := utils.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	token := models.APIToken{
+		UserID:    userID.(uint),
+		Name:      req.Name,
+		TokenHash: hash,
+		Prefix:    prefix,
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := database.GetDB().Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    token,
+		"token":   plain,
+		"message": "请妥善保存令牌，关闭后将无法再次查看",
+	})
+}
+
+// UpdateAPIToken 更新访问令牌的名称或权限
+func UpdateAPIToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id := c.Param("id")
+
+	var req UpdateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var token models.APIToken
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+
+	if req.Name != "" {
+		token.Name = req.Name
+	}
+	if req.Scopes != nil {
+		scopes, err := normalizeScopes(req.Scopes)
+		if err != nil || scopes == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限范围"})
+			return
+		}
+		token.Scopes = scopes
+	}
+
+	if err := db.Save(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": token})
+}
+
+// DeleteAPIToken 吊销访问令牌
+func DeleteAPIToken(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id := c.Param("id")
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销令牌失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销"})
+}
@@ -6,6 +6,7 @@ import (
 	"imagebed/models"
 	"imagebed/utils"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,8 +26,10 @@ type LoginRequest struct {
 
 // AuthResponse 认证响应
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`        // 访问令牌
+	RefreshToken string       `json:"refreshToken"` // 刷新令牌
+	ExpiresIn    int64        `json:"expiresIn"`    // 访问令牌有效期（秒）
+	User         *models.User `json:"user"`
 }
 
 // Register 用户注册
@@ -77,17 +80,15 @@ func Register(c *gin.Context) {
 	}
 
 	// 生成 token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Role)
+	resp, err := issueAuthTokens(c, &user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": AuthResponse{
-			Token: token,
-			User:  &user,
-		},
+		"data":    resp,
+		"message": "注册成功",
 	})
 }
 
@@ -114,24 +115,24 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if user.Status == "disabled" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "用户已被禁用"})
+		return
+	}
+
 	// 生成 token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Role)
+	resp, err := issueAuthTokens(c, &user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": AuthResponse{
-			Token: token,
-			User:  &user,
-		},
-	})
+	c.JSON(http.StatusOK, gin.H{"data": resp})
 }
 
 // GetCurrentUser 获取当前登录用户信息
 func GetCurrentUser(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+	userID, _ := c.Get("userID")
 
 	db := database.GetDB()
 	var user models.User
@@ -143,7 +144,28 @@ func GetCurrentUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": user})
 }
 
-// Logout 登出（客户端删除token即可）
+// Logout 登出，吊销当前使用的访问令牌和刷新令牌
+// 令牌无效或已过期时同样视为登出成功
 func Logout(c *gin.Context) {
+	if err := revokeRequestTokens(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败"})
+		return
+	}
+
+	setRefreshCookie(c, "", -1)
 	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
 }
+
+// bearerClaims 解析请求携带的 JWT，API 令牌和无效令牌返回 nil
+func bearerClaims(c *gin.Context) *utils.Claims {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" || utils.IsAPIToken(parts[1]) {
+		return nil
+	}
+
+	claims, err := utils.ParseToken(parts[1])
+	if err != nil {
+		return nil
+	}
+	return claims
+}
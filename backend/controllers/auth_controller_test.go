@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"imagebed/config"
 	"imagebed/database"
+	"imagebed/middleware"
 	"imagebed/models"
 	"net/http"
 	"net/http/httptest"
@@ -29,6 +30,8 @@ func cleanupTestDB() {
 	db.Exec("DELETE FROM images")
 	db.Exec("DELETE FROM albums")
 	db.Exec("DELETE FROM users")
+	db.Exec("DELETE FROM refresh_tokens")
+	db.Exec("DELETE FROM revoked_tokens")
 }
 
 // createTestUser 创建测试用户
@@ -146,7 +149,10 @@ func TestLogin(t *testing.T) {
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, response["token"])
+	data, ok := response["data"].(map[string]interface{})
+	assert.True(t, ok)
+	assert.NotEmpty(t, data["token"])
+	assert.NotEmpty(t, data["refreshToken"])
 }
 
 // TestLoginFailed 测试登录失败
@@ -171,3 +177,95 @@ func TestLoginFailed(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+// TestRefreshTokenRotation 测试刷新令牌轮换与重复使用检测
+func TestRefreshTokenRotation(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	user := createTestUser("refreshtest", "password123")
+	assert.NotNil(t, user)
+
+	r := gin.Default()
+	r.POST("/login", Login)
+	r.POST("/refresh", RefreshToken)
+
+	post := func(path string, body map[string]string) (int, map[string]interface{}) {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data, _ := response["data"].(map[string]interface{})
+		return w.Code, data
+	}
+
+	code, data := post("/login", map[string]string{"username": "refreshtest", "password": "password123"})
+	assert.Equal(t, http.StatusOK, code)
+	first, _ := data["refreshToken"].(string)
+	assert.NotEmpty(t, first)
+
+	// 正常刷新，返回新的刷新令牌
+	code, data = post("/refresh", map[string]string{"refreshToken": first})
+	assert.Equal(t, http.StatusOK, code)
+	second, _ := data["refreshToken"].(string)
+	assert.NotEmpty(t, data["token"])
+	assert.NotEqual(t, first, second)
+
+	// 重复使用旧令牌被拒绝，并吊销整组令牌
+	code, _ = post("/refresh", map[string]string{"refreshToken": first})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = post("/refresh", map[string]string{"refreshToken": second})
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+// TestChangePasswordKeepsNewToken 测试修改密码后旧令牌失效、返回的新令牌可用
+func TestChangePasswordKeepsNewToken(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	user := createTestUser("passwordtest", "password123")
+	assert.NotNil(t, user)
+
+	r := gin.Default()
+	r.POST("/login", Login)
+	r.POST("/password", middleware.AuthMiddleware(), ChangePassword)
+	r.GET("/me", middleware.AuthMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"userID": c.GetUint("userID")})
+	})
+
+	request := func(method, path, token string, body map[string]string) (int, map[string]interface{}) {
+		jsonData, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data, _ := response["data"].(map[string]interface{})
+		return w.Code, data
+	}
+
+	code, data := request("POST", "/login", "", map[string]string{"username": "passwordtest", "password": "password123"})
+	assert.Equal(t, http.StatusOK, code)
+	oldToken, _ := data["token"].(string)
+
+	code, data = request("POST", "/password", oldToken, map[string]string{"oldPassword": "password123", "newPassword": "newpassword123"})
+	assert.Equal(t, http.StatusOK, code)
+	newToken, _ := data["token"].(string)
+	assert.NotEmpty(t, newToken)
+
+	// 修改密码返回的令牌立即可用，之前的令牌已失效
+	code, _ = request("GET", "/me", newToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = request("GET", "/me", oldToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	apperrors "imagebed/errors"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/storage"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// directUploadExpiry 直传凭证的有效期，过期后需要重新申请
+const directUploadExpiry = 15 * time.Minute
+
+// directUploadView 直传记录和上传凭证
+type directUploadView struct {
+	*models.DirectUpload
+	Upload *storage.PresignedUpload `json:"upload"`
+}
+
+// loadDirectUpload 加载当前用户未过期的直传记录，不存在时输出错误响应
+func loadDirectUpload(c *gin.Context) (*models.DirectUpload, bool) {
+	userID, _ := requestUser(c)
+
+	var upload models.DirectUpload
+	if err := database.GetDB().Where("id = ? AND user_id = ? AND expires_at > ?", c.Param("id"), userID, time.Now()).
+		First(&upload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "直传记录不存在或已过期"})
+		return nil, false
+	}
+	return &upload, true
+}
+
+// deleteDirectUpload 删除直传记录和暂存的文件
+func deleteDirectUpload(upload *models.DirectUpload) {
+	if err := storage.GetStorage().Delete(upload.ObjectPath); err != nil {
+		logger.Warn("删除直传文件失败", zap.String("path", upload.ObjectPath), zap.Error(err))
+	}
+	database.GetDB().Delete(&models.DirectUpload{}, "id = ?", upload.ID)
+}
+
+// readDirectUpload 读取客户端上传到暂存路径的文件，校验大小、校验值和图片内容
+// 不符合要求的文件直接删除，凭证未过期时可以重新上传；返回的状态码用于输出错误响应，
+// 图片内容校验不通过时返回 *apperrors.AppError
+func readDirectUpload(upload *models.DirectUpload) ([]byte, int, error) {
+	store := storage.GetStorage()
+	exists, err := store.Exists(upload.ObjectPath)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("检查上传的文件失败")
+	}
+	if !exists {
+		return nil, http.StatusBadRequest, errors.New("文件尚未上传到存储")
+	}
+
+	reader, err := store.Get(upload.ObjectPath)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("读取上传的文件失败")
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, upload.FileSize+1))
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("读取上传的文件失败")
+	}
+
+	switch {
+	case int64(len(data)) != upload.FileSize:
+		err = fmt.Errorf("文件大小应为 %d 字节，请重新上传", upload.FileSize)
+	case upload.Checksum != "" && contentHash(data) != upload.Checksum:
+		err = errors.New("文件校验失败，上传的内容与申请时不一致，请重新上传")
+	default:
+		_, err = validateUploadContent(strings.ToLower(path.Ext(upload.FileName)), data)
+	}
+	if err != nil {
+		if err := store.Delete(upload.ObjectPath); err != nil {
+			logger.Warn("删除直传文件失败", zap.String("path", upload.ObjectPath), zap.Error(err))
+		}
+		return nil, http.StatusBadRequest, err
+	}
+	return data, http.StatusOK, nil
+}
+
+// CreateDirectUpload 申请直传存储的上传凭证，客户端按凭证将文件直接上传到云存储
+// 文件大小和 Content-Type 由存储服务按凭证校验，上传完成后调用确认接口创建图片
+func CreateDirectUpload(c *gin.Context) {
+	uploader, ok := storage.GetPresignedUploader()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前存储不支持直传，请使用普通上传或分片上传"})
+		return
+	}
+
+	var req InitUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, ok := validateUploadRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var active int64
+	db.Model(&models.DirectUpload{}).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Count(&active)
+	if active >= maxUploadSessionsPerUser {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("最多同时进行 %d 个直传，请先完成或取消其他上传", maxUploadSessionsPerUser),
+		})
+		return
+	}
+
+	// 上传类型按扩展名确定，不使用客户端提供的类型
+	ext := strings.ToLower(path.Ext(req.FileName))
+	id := uuid.New().String()
+	upload := models.DirectUpload{
+		ID:          id,
+		UserID:      userID,
+		AlbumID:     req.AlbumID,
+		FileName:    req.FileName,
+		ContentType: formatMimeType(strings.TrimPrefix(ext, ".")),
+		FileSize:    req.FileSize,
+		Checksum:    req.Checksum,
+		ObjectPath:  path.Join("direct", id+ext),
+		ExpiresAt:   time.Now().Add(config.GetConfig().UploadSessionTTL),
+	}
+
+	credential, err := uploader.PresignUpload(upload.ObjectPath, upload.ContentType, upload.FileSize, directUploadExpiry)
+	if err != nil {
+		logger.Error("生成直传凭证失败", zap.String("path", upload.ObjectPath), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成上传凭证失败"})
+		return
+	}
+	if err := db.Create(&upload).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建直传记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": directUploadView{DirectUpload: &upload, Upload: credential}})
+}
+
+// CompleteDirectUpload 确认直传完成，校验存储中的文件后按普通上传流程创建图片记录
+// 支持与普通上传相同的 onDuplicate、enableShortLink 参数；文件尚未上传或创建失败时保留记录，可以重试
+func CompleteDirectUpload(c *gin.Context) {
+	upload, ok := loadDirectUpload(c)
+	if !ok {
+		return
+	}
+
+	album, ok := loadUploadAlbum(c, upload.AlbumID)
+	if !ok {
+		return
+	}
+
+	// 删除直传记录占用本次确认，避免重复确认时创建多条图片记录
+	db := database.GetDB()
+	if db.Delete(&models.DirectUpload{}, "id = ?", upload.ID).RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "直传记录不存在或已过期"})
+		return
+	}
+
+	data, status, err := readDirectUpload(upload)
+	if err != nil {
+		db.Create(upload)
+		if _, ok := err.(*apperrors.AppError); ok {
+			respondUploadError(c, err)
+		} else {
+			c.JSON(status, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	saveUploadedImage(c, album, upload.UserID, upload.FileName, data)
+	if c.Writer.Status() >= http.StatusBadRequest {
+		// 配额不足等原因创建失败，恢复记录以便重试
+		db.Create(upload)
+		return
+	}
+	deleteDirectUpload(upload)
+}
+
+// AbortDirectUpload 取消直传，删除已上传的暂存文件
+func AbortDirectUpload(c *gin.Context) {
+	upload, ok := loadDirectUpload(c)
+	if !ok {
+		return
+	}
+
+	deleteDirectUpload(upload)
+	c.JSON(http.StatusOK, gin.H{"message": "已取消上传"})
+}
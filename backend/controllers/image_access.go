@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"imagebed/database"
+	"imagebed/middleware"
+	"imagebed/models"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// deliveryURLTTL 返回给前端的私有图片地址的有效期
+const deliveryURLTTL = time.Hour
+
+// deliveryAccess 输出图片时当前请求的权限
+type deliveryAccess struct {
+	view     bool
+	download bool
+}
+
+// requestUser 当前请求的用户，未登录时 userID 为 0
+func requestUser(c *gin.Context) (uint, bool) {
+	var userID uint
+	if v, exists := c.Get("userID"); exists {
+		userID, _ = v.(uint)
+	}
+	isAdmin, _ := c.Get("isAdmin")
+	return userID, isAdmin == true
+}
+
+// userDeliveryAccess 按登录用户判断权限：公开图片、所有者、管理员或共享相册的成员
+func userDeliveryAccess(c *gin.Context, imageRecord *models.Image) deliveryAccess {
+	userID, isAdmin := requestUser(c)
+	access := deliveryAccess{
+		view:     imageRecord.CanAccess(userID, isAdmin),
+		download: imageRecord.CanDownload(userID, isAdmin),
+	}
+	if access.view || userID == 0 {
+		return access
+	}
+
+	var member models.AlbumMember
+	if err := database.GetDB().Where("album_id = ? AND user_id = ?", imageRecord.AlbumID, userID).
+		First(&member).Error; err == nil {
+		access = deliveryAccess{view: true, download: imageRecord.AllowDownload}
+	}
+	return access
+}
+
+// visibleImages 将图片查询限制为当前用户可以查看的图片：
+// 管理员可以查看全部；登录用户可以查看自己的图片、公开图片和共享相册中的图片；未登录用户只能查看公开图片
+func visibleImages(db, query *gorm.DB, c *gin.Context) *gorm.DB {
+	userID, isAdmin := requestUser(c)
+	if isAdmin {
+		return query
+	}
+	if userID == 0 {
+		return query.Where("images.is_public = ? AND images.is_private = ?", true, false)
+	}
+	return query.Where(
+		db.Where("images.owner_id = ?", userID).
+			Or("(images.is_public = ? AND images.is_private = ?)", true, false).
+			Or("images.album_id IN (?)", db.Model(&models.AlbumMember{}).Select("album_id").Where("user_id = ?", userID)),
+	)
+}
+
+// resolveDeliveryAccess 判断当前请求能否查看、下载图片
+// 除登录用户的权限外，有效的签名URL也可以查看私有图片
+func resolveDeliveryAccess(c *gin.Context, imageRecord *models.Image) deliveryAccess {
+	access := userDeliveryAccess(c, imageRecord)
+	if access.view {
+		return access
+	}
+
+	if middleware.VerifySignedToken(imageRecord.UUID, c.Query("token"), c.Query("expires")) == nil {
+		access = deliveryAccess{view: true, download: imageRecord.AllowDownload}
+	}
+	return access
+}
+
+// wantsDownload 请求是否以附件形式下载
+func wantsDownload(c *gin.Context) bool {
+	switch c.Query("download") {
+	case "1", "true":
+		return true
+	}
+	return false
+}
+
+// authorizeDelivery 检查输出图片的权限，无权限时输出错误响应并返回 false
+func authorizeDelivery(c *gin.Context, imageRecord *models.Image) bool {
+	access := resolveDeliveryAccess(c, imageRecord)
+	if !access.view {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此图片"})
+		return false
+	}
+	if wantsDownload(c) && !access.download {
+		c.JSON(http.StatusForbidden, gin.H{"error": "此图片不允许下载"})
+		return false
+	}
+	return true
+}
+
+// imageCacheControl 公开图片允许 CDN 长期缓存，其他图片只允许浏览器缓存且每次都需要验证权限
+func imageCacheControl(imageRecord *models.Image) string {
+	if imageRecord.IsPublic && !imageRecord.IsPrivate {
+		return "public, max-age=31536000"
+	}
+	return "private, no-cache"
+}
+
+// setContentDisposition 区分查看（inline）和下载（attachment）
+// ext 为实际输出的扩展名，与原文件不同时替换文件名的扩展名
+func setContentDisposition(c *gin.Context, imageRecord *models.Image, ext string) {
+	disposition := "inline"
+	if wantsDownload(c) {
+		disposition = "attachment"
+	}
+
+	filename := imageRecord.OriginalName
+	if filename == "" {
+		filename = imageRecord.FileName
+	}
+	if ext != "" && !strings.EqualFold(path.Ext(filename), ext) {
+		filename = strings.TrimSuffix(filename, path.Ext(filename)) + ext
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+}
+
+// imageDeliveryURL 返回给前端的图片地址，非公开图片使用带签名的地址
+// 能修改图片的用户获取无水印的签名地址，其他用户的签名地址仍然叠加水印
+// 调用方需要确认当前用户有权查看该图片
+func imageDeliveryURL(c *gin.Context, imageRecord *models.Image) string {
+	if imageRecord.IsPublic && !imageRecord.IsPrivate {
+		return buildImageURL(generateImageURL(imageRecord.UUID))
+	}
+	userID, isAdmin := requestUser(c)
+	if imageRecord.CanModify(userID, isAdmin) {
+		return buildImageURL(middleware.GenerateCleanSignedURL(imageRecord.UUID, deliveryURLTTL))
+	}
+	return buildImageURL(middleware.GenerateSignedURL(imageRecord.UUID, deliveryURLTTL))
+}
@@ -2,25 +2,30 @@ package controllers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"image"
 	_ "image/gif"
 	"image/jpeg"
 	_ "image/png"
 	"imagebed/cache"
 	"imagebed/config"
 	"imagebed/database"
+	apperrors "imagebed/errors"
+	"imagebed/jobs"
 	"imagebed/logger"
 	"imagebed/middleware"
 	"imagebed/models"
+	"imagebed/search"
+	"imagebed/storage"
 	"imagebed/utils"
-	"imagebed/utils/imageprocessor"
+	"imagebed/utils/animation"
+	"imagebed/utils/metadata"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
@@ -78,13 +83,13 @@ func UploadImage(c *gin.Context) {
 	// 检查相册是否存在并验证权限
 	db := database.GetDB()
 	var album models.Album
-	if err := db.First(&album, albumID).Error; err != nil {
+	if err := db.Preload("Members").First(&album, albumID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
 		return
 	}
 
-	// 检查是否有权限上传到此相册
-	if !album.CanModify(userID.(uint), isAdmin) {
+	// 检查是否有权限上传到此相册（所有者、编辑者和贡献者）
+	if !album.CanUpload(userID.(uint), isAdmin) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权限上传到此相册"})
 		return
 	}
@@ -104,99 +109,179 @@ func UploadImage(c *gin.Context) {
 		return
 	}
 
-	// 检查文件类型
+	// 检查文件类型，扩展名只用于快速拒绝，实际格式在保存前按内容校验
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	if !utils.IsSupportedFormat(ext) {
-		supported, _ := utils.GetFormatList()
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("不支持的格式，支持的格式: %v", supported),
-		})
+		respondUploadError(c, unsupportedFormatError(ext))
 		return
 	}
 
-	// 生成唯一文件名和UUID
-	imageUUID := uuid.New().String()
-	newFileName := imageUUID + ext
+	data, err := readUploadedFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
 
-	// 创建相册目录
-	albumPath := filepath.Join(cfg.UploadPath, fmt.Sprintf("album_%d", albumID))
-	os.MkdirAll(albumPath, 0755)
+	saveUploadedImage(c, &album, userID.(uint), file.Filename, data)
+}
 
-	// 保存文件
-	filePath := filepath.Join(albumPath, newFileName)
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
-		return
+// unsupportedFormatError 扩展名不在支持列表中时返回的 ErrInvalidFormat 错误
+func unsupportedFormatError(ext string) error {
+	supported, _ := utils.GetFormatList()
+	if ext == "" {
+		return apperrors.New(apperrors.ErrInvalidFormat, fmt.Sprintf("文件没有扩展名，支持的格式: %v", supported))
 	}
+	return apperrors.New(apperrors.ErrInvalidFormat, fmt.Sprintf("扩展名 %s 不在支持列表中，支持的格式: %v", ext, supported))
+}
+
+// validateUploadContent 按文件内容校验上传的图片：格式与扩展名一致、图片头可以解析、像素数不超过上限、
+// 没有夹带脚本或其他格式的内容；不通过时返回 ErrInvalidFormat 错误
+func validateUploadContent(ext string, data []byte) (*utils.ImageContent, error) {
+	content, err := utils.ValidateImageContent(data, ext, config.GetConfig().MaxImagePixels)
+	if err != nil {
+		return nil, apperrors.New(apperrors.ErrInvalidFormat, err.Error())
+	}
+	return content, nil
+}
 
-	// 异步处理图片：压缩 + 生成缩略图 + WebP转换
-	go func(path string) {
-		processor := imageprocessor.NewImageProcessor(85)
-		if err := processor.ProcessImage(path); err != nil {
-			fmt.Printf("图片处理失败: %v\n", err)
+// uploadOutcome 保存上传内容的结果
+type uploadOutcome struct {
+	image    *models.Image // 新建的图片记录，跳过重复内容时为 nil
+	existing *models.Image // 已上传过相同内容时的已有图片
+	job      *models.Job   // 新文件的后台处理任务
+}
+
+// saveUploadedImage 按相册的处理配置保存上传的图片内容，创建图片记录并输出响应
+func saveUploadedImage(c *gin.Context, album *models.Album, userID uint, originalName string, data []byte) {
+	outcome, err := createUploadedImage(c, album, userID, originalName, data)
+	if err != nil {
+		if _, ok := err.(*apperrors.AppError); ok {
+			respondUploadError(c, err)
 		} else {
-			fmt.Printf("图片处理成功: %s\n", path)
-		}
-	}(filePath)
-
-	// 获取图片尺寸
-	width, height := getImageDimensions(filePath)
-
-	// 生成缩略图
-	thumbnailPath := ""
-	if !utils.IsAnimatedFormat(ext) { // 动态图片不生成缩略图
-		thumbDir := filepath.Join(cfg.UploadPath, "thumbnails", fmt.Sprintf("album_%d", albumID))
-		os.MkdirAll(thumbDir, 0755)
-		thumbnailPath = filepath.Join(thumbDir, "thumb_"+newFileName)
-
-		// 生成缩略图（宽度300px）
-		if err := utils.GenerateThumbnail(filePath, thumbnailPath, 300); err != nil {
-			// 缩略图生成失败不影响主流程
-			fmt.Printf("缩略图生成失败: %v\n", err)
-			thumbnailPath = ""
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
+		return
+	}
+
+	if outcome.image == nil {
+		outcome.existing.URL = imageDeliveryURL(c, outcome.existing)
+		c.JSON(http.StatusOK, gin.H{"data": outcome.existing, "duplicate": true, "existingUuid": outcome.existing.UUID})
+		return
+	}
+
+	response := gin.H{"data": outcome.image, "duplicate": outcome.existing != nil}
+	if outcome.existing != nil {
+		response["existingUuid"] = outcome.existing.UUID
+	}
+	if outcome.job != nil {
+		response["jobId"] = outcome.job.ID
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// createUploadedImage 按相册的处理配置保存上传的图片内容，创建图片记录并按需生成短链
+// 普通上传、分片上传、直传和远程导入都经过此流程
+// 内容校验不通过或配额不足时返回 *apperrors.AppError，其他错误的内容可以直接作为响应
+func createUploadedImage(c *gin.Context, album *models.Album, userID uint, originalName string, data []byte) (*uploadOutcome, error) {
+	outcome, err := storeUploadedImage(c, album, userID, originalName, data)
+	if err != nil || outcome.image == nil {
+		return outcome, err
+	}
+
+	imageRecord := outcome.image
+	if uploadShortLinkEnabled(c, album) {
+		createImageShortLink(imageRecord)
+	}
+
+	// 返回前将相对路径转换为完整URL
+	imageRecord.URL = imageDeliveryURL(c, imageRecord)
+	return outcome, nil
+}
+
+// storeUploadedImage 校验上传的图片内容并创建图片记录，不生成短链
+// 依次进行：内容校验、处理配置、去重、配额、写入存储、保存记录、后台处理、元数据和搜索索引
+// 返回的图片记录中 URL 为相对路径；批量上传逐个调用后统一生成短链
+func storeUploadedImage(c *gin.Context, album *models.Album, userID uint, originalName string, data []byte) (*uploadOutcome, error) {
+	db := database.GetDB()
+	ext := strings.ToLower(filepath.Ext(originalName))
+
+	// 按内容校验图片，MIME 类型取识别出的格式，不使用客户端提供的类型
+	content, err := validateUploadContent(ext, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按相册的处理配置缩小原图或删除元数据，去重按处理后的内容判断，元数据从处理前的内容中解析
+	profile := albumProcessingProfile(album)
+	data = applyUploadProfile(profile, ext, content.Data)
+
+	// 检查是否已上传过相同内容
+	hash := contentHash(data)
+	existing := findDuplicateImage(hash, userID)
+	if existing != nil && duplicateMode(c) == "skip" {
+		return &uploadOutcome{existing: existing}, nil
+	}
+
+	// 检查并占用用户配额
+	fileSize := int64(len(data))
+	if err := reserveQuota(userID, fileSize, fileSize, 1); err != nil {
+		return nil, err
+	}
+
+	// 写入存储后端（原图 + 缩略图），相同内容共享同一份文件
+	blob, created, err := acquireBlob(hash, ext, data)
+	if err != nil {
+		releaseQuota(userID, fileSize, 1)
+		return nil, errors.New("保存文件失败")
 	}
 
 	// 保存到数据库
+	imageUUID := uuid.New().String()
 	imageRecord := models.Image{
 		UUID:          imageUUID,
-		AlbumID:       uint(albumID),
-		FileName:      newFileName,
-		OriginalName:  file.Filename,
-		FilePath:      filePath,
-		FileSize:      file.Size,
-		MimeType:      file.Header.Get("Content-Type"),
-		Width:         width,
-		Height:        height,
-		Thumbnail:     thumbnailPath,
-		OwnerID:       userID.(uint),   // 设置所有者
+		AlbumID:       album.ID,
+		FileName:      imageUUID + ext,
+		OriginalName:  originalName,
+		MimeType:      content.MimeType,
+		OwnerID:       userID,          // 设置所有者
 		IsPrivate:     album.IsPrivate, // 继承相册的私有性
 		IsPublic:      album.IsPublic,  // 继承相册的公开性
 		AllowDownload: true,            // 默认允许下载
 	}
+	applyBlob(&imageRecord, blob)
 
 	if err := db.Create(&imageRecord).Error; err != nil {
-		os.Remove(filePath) // 删除已上传的文件
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存记录失败"})
-		return
+		releaseBlob(blob.Hash) // 释放已上传的文件
+		releaseQuota(userID, fileSize, 1)
+		return nil, errors.New("保存记录失败")
+	}
+
+	// 后台处理图片：压缩 + 生成缩略图 + WebP转换
+	var processJob *models.Job
+	if created {
+		processJob = enqueueProcessImage(blob.FilePath, profile.ID, userID)
 	}
 
 	// 更新相册图片数量和封面
-	db.Model(&album).Update("image_count", gorm.Expr("image_count + ?", 1))
+	db.Model(album).Update("image_count", gorm.Expr("image_count + ?", 1))
 	if album.CoverImage == "" {
-		db.Model(&album).Update("cover_image", generateImageURL(imageRecord.UUID))
+		db.Model(album).Update("cover_image", generateImageURL(imageRecord.UUID))
 	}
 
 	// 清除缓存，确保上传后立即可见
-	clearImageListCache(uint64(albumID))
+	clearImageListCache(uint64(album.ID))
+	imageRecord.Metadata = saveImageMetadata(imageRecord.ID, content.Data)
+	search.IndexImages(imageRecord.ID)
 
 	// 构造返回的URL（数据库存相对路径）
 	imageRecord.URL = generateImageURL(imageRecord.UUID)
 
-	// 检查是否需要生成短链
-	// 优先级：请求参数 > 相册配置
-	generateShortLink := false
-	// enableShortLink 可以来自 Query 参数或 Form 参数
+	return &uploadOutcome{image: &imageRecord, existing: existing, job: processJob}, nil
+}
+
+// uploadShortLinkEnabled 上传时是否生成短链
+// 优先级：请求参数 > 相册配置，enableShortLink 可以来自 Query 参数或 Form 参数
+func uploadShortLinkEnabled(c *gin.Context, album *models.Album) bool {
 	enableShortLinkStr := c.Query("enableShortLink")
 	if enableShortLinkStr == "" {
 		enableShortLinkStr = c.PostForm("enableShortLink")
@@ -204,52 +289,48 @@ func UploadImage(c *gin.Context) {
 
 	if enableShortLinkStr != "" {
 		// 如果请求中明确指定了是否生成短链，使用请求参数
-		generateShortLink = enableShortLinkStr == "true" || enableShortLinkStr == "1"
+		generateShortLink := enableShortLinkStr == "true" || enableShortLinkStr == "1"
 		logger.Info("从请求参数读取短链配置", zap.String("enableShortLink", enableShortLinkStr), zap.Bool("result", generateShortLink))
-	} else {
-		// 否则使用相册的配置
-		generateShortLink = album.EnableShortLink
-		logger.Info("从相册配置读取短链配置", zap.Bool("album.EnableShortLink", album.EnableShortLink), zap.Bool("result", generateShortLink))
+		return generateShortLink
 	}
 
-	logger.Info("短链接生成决策", zap.Bool("generateShortLink", generateShortLink))
-
-	// 生成短链
-	if generateShortLink {
-		logger.Info("开始生成短链接", zap.String("image_path", imageRecord.URL))
-		shortLinkClient := utils.NewShortLinkClient(cfg.ShortLinkBaseURL, cfg.ShortLinkAPIKey)
-
-		// 使用CDN路径而不是完整URL，让短链服务根据GeoIP分流
-		imagePath := imageRecord.URL // 例如: /uploads/xxx.jpg
-
-		shortLinkReq := &utils.ShortLinkRequest{
-			ImagePath: imagePath, // 只传路径，短链服务会根据访问者IP自动选择CDN
-			Metadata: map[string]interface{}{
-				"image_id":      imageRecord.ID,
-				"album_id":      imageRecord.AlbumID,
-				"original_name": imageRecord.OriginalName,
-				"file_size":     imageRecord.FileSize,
-			},
-		}
+	// 否则使用相册的配置
+	logger.Info("从相册配置读取短链配置", zap.Bool("album.EnableShortLink", album.EnableShortLink), zap.Bool("result", album.EnableShortLink))
+	return album.EnableShortLink
+}
 
-		if shortLink, err := shortLinkClient.CreateShortLink(shortLinkReq); err == nil {
-			logger.Info("短链接生成成功", zap.String("code", shortLink.Code), zap.String("url", shortLink.ShortURL))
-			// 保存短链信息到数据库
-			imageRecord.ShortLinkCode = shortLink.Code
-			db.Model(&imageRecord).Updates(map[string]interface{}{
-				"short_link_code": shortLink.Code,
-			})
-			// 设置完整短链URL用于返回
-			imageRecord.ShortLinkURL = shortLink.ShortURL
-		} else {
-			logger.Error("生成短链失败", zap.Error(err), zap.String("base_url", cfg.ShortLinkBaseURL))
-		}
+// createImageShortLink 为新上传的图片生成短链，失败时只记录日志
+func createImageShortLink(imageRecord *models.Image) {
+	logger.Info("开始生成短链接", zap.String("image_path", imageRecord.URL))
+	shortLinkClient := utils.NewShortLinkClient(cfg.ShortLinkBaseURL, cfg.ShortLinkAPIKey)
+
+	// 使用CDN路径而不是完整URL，让短链服务根据GeoIP分流
+	imagePath := imageRecord.URL // 例如: /uploads/xxx.jpg
+
+	shortLinkReq := &utils.ShortLinkRequest{
+		ImagePath: imagePath, // 只传路径，短链服务会根据访问者IP自动选择CDN
+		Metadata: map[string]interface{}{
+			"image_id":      imageRecord.ID,
+			"album_id":      imageRecord.AlbumID,
+			"original_name": imageRecord.OriginalName,
+			"file_size":     imageRecord.FileSize,
+		},
 	}
 
-	// 返回前将相对路径转换为完整URL
-	imageRecord.URL = buildImageURL(imageRecord.URL)
+	shortLink, err := shortLinkClient.CreateShortLink(shortLinkReq)
+	if err != nil {
+		logger.Error("生成短链失败", zap.Error(err), zap.String("base_url", cfg.ShortLinkBaseURL))
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": imageRecord})
+	logger.Info("短链接生成成功", zap.String("code", shortLink.Code), zap.String("url", shortLink.ShortURL))
+	// 保存短链信息到数据库
+	imageRecord.ShortLinkCode = shortLink.Code
+	database.GetDB().Model(imageRecord).Updates(map[string]interface{}{
+		"short_link_code": shortLink.Code,
+	})
+	// 设置完整短链URL用于返回
+	imageRecord.ShortLinkURL = shortLink.ShortURL
 }
 
 // GetImages 获取图片列表
@@ -263,16 +344,6 @@ func GetImages(c *gin.Context) {
 	pageSizeStr := c.Query("pageSize")
 	db := database.GetDB()
 
-	// 获取用户信息
-	userID, userExists := c.Get("userID")
-	isAdminVal, _ := c.Get("isAdmin")
-
-	// 安全地获取 isAdmin 值
-	isAdmin := false
-	if isAdminVal != nil {
-		isAdmin = isAdminVal.(bool)
-	}
-
 	var images []models.Image
 	query := db.Model(&models.Image{}).Preload("Owner")
 
@@ -281,18 +352,7 @@ func GetImages(c *gin.Context) {
 	}
 
 	// 权限过滤：只显示有权限访问的图片
-	if !isAdmin {
-		if userExists {
-			uid := userID.(uint)
-			query = query.Where(
-				db.Where("owner_id = ?", uid).
-					Or("(is_public = ? AND is_private = ?)", true, false),
-			)
-		} else {
-			// 未登录用户只能看到公开图片
-			query = query.Where("is_public = ? AND is_private = ?", true, false)
-		}
-	}
+	query = visibleImages(db, query, c)
 
 	// 添加搜索功能
 	if keyword != "" {
@@ -370,7 +430,7 @@ func GetImages(c *gin.Context) {
 			images[i].ShortLinkURL = fmt.Sprintf("%s/%s", shortLinkHost, images[i].ShortLinkCode)
 		}
 		// 返回前将相对路径转换为完整URL
-		images[i].URL = buildImageURL(images[i].URL)
+		images[i].URL = imageDeliveryURL(c, &images[i])
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": images, "total": total, "page": page, "pageSize": pageSize})
@@ -387,6 +447,11 @@ func GetImage(c *gin.Context) {
 		return
 	}
 
+	if !userDeliveryAccess(c, &imageRecord).view {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此图片"})
+		return
+	}
+
 	imageRecord.URL = generateImageURL(imageRecord.UUID)
 	// 如果有短链代码，构造完整的短链URL
 	if imageRecord.ShortLinkCode != "" {
@@ -398,7 +463,7 @@ func GetImage(c *gin.Context) {
 		imageRecord.ShortLinkURL = fmt.Sprintf("%s/%s", shortLinkHost, imageRecord.ShortLinkCode)
 	}
 	// 返回前将相对路径转换为完整URL
-	imageRecord.URL = buildImageURL(imageRecord.URL)
+	imageRecord.URL = imageDeliveryURL(c, &imageRecord)
 
 	c.JSON(http.StatusOK, gin.H{"data": imageRecord})
 }
@@ -414,15 +479,22 @@ func GetImageFile(c *gin.Context) {
 		return
 	}
 
-	if _, err := os.Stat(imageRecord.FilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+	if !authorizeDelivery(c, &imageRecord) {
+		return
+	}
+	mark, ok := deliveryWatermark(c, &imageRecord)
+	if !ok {
 		return
 	}
 
-	c.File(imageRecord.FilePath)
+	c.Header("Cache-Control", deliveryCacheControl(c, &imageRecord))
+	serveOriginalImage(c, &imageRecord, mark)
 }
 
 // ServeImage 优雅的图片访问路径 /i/:uuid
+// 支持变换参数：w、h、fit(cover/contain/fill)、gravity、format(webp/jpeg/png)、q、blur、rotate
+// 私有图片需要登录或签名URL（token、expires）；download=1 时以附件形式下载
+// 相册或处理配置设置了水印时，所有者、管理员和签名URL以外的访问输出叠加水印的图片
 func ServeImage(c *gin.Context) {
 	imageUUID := c.Param("uuid")
 
@@ -434,14 +506,35 @@ func ServeImage(c *gin.Context) {
 		return
 	}
 
-	if _, err := os.Stat(imageRecord.FilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+	if !authorizeDelivery(c, &imageRecord) {
+		return
+	}
+
+	// 带变换参数时输出变换后的图片
+	opts, ok := parseImageTransform(c, &imageRecord)
+	if !ok {
+		return
+	}
+
+	mark, ok := deliveryWatermark(c, &imageRecord)
+	if !ok {
 		return
 	}
 
-	// 设置缓存头
-	c.Header("Cache-Control", "public, max-age=31536000")
-	c.File(imageRecord.FilePath)
+	if opts != nil {
+		serveTransformedImage(c, &imageRecord, opts, mark)
+		return
+	}
+
+	// 设置缓存头，输出内容随 Accept 头变化
+	c.Header("Cache-Control", deliveryCacheControl(c, &imageRecord))
+	c.Header("Vary", "Accept")
+	// 预生成的 WebP/AVIF 版本没有水印
+	if mark == nil && serveNegotiatedImage(c, &imageRecord) {
+		return
+	}
+
+	serveOriginalImage(c, &imageRecord, mark)
 }
 
 // GetImageThumbnail 获取图片缩略图
@@ -455,6 +548,10 @@ func GetImageThumbnail(c *gin.Context) {
 		return
 	}
 
+	if !authorizeDelivery(c, &imageRecord) {
+		return
+	}
+
 	// 获取质量参数，默认80
 	quality := 80
 	if q := c.Query("quality"); q != "" {
@@ -465,23 +562,40 @@ func GetImageThumbnail(c *gin.Context) {
 
 	// 如果有缩略图则返回缩略图，否则返回原图
 	thumbnailPath := imageRecord.Thumbnail
-	if thumbnailPath == "" || !fileExists(thumbnailPath) {
+	if size := c.Query("size"); size != "" {
+		// 处理配置生成的指定尺寸缩略图，尚未生成时使用默认缩略图
+		if sized := processedThumbnailPath(&imageRecord, size); sized != "" {
+			thumbnailPath = sized
+		}
+	}
+	if thumbnailPath == "" || !storedFileExists(thumbnailPath) {
 		thumbnailPath = imageRecord.FilePath
 	}
 
-	if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+	mark, ok := deliveryWatermark(c, &imageRecord)
+	if !ok {
 		return
 	}
 
 	// 如果质量不是默认值(80)，则动态生成指定质量的缩略图
 	if quality != 80 {
 		// 读取图片
-		img, err := imaging.Open(thumbnailPath)
-		if err == nil {
+		data, err := readStoredFile(thumbnailPath)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+			return
+		}
+		// 动态缩略图重新编码为 JPEG 会丢失动画，直接输出
+		img, err := imaging.Decode(bytes.NewReader(data))
+		if err == nil && !animation.IsAnimated(data) {
+			img = metadata.AutoOrient(img, data)
+			if mark != nil {
+				img = mark.watermark.Apply(img)
+			}
+
 			// 设置响应头
 			c.Header("Content-Type", "image/jpeg")
-			c.Header("Cache-Control", "public, max-age=31536000")
+			c.Header("Cache-Control", deliveryCacheControl(c, &imageRecord))
 
 			// 编码并输出
 			buf := new(bytes.Buffer)
@@ -492,11 +606,12 @@ func GetImageThumbnail(c *gin.Context) {
 		}
 	}
 
-	// 默认返回原缩略图文件
-	c.File(thumbnailPath)
+	// 默认返回原缩略图文件，没有缩略图时返回原图
+	c.Header("Cache-Control", deliveryCacheControl(c, &imageRecord))
+	serveThumbnailFile(c, &imageRecord, thumbnailPath, mark)
 }
 
-// DeleteImage 删除图片
+// DeleteImage 删除图片（移入回收站）
 func DeleteImage(c *gin.Context) {
 	id := c.Param("id")
 	var imageRecord models.Image
@@ -507,37 +622,12 @@ func DeleteImage(c *gin.Context) {
 		return
 	}
 
-	// 删除文件
-	if err := os.Remove(imageRecord.FilePath); err != nil {
-		// 记录错误但继续删除数据库记录
-		fmt.Printf("删除文件失败: %v\n", err)
-	}
-
-	// 如果有短链,删除短链(硬删除)
-	if imageRecord.ShortLinkCode != "" {
-		cfg := config.GetConfig()
-		if cfg.ShortLinkEnabled {
-			shortLinkClient := utils.NewShortLinkClient(cfg.ShortLinkBaseURL, cfg.ShortLinkAPIKey)
-			// 使用硬删除,永久删除短链记录
-			if err := shortLinkClient.DeleteShortLinkWithMode(imageRecord.ShortLinkCode, true); err != nil {
-				// 记录错误但继续删除图片
-				fmt.Printf("删除短链失败 %s: %v\n", imageRecord.ShortLinkCode, err)
-			} else {
-				fmt.Printf("✅ 已永久删除短链: %s\n", imageRecord.ShortLinkCode)
-			}
-		}
-	}
-
-	// 删除数据库记录
-	if err := db.Delete(&imageRecord).Error; err != nil {
+	// 移入回收站，短链和文件在彻底删除时才清理
+	if err := trashImage(&imageRecord); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
 
-	// 更新相册图片数量（使用数据库表达式避免并发问题）
-	db.Model(&models.Album{}).Where("id = ? AND image_count > 0", imageRecord.AlbumID).
-		Update("image_count", gorm.Expr("image_count - ?", 1))
-
 	// 清除缓存，确保删除后立即生效
 	clearImageListCache(uint64(imageRecord.AlbumID))
 
@@ -563,96 +653,64 @@ func BatchUpload(c *gin.Context) {
 	// 获取相册信息，检查短链配置
 	db := database.GetDB()
 	var album models.Album
-	if err := db.First(&album, albumID).Error; err != nil {
+	if err := db.Preload("Members").First(&album, albumID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
 		return
 	}
 
 	// 获取当前用户信息
 	userID, _ := c.Get("userID")
+	_, isAdmin := requestUser(c)
 
-	// 检查是否需要生成短链
-	generateShortLink := false
-	enableShortLinkStr := c.PostForm("enableShortLink")
-	fmt.Printf("========== 批量上传调试 ==========\n")
-	fmt.Printf("请求参数 enableShortLink: %s\n", enableShortLinkStr)
-	fmt.Printf("相册 EnableShortLink: %v\n", album.EnableShortLink)
-
-	if enableShortLinkStr != "" {
-		generateShortLink = enableShortLinkStr == "true" || enableShortLinkStr == "1"
-		fmt.Printf("使用请求参数，generateShortLink: %v\n", generateShortLink)
-	} else {
-		generateShortLink = album.EnableShortLink
-		fmt.Printf("使用相册配置，generateShortLink: %v\n", generateShortLink)
+	// 检查是否有权限上传到此相册（所有者、编辑者和贡献者）
+	if !album.CanUpload(userID.(uint), isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限上传到此相册"})
+		return
 	}
 
+	generateShortLink := uploadShortLinkEnabled(c, &album)
+
 	var uploadedImages []models.Image
 	var errors []string
+	var duplicates []gin.H
 	var shortLinkImages []utils.ImageInfo // 用于批量生成短链
 
+	// 逐个文件按普通上传流程保存，短链在全部保存后批量生成
 	for _, file := range files {
-		// 为每个文件创建一个临时context来处理
-		c.Request.Header.Set("Content-Type", "multipart/form-data")
+		if file.Size > cfg.MaxFileSize*1024*1024 {
+			errors = append(errors, fmt.Sprintf("%s: 文件大小不能超过 %dMB", file.Filename, cfg.MaxFileSize))
+			continue
+		}
 
 		ext := strings.ToLower(filepath.Ext(file.Filename))
-
 		if !utils.IsSupportedFormat(ext) {
 			errors = append(errors, fmt.Sprintf("%s: 不支持的文件格式", file.Filename))
 			continue
 		}
 
-		imageUUID := uuid.New().String()
-		newFileName := imageUUID + ext
-		albumPath := filepath.Join(cfg.UploadPath, fmt.Sprintf("album_%d", albumID))
-		os.MkdirAll(albumPath, 0755)
-
-		filePath := filepath.Join(albumPath, newFileName)
-		if err := c.SaveUploadedFile(file, filePath); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: 保存失败", file.Filename))
+		data, err := readUploadedFile(file)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: 读取失败", file.Filename))
 			continue
 		}
 
-		width, height := getImageDimensions(filePath)
-
-		// 生成缩略图
-		thumbnailPath := ""
-		if !utils.IsAnimatedFormat(ext) { // 动态图片不生成缩略图
-			thumbDir := filepath.Join(cfg.UploadPath, "thumbnails", fmt.Sprintf("album_%d", albumID))
-			os.MkdirAll(thumbDir, 0755)
-			thumbnailPath = filepath.Join(thumbDir, "thumb_"+newFileName)
-
-			// 生成缩略图（宽度300px）
-			if err := utils.GenerateThumbnail(filePath, thumbnailPath, 300); err != nil {
-				// 缩略图生成失败不影响主流程
-				fmt.Printf("缩略图生成失败: %v\n", err)
-				thumbnailPath = ""
-			}
+		outcome, err := storeUploadedImage(c, &album, userID.(uint), file.Filename, data)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", file.Filename, err))
+			continue
 		}
-
-		imageRecord := models.Image{
-			UUID:          imageUUID,
-			AlbumID:       uint(albumID),
-			FileName:      newFileName,
-			OriginalName:  file.Filename,
-			FilePath:      filePath,
-			FileSize:      file.Size,
-			MimeType:      file.Header.Get("Content-Type"),
-			Width:         width,
-			Height:        height,
-			Thumbnail:     thumbnailPath,
-			OwnerID:       userID.(uint),
-			IsPrivate:     album.IsPrivate,
-			IsPublic:      album.IsPublic,
-			AllowDownload: true,
+		if outcome.existing != nil {
+			duplicates = append(duplicates, gin.H{
+				"fileName":     file.Filename,
+				"existingUuid": outcome.existing.UUID,
+				"skipped":      outcome.image == nil,
+			})
 		}
-
-		if err := db.Create(&imageRecord).Error; err != nil {
-			os.Remove(filePath)
-			errors = append(errors, fmt.Sprintf("%s: 保存记录失败", file.Filename))
+		if outcome.image == nil {
 			continue
 		}
 
-		imageRecord.URL = generateImageURL(imageRecord.UUID)
+		imageRecord := *outcome.image
 		uploadedImages = append(uploadedImages, imageRecord)
 
 		// 准备短链信息（使用CDN路径）
@@ -723,14 +781,8 @@ func BatchUpload(c *gin.Context) {
 		fmt.Printf("跳过短链生成: generateShortLink=%v, shortLinkImages数量=%d\n", generateShortLink, len(shortLinkImages))
 	}
 
-	// 批量更新相册图片数量
+	// 相册图片数量、缓存和搜索索引已在逐个保存时更新
 	if len(uploadedImages) > 0 {
-		db.Model(&models.Album{}).Where("id = ?", albumID).
-			Update("image_count", gorm.Expr("image_count + ?", len(uploadedImages)))
-
-		// 清除图片列表相关的缓存，确保上传后立即可见
-		clearImageListCache(albumID)
-
 		// 重新查询图片数据,确保返回最新的短链信息
 		var imageIDs []uint
 		for _, img := range uploadedImages {
@@ -751,37 +803,22 @@ func BatchUpload(c *gin.Context) {
 
 	// 返回前，将所有图片的相对路径转换为完整URL
 	for i := range uploadedImages {
-		uploadedImages[i].URL = buildImageURL(uploadedImages[i].URL)
+		uploadedImages[i].URL = imageDeliveryURL(c, &uploadedImages[i])
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":    uploadedImages,
-		"errors":  errors,
-		"total":   len(files),
-		"success": len(uploadedImages),
+		"data":       uploadedImages,
+		"errors":     errors,
+		"duplicates": duplicates,
+		"total":      len(files),
+		"success":    len(uploadedImages),
 	})
 }
 
-// getImageDimensions 获取图片尺寸
-func getImageDimensions(filePath string) (int, int) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return 0, 0
-	}
-	defer file.Close()
-
-	img, _, err := image.DecodeConfig(file)
-	if err != nil {
-		return 0, 0
-	}
-
-	return img.Width, img.Height
-}
-
-// fileExists 检查文件是否存在
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+// storedFileExists 检查存储中的文件是否存在
+func storedFileExists(filePath string) bool {
+	exists, err := storage.GetStorage().Exists(storageKey(filePath))
+	return err == nil && exists
 }
 
 // MoveImage 移动图片到其他相册
@@ -806,11 +843,17 @@ func MoveImage(c *gin.Context) {
 
 	// 检查目标相册是否存在
 	var targetAlbum models.Album
-	if err := db.First(&targetAlbum, req.AlbumID).Error; err != nil {
+	if err := db.Preload("Members").First(&targetAlbum, req.AlbumID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "目标相册不存在"})
 		return
 	}
 
+	userID, isAdmin := requestUser(c)
+	if !targetAlbum.CanUpload(userID, isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限移动到此相册"})
+		return
+	}
+
 	oldAlbumID := imageRecord.AlbumID
 	imageRecord.AlbumID = req.AlbumID
 
@@ -824,6 +867,7 @@ func MoveImage(c *gin.Context) {
 		Update("image_count", gorm.Expr("image_count - 1"))
 	db.Model(&models.Album{}).Where("id = ?", req.AlbumID).
 		Update("image_count", gorm.Expr("image_count + 1"))
+	search.IndexImages(imageRecord.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": imageRecord})
 }
@@ -865,6 +909,51 @@ func RenameImage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名失败"})
 		return
 	}
+	search.IndexImages(imageRecord.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": imageRecord})
+}
+
+// maxAltTextLength 替代文本的最大长度，与 images.alt_text 列一致
+const maxAltTextLength = 500
+
+// UpdateImageDescription 更新图片描述和替代文本
+func UpdateImageDescription(c *gin.Context) {
+	id := c.Param("id")
+
+	var req struct {
+		Description string `json:"description"`
+		AltText     string `json:"altText"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求"})
+		return
+	}
+
+	req.AltText = strings.TrimSpace(req.AltText)
+	if utf8.RuneCountInString(req.AltText) > maxAltTextLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("替代文本不能超过 %d 个字符", maxAltTextLength)})
+		return
+	}
+
+	db := database.GetDB()
+	var imageRecord models.Image
+
+	if err := db.First(&imageRecord, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	if err := db.Model(&imageRecord).Updates(map[string]interface{}{
+		"description": strings.TrimSpace(req.Description),
+		"alt_text":    req.AltText,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新描述失败"})
+		return
+	}
+	clearImageListCache(uint64(imageRecord.AlbumID))
+	search.IndexImages(imageRecord.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": imageRecord})
 }
@@ -894,53 +983,37 @@ func UpdateImageFile(c *gin.Context) {
 		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
 	}
 	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "只支持 jpg, jpeg, png, gif, webp 格式"})
+		respondUploadError(c, apperrors.New(apperrors.ErrInvalidFormat, "只支持 jpg, jpeg, png, gif, webp 格式"))
 		return
 	}
 
-	// 使用原来的UUID和文件扩展名
-	oldFilePath := filepath.Join(cfg.UploadPath, fmt.Sprintf("album_%d", imageRecord.AlbumID), imageRecord.FileName)
-
-	// 如果扩展名改变，更新文件名
-	newFileName := imageRecord.UUID + ext
-	newFilePath := filepath.Join(cfg.UploadPath, fmt.Sprintf("album_%d", imageRecord.AlbumID), newFileName)
-
-	// 保存新文件
-	if err := c.SaveUploadedFile(file, newFilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+	data, err := readUploadedFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
 		return
 	}
-
-	// 如果文件名改变，删除旧文件
-	if oldFilePath != newFilePath {
-		os.Remove(oldFilePath)
-		os.Remove(oldFilePath + ".thumb") // 删除旧缩略图
+	content, err := validateUploadContent(ext, data)
+	if err != nil {
+		respondUploadError(c, err)
+		return
 	}
+	data = applyUploadProfile(loadProcessingProfile(albumProfileID(imageRecord.AlbumID)), ext, content.Data)
 
-	// 获取新图片的尺寸
-	width, height := getImageDimensions(newFilePath)
-
-	// 生成新缩略图
-	utils.GenerateThumbnail(newFilePath, newFilePath+".thumb", 300)
-
-	// 获取新文件大小
-	fileInfo, _ := os.Stat(newFilePath)
-
-	// 更新数据库记录
-	imageRecord.FileName = newFileName
-	imageRecord.FilePath = newFilePath
-	imageRecord.Width = width
-	imageRecord.Height = height
-	imageRecord.FileSize = fileInfo.Size()
-
-	if err := db.Save(&imageRecord).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新记录失败"})
+	// 使用原来的UUID，扩展名改变时文件名随之改变
+	mimeType := imageRecord.MimeType
+	imageRecord.MimeType = content.MimeType
+	if err := replaceImageContent(&imageRecord, ext, data, content.Data); err != nil {
+		imageRecord.MimeType = mimeType
+		respondUploadError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": imageRecord})
 }
 
+// maxSignedURLTTL 签名URL的最长有效期（秒）
+const maxSignedURLTTL = 7 * 24 * 3600
+
 // GetSignedURL 生成带签名的图片访问URL
 func GetSignedURL(c *gin.Context) {
 	id := c.Param("id")
@@ -951,6 +1024,10 @@ func GetSignedURL(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ttl参数"})
 		return
 	}
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttl 取值范围为 1-%d 秒", maxSignedURLTTL)})
+		return
+	}
 
 	db := database.GetDB()
 	var imageRecord models.Image
@@ -960,6 +1037,12 @@ func GetSignedURL(c *gin.Context) {
 		return
 	}
 
+	// 只有能查看图片的用户才能生成签名URL，签名URL本身不能用来续期
+	if !userDeliveryAccess(c, &imageRecord).view {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此图片"})
+		return
+	}
+
 	// 生成签名URL
 	signedURL := middleware.GenerateSignedURL(imageRecord.UUID, time.Duration(ttl)*time.Second)
 	expiresAt := time.Now().Add(time.Duration(ttl) * time.Second)
@@ -1013,61 +1096,28 @@ func ConvertImageFormat(c *gin.Context) {
 		return
 	}
 
-	cfg := config.GetConfig()
-
-	// 生成新文件名
-	newFileName := strings.TrimSuffix(imageRecord.FileName, currentExt) + targetExt
-	newFilePath := filepath.Join(filepath.Dir(imageRecord.FilePath), newFileName)
-
-	// 转换格式
-	if err := utils.ConvertImageFormat(imageRecord.FilePath, newFilePath, req.TargetFormat, req.Quality); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "格式转换失败: " + err.Error()})
+	// 转换为静态格式会丢失动画
+	if imageRecord.FrameCount > 1 && !animation.SupportsFormat(targetExt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "动图只能转换为 GIF 或 WebP，转换为其他格式会丢失动画"})
 		return
 	}
 
-	// 获取新文件大小
-	fileInfo, _ := os.Stat(newFilePath)
-	newFileSize := int64(0)
-	if fileInfo != nil {
-		newFileSize = fileInfo.Size()
-	}
-
-	// 删除旧文件
-	os.Remove(imageRecord.FilePath)
-
-	// 如果有缩略图,也需要重新生成
-	if imageRecord.Thumbnail != "" {
-		os.Remove(imageRecord.Thumbnail)
-
-		// 为新格式生成缩略图(如果不是动态格式)
-		if !utils.IsAnimatedFormat(targetExt) {
-			thumbDir := filepath.Join(cfg.UploadPath, "thumbnails", fmt.Sprintf("album_%d", imageRecord.AlbumID))
-			os.MkdirAll(thumbDir, 0755)
-			newThumbnailPath := filepath.Join(thumbDir, "thumb_"+newFileName)
-
-			if err := utils.GenerateThumbnail(newFilePath, newThumbnailPath, 300); err == nil {
-				imageRecord.Thumbnail = newThumbnailPath
-			}
-		} else {
-			imageRecord.Thumbnail = ""
-		}
-	}
-
-	// 更新数据库
-	imageRecord.FileName = newFileName
-	imageRecord.FilePath = newFilePath
-	imageRecord.FileSize = newFileSize
-	imageRecord.MimeType = "image/" + strings.TrimPrefix(req.TargetFormat, ".")
-
-	if err := database.DB.Save(&imageRecord).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新数据库失败"})
+	// 提交后台转换任务，通过 /api/jobs/:id 查询进度
+	userID, _ := c.Get("userID")
+	job, err := jobs.Enqueue(JobTypeConvertImage, convertImagePayload{
+		ImageIDs:     []uint{imageRecord.ID},
+		TargetFormat: req.TargetFormat,
+		Quality:      req.Quality,
+	}, jobs.WithUser(userID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交转换任务失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "success",
-		"data":    imageRecord,
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "格式转换任务已提交",
+		"data":    newJobResponse(job),
 	})
 }
 
@@ -1099,81 +1149,50 @@ func BatchConvertFormat(c *gin.Context) {
 		req.Quality = 90
 	}
 
-	var convertedImages []models.Image
-	var errors []string
+	userID, _ := c.Get("userID")
+	isAdmin, _ := c.Get("isAdmin")
 
+	// 提交前过滤不存在或无权操作的图片
+	var imageIDs []uint
+	var errors []string
 	for _, imageID := range req.ImageIDs {
-		// 查询图片
 		var imageRecord models.Image
 		if err := database.DB.First(&imageRecord, imageID).Error; err != nil {
 			errors = append(errors, fmt.Sprintf("图片ID %d 不存在", imageID))
 			continue
 		}
-
-		// 检查当前格式
-		currentExt := strings.ToLower(filepath.Ext(imageRecord.FileName))
-		if currentExt == targetExt {
-			// 跳过已经是目标格式的图片
+		if imageRecord.OwnerID != userID.(uint) && isAdmin != true {
+			errors = append(errors, fmt.Sprintf("图片ID %d 无权操作", imageID))
 			continue
 		}
-
-		// 生成新文件名
-		newFileName := strings.TrimSuffix(imageRecord.FileName, currentExt) + targetExt
-		newFilePath := filepath.Join(filepath.Dir(imageRecord.FilePath), newFileName)
-
-		// 转换格式
-		if err := utils.ConvertImageFormat(imageRecord.FilePath, newFilePath, req.TargetFormat, req.Quality); err != nil {
-			errors = append(errors, fmt.Sprintf("图片ID %d 转换失败: %v", imageID, err))
+		if imageRecord.FrameCount > 1 && !animation.SupportsFormat(targetExt) {
+			errors = append(errors, fmt.Sprintf("图片ID %d 是动图，转换为 %s 会丢失动画", imageID, strings.TrimPrefix(targetExt, ".")))
 			continue
-		} // 获取新文件大小
-		fileInfo, _ := os.Stat(newFilePath)
-		newFileSize := int64(0)
-		if fileInfo != nil {
-			newFileSize = fileInfo.Size()
-		}
-
-		// 删除旧文件
-		os.Remove(imageRecord.FilePath)
-
-		// 处理缩略图
-		if imageRecord.Thumbnail != "" {
-			os.Remove(imageRecord.Thumbnail)
-
-			if !utils.IsAnimatedFormat(targetExt) {
-				thumbDir := filepath.Join(cfg.UploadPath, "thumbnails", fmt.Sprintf("album_%d", imageRecord.AlbumID))
-				os.MkdirAll(thumbDir, 0755)
-				newThumbnailPath := filepath.Join(thumbDir, "thumb_"+newFileName)
-
-				if err := utils.GenerateThumbnail(newFilePath, newThumbnailPath, 300); err == nil {
-					imageRecord.Thumbnail = newThumbnailPath
-				}
-			} else {
-				imageRecord.Thumbnail = ""
-			}
 		}
+		imageIDs = append(imageIDs, imageID)
+	}
 
-		// 更新数据库
-		imageRecord.FileName = newFileName
-		imageRecord.FilePath = newFilePath
-		imageRecord.FileSize = newFileSize
-		imageRecord.MimeType = "image/" + strings.TrimPrefix(req.TargetFormat, ".")
-
-		if err := database.DB.Save(&imageRecord).Error; err != nil {
-			errors = append(errors, fmt.Sprintf("图片ID %d 数据库更新失败: %v", imageID, err))
-			continue
-		}
+	if len(imageIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有可转换的图片", "errors": errors})
+		return
+	}
 
-		// 添加到成功列表
-		convertedImages = append(convertedImages, imageRecord)
+	// 提交后台转换任务，通过 /api/jobs/:id 查询进度
+	job, err := jobs.Enqueue(JobTypeBatchConvert, convertImagePayload{
+		ImageIDs:     imageIDs,
+		TargetFormat: req.TargetFormat,
+		Quality:      req.Quality,
+	}, jobs.WithUser(userID.(uint)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交转换任务失败"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "success",
-		"data": gin.H{
-			"converted": convertedImages,
-			"errors":    errors,
-		},
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "格式转换任务已提交",
+		"data":    newJobResponse(job),
+		"errors":  errors,
 	})
 }
 
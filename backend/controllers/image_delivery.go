@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"imagebed/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errRangeNotSatisfiable 请求的范围超出文件大小
+var errRangeNotSatisfiable = errors.New("请求范围无法满足")
+
+// byteRange 请求的字节范围
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange Content-Range 响应头
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// imageETag 图片内容的强 ETag，相同内容的图片共享 ETag
+// suffix 区分同一内容的不同输出（如 WebP 版本、变换结果）
+func imageETag(imageRecord *models.Image, suffix string) string {
+	tag := imageRecord.ContentHash
+	if tag == "" {
+		// 没有内容哈希的旧数据按更新时间区分版本
+		tag = fmt.Sprintf("%s-%x", imageRecord.UUID, imageRecord.UpdatedAt.UnixNano())
+	}
+	if suffix != "" {
+		tag += "-" + suffix
+	}
+	return `"` + tag + `"`
+}
+
+// setValidators 设置 ETag 和 Last-Modified 响应头
+func setValidators(c *gin.Context, etag string, lastModified time.Time) {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkNotModified 处理条件请求，缓存仍然有效时输出 304 并返回 true
+// 需要先调用 setValidators；If-None-Match 优先于 If-Modified-Since
+func checkNotModified(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	etag := c.Writer.Header().Get("ETag")
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, etag) {
+			return false
+		}
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	ims := c.GetHeader("If-Modified-Since")
+	lastModified := c.Writer.Header().Get("Last-Modified")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil || modified.After(since) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagListMatches If-None-Match 使用弱比较，忽略 W/ 前缀
+func etagListMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifRangeMatches 检查 If-Range 条件，不满足时应忽略 Range 输出完整内容
+// If-Range 使用强比较，弱 ETag 不能满足条件
+func ifRangeMatches(c *gin.Context) bool {
+	ifRange := c.GetHeader("If-Range")
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) {
+		return ifRange == c.Writer.Header().Get("ETag")
+	}
+
+	since, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(c.Writer.Header().Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// parseByteRange 解析 Range 请求头，只支持单个范围
+// 返回 nil 表示忽略 Range 输出完整内容（无 Range、格式错误或多个范围）
+func parseByteRange(header string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+
+	// bytes=-n 表示最后 n 个字节
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &byteRange{start: size - n, length: n}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+
+	end := size - 1
+	if endStr != "" {
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+			return nil, nil
+		}
+	}
+
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header string
+		start  int64
+		length int64
+		full   bool
+		err    error
+	}{
+		{header: "bytes=0-99", start: 0, length: 100},
+		{header: "bytes=100-", start: 100, length: 900},
+		{header: "bytes=-100", start: 900, length: 100},
+		{header: "bytes=900-5000", start: 900, length: 100},
+		{header: "bytes=-5000", start: 0, length: 1000},
+		{header: "bytes=1000-", err: errRangeNotSatisfiable},
+		{header: "bytes=-0", err: errRangeNotSatisfiable},
+		{header: "bytes=0-1,5-9", full: true},
+		{header: "bytes=9-5", full: true},
+		{header: "items=0-1", full: true},
+		{header: "bytes=abc", full: true},
+	}
+
+	for _, tt := range tests {
+		r, err := parseByteRange(tt.header, 1000)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.header)
+			continue
+		}
+		assert.NoError(t, err, tt.header)
+		if tt.full {
+			assert.Nil(t, r, tt.header)
+			continue
+		}
+		if assert.NotNil(t, r, tt.header) {
+			assert.Equal(t, tt.start, r.start, tt.header)
+			assert.Equal(t, tt.length, r.length, tt.header)
+		}
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{"无条件", nil, http.StatusOK},
+		{"ETag 匹配", map[string]string{"If-None-Match": `"abc"`}, http.StatusNotModified},
+		{"ETag 列表匹配", map[string]string{"If-None-Match": `"x", W/"abc"`}, http.StatusNotModified},
+		{"ETag 不匹配", map[string]string{"If-None-Match": `"x"`}, http.StatusOK},
+		{"ETag 优先于时间", map[string]string{
+			"If-None-Match":     `"x"`,
+			"If-Modified-Since": modified.Format(http.TimeFormat),
+		}, http.StatusOK},
+		{"未修改", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"已修改", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				setValidators(c, `"abc"`, modified)
+				if checkNotModified(c) {
+					return
+				}
+				c.String(http.StatusOK, "body")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/storage"
+	"imagebed/utils/metadata"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stripMetadataKey 删除敏感元数据后的原图在变换缓存中的键
+const stripMetadataKey = "strip-metadata"
+
+// newImageMetadata 将解析结果转换为数据库记录
+func newImageMetadata(imageID uint, m *metadata.Metadata) *models.ImageMetadata {
+	record := &models.ImageMetadata{
+		ImageID:         imageID,
+		CameraMake:      truncateRunes(m.Make, 100),
+		CameraModel:     truncateRunes(m.Model, 100),
+		LensMake:        truncateRunes(m.LensMake, 100),
+		LensModel:       truncateRunes(m.LensModel, 255),
+		Software:        truncateRunes(m.Software, 255),
+		Artist:          truncateRunes(m.Artist, 255),
+		Copyright:       truncateRunes(m.Copyright, 500),
+		ExposureTime:    truncateRunes(m.ExposureTime, 20),
+		FNumber:         m.FNumber,
+		ISO:             m.ISO,
+		FocalLength:     m.FocalLength,
+		FocalLength35mm: m.FocalLength35mm,
+		ExposureBias:    m.ExposureBias,
+		Flash:           m.Flash,
+		TakenAt:         m.TakenAt,
+		Orientation:     m.Orientation,
+		HasXMP:          m.HasXMP,
+		Title:           truncateRunes(m.Title, 500),
+		Description:     m.Description,
+		Keywords:        strings.Join(m.Keywords, ","),
+		Rating:          m.Rating,
+		Sensitive:       m.Sensitive,
+	}
+	if m.GPS != nil {
+		record.Latitude = &m.GPS.Latitude
+		record.Longitude = &m.GPS.Longitude
+		record.Altitude = m.GPS.Altitude
+	}
+	return record
+}
+
+// truncateRunes 按字符截断，避免超出字段长度
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// saveImageMetadata 解析图片内容中的元数据并保存，图片内容替换后覆盖原有记录
+func saveImageMetadata(imageID uint, data []byte) *models.ImageMetadata {
+	record := newImageMetadata(imageID, metadata.Extract(data))
+	err := database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "image_id"}},
+		UpdateAll: true,
+	}).Create(record).Error
+	if err != nil {
+		fmt.Printf("保存图片元数据失败: %v\n", err)
+	}
+	return record
+}
+
+// loadImageMetadata 读取图片的元数据，旧图片没有记录时从原图中解析
+func loadImageMetadata(imageRecord *models.Image) (*models.ImageMetadata, error) {
+	var record models.ImageMetadata
+	err := database.GetDB().Where("image_id = ?", imageRecord.ID).First(&record).Error
+	if err == nil {
+		return &record, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	data, err := readStoredFile(imageRecord.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return saveImageMetadata(imageRecord.ID, data), nil
+}
+
+// stripMetadataEnabled 输出原图时是否删除敏感元数据
+// 相册的设置优先，未设置时使用所有者的设置，默认删除
+func stripMetadataEnabled(imageRecord *models.Image) bool {
+	var result struct{ Strip bool }
+	err := database.GetDB().Table("images").
+		Select("COALESCE(albums.strip_metadata, users.strip_metadata, ?) AS strip", true).
+		Joins("LEFT JOIN albums ON albums.id = images.album_id").
+		Joins("LEFT JOIN users ON users.id = images.owner_id").
+		Where("images.id = ?", imageRecord.ID).
+		Scan(&result).Error
+	if err != nil {
+		return true
+	}
+	return result.Strip
+}
+
+// originalFile 输出原图时实际使用的文件
+type originalFile struct {
+	path     string
+	size     int64
+	mimeType string
+	etag     string // ETag 后缀，区分删除元数据后的版本
+}
+
+// resolveOriginalFile 确定输出原图时使用的文件
+// 需要删除敏感元数据且原图包含敏感信息时，使用缓存的清理后版本，没有时生成
+func resolveOriginalFile(imageRecord *models.Image) (*originalFile, error) {
+	original := &originalFile{
+		path:     imageRecord.FilePath,
+		size:     imageRecord.FileSize,
+		mimeType: imageRecord.MimeType,
+	}
+	if !stripMetadataEnabled(imageRecord) {
+		return original, nil
+	}
+
+	record, err := loadImageMetadata(imageRecord)
+	if err != nil {
+		return nil, err
+	}
+	if !record.Sensitive {
+		return original, nil
+	}
+
+	db := database.GetDB()
+	store := storage.GetStorage()
+	base := variantBase(imageRecord)
+	stripped := &originalFile{mimeType: imageRecord.MimeType, etag: "nometa"}
+
+	var variant models.ImageVariant
+	if err := db.Where("content_hash = ? AND transform_key = ?", base, stripMetadataKey).First(&variant).Error; err == nil {
+		if storedFileExists(variant.FilePath) {
+			stripped.path, stripped.size = variant.FilePath, variant.FileSize
+			return stripped, nil
+		}
+		// 缓存文件丢失，重新生成
+		db.Delete(&variant)
+	}
+
+	data, err := readStoredFile(imageRecord.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	output, _ := metadata.Strip(data)
+
+	objectPath := variantObjectPath(base, stripMetadataKey, strings.ToLower(path.Ext(imageRecord.FilePath)))
+	if _, err := store.SaveFromReader(objectPath, bytes.NewReader(output), int64(len(output))); err != nil {
+		return nil, fmt.Errorf("保存清理后的图片失败: %w", err)
+	}
+	db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ImageVariant{
+		ContentHash: base,
+		Key:         stripMetadataKey,
+		FilePath:    objectPath,
+		FileSize:    int64(len(output)),
+		MimeType:    imageRecord.MimeType,
+	})
+
+	stripped.path, stripped.size = objectPath, int64(len(output))
+	return stripped, nil
+}
+
+// serveOriginalImage 输出原图，按设置删除 GPS 等敏感元数据，mark 不为空时输出叠加水印的版本
+// 无法确认元数据已删除时返回错误，不输出原图
+func serveOriginalImage(c *gin.Context, imageRecord *models.Image, mark *deliveryMark) {
+	if mark != nil {
+		serveWatermarkedFile(c, imageRecord, imageRecord.FilePath, mark)
+		return
+	}
+
+	file, err := resolveOriginalFile(imageRecord)
+	if err != nil {
+		fmt.Printf("处理图片元数据失败: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取图片失败"})
+		return
+	}
+
+	setContentDisposition(c, imageRecord, "")
+	setValidators(c, imageETag(imageRecord, file.etag), imageRecord.UpdatedAt)
+	if checkNotModified(c) {
+		return
+	}
+	serveStoredFile(c, file.path, file.size, file.mimeType)
+}
+
+// visibleImageMetadata 按权限返回图片的元数据
+// 输出原图时删除敏感信息的图片，只有所有者和管理员可以看到 GPS 位置
+func visibleImageMetadata(c *gin.Context, imageRecord *models.Image) (*models.ImageMetadata, error) {
+	record, err := loadImageMetadata(imageRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, isAdmin := requestUser(c)
+	if !imageRecord.CanModify(userID, isAdmin) && stripMetadataEnabled(imageRecord) {
+		record.HideLocation()
+	}
+	return record, nil
+}
+
+// GetImageMetadata 获取图片的 EXIF/XMP 元数据
+func GetImageMetadata(c *gin.Context) {
+	var imageRecord models.Image
+	if err := database.GetDB().First(&imageRecord, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	if !userDeliveryAccess(c, &imageRecord).view {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此图片"})
+		return
+	}
+
+	record, err := visibleImageMetadata(c, &imageRecord)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取图片元数据失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": record})
+}
@@ -0,0 +1,518 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"imagebed/database"
+	"imagebed/jobs"
+	"imagebed/middleware"
+	"imagebed/models"
+	"imagebed/utils"
+	"imagebed/utils/imageprocessor"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 相似图片的汉明距离阈值（64 位 dHash）
+const (
+	defaultSimilarDistance   = 10 // 查找相似图片
+	defaultDuplicateDistance = 6  // 重复图片报告
+	maxSimilarDistance       = 20
+)
+
+// maxResolveDuplicates 一次最多删除的重复图片数量
+const maxResolveDuplicates = 1000
+
+// perceptualHashBatchSize 回填任务每批处理的文件数量
+const perceptualHashBatchSize = 100
+
+// SimilarImage 相似图片及其与参照图片的汉明距离
+type SimilarImage struct {
+	models.Image
+	Distance int `json:"distance"`
+}
+
+// DuplicateCluster 一组相似的图片，建议保留分辨率最高的一张
+type DuplicateCluster struct {
+	SuggestedKeepID uint           `json:"suggestedKeepId"`
+	Images          []SimilarImage `json:"images"` // 距离相对于建议保留的图片
+}
+
+// DuplicateResolution 保留一张图片，删除其余相似的图片
+type DuplicateResolution struct {
+	KeepID    uint   `json:"keepId" binding:"required"`
+	DeleteIDs []uint `json:"deleteIds" binding:"required,min=1"`
+}
+
+// ResolveDuplicatesRequest 批量清理重复图片请求
+type ResolveDuplicatesRequest struct {
+	Clusters []DuplicateResolution `json:"clusters" binding:"required,min=1,dive"`
+}
+
+// perceptualHashResult 感知哈希回填任务结果
+type perceptualHashResult struct {
+	Hashed int `json:"hashed"`
+	Failed int `json:"failed"` // 读取或解码失败的文件
+}
+
+// hashedImage 已计算感知哈希的图片
+type hashedImage struct {
+	ID   uint
+	Hash uint64
+}
+
+// computePerceptualHash 计算图片内容的感知哈希，按 Orientation 旋转后计算，无法解码时返回空字符串
+func computePerceptualHash(data []byte) string {
+	img, err := utils.DecodeImage(data)
+	if err != nil {
+		return ""
+	}
+	return imageprocessor.FormatHash(imageprocessor.DHash(img))
+}
+
+// savePerceptualHash 计算存储文件的感知哈希，写入文件记录和使用该文件的全部图片
+// 无法解码的图片返回空字符串
+func savePerceptualHash(filePath string) (string, error) {
+	data, err := readStoredFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	hash := computePerceptualHash(data)
+	if hash == "" {
+		return "", nil
+	}
+
+	db := database.GetDB()
+	if err := db.Model(&models.Blob{}).Where("file_path = ?", filePath).
+		UpdateColumn("perceptual_hash", hash).Error; err != nil {
+		return "", err
+	}
+	// 不修改 updated_at，避免影响图片的缓存校验
+	if err := db.Unscoped().Model(&models.Image{}).Where("file_path = ?", filePath).
+		UpdateColumn("perceptual_hash", hash).Error; err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// loadHashedImages 读取查询范围内已计算感知哈希的图片
+func loadHashedImages(query *gorm.DB) ([]hashedImage, error) {
+	var rows []struct {
+		ID             uint
+		PerceptualHash string
+	}
+	if err := query.Where("images.perceptual_hash <> ''").
+		Select("images.id, images.perceptual_hash").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	images := make([]hashedImage, 0, len(rows))
+	for _, row := range rows {
+		if hash, err := imageprocessor.ParseHash(row.PerceptualHash); err == nil {
+			images = append(images, hashedImage{ID: row.ID, Hash: hash})
+		}
+	}
+	return images, nil
+}
+
+// loadImagesByID 按 ID 读取图片并生成访问地址
+func loadImagesByID(c *gin.Context, ids []uint) (map[uint]models.Image, error) {
+	var images []models.Image
+	if len(ids) > 0 {
+		if err := database.GetDB().Where("id IN ?", ids).Find(&images).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[uint]models.Image, len(images))
+	for _, img := range images {
+		img.URL = imageDeliveryURL(c, &img)
+		result[img.ID] = img
+	}
+	return result, nil
+}
+
+// clusterSimilarImages 将距离不超过 maxDistance 的图片合并为一组（传递合并）
+// 返回至少包含两张图片的分组，图片多的分组在前
+func clusterSimilarImages(images []hashedImage, maxDistance int) [][]uint {
+	var index imageprocessor.HashIndex
+	parent := make(map[uint]uint, len(images))
+	for _, img := range images {
+		index.Add(img.ID, img.Hash)
+		parent[img.ID] = img.ID
+	}
+
+	find := func(id uint) uint {
+		root := id
+		for parent[root] != root {
+			root = parent[root]
+		}
+		for parent[id] != root {
+			parent[id], id = root, parent[id]
+		}
+		return root
+	}
+
+	for _, img := range images {
+		index.Search(img.Hash, maxDistance, func(id uint, _ int) {
+			if a, b := find(img.ID), find(id); a != b {
+				parent[a] = b
+			}
+		})
+	}
+
+	groups := make(map[uint][]uint)
+	for _, img := range images {
+		root := find(img.ID)
+		groups[root] = append(groups[root], img.ID)
+	}
+
+	clusters := make([][]uint, 0)
+	for _, ids := range groups {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		clusters = append(clusters, ids)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i]) != len(clusters[j]) {
+			return len(clusters[i]) > len(clusters[j])
+		}
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
+
+// preferKeep 判断 a 是否比 b 更适合保留：分辨率高、文件大、上传早的优先
+func preferKeep(a, b *models.Image) bool {
+	if pa, pb := a.Width*a.Height, b.Width*b.Height; pa != pb {
+		return pa > pb
+	}
+	if a.FileSize != b.FileSize {
+		return a.FileSize > b.FileSize
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// parseSimilarDistance 读取汉明距离参数，参数错误时输出错误响应并返回 ok=false
+func parseSimilarDistance(c *gin.Context, defaultValue int) (int, bool) {
+	value := c.Query("maxDistance")
+	if value == "" {
+		return defaultValue, true
+	}
+	distance, err := strconv.Atoi(value)
+	if err != nil || distance < 0 || distance > maxSimilarDistance {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("maxDistance 取值范围为 0-%d", maxSimilarDistance)})
+		return 0, false
+	}
+	return distance, true
+}
+
+// GetSimilarImages 查找与图片视觉相似的图片，按汉明距离从小到大排序
+// 参数：maxDistance 最大汉明距离（默认 10），limit 返回数量（默认 20，最多 100）
+func GetSimilarImages(c *gin.Context) {
+	var imageRecord models.Image
+	db := database.GetDB()
+	if err := db.First(&imageRecord, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	if !userDeliveryAccess(c, &imageRecord).view {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限访问此图片"})
+		return
+	}
+
+	maxDistance, ok := parseSimilarDistance(c, defaultSimilarDistance)
+	if !ok {
+		return
+	}
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 取值范围为 1-100"})
+			return
+		}
+		limit = n
+	}
+
+	// 后台处理尚未完成或旧图片，当场计算
+	hashText := imageRecord.PerceptualHash
+	if hashText == "" {
+		var err error
+		if hashText, err = savePerceptualHash(imageRecord.FilePath); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取图片失败"})
+			return
+		}
+	}
+	source, err := imageprocessor.ParseHash(hashText)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "无法计算图片的感知哈希"})
+		return
+	}
+
+	candidates, err := loadHashedImages(visibleImages(db, db.Model(&models.Image{}), c).
+		Where("images.id <> ?", imageRecord.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相似图片失败"})
+		return
+	}
+
+	matches := make([]hashedImage, 0)
+	distances := make(map[uint]int)
+	for _, candidate := range candidates {
+		if d := imageprocessor.HammingDistance(source, candidate.Hash); d <= maxDistance {
+			matches = append(matches, candidate)
+			distances[candidate.ID] = d
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		di, dj := distances[matches[i].ID], distances[matches[j].ID]
+		if di != dj {
+			return di < dj
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	images, err := loadImagesByID(c, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相似图片失败"})
+		return
+	}
+
+	result := make([]SimilarImage, 0, len(ids))
+	for _, id := range ids {
+		if img, ok := images[id]; ok {
+			result = append(result, SimilarImage{Image: img, Distance: distances[id]})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":           result,
+		"perceptualHash": hashText,
+		"maxDistance":    maxDistance,
+	})
+}
+
+// GetDuplicateImages 管理员查看全部图片中的相似图片分组
+// 参数：maxDistance 最大汉明距离（默认 6），page、pageSize 分页
+func GetDuplicateImages(c *gin.Context) {
+	maxDistance, ok := parseSimilarDistance(c, defaultDuplicateDistance)
+	if !ok {
+		return
+	}
+	params := utils.GetPaginationParams(c)
+	db := database.GetDB()
+
+	hashed, err := loadHashedImages(db.Model(&models.Image{}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询图片失败"})
+		return
+	}
+	hashes := make(map[uint]uint64, len(hashed))
+	for _, img := range hashed {
+		hashes[img.ID] = img.Hash
+	}
+
+	var unhashed int64
+	db.Model(&models.Image{}).Where("perceptual_hash IS NULL OR perceptual_hash = ''").Count(&unhashed)
+
+	clusters := clusterSimilarImages(hashed, maxDistance)
+	duplicateCount := 0
+	for _, ids := range clusters {
+		duplicateCount += len(ids) - 1
+	}
+
+	start := (params.Page - 1) * params.PageSize
+	if start > len(clusters) {
+		start = len(clusters)
+	}
+	end := start + params.PageSize
+	if end > len(clusters) {
+		end = len(clusters)
+	}
+	page := clusters[start:end]
+
+	ids := make([]uint, 0)
+	for _, cluster := range page {
+		ids = append(ids, cluster...)
+	}
+	images, err := loadImagesByID(c, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询图片失败"})
+		return
+	}
+
+	result := make([]DuplicateCluster, 0, len(page))
+	for _, cluster := range page {
+		members := make([]models.Image, 0, len(cluster))
+		for _, id := range cluster {
+			if img, ok := images[id]; ok {
+				members = append(members, img)
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+		sort.Slice(members, func(i, j int) bool { return preferKeep(&members[i], &members[j]) })
+
+		keep := members[0]
+		group := DuplicateCluster{SuggestedKeepID: keep.ID, Images: make([]SimilarImage, 0, len(members))}
+		for _, img := range members {
+			group.Images = append(group.Images, SimilarImage{
+				Image:    img,
+				Distance: imageprocessor.HammingDistance(hashes[keep.ID], hashes[img.ID]),
+			})
+		}
+		result = append(result, group)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":           result,
+		"total":          len(clusters),
+		"page":           params.Page,
+		"pageSize":       params.PageSize,
+		"maxDistance":    maxDistance,
+		"duplicateCount": duplicateCount, // 每组保留一张时可以删除的图片数量
+		"unhashed":       unhashed,       // 尚未计算感知哈希的图片数量
+	})
+}
+
+// ResolveDuplicates 批量清理重复图片：每组保留一张，其余移入回收站
+// 要删除的图片必须与保留的图片相似（汉明距离不超过 20），避免误删
+func ResolveDuplicates(c *gin.Context) {
+	var req ResolveDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	total := 0
+	for _, cluster := range req.Clusters {
+		total += len(cluster.DeleteIDs)
+	}
+	if total > maxResolveDuplicates {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多删除 %d 张图片", maxResolveDuplicates)})
+		return
+	}
+
+	db := database.GetDB()
+	deleted := make([]uint, 0, total)
+	errors := make([]string, 0)
+	albums := make(map[uint]bool)
+
+	for _, cluster := range req.Clusters {
+		var keep models.Image
+		if err := db.First(&keep, cluster.KeepID).Error; err != nil {
+			errors = append(errors, fmt.Sprintf("保留的图片 %d 不存在", cluster.KeepID))
+			continue
+		}
+		keepHash, err := imageprocessor.ParseHash(keep.PerceptualHash)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("图片 %d 尚未计算感知哈希", keep.ID))
+			continue
+		}
+
+		for _, id := range cluster.DeleteIDs {
+			if id == keep.ID {
+				errors = append(errors, fmt.Sprintf("图片 %d 不能同时保留和删除", id))
+				continue
+			}
+			var imageRecord models.Image
+			if err := db.First(&imageRecord, id).Error; err != nil {
+				errors = append(errors, fmt.Sprintf("图片 %d 不存在", id))
+				continue
+			}
+			hash, err := imageprocessor.ParseHash(imageRecord.PerceptualHash)
+			if err != nil || imageprocessor.HammingDistance(hash, keepHash) > maxSimilarDistance {
+				errors = append(errors, fmt.Sprintf("图片 %d 与保留的图片 %d 不相似", id, keep.ID))
+				continue
+			}
+			if err := trashImage(&imageRecord); err != nil {
+				errors = append(errors, fmt.Sprintf("图片 %d 删除失败", id))
+				continue
+			}
+			deleted = append(deleted, id)
+			albums[imageRecord.AlbumID] = true
+		}
+	}
+
+	for albumID := range albums {
+		clearImageListCache(uint64(albumID))
+	}
+	if len(deleted) > 0 {
+		middleware.RecordOperation(c, "resolve_duplicates", "image", 0,
+			fmt.Sprintf("清理重复图片，删除 %d 张", len(deleted)))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deleted": deleted,
+		"errors":  errors,
+	})
+}
+
+// StartPerceptualHashBackfill 提交后台任务，为没有感知哈希的图片计算哈希
+func StartPerceptualHashBackfill(c *gin.Context) {
+	userID, _ := requestUser(c)
+	job, err := jobs.Enqueue(JobTypePerceptualHash, struct{}{}, jobs.WithUser(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交任务失败"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "感知哈希计算任务已提交",
+		"data":    newJobResponse(job),
+	})
+}
+
+// handlePerceptualHash 为没有感知哈希的图片计算哈希，按文件路径分批处理
+func handlePerceptualHash(ctx context.Context, job *models.Job) (interface{}, error) {
+	db := database.GetDB()
+	result := perceptualHashResult{}
+	lastPath := ""
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var paths []string
+		if err := db.Model(&models.Image{}).
+			Where("(perceptual_hash IS NULL OR perceptual_hash = '') AND file_path > ?", lastPath).
+			Distinct("file_path").Order("file_path").Limit(perceptualHashBatchSize).
+			Pluck("file_path", &paths).Error; err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return result, nil
+		}
+
+		for _, filePath := range paths {
+			lastPath = filePath
+			if hash, err := savePerceptualHash(filePath); err != nil || hash == "" {
+				result.Failed++
+				continue
+			}
+			result.Hashed++
+		}
+	}
+}
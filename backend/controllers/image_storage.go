@@ -0,0 +1,477 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/search"
+	"imagebed/storage"
+	"imagebed/utils"
+	"imagebed/utils/animation"
+	"imagebed/utils/imageprocessor"
+	"imagebed/utils/metadata"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// thumbnailWidth 列表缩略图宽度
+const thumbnailWidth = 300
+
+// storageKey 将数据库中记录的路径转换为存储路径
+// 旧数据记录的是包含 UploadPath 前缀的本地路径，这里统一去掉前缀
+func storageKey(p string) string {
+	if p == "" {
+		return ""
+	}
+
+	key := path.Clean(filepath.ToSlash(p))
+	base := path.Clean(filepath.ToSlash(config.GetConfig().UploadPath))
+	if base != "." && base != "/" && strings.HasPrefix(key, base+"/") {
+		key = strings.TrimPrefix(key, base+"/")
+	}
+
+	return strings.TrimPrefix(key, "/")
+}
+
+// readUploadedFile 读取上传文件的全部内容
+func readUploadedFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return io.ReadAll(src)
+}
+
+// readStoredFile 从存储后端读取文件的全部内容
+func readStoredFile(objectPath string) ([]byte, error) {
+	reader, err := storage.GetStorage().Get(storageKey(objectPath))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// contentHash 计算文件内容的 SHA-256
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newBlobGeneration 为新写入的内容生成路径后缀
+// 同一内容在释放后重新上传时写入新的路径，释放旧文件不会删除新写入的文件
+func newBlobGeneration() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+}
+
+// blobObjectPath 内容寻址文件的存储路径
+func blobObjectPath(hash, generation, ext string) string {
+	return path.Join("blobs", hash[:2], hash+"-"+generation+ext)
+}
+
+// blobThumbnailPath 内容寻址文件的缩略图存储路径
+func blobThumbnailPath(hash, generation, ext string) string {
+	return path.Join("thumbnails", "blobs", hash[:2], hash+"-"+generation+ext)
+}
+
+// acquireBlob 获取内容对应的存储文件并增加引用计数
+// 相同内容已存在时直接复用，否则写入存储并生成缩略图；created 表示是否为新写入的文件
+func acquireBlob(hash, ext string, data []byte) (blob *models.Blob, created bool, err error) {
+	if blob, ok := retainBlob(hash); ok {
+		return blob, false, nil
+	}
+
+	blob, err = writeBlobFiles(hash, ext, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := database.GetDB().Create(blob).Error; err != nil {
+		// 并发上传相同内容时唯一索引冲突，改为复用已有记录；新写入的文件路径唯一，可以直接删除
+		deleteStoredFiles(blob.FilePath, blob.Thumbnail)
+		existing, ok := retainBlob(hash)
+		if !ok {
+			return nil, false, fmt.Errorf("保存文件记录失败: %w", err)
+		}
+		return existing, false, nil
+	}
+
+	return blob, true, nil
+}
+
+// retainBlob 为已存在的文件增加一次引用
+// 在事务中锁定文件记录，与 releaseBlob 互斥
+func retainBlob(hash string) (*models.Blob, bool) {
+	var blob models.Blob
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND ref_count > 0", hash).Limit(1).Find(&blob)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		blob.RefCount++
+		return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count + ?", 1)).Error
+	})
+	if err != nil {
+		return nil, false
+	}
+	return &blob, true
+}
+
+// releaseBlob 释放一次引用，最后一个引用释放时删除存储中的文件
+// 引用计数的减少和记录的删除在同一事务中完成，并锁定文件记录，与 retainBlob 互斥
+func releaseBlob(hash string) {
+	var blob models.Blob
+	removed := false
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ?", hash).Limit(1).Find(&blob)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if blob.RefCount > 1 {
+			return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count - ?", 1)).Error
+		}
+		removed = true
+		return tx.Delete(&blob).Error
+	})
+	if err != nil || !removed {
+		return
+	}
+
+	// 记录删除后再上传相同内容会写入新的路径，这里只删除本记录的文件
+	deleteStoredFiles(blob.FilePath, blob.Thumbnail)
+	if err := imageprocessor.CleanupStoredFiles(storage.GetStorage(), blob.FilePath, blobThumbnailSizes(&blob)); err != nil {
+		fmt.Printf("清理处理文件失败: %v\n", err)
+	}
+	deleteImageVariants(hash)
+}
+
+// releaseImageFiles 释放图片记录引用的存储文件
+func releaseImageFiles(imageRecord *models.Image) {
+	if imageRecord.ContentHash != "" {
+		releaseBlob(imageRecord.ContentHash)
+		return
+	}
+
+	// 去重之前上传的图片独占文件，直接删除
+	deleteStoredFiles(imageRecord.FilePath, imageRecord.Thumbnail)
+	if err := imageprocessor.CleanupStoredFiles(storage.GetStorage(), storageKey(imageRecord.FilePath), blobThumbnailSizes(nil)); err != nil {
+		fmt.Printf("清理处理文件失败: %v\n", err)
+	}
+	deleteImageVariants(variantBase(imageRecord))
+}
+
+// applyBlob 将文件信息写入图片记录
+func applyBlob(imageRecord *models.Image, blob *models.Blob) {
+	imageRecord.ContentHash = blob.Hash
+	imageRecord.FilePath = blob.FilePath
+	imageRecord.Thumbnail = blob.Thumbnail
+	imageRecord.FileSize = blob.FileSize
+	imageRecord.Width = blob.Width
+	imageRecord.Height = blob.Height
+	imageRecord.PerceptualHash = blob.PerceptualHash
+	imageRecord.FrameCount = blob.FrameCount
+	imageRecord.Duration = blob.Duration
+}
+
+// duplicateMode 读取重复内容的处理方式：skip 直接返回已有图片，默认 reuse 新建记录共享文件
+func duplicateMode(c *gin.Context) string {
+	mode := c.PostForm("onDuplicate")
+	if mode == "" {
+		mode = c.Query("onDuplicate")
+	}
+	if mode == "skip" {
+		return "skip"
+	}
+	return "reuse"
+}
+
+// findDuplicateImage 查找用户已上传过的相同内容图片
+func findDuplicateImage(hash string, ownerID uint) *models.Image {
+	var existing models.Image
+	if err := database.GetDB().Where("content_hash = ? AND owner_id = ?", hash, ownerID).
+		Order("id ASC").First(&existing).Error; err != nil {
+		return nil
+	}
+	return &existing
+}
+
+// writeBlobFiles 写入原图和缩略图
+func writeBlobFiles(hash, ext string, data []byte) (*models.Blob, error) {
+	store := storage.GetStorage()
+	ext = strings.ToLower(ext)
+
+	generation := newBlobGeneration()
+	objectPath := blobObjectPath(hash, generation, ext)
+	if _, err := store.SaveFromReader(objectPath, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("保存文件失败: %w", err)
+	}
+
+	width, height := getImageDimensions(data)
+	frameCount, duration := 1, 0
+	if info := animation.Inspect(data); info != nil {
+		frameCount, duration = info.FrameCount, info.Duration
+	}
+
+	// 生成缩略图，动图在处理上限以内时生成动态缩略图
+	thumbnailPath := ""
+	if thumb, err := utils.GenerateThumbnailFromBytes(data, ext, thumbnailWidth, animationLimits()); err != nil {
+		// 缩略图生成失败不影响主流程
+		fmt.Printf("缩略图生成失败: %v\n", err)
+	} else {
+		thumbnailPath = blobThumbnailPath(hash, generation, ext)
+		if _, err := store.SaveFromReader(thumbnailPath, bytes.NewReader(thumb), int64(len(thumb))); err != nil {
+			fmt.Printf("缩略图保存失败: %v\n", err)
+			thumbnailPath = ""
+		}
+	}
+
+	return &models.Blob{
+		Hash:       hash,
+		FilePath:   objectPath,
+		Thumbnail:  thumbnailPath,
+		FileSize:   int64(len(data)),
+		Width:      width,
+		Height:     height,
+		RefCount:   1,
+		FrameCount: frameCount,
+		Duration:   duration,
+	}, nil
+}
+
+// animationLimits 逐帧处理动图的上限
+func animationLimits() animation.Limits {
+	cfg := config.GetConfig()
+	return animation.Limits{
+		MaxFrames: cfg.AnimationMaxFrames,
+		MaxPixels: cfg.AnimationMaxPixels,
+	}
+}
+
+// deleteStoredFiles 删除图片在存储中的原图和缩略图
+func deleteStoredFiles(filePath, thumbnail string) {
+	store := storage.GetStorage()
+	if key := storageKey(filePath); key != "" {
+		if err := store.Delete(key); err != nil {
+			fmt.Printf("删除文件失败: %v\n", err)
+		}
+	}
+	if key := storageKey(thumbnail); key != "" {
+		if err := store.Delete(key); err != nil {
+			fmt.Printf("删除缩略图失败: %v\n", err)
+		}
+	}
+}
+
+// serveStoredFile 从存储后端输出文件内容
+// 已知文件大小时支持单个范围的 Range 请求
+func serveStoredFile(c *gin.Context, objectPath string, size int64, contentType string) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(objectPath))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if size > 0 {
+		c.Header("Accept-Ranges", "bytes")
+		if header := c.GetHeader("Range"); header != "" && ifRangeMatches(c) {
+			r, err := parseByteRange(header, size)
+			if err != nil {
+				c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+				c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "请求范围无法满足"})
+				return
+			}
+			if r != nil {
+				serveStoredRange(c, objectPath, size, contentType, r)
+				return
+			}
+		}
+	}
+
+	reader, err := storage.GetStorage().Get(storageKey(objectPath))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
+}
+
+// serveStoredRange 输出文件的指定范围
+func serveStoredRange(c *gin.Context, objectPath string, size int64, contentType string, r *byteRange) {
+	reader, err := storage.GetStorage().GetRange(storageKey(objectPath), r.start, r.length)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusPartialContent, r.length, contentType, reader, map[string]string{
+		"Content-Range": r.contentRange(size),
+	})
+}
+
+// serveNegotiatedImage 按 Accept 头输出预生成的 WebP/AVIF 版本
+// 只有版本存在、格式正确且比原图小时才使用，否则返回 false 由调用方输出原图
+// 只读取文件大小和文件头做判断，客户端缓存有效时不读取文件，输出时支持 Range 请求
+func serveNegotiatedImage(c *gin.Context, imageRecord *models.Image) bool {
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return false
+	}
+
+	// 旧版本为 GIF 预生成的 WebP 只有第一帧，动图（或帧数未知的 GIF）只使用同样是动图的版本
+	originalExt := strings.ToLower(path.Ext(imageRecord.FilePath))
+	requireAnimated := imageRecord.FrameCount > 1 || (imageRecord.FrameCount == 0 && originalExt == ".gif")
+
+	store := storage.GetStorage()
+	for _, format := range imageprocessor.NegotiableFormats {
+		if format.Ext == originalExt || !imageprocessor.AcceptsMIME(accept, format.MimeType) {
+			continue
+		}
+
+		objectPath := format.ObjectPath(storageKey(imageRecord.FilePath))
+		size, err := store.Size(objectPath)
+		if err != nil || size <= 0 || size >= imageRecord.FileSize {
+			continue
+		}
+
+		// 版本由原图内容生成，按内容哈希和格式判断缓存是否有效，客户端缓存的版本之前已经通过检查
+		setContentDisposition(c, imageRecord, format.Ext)
+		setValidators(c, imageETag(imageRecord, strings.TrimPrefix(format.Ext, ".")), imageRecord.UpdatedAt)
+		if checkNotModified(c) {
+			return true
+		}
+
+		header, err := readStoredHeader(objectPath, animation.WebPHeaderSize)
+		if err != nil || !format.Matches(header) {
+			continue
+		}
+		if requireAnimated && !animation.IsAnimatedWebPHeader(header) {
+			continue
+		}
+
+		serveStoredFile(c, objectPath, size, format.MimeType)
+		return true
+	}
+
+	return false
+}
+
+// readStoredHeader 读取存储中文件的前 n 个字节，文件不足 n 字节时返回整个文件
+func readStoredHeader(objectPath string, n int64) ([]byte, error) {
+	reader, err := storage.GetStorage().GetRange(objectPath, 0, n)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, n))
+}
+
+// getImageDimensions 获取图片的显示尺寸，按 EXIF Orientation 标签需要旋转时宽高互换
+func getImageDimensions(data []byte) (int, int) {
+	img, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+
+	return metadata.OrientedSize(img.Width, img.Height, metadata.Orientation(data))
+}
+
+// replaceImageContent 用新内容替换图片文件并保存记录，旧文件的引用随之释放
+// source 为按处理配置处理之前的内容，用于解析元数据；为 nil 时保留原有的元数据记录
+func replaceImageContent(imageRecord *models.Image, ext string, data, source []byte) error {
+	// 文件变大时需要占用额外的配额
+	newSize := int64(len(data))
+	delta := newSize - imageRecord.FileSize
+	if delta < 0 {
+		delta = 0
+	}
+	if err := reserveQuota(imageRecord.OwnerID, newSize, delta, 0); err != nil {
+		return err
+	}
+
+	blob, created, err := acquireBlob(contentHash(data), ext, data)
+	if err != nil {
+		releaseQuota(imageRecord.OwnerID, delta, 0)
+		return err
+	}
+
+	previous := *imageRecord
+	applyBlob(imageRecord, blob)
+	imageRecord.FileName = imageRecord.UUID + strings.ToLower(ext)
+
+	if err := database.GetDB().Save(imageRecord).Error; err != nil {
+		*imageRecord = previous
+		releaseBlob(blob.Hash)
+		releaseQuota(previous.OwnerID, delta, 0)
+		return fmt.Errorf("更新记录失败: %w", err)
+	}
+
+	if created {
+		enqueueProcessImage(blob.FilePath, albumProfileID(imageRecord.AlbumID), imageRecord.OwnerID)
+	}
+	if source != nil {
+		saveImageMetadata(imageRecord.ID, source)
+	}
+	search.IndexImages(imageRecord.ID)
+	releaseImageFiles(&previous)
+
+	// 文件变小时归还多余的配额
+	if previous.FileSize > newSize {
+		releaseQuota(previous.OwnerID, previous.FileSize-newSize, 0)
+	}
+
+	return nil
+}
+
+// convertStoredImage 转换存储中图片的格式并保存记录
+func convertStoredImage(imageRecord *models.Image, targetFormat string, quality int) error {
+	data, err := readStoredFile(imageRecord.FilePath)
+	if err != nil {
+		return fmt.Errorf("读取原图失败: %w", err)
+	}
+
+	converted, err := utils.ConvertImageBytes(data, targetFormat, quality, animationLimits())
+	if err != nil {
+		return err
+	}
+
+	targetExt := "." + strings.ToLower(strings.TrimPrefix(targetFormat, "."))
+	mimeType := imageRecord.MimeType
+	// MIME 类型按转换结果识别，目标格式写作 jpg 时也得到 image/jpeg
+	imageRecord.MimeType = utils.FormatMimeType(utils.DetectImageFormat(converted))
+	if err := replaceImageContent(imageRecord, targetExt, converted, nil); err != nil {
+		imageRecord.MimeType = mimeType
+		return err
+	}
+
+	return nil
+}
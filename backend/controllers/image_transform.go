@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/storage"
+	"imagebed/utils/imageprocessor"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// transformPool 限制同时进行的图片变换数量
+	transformPool     chan struct{}
+	transformPoolOnce sync.Once
+)
+
+// acquireTransformSlot 占用一个变换名额
+func acquireTransformSlot() func() {
+	transformPoolOnce.Do(func() {
+		size := config.GetConfig().TransformConcurrency
+		if size <= 0 {
+			size = 1
+		}
+		transformPool = make(chan struct{}, size)
+	})
+
+	transformPool <- struct{}{}
+	return func() { <-transformPool }
+}
+
+// transformLimits 变换参数的允许范围
+func transformLimits() imageprocessor.TransformLimits {
+	cfg := config.GetConfig()
+	return imageprocessor.TransformLimits{
+		MaxWidth:  cfg.TransformMaxWidth,
+		MaxHeight: cfg.TransformMaxHeight,
+		MaxBlur:   float64(cfg.TransformMaxBlur),
+	}
+}
+
+// transformSecret 变换签名密钥
+func transformSecret() string {
+	cfg := config.GetConfig()
+	if cfg.TransformSecret != "" {
+		return cfg.TransformSecret
+	}
+	return cfg.JWTSecret
+}
+
+// variantBase 变换缓存的归属键，相同内容的图片共享缓存
+func variantBase(imageRecord *models.Image) string {
+	if imageRecord.ContentHash != "" {
+		return imageRecord.ContentHash
+	}
+	return "uuid-" + imageRecord.UUID
+}
+
+// variantID 变换参数键的短哈希
+func variantID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// variantObjectPath 变换结果在存储中的路径
+func variantObjectPath(base, key, ext string) string {
+	return path.Join("transforms", base[:2], base, variantID(key)+ext)
+}
+
+// parseImageTransform 解析请求中的变换参数，没有变换参数时返回 nil
+// 参数错误时直接输出错误响应并返回 ok=false
+func parseImageTransform(c *gin.Context, imageRecord *models.Image) (opts *imageprocessor.TransformOptions, ok bool) {
+	query := c.Request.URL.Query()
+	if !imageprocessor.HasTransformParams(query) {
+		return nil, true
+	}
+
+	cfg := config.GetConfig()
+	if !cfg.TransformEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "图片变换功能未启用"})
+		return nil, false
+	}
+
+	opts, err := imageprocessor.ParseTransformOptions(query, transformLimits())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "变换参数错误: " + err.Error()})
+		return nil, false
+	}
+	opts.Normalize(path.Ext(imageRecord.FileName))
+
+	if cfg.TransformRequireSignature &&
+		!imageprocessor.VerifyTransformSignature(transformSecret(), imageRecord.UUID, opts, c.Query("s")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "变换参数签名无效"})
+		return nil, false
+	}
+
+	return opts, true
+}
+
+// serveTransformedImage 输出变换后的图片，结果按规范化的参数缓存在存储中
+// mark 不为空时在变换后叠加水印，水印参与缓存键
+func serveTransformedImage(c *gin.Context, imageRecord *models.Image, opts *imageprocessor.TransformOptions, mark *deliveryMark) {
+	db := database.GetDB()
+	store := storage.GetStorage()
+	base := variantBase(imageRecord)
+	key := opts.CacheKey()
+	if mark != nil {
+		key += "&" + mark.key
+		opts.Overlay = mark.watermark.Apply
+	}
+
+	// 变换结果由原图内容和参数唯一确定，缓存有效时无需读取或生成
+	setContentDisposition(c, imageRecord, opts.Extension())
+	setValidators(c, imageETag(imageRecord, variantID(key)), imageRecord.UpdatedAt)
+	if checkNotModified(c) {
+		c.Header("Cache-Control", deliveryCacheControl(c, imageRecord))
+		return
+	}
+
+	var variant models.ImageVariant
+	if err := db.Where("content_hash = ? AND transform_key = ?", base, key).First(&variant).Error; err == nil {
+		if reader, err := store.Get(variant.FilePath); err == nil {
+			defer reader.Close()
+			c.Header("Cache-Control", deliveryCacheControl(c, imageRecord))
+			c.DataFromReader(http.StatusOK, variant.FileSize, variant.MimeType, reader, nil)
+			return
+		}
+		// 缓存文件丢失，重新生成
+		db.Delete(&variant)
+	}
+
+	cfg := config.GetConfig()
+	if cfg.TransformMaxSourcePixels > 0 &&
+		int64(imageRecord.Width)*int64(imageRecord.Height) > cfg.TransformMaxSourcePixels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原图尺寸过大，不支持变换"})
+		return
+	}
+
+	release := acquireTransformSlot()
+	data, err := readStoredFile(imageRecord.FilePath)
+	if err != nil {
+		release()
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	output, err := opts.Transform(data, animationLimits())
+	release()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "图片变换失败: " + err.Error()})
+		return
+	}
+
+	// 写入缓存失败不影响本次响应
+	objectPath := variantObjectPath(base, key, opts.Extension())
+	if _, err := store.SaveFromReader(objectPath, bytes.NewReader(output), int64(len(output))); err != nil {
+		fmt.Printf("保存变换结果失败: %v\n", err)
+	} else {
+		db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ImageVariant{
+			ContentHash: base,
+			Key:         key,
+			FilePath:    objectPath,
+			FileSize:    int64(len(output)),
+			MimeType:    opts.ContentType(),
+		})
+	}
+
+	c.Header("Cache-Control", deliveryCacheControl(c, imageRecord))
+	c.Data(http.StatusOK, opts.ContentType(), output)
+}
+
+// deleteImageVariants 删除图片内容对应的全部变换缓存
+func deleteImageVariants(base string) {
+	db := database.GetDB()
+	store := storage.GetStorage()
+
+	var variants []models.ImageVariant
+	if err := db.Where("content_hash = ?", base).Find(&variants).Error; err != nil {
+		return
+	}
+
+	for _, variant := range variants {
+		if err := store.Delete(variant.FilePath); err != nil {
+			fmt.Printf("删除变换缓存失败: %v\n", err)
+		}
+	}
+	db.Where("content_hash = ?", base).Delete(&models.ImageVariant{})
+}
+
+// GetTransformURL 生成带签名的图片变换地址
+func GetTransformURL(c *gin.Context) {
+	var imageRecord models.Image
+	if err := database.GetDB().First(&imageRecord, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	opts, err := imageprocessor.ParseTransformOptions(c.Request.URL.Query(), transformLimits())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "变换参数错误: " + err.Error()})
+		return
+	}
+	if opts == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少变换参数"})
+		return
+	}
+	opts.Normalize(path.Ext(imageRecord.FileName))
+
+	query := opts.Query()
+	query.Set("s", imageprocessor.SignTransform(transformSecret(), imageRecord.UUID, opts))
+	imagePath := generateImageURL(imageRecord.UUID) + "?" + query.Encode()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"url":  buildImageURL(imagePath),
+			"path": imagePath,
+		},
+	})
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"imagebed/config"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportURLs     = 20  // 批量导入时一次最多导入的地址数量
+	importConcurrency = 4   // 批量导入时同时下载的数量
+	maxImportNameLen  = 200 // 从地址中取出的文件名的最大长度
+)
+
+// errNotImage 远程文件不是支持的图片格式
+var errNotImage = errors.New("远程文件不是支持的图片格式")
+
+// importFetcher 创建远程导入使用的下载器，文件大小上限与普通上传一致
+var importFetcher = func() *utils.RemoteFetcher {
+	cfg := config.GetConfig()
+	return utils.NewRemoteFetcher(cfg.MaxFileSize*1024*1024, cfg.ImportURLTimeout)
+}
+
+// ImportURLRequest 从远程地址导入图片请求，url 和 urls 二选一
+type ImportURLRequest struct {
+	URL     string   `json:"url"`
+	URLs    []string `json:"urls"`    // 批量导入
+	AlbumID uint     `json:"albumId"` // 为空时导入到默认相册
+}
+
+// remoteImage 下载并按内容识别格式的远程图片
+type remoteImage struct {
+	url  string
+	name string
+	data []byte
+	err  error
+}
+
+// fetchRemoteImage 下载远程图片并按文件头识别格式，不信任地址中的扩展名和响应头中的类型
+// 文件名取自地址，扩展名与识别出的格式不一致时改为识别出的格式；完整的内容校验在创建图片时进行
+func fetchRemoteImage(ctx context.Context, fetcher *utils.RemoteFetcher, rawURL string) *remoteImage {
+	result := &remoteImage{url: rawURL}
+	file, err := fetcher.Fetch(ctx, strings.TrimSpace(rawURL))
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	format := utils.DetectImageFormat(file.Data)
+	ext := utils.FormatExt(format)
+	if format == "" || !utils.IsSupportedFormat(ext) {
+		result.err = errNotImage
+		return result
+	}
+
+	name := file.FileName
+	if !utils.ExtMatchesFormat(path.Ext(name), format) {
+		name = strings.TrimSuffix(name, path.Ext(name)) + ext
+	}
+	if name == ext || utf8.RuneCountInString(name) > maxImportNameLen {
+		name = "image" + ext
+	}
+
+	result.name = name
+	result.data = file.Data
+	return result
+}
+
+// importErrorResponse 下载失败时的状态码和提示
+// 地址不允许访问、文件过大或不是图片时为请求错误，远程服务器无法访问时为网关错误
+func importErrorResponse(err error) (int, string) {
+	switch {
+	case errors.Is(err, utils.ErrRemoteTooLarge):
+		return http.StatusBadRequest, fmt.Sprintf("文件大小不能超过 %dMB", config.GetConfig().MaxFileSize)
+	case errors.Is(err, utils.ErrBlockedAddress), errors.Is(err, utils.ErrInvalidURL), errors.Is(err, errNotImage):
+		return http.StatusBadRequest, err.Error()
+	default:
+		return http.StatusBadGateway, err.Error()
+	}
+}
+
+// ImportImageFromURL 下载远程图片并按普通上传流程创建图片记录
+// 只允许访问公网地址，重定向后的地址同样检查；文件大小上限与普通上传一致，下载时边读边检查
+// 支持与普通上传相同的 onDuplicate、enableShortLink 参数
+// 指定 urls 时批量导入，并发下载，返回每个地址的结果
+func ImportImageFromURL(c *gin.Context) {
+	var req ImportURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if (req.URL == "") == (len(req.URLs) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定 url 或 urls 其中之一"})
+		return
+	}
+	if len(req.URLs) > maxImportURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多导入 %d 个地址", maxImportURLs)})
+		return
+	}
+	if req.AlbumID == 0 {
+		req.AlbumID = 1 // 默认相册
+	}
+
+	album, ok := loadUploadAlbum(c, req.AlbumID)
+	if !ok {
+		return
+	}
+	userID, _ := requestUser(c)
+	fetcher := importFetcher()
+
+	if req.URL != "" {
+		remote := fetchRemoteImage(c.Request.Context(), fetcher, req.URL)
+		if remote.err != nil {
+			status, message := importErrorResponse(remote.err)
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		saveUploadedImage(c, album, userID, remote.name, remote.data)
+		return
+	}
+
+	importBatch(c, fetcher, album, userID, req.URLs)
+}
+
+// importBatch 并发下载多个远程图片，下载完成后逐个创建图片记录
+// 下载结果不缓存，同时在内存中的图片不超过下载并发数
+func importBatch(c *gin.Context, fetcher *utils.RemoteFetcher, album *models.Album, userID uint, urls []string) {
+	queue := make(chan string)
+	results := make(chan *remoteImage)
+	var wg sync.WaitGroup
+	for i := 0; i < importConcurrency && i < len(urls); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawURL := range queue {
+				results <- fetchRemoteImage(c.Request.Context(), fetcher, rawURL)
+			}
+		}()
+	}
+	go func() {
+		for _, rawURL := range urls {
+			queue <- rawURL
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	images := []models.Image{}
+	failures := []string{}
+	duplicates := []gin.H{}
+	for remote := range results {
+		if remote.err != nil {
+			_, message := importErrorResponse(remote.err)
+			failures = append(failures, fmt.Sprintf("%s: %s", remote.url, message))
+			continue
+		}
+
+		outcome, err := createUploadedImage(c, album, userID, remote.name, remote.data)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", remote.url, err))
+			continue
+		}
+		if outcome.existing != nil {
+			duplicates = append(duplicates, gin.H{
+				"url":          remote.url,
+				"existingUuid": outcome.existing.UUID,
+				"skipped":      outcome.image == nil,
+			})
+		}
+		if outcome.image != nil {
+			images = append(images, *outcome.image)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       images,
+		"errors":     failures,
+		"duplicates": duplicates,
+		"total":      len(urls),
+		"success":    len(images),
+	})
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imagebed/database"
+	"imagebed/jobs"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/utils/animation"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 任务类型
+const (
+	JobTypeProcessImage = "image.process"       // 压缩 + 生成多尺寸缩略图 + WebP
+	JobTypeConvertImage = "image.convert"       // 转换单张图片格式
+	JobTypeBatchConvert = "image.batch_convert" // 批量转换图片格式
+
+	JobTypePerceptualHash = "image.perceptual_hash" // 为没有感知哈希的图片计算哈希
+
+	JobTypeReprocessAlbum = "album.reprocess" // 按相册的处理配置重新处理已有图片
+)
+
+// processImagePayload 图片处理任务参数
+type processImagePayload struct {
+	FilePath  string `json:"filePath"`
+	ProfileID uint   `json:"profileId,omitempty"` // 处理配置，为 0 时使用系统默认配置
+}
+
+// convertImagePayload 格式转换任务参数
+type convertImagePayload struct {
+	ImageIDs     []uint `json:"imageIds"`
+	TargetFormat string `json:"targetFormat"`
+	Quality      int    `json:"quality"`
+}
+
+// batchConvertResult 批量转换结果
+type batchConvertResult struct {
+	Converted []uint   `json:"converted"`
+	Skipped   []uint   `json:"skipped"`
+	Errors    []string `json:"errors"`
+}
+
+// JobResponse 任务信息，参数和结果以 JSON 对象返回
+type JobResponse struct {
+	models.Job
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+func newJobResponse(job *models.Job) JobResponse {
+	resp := JobResponse{Job: *job}
+	if job.Payload != "" {
+		resp.Payload = json.RawMessage(job.Payload)
+	}
+	if job.Result != "" {
+		resp.Result = json.RawMessage(job.Result)
+	}
+	return resp
+}
+
+// RegisterJobHandlers 注册后台任务处理函数
+func RegisterJobHandlers() {
+	jobs.Register(JobTypeProcessImage, handleProcessImage)
+	jobs.Register(JobTypeConvertImage, handleConvertImage)
+	jobs.Register(JobTypeBatchConvert, handleConvertImage)
+	jobs.Register(JobTypePerceptualHash, handlePerceptualHash)
+	jobs.Register(JobTypeReprocessAlbum, handleReprocessAlbum)
+}
+
+// enqueueProcessImage 按处理配置提交图片处理任务，提交失败不影响上传结果
+func enqueueProcessImage(filePath string, profileID, userID uint) *models.Job {
+	payload := processImagePayload{FilePath: filePath, ProfileID: profileID}
+	job, err := jobs.Enqueue(JobTypeProcessImage, payload, jobs.WithUser(userID))
+	if err != nil {
+		logger.Error("提交图片处理任务失败", zap.String("file", filePath), zap.Error(err))
+		return nil
+	}
+	return job
+}
+
+// handleProcessImage 按处理配置生成缩略图和 WebP 等格式版本
+func handleProcessImage(ctx context.Context, job *models.Job) (interface{}, error) {
+	var payload processImagePayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	// 文件已被彻底删除时无需处理
+	if !storedFileExists(payload.FilePath) {
+		return gin.H{"skipped": true}, nil
+	}
+
+	// 感知哈希用于查找相似图片，计算失败不影响其他处理
+	if _, err := savePerceptualHash(payload.FilePath); err != nil {
+		logger.Error("计算感知哈希失败", zap.String("file", payload.FilePath), zap.Error(err))
+	}
+
+	if err := processStoredFile(payload.FilePath, loadProcessingProfile(payload.ProfileID)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// handleConvertImage 转换图片格式，已经是目标格式的图片直接跳过，重试时不会重复转换
+func handleConvertImage(ctx context.Context, job *models.Job) (interface{}, error) {
+	var payload convertImagePayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	targetExt := "." + strings.ToLower(strings.TrimPrefix(payload.TargetFormat, "."))
+	result := batchConvertResult{
+		Converted: []uint{},
+		Skipped:   []uint{},
+		Errors:    []string{},
+	}
+	retryable := false
+
+	for _, imageID := range payload.ImageIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var imageRecord models.Image
+		if err := database.GetDB().First(&imageRecord, imageID).Error; err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("图片ID %d 不存在", imageID))
+			retryable = true
+			continue
+		}
+
+		if strings.ToLower(filepath.Ext(imageRecord.FileName)) == targetExt {
+			result.Skipped = append(result.Skipped, imageID)
+			continue
+		}
+
+		if err := convertStoredImage(&imageRecord, payload.TargetFormat, payload.Quality); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("图片ID %d 转换失败: %v", imageID, err))
+			// 动图不能转换为静态格式或超过处理上限，重试也不会成功
+			if !errors.Is(err, animation.ErrStaticFormat) && !errors.Is(err, animation.ErrTooLarge) {
+				retryable = true
+			}
+			continue
+		}
+
+		result.Converted = append(result.Converted, imageID)
+		clearImageListCache(uint64(imageRecord.AlbumID))
+	}
+
+	// 全部失败时重试，部分失败记录在结果中
+	if len(result.Converted) == 0 && len(result.Skipped) == 0 && len(result.Errors) > 0 {
+		err := errors.New(strings.Join(result.Errors, "; "))
+		if !retryable {
+			return nil, jobs.Permanent(err)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetJob 获取任务状态
+func GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	job, err := jobs.Get(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	isAdmin, _ := c.Get("isAdmin")
+	if job.UserID != userID.(uint) && isAdmin != true {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": newJobResponse(job)})
+}
+
+// GetJobs 获取任务列表（管理员），status=dead 即死信列表
+func GetJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := database.GetDB().Model(&models.Job{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取任务列表失败"})
+		return
+	}
+
+	var list []models.Job
+	if err := query.Session(&gorm.Session{}).Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取任务列表失败"})
+		return
+	}
+
+	data := make([]JobResponse, 0, len(list))
+	for i := range list {
+		data = append(data, newJobResponse(&list[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     data,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// RetryJob 重新执行死信列表中的任务（管理员）
+func RetryJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	job, err := jobs.Retry(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": newJobResponse(job)})
+}
@@ -0,0 +1,557 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imagebed/database"
+	"imagebed/jobs"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/storage"
+	"imagebed/utils"
+	"imagebed/utils/imageprocessor"
+	"imagebed/utils/metadata"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 处理配置的取值范围
+const (
+	maxProfileThumbnails    = 10   // 最多缩略图尺寸数量
+	maxProfileThumbnailSize = 4096 // 缩略图最大宽高
+	maxProfileDimension     = 20000
+	reprocessBatchSize      = 100
+)
+
+// profileThumbnailName 缩略图尺寸名称会作为文件名后缀，只允许小写字母、数字、下划线和连字符
+var profileThumbnailName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,19}$`)
+
+// ProcessingProfileRequest 创建、修改处理配置请求，修改时整体替换
+type ProcessingProfileRequest struct {
+	Name           string                    `json:"name" binding:"required"`
+	Description    string                    `json:"description"`
+	IsDefault      bool                      `json:"isDefault"`
+	ThumbnailSizes []models.ProfileThumbnail `json:"thumbnailSizes"`
+	Formats        []string                  `json:"formats"`
+	Quality        int                       `json:"quality"`
+	MaxWidth       int                       `json:"maxWidth"`
+	MaxHeight      int                       `json:"maxHeight"`
+	StripMetadata  bool                      `json:"stripMetadata"`
+
+	Watermark *models.WatermarkSettings `json:"watermark"`
+}
+
+// reprocessAlbumPayload 相册重新处理任务参数
+type reprocessAlbumPayload struct {
+	AlbumID uint `json:"albumId"`
+}
+
+// reprocessAlbumResult 相册重新处理结果
+type reprocessAlbumResult struct {
+	ProfileID uint     `json:"profileId"`
+	Processed int      `json:"processed"` // 重新生成缩略图和其他格式版本的文件数
+	Replaced  []uint   `json:"replaced"`  // 原图按配置缩小或删除元数据的图片
+	Errors    []string `json:"errors"`
+}
+
+// builtinProcessingProfile 没有设置系统默认配置时使用的内置配置
+func builtinProcessingProfile() *models.ProcessingProfile {
+	profile := &models.ProcessingProfile{
+		Name:    "builtin",
+		Formats: slices.Clone(imageprocessor.OutputFormats),
+		Quality: 85,
+	}
+	for _, size := range imageprocessor.ThumbnailSizes {
+		profile.ThumbnailSizes = append(profile.ThumbnailSizes, models.ProfileThumbnail{
+			Name:   size.Name,
+			Width:  int(size.Width),
+			Height: int(size.Height),
+		})
+	}
+	return profile
+}
+
+// loadProcessingProfile 加载处理配置，id 为 0 或配置已删除时使用系统默认配置
+func loadProcessingProfile(id uint) *models.ProcessingProfile {
+	db := database.GetDB()
+
+	var profile models.ProcessingProfile
+	if id != 0 && db.First(&profile, id).Error == nil {
+		return &profile
+	}
+	if db.Where("is_default = ?", true).Order("id").First(&profile).Error == nil {
+		return &profile
+	}
+	return builtinProcessingProfile()
+}
+
+// albumProfileID 相册指定的处理配置，未指定时为 0
+func albumProfileID(albumID uint) uint {
+	var album models.Album
+	if err := database.GetDB().Select("id", "processing_profile_id").First(&album, albumID).Error; err != nil ||
+		album.ProcessingProfileID == nil {
+		return 0
+	}
+	return *album.ProcessingProfileID
+}
+
+// albumProcessingProfile 相册使用的处理配置
+func albumProcessingProfile(album *models.Album) *models.ProcessingProfile {
+	if album.ProcessingProfileID == nil {
+		return loadProcessingProfile(0)
+	}
+	return loadProcessingProfile(*album.ProcessingProfileID)
+}
+
+// newProfileProcessor 按处理配置创建图片处理器
+func newProfileProcessor(profile *models.ProcessingProfile) *imageprocessor.ImageProcessor {
+	processor := imageprocessor.NewImageProcessor(profile.Quality)
+	processor.Animation = animationLimits()
+	processor.WebP = slices.Contains(profile.Formats, "webp")
+	processor.ThumbnailSizes = make([]imageprocessor.ThumbnailSize, 0, len(profile.ThumbnailSizes))
+	for _, size := range profile.ThumbnailSizes {
+		processor.ThumbnailSizes = append(processor.ThumbnailSizes, imageprocessor.ThumbnailSize{
+			Name:   size.Name,
+			Width:  uint(size.Width),
+			Height: uint(size.Height),
+		})
+	}
+	return processor
+}
+
+// applyUploadProfile 按处理配置处理上传的原图：超过最大尺寸时缩小，需要时删除敏感元数据
+// 处理失败时保留原图，不影响上传
+func applyUploadProfile(profile *models.ProcessingProfile, ext string, data []byte) []byte {
+	fitted, changed, err := utils.FitImageBytes(data, ext, profile.MaxWidth, profile.MaxHeight, profile.Quality, animationLimits())
+	if err != nil {
+		logger.Error("按处理配置缩小原图失败", zap.String("profile", profile.Name), zap.Error(err))
+	} else if changed {
+		data = fitted
+	}
+
+	if profile.StripMetadata {
+		data, _ = metadata.Strip(data)
+	}
+	return data
+}
+
+// blobThumbnailSizes 文件已生成的缩略图尺寸名称
+func blobThumbnailSizes(blob *models.Blob) []string {
+	if blob == nil || blob.ThumbnailSizes == "" {
+		return imageprocessor.ThumbnailSizeNames(imageprocessor.ThumbnailSizes)
+	}
+	return strings.Split(blob.ThumbnailSizes, ",")
+}
+
+// processedThumbnailPath 后台处理生成的指定尺寸缩略图路径，没有该尺寸时返回空
+func processedThumbnailPath(imageRecord *models.Image, size string) string {
+	var blob *models.Blob
+	if imageRecord.ContentHash != "" {
+		blob = &models.Blob{}
+		if err := database.GetDB().Where("hash = ?", imageRecord.ContentHash).First(blob).Error; err != nil {
+			return ""
+		}
+	}
+	if !slices.Contains(blobThumbnailSizes(blob), size) {
+		return ""
+	}
+
+	sized := imageprocessor.ThumbnailObjectPath(storageKey(imageRecord.FilePath), size)
+	if !storedFileExists(sized) {
+		return ""
+	}
+	return sized
+}
+
+// processStoredFile 按处理配置生成缩略图和其他格式版本，并删除之前的配置生成、现在不再需要的文件
+// 内容相同的图片共享处理结果，以最近一次处理使用的配置为准
+func processStoredFile(filePath string, profile *models.ProcessingProfile) error {
+	store := storage.GetStorage()
+	objectPath := storageKey(filePath)
+	processor := newProfileProcessor(profile)
+	if err := processor.ProcessStoredImage(store, objectPath); err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	sizes := imageprocessor.ThumbnailSizeNames(processor.ThumbnailSizes)
+	var previous []string
+	var blob models.Blob
+	if err := db.Where("file_path = ?", filePath).First(&blob).Error; err == nil {
+		previous = blobThumbnailSizes(&blob)
+		db.Model(&blob).Update("thumbnail_sizes", strings.Join(sizes, ","))
+	} else {
+		previous = blobThumbnailSizes(nil)
+	}
+
+	for _, size := range previous {
+		if !slices.Contains(sizes, size) {
+			store.Delete(imageprocessor.ThumbnailObjectPath(objectPath, size))
+		}
+	}
+	if webpObject := imageprocessor.WebPObjectPath(objectPath); !processor.WebP && webpObject != objectPath {
+		store.Delete(webpObject)
+	}
+	return nil
+}
+
+// validateProcessingProfile 校验处理配置请求
+func validateProcessingProfile(req *ProcessingProfileRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return errors.New("配置名称不能为空且不能超过 100 个字符")
+	}
+	if req.Quality == 0 {
+		req.Quality = 85
+	}
+	if req.Quality < 1 || req.Quality > 100 {
+		return errors.New("压缩质量取值范围为 1-100")
+	}
+	if req.MaxWidth < 0 || req.MaxWidth > maxProfileDimension || req.MaxHeight < 0 || req.MaxHeight > maxProfileDimension {
+		return fmt.Errorf("原图最大尺寸取值范围为 0-%d，0 表示不限制", maxProfileDimension)
+	}
+
+	if len(req.ThumbnailSizes) > maxProfileThumbnails {
+		return fmt.Errorf("最多 %d 种缩略图尺寸", maxProfileThumbnails)
+	}
+	seen := make(map[string]bool, len(req.ThumbnailSizes))
+	for _, size := range req.ThumbnailSizes {
+		if !profileThumbnailName.MatchString(size.Name) {
+			return fmt.Errorf("缩略图尺寸名称 %q 无效，只能包含小写字母、数字、下划线和连字符", size.Name)
+		}
+		if seen[size.Name] {
+			return fmt.Errorf("缩略图尺寸名称 %q 重复", size.Name)
+		}
+		seen[size.Name] = true
+		if size.Width < 1 || size.Width > maxProfileThumbnailSize || size.Height < 1 || size.Height > maxProfileThumbnailSize {
+			return fmt.Errorf("缩略图 %s 的宽高取值范围为 1-%d", size.Name, maxProfileThumbnailSize)
+		}
+	}
+
+	formats := make([]string, 0, len(req.Formats))
+	for _, format := range req.Formats {
+		format = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if !slices.Contains(imageprocessor.OutputFormats, format) {
+			return fmt.Errorf("不支持预生成的格式: %s，可选 %s", format, strings.Join(imageprocessor.OutputFormats, "/"))
+		}
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	req.Formats = formats
+	if req.ThumbnailSizes == nil {
+		req.ThumbnailSizes = []models.ProfileThumbnail{}
+	}
+	// 处理配置只有管理员可以修改，可以使用任何图片作为水印
+	return validateWatermarkSettings(req.Watermark, 0, true)
+}
+
+// saveProcessingProfile 保存处理配置，设为默认时取消其他配置的默认标记
+func saveProcessingProfile(profile *models.ProcessingProfile) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(profile).Error; err != nil {
+			return err
+		}
+		if !profile.IsDefault {
+			return nil
+		}
+		return tx.Model(&models.ProcessingProfile{}).
+			Where("id <> ? AND is_default = ?", profile.ID, true).
+			Update("is_default", false).Error
+	})
+}
+
+// profileNameTaken 配置名称是否已被其他配置使用
+func profileNameTaken(name string, excludeID uint) bool {
+	var count int64
+	database.GetDB().Model(&models.ProcessingProfile{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count)
+	return count > 0
+}
+
+// GetProcessingProfiles 获取处理配置列表（管理员），同时返回没有设置默认配置时使用的内置配置
+func GetProcessingProfiles(c *gin.Context) {
+	var profiles []models.ProcessingProfile
+	if err := database.GetDB().Order("id").Find(&profiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取处理配置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    profiles,
+		"builtin": builtinProcessingProfile(),
+	})
+}
+
+// GetProcessingProfile 获取处理配置详情（管理员）
+func GetProcessingProfile(c *gin.Context) {
+	var profile models.ProcessingProfile
+	if err := database.GetDB().First(&profile, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "处理配置不存在"})
+		return
+	}
+
+	var albumCount int64
+	database.GetDB().Model(&models.Album{}).Where("processing_profile_id = ?", profile.ID).Count(&albumCount)
+
+	c.JSON(http.StatusOK, gin.H{"data": profile, "albumCount": albumCount})
+}
+
+// CreateProcessingProfile 创建处理配置（管理员）
+func CreateProcessingProfile(c *gin.Context) {
+	var req ProcessingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := validateProcessingProfile(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if profileNameTaken(req.Name, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": "配置名称已存在"})
+		return
+	}
+
+	profile := models.ProcessingProfile{}
+	applyProfileRequest(&profile, &req)
+	if err := saveProcessingProfile(&profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建处理配置失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": profile})
+}
+
+// UpdateProcessingProfile 修改处理配置（管理员）
+// 只影响之后上传和处理的图片，已有图片需要重新处理相册
+func UpdateProcessingProfile(c *gin.Context) {
+	db := database.GetDB()
+	var profile models.ProcessingProfile
+	if err := db.First(&profile, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "处理配置不存在"})
+		return
+	}
+
+	var req ProcessingProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := validateProcessingProfile(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if profileNameTaken(req.Name, profile.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "配置名称已存在"})
+		return
+	}
+
+	applyProfileRequest(&profile, &req)
+	if err := saveProcessingProfile(&profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改处理配置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+// DeleteProcessingProfile 删除处理配置（管理员），使用该配置的相册改为使用系统默认配置
+func DeleteProcessingProfile(c *gin.Context) {
+	db := database.GetDB()
+	var profile models.ProcessingProfile
+	if err := db.First(&profile, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "处理配置不存在"})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Album{}).Where("processing_profile_id = ?", profile.ID).
+			Update("processing_profile_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&profile).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除处理配置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// applyProfileRequest 将请求写入处理配置
+func applyProfileRequest(profile *models.ProcessingProfile, req *ProcessingProfileRequest) {
+	profile.Name = req.Name
+	profile.Description = req.Description
+	profile.IsDefault = req.IsDefault
+	profile.ThumbnailSizes = req.ThumbnailSizes
+	profile.Formats = req.Formats
+	profile.Quality = req.Quality
+	profile.MaxWidth = req.MaxWidth
+	profile.MaxHeight = req.MaxHeight
+	profile.StripMetadata = req.StripMetadata
+	profile.Watermark = req.Watermark
+}
+
+// validateAlbumProfile 校验相册指定的处理配置是否存在，value 为空表示使用系统默认配置
+func validateAlbumProfile(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	var id uint64
+	switch v := value.(type) {
+	case uint:
+		id = uint64(v)
+	case float64:
+		if v < 1 || v != float64(uint64(v)) {
+			return errors.New("处理配置ID无效")
+		}
+		id = uint64(v)
+	case string:
+		var err error
+		if id, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return errors.New("处理配置ID无效")
+		}
+	default:
+		return errors.New("处理配置ID无效")
+	}
+
+	var count int64
+	database.GetDB().Model(&models.ProcessingProfile{}).Where("id = ?", id).Count(&count)
+	if count == 0 {
+		return errors.New("处理配置不存在")
+	}
+	return nil
+}
+
+// ReprocessAlbum 按相册当前的处理配置重新处理相册中的已有图片
+func ReprocessAlbum(c *gin.Context) {
+	var album models.Album
+	if err := database.GetDB().First(&album, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+		return
+	}
+
+	userID, _ := requestUser(c)
+	job, err := jobs.Enqueue(JobTypeReprocessAlbum, reprocessAlbumPayload{AlbumID: album.ID}, jobs.WithUser(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交任务失败"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    202,
+		"message": "相册重新处理任务已提交",
+		"data":    newJobResponse(job),
+	})
+}
+
+// handleReprocessAlbum 按相册的处理配置重新处理已有图片
+// 原图超过最大尺寸或需要删除元数据时替换原图（替换后的新文件由图片处理任务处理），
+// 其余图片重新生成缩略图和其他格式版本
+func handleReprocessAlbum(ctx context.Context, job *models.Job) (interface{}, error) {
+	var payload reprocessAlbumPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	db := database.GetDB()
+	var album models.Album
+	if err := db.First(&album, payload.AlbumID).Error; err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("相册 %d 不存在", payload.AlbumID))
+	}
+
+	profile := albumProcessingProfile(&album)
+	result := reprocessAlbumResult{
+		ProfileID: profile.ID,
+		Replaced:  []uint{},
+		Errors:    []string{},
+	}
+	processed := make(map[string]bool)
+	var lastID uint
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var images []models.Image
+		if err := db.Where("album_id = ? AND id > ?", album.ID, lastID).
+			Order("id").Limit(reprocessBatchSize).Find(&images).Error; err != nil {
+			return nil, err
+		}
+		if len(images) == 0 {
+			break
+		}
+		lastID = images[len(images)-1].ID
+
+		for i := range images {
+			imageRecord := &images[i]
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			replaced, err := reprocessImageContent(imageRecord, profile)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("图片ID %d 处理失败: %v", imageRecord.ID, err))
+				continue
+			}
+			if replaced {
+				result.Replaced = append(result.Replaced, imageRecord.ID)
+				continue
+			}
+
+			if processed[imageRecord.FilePath] {
+				continue
+			}
+			processed[imageRecord.FilePath] = true
+			if err := processStoredFile(imageRecord.FilePath, profile); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("图片ID %d 处理失败: %v", imageRecord.ID, err))
+				continue
+			}
+			result.Processed++
+		}
+	}
+
+	clearImageListCache(uint64(album.ID))
+
+	// 全部失败时重试，部分失败记录在结果中
+	if result.Processed == 0 && len(result.Replaced) == 0 && len(result.Errors) > 0 {
+		return nil, errors.New(strings.Join(result.Errors, "; "))
+	}
+	return result, nil
+}
+
+// reprocessImageContent 原图不符合处理配置时按配置处理并替换原图
+func reprocessImageContent(imageRecord *models.Image, profile *models.ProcessingProfile) (bool, error) {
+	if profile.MaxWidth <= 0 && profile.MaxHeight <= 0 && !profile.StripMetadata {
+		return false, nil
+	}
+
+	data, err := readStoredFile(imageRecord.FilePath)
+	if err != nil {
+		return false, fmt.Errorf("读取原图失败: %w", err)
+	}
+
+	ext := strings.ToLower(path.Ext(imageRecord.FilePath))
+	output := applyUploadProfile(profile, ext, data)
+	if bytes.Equal(output, data) {
+		return false, nil
+	}
+
+	if err := replaceImageContent(imageRecord, ext, output, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	apperrors "imagebed/errors"
+	"imagebed/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// userQuota 计算用户生效的配额
+func userQuota(user *models.User) models.UserQuota {
+	cfg := config.GetConfig()
+
+	defaultFileSize := cfg.DefaultUserFileSize
+	if defaultFileSize <= 0 {
+		defaultFileSize = cfg.MaxFileSize
+	}
+
+	return user.EffectiveQuota(
+		cfg.DefaultStorageQuota*1024*1024,
+		cfg.DefaultImageQuota,
+		defaultFileSize*1024*1024,
+	)
+}
+
+// loadUserQuota 读取用户生效的配额
+func loadUserQuota(userID uint) (models.UserQuota, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		return models.UserQuota{}, apperrors.New(apperrors.ErrUserNotFound, "")
+	}
+	return userQuota(&user), nil
+}
+
+// checkFileSizeQuota 检查单个文件是否超过用户的文件大小上限
+func checkFileSizeQuota(quota models.UserQuota, size int64) error {
+	if quota.MaxFileSize > 0 && size > quota.MaxFileSize {
+		return apperrors.New(apperrors.ErrImageTooLarge,
+			fmt.Sprintf("单个文件不能超过 %dMB", quota.MaxFileSize/1024/1024))
+	}
+	return nil
+}
+
+// reserveQuota 原子地占用用户配额，超出配额时返回 ErrStorageQuotaExceeded
+// fileSize 为单个文件大小（用于文件大小上限检查），size 为需要占用的存储空间
+// 占用成功后如果后续步骤失败，需要调用 releaseQuota 归还
+func reserveQuota(userID uint, fileSize, size, count int64) error {
+	quota, err := loadUserQuota(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkFileSizeQuota(quota, fileSize); err != nil {
+		return err
+	}
+
+	// 条件更新保证并发上传时不会超出配额
+	query := database.GetDB().Model(&models.User{}).Where("id = ?", userID)
+	if quota.StorageQuota > 0 && size > 0 {
+		query = query.Where("storage_used + ? <= ?", size, quota.StorageQuota)
+	}
+	if quota.ImageQuota > 0 && count > 0 {
+		query = query.Where("image_count + ? <= ?", count, quota.ImageQuota)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"storage_used": gorm.Expr("storage_used + ?", size),
+		"image_count":  gorm.Expr("image_count + ?", count),
+	})
+	if result.Error != nil {
+		return apperrors.New(apperrors.ErrInternalServer, result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return apperrors.New(apperrors.ErrStorageQuotaExceeded, quotaUsageDetail(quota))
+	}
+
+	return nil
+}
+
+// quotaUsageDetail 配额用量说明
+func quotaUsageDetail(quota models.UserQuota) string {
+	var parts []string
+	if quota.StorageQuota > 0 {
+		parts = append(parts, fmt.Sprintf("已用空间 %d/%d 字节", quota.StorageUsed, quota.StorageQuota))
+	}
+	if quota.ImageQuota > 0 {
+		parts = append(parts, fmt.Sprintf("已上传 %d/%d 张", quota.ImageCount, quota.ImageQuota))
+	}
+	return strings.Join(parts, "，")
+}
+
+// releaseQuota 归还用户配额
+func releaseQuota(userID uint, size int64, count int64) {
+	database.GetDB().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"storage_used": gorm.Expr("CASE WHEN storage_used > ? THEN storage_used - ? ELSE 0 END", size, size),
+		"image_count":  gorm.Expr("CASE WHEN image_count > ? THEN image_count - ? ELSE 0 END", count, count),
+	})
+}
+
+// respondUploadError 输出配额不足、格式不正确等 *apperrors.AppError 错误，其他错误按普通上传失败处理
+// 除 code、message 外同时返回 error 字段，与其他上传错误一致，只读取 error 字段的客户端也能显示原因
+func respondUploadError(c *gin.Context, err error) {
+	if appErr, ok := err.(*apperrors.AppError); ok {
+		c.JSON(appErr.HTTPStatus, gin.H{
+			"error":   appErr.Error(),
+			"code":    appErr.Code,
+			"message": appErr.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "上传失败"})
+}
+
+// UpdateUserQuotaRequest 更新用户配额请求
+// 0 表示使用系统默认值，-1 表示不限制
+type UpdateUserQuotaRequest struct {
+	StorageQuota *int64 `json:"storageQuota"` // 字节
+	ImageQuota   *int64 `json:"imageQuota"`
+	MaxFileSize  *int64 `json:"maxFileSize"` // 字节
+}
+
+// GetUserQuota 获取用户配额及用量（管理员）
+func GetUserQuota(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"userId": user.ID,
+			"configured": gin.H{
+				"storageQuota": user.StorageQuota,
+				"imageQuota":   user.ImageQuota,
+				"maxFileSize":  user.MaxFileSize,
+			},
+			"effective": userQuota(&user),
+		},
+	})
+}
+
+// UpdateUserQuota 调整用户配额（管理员）
+func UpdateUserQuota(c *gin.Context) {
+	id := c.Param("id")
+	var req UpdateUserQuotaRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	for column, value := range map[string]*int64{
+		"storage_quota": req.StorageQuota,
+		"image_quota":   req.ImageQuota,
+		"max_file_size": req.MaxFileSize,
+	} {
+		if value == nil {
+			continue
+		}
+		if *value < -1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "配额不能小于 -1"})
+			return
+		}
+		updates[column] = *value
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有需要更新的配额"})
+		return
+	}
+
+	db := database.GetDB()
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := db.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新配额失败"})
+		return
+	}
+
+	db.First(&user, user.ID)
+	c.JSON(http.StatusOK, gin.H{"data": userQuota(&user)})
+}
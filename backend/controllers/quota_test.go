@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"imagebed/config"
+	"imagebed/database"
+	apperrors "imagebed/errors"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/storage"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPNG 生成纯色 PNG 图片
+func testPNG(c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	buf := new(bytes.Buffer)
+	png.Encode(buf, img)
+	return buf.Bytes()
+}
+
+// uploadTestImage 以指定用户身份上传图片到相册
+func uploadTestImage(r *gin.Engine, albumID uint, data []byte) (int, map[string]interface{}) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.png")
+	part.Write(data)
+	writer.WriteField("albumId", strconv.FormatUint(uint64(albumID), 10))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// TestUploadQuotaExceeded 测试超出存储空间和图片数量配额时拒绝上传
+func TestUploadQuotaExceeded(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	logger.InitLogger(t.TempDir())
+	storage.InitStorage(&storage.Config{Type: storage.StorageTypeLocal, LocalPath: t.TempDir()})
+	InitImageController(config.GetConfig())
+
+	user := createTestUser("quotatest", "password123")
+	assert.NotNil(t, user)
+	album := models.Album{Name: "配额测试", OwnerID: user.ID}
+	database.GetDB().Create(&album)
+
+	r := gin.Default()
+	r.POST("/upload", func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		c.Set("isAdmin", false)
+		c.Next()
+	}, UploadImage)
+
+	db := database.GetDB()
+	first := testPNG(color.RGBA{200, 10, 10, 255})
+
+	// 存储空间配额小于文件大小
+	db.Model(user).Update("storage_quota", int64(len(first)-1))
+	code, response := uploadTestImage(r, album.ID, first)
+	assert.Equal(t, http.StatusInsufficientStorage, code)
+	assert.Equal(t, float64(apperrors.ErrStorageQuotaExceeded), response["code"])
+	assert.NotEmpty(t, response["error"])
+
+	// 图片数量配额用完
+	db.Model(user).Updates(map[string]interface{}{"storage_quota": 0, "image_quota": 1})
+	code, _ = uploadTestImage(r, album.ID, first)
+	assert.Equal(t, http.StatusCreated, code)
+
+	code, response = uploadTestImage(r, album.ID, testPNG(color.RGBA{10, 200, 10, 255}))
+	assert.Equal(t, http.StatusInsufficientStorage, code)
+	assert.Equal(t, float64(apperrors.ErrStorageQuotaExceeded), response["code"])
+
+	// 拒绝的上传不占用配额，也不创建图片记录
+	var stored models.User
+	db.First(&stored, user.ID)
+	assert.Equal(t, int64(1), stored.ImageCount)
+	assert.Equal(t, int64(len(first)), stored.StorageUsed)
+
+	var count int64
+	db.Model(&models.Image{}).Where("owner_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/middleware"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// refreshTokenCookie 刷新令牌 Cookie 名称
+const refreshTokenCookie = "refresh_token"
+
+// refreshTokenCookiePath Cookie 作用路径，同时覆盖 /api 和 /api/v1
+const refreshTokenCookiePath = "/api"
+
+// RefreshTokenRequest 刷新令牌请求，未提供时从 Cookie 读取
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// issueAuthTokens 为用户签发访问令牌和刷新令牌
+// familyID 为空表示新的登录会话
+func issueAuthTokens(c *gin.Context, user *models.User, familyID string) (*AuthResponse, error) {
+	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	cfg := config.GetConfig()
+	refreshToken := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenExpiration),
+		ClientIP:  c.ClientIP(),
+		UserAgent: truncateString(c.Request.UserAgent(), 255),
+	}
+	if err := database.GetDB().Create(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+
+	if cfg.RefreshTokenCookie {
+		setRefreshCookie(c, plain, int(cfg.RefreshTokenExpiration.Seconds()))
+	}
+
+	return &AuthResponse{
+		Token:        accessToken,
+		RefreshToken: plain,
+		ExpiresIn:    int64(utils.TokenTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// setRefreshCookie 通过 HttpOnly Cookie 下发刷新令牌，maxAge 小于 0 表示删除
+func setRefreshCookie(c *gin.Context, token string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshTokenCookie, token, maxAge, refreshTokenCookiePath, "", secure, true)
+}
+
+// refreshTokenFromRequest 从请求体或 Cookie 中读取刷新令牌
+func refreshTokenFromRequest(c *gin.Context) string {
+	var req RefreshTokenRequest
+	if c.Request.ContentLength != 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+
+	token, _ := c.Cookie(refreshTokenCookie)
+	return token
+}
+
+// revokeRefreshFamily 吊销同一会话的全部刷新令牌
+func revokeRefreshFamily(familyID string) {
+	database.GetDB().Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+}
+
+// errRefreshTokenReused 刷新令牌被重复使用
+var errRefreshTokenReused = errors.New("刷新令牌已被使用，请重新登录")
+
+// consumeRefreshToken 校验刷新令牌并标记为已使用
+// 已使用的令牌再次出现时吊销整组令牌
+func consumeRefreshToken(raw string) (*models.RefreshToken, error) {
+	db := database.GetDB()
+
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", utils.HashAPIToken(raw)).First(&token).Error; err != nil {
+		return nil, errors.New("无效的刷新令牌")
+	}
+	if token.RevokedAt != nil {
+		return nil, errors.New("刷新令牌已失效，请重新登录")
+	}
+	if token.UsedAt != nil {
+		revokeRefreshFamily(token.FamilyID)
+		return nil, errRefreshTokenReused
+	}
+	if !time.Now().Before(token.ExpiresAt) {
+		return nil, errors.New("刷新令牌已过期，请重新登录")
+	}
+
+	// 条件更新，并发使用同一令牌时只有一个请求能成功
+	result := db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		revokeRefreshFamily(token.FamilyID)
+		return nil, errRefreshTokenReused
+	}
+
+	return &token, nil
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+func RefreshToken(c *gin.Context) {
+	raw := refreshTokenFromRequest(c)
+	if raw == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供刷新令牌"})
+		return
+	}
+
+	token, err := consumeRefreshToken(raw)
+	if err != nil {
+		setRefreshCookie(c, "", -1)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, token.UserID).Error; err != nil || user.Status == "disabled" {
+		revokeRefreshFamily(token.FamilyID)
+		setRefreshCookie(c, "", -1)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在或已被禁用"})
+		return
+	}
+
+	resp, err := issueAuthTokens(c, &user, token.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+// revokeRequestTokens 吊销请求携带的访问令牌和刷新令牌
+func revokeRequestTokens(c *gin.Context) error {
+	if raw := refreshTokenFromRequest(c); raw != "" {
+		var token models.RefreshToken
+		if err := database.GetDB().Where("token_hash = ?", utils.HashAPIToken(raw)).First(&token).Error; err == nil {
+			revokeRefreshFamily(token.FamilyID)
+		}
+	}
+
+	if claims := bearerClaims(c); claims != nil {
+		return middleware.RevokeToken(claims)
+	}
+	return nil
+}
+
+// truncateString 按字符截断字符串
+func truncateString(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/search"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 搜索结果每页数量
+const (
+	defaultSearchLimit = 24
+	maxSearchLimit     = 100
+	maxTagFacets       = 20
+)
+
+// searchSortColumns 排序方式对应的列，relevance 只在有搜索关键词时可用
+var searchSortColumns = map[string]string{
+	"relevance": "hits.score",
+	"date":      "images.created_at",
+	"views":     "images.view_count",
+	"size":      "images.file_size",
+}
+
+// sizeBucket 文件大小分面的区间，Max 为 0 表示没有上限
+type sizeBucket struct {
+	Label string
+	Min   int64
+	Max   int64
+}
+
+var sizeBuckets = []sizeBucket{
+	{Label: "<100KB", Min: 0, Max: 100 << 10},
+	{Label: "100KB-1MB", Min: 100 << 10, Max: 1 << 20},
+	{Label: "1MB-5MB", Min: 1 << 20, Max: 5 << 20},
+	{Label: "5MB-20MB", Min: 5 << 20, Max: 20 << 20},
+	{Label: ">20MB", Min: 20 << 20},
+}
+
+// SearchFacet 分面统计项
+type SearchFacet struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets 搜索结果的分面统计
+type SearchFacets struct {
+	Album  []SearchFacet `json:"album"`
+	Format []SearchFacet `json:"format"`
+	Tag    []SearchFacet `json:"tag"`
+	Date   []SearchFacet `json:"date"`
+	Size   []SearchFacet `json:"size"`
+}
+
+// searchCursor 游标分页位置：上一页最后一条结果的排序值和图片ID
+type searchCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// searchRequest 搜索参数
+type searchRequest struct {
+	query    string
+	albumID  uint
+	formats  []string
+	tags     []string
+	dateFrom *time.Time
+	dateTo   *time.Time
+	minSize  int64
+	maxSize  int64
+	sort     string
+	desc     bool
+	limit    int
+	cursor   *searchCursor
+}
+
+// parseSearchRequest 解析并校验搜索参数
+func parseSearchRequest(c *gin.Context) (*searchRequest, string) {
+	req := &searchRequest{
+		query: strings.TrimSpace(c.Query("q")),
+		tags:  models.ParseTagNames(c.Query("tags")),
+		sort:  c.Query("sort"),
+		desc:  c.DefaultQuery("order", "desc") != "asc",
+		limit: defaultSearchLimit,
+	}
+
+	if v := c.Query("albumId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, "无效的相册ID"
+		}
+		req.albumID = uint(id)
+	}
+
+	for _, f := range strings.Split(c.Query("format"), ",") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			req.formats = append(req.formats, formatMimeType(f))
+		}
+	}
+
+	var err error
+	if req.dateFrom, err = parseSearchDate(c.Query("dateFrom"), 0); err != nil {
+		return nil, "无效的开始日期，格式为 YYYY-MM-DD"
+	}
+	// 结束日期包含当天
+	if req.dateTo, err = parseSearchDate(c.Query("dateTo"), 1); err != nil {
+		return nil, "无效的结束日期，格式为 YYYY-MM-DD"
+	}
+
+	if v := c.Query("minSize"); v != "" {
+		if req.minSize, err = strconv.ParseInt(v, 10, 64); err != nil || req.minSize < 0 {
+			return nil, "无效的最小文件大小"
+		}
+	}
+	if v := c.Query("maxSize"); v != "" {
+		if req.maxSize, err = strconv.ParseInt(v, 10, 64); err != nil || req.maxSize < 0 {
+			return nil, "无效的最大文件大小"
+		}
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return nil, "无效的数量"
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		req.limit = limit
+	}
+
+	hasQuery := len(search.Terms(req.query)) > 0
+	if req.sort == "" {
+		req.sort = "date"
+		if hasQuery {
+			req.sort = "relevance"
+		}
+	}
+	if _, ok := searchSortColumns[req.sort]; !ok {
+		return nil, "无效的排序方式"
+	}
+	if req.sort == "relevance" && !hasQuery {
+		return nil, "按相关度排序需要搜索关键词"
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := decodeSearchCursor(v)
+		if err != nil || cursor.Sort != req.sort {
+			return nil, "无效的分页游标"
+		}
+		req.cursor = cursor
+	}
+
+	return req, ""
+}
+
+// formatMimeType 将格式参数转换为 MIME 类型，支持 png、jpg 等扩展名
+func formatMimeType(format string) string {
+	if strings.Contains(format, "/") {
+		return format
+	}
+	format = strings.TrimPrefix(format, ".")
+	switch format {
+	case "jpg":
+		format = "jpeg"
+	case "tif":
+		format = "tiff"
+	case "svg":
+		format = "svg+xml"
+	case "ico":
+		format = "x-icon"
+	}
+	return "image/" + format
+}
+
+func parseSearchDate(value string, addDays int) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	t = t.AddDate(0, 0, addDays)
+	return &t, nil
+}
+
+func decodeSearchCursor(value string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func encodeSearchCursor(sort string, value interface{}, id uint) string {
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(searchCursor{Sort: sort, Value: raw, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cursorValue 将游标中的排序值解析为与排序列相同的类型
+func (req *searchRequest) cursorValue() (interface{}, error) {
+	switch req.sort {
+	case "date":
+		var t time.Time
+		err := json.Unmarshal(req.cursor.Value, &t)
+		return t, err
+	case "relevance":
+		var score float64
+		err := json.Unmarshal(req.cursor.Value, &score)
+		return score, err
+	default:
+		var n int64
+		err := json.Unmarshal(req.cursor.Value, &n)
+		return n, err
+	}
+}
+
+// filtered 返回应用了权限、关键词和筛选条件的图片查询，每次调用都会生成新的查询
+func (req *searchRequest) filtered(db *gorm.DB, c *gin.Context) *gorm.DB {
+	query := visibleImages(db, db.Model(&models.Image{}), c)
+
+	if match := search.Match(db, req.query); match != nil {
+		query = query.Joins("JOIN (?) AS hits ON hits.image_id = images.id", match)
+	}
+	if req.albumID > 0 {
+		query = query.Where("images.album_id = ?", req.albumID)
+	}
+	if len(req.formats) > 0 {
+		query = query.Where("images.mime_type IN ?", req.formats)
+	}
+	if len(req.tags) > 0 {
+		// 需要包含所有指定的标签
+		tagged := db.Table("image_tags").
+			Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.name IN ?", req.tags).
+			Group("image_tags.image_id").
+			Having("COUNT(DISTINCT tags.name) = ?", len(req.tags))
+		query = query.Where("images.id IN (?)", tagged)
+	}
+	if req.dateFrom != nil {
+		query = query.Where("images.created_at >= ?", *req.dateFrom)
+	}
+	if req.dateTo != nil {
+		query = query.Where("images.created_at < ?", *req.dateTo)
+	}
+	if req.minSize > 0 {
+		query = query.Where("images.file_size >= ?", req.minSize)
+	}
+	if req.maxSize > 0 {
+		query = query.Where("images.file_size <= ?", req.maxSize)
+	}
+	return query
+}
+
+// SearchImages 全文搜索图片
+// 搜索文件名、标签、相册名称、描述和 EXIF 信息，支持分面统计、多种排序和游标分页
+func SearchImages(c *gin.Context) {
+	req, errMsg := parseSearchRequest(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	db := database.GetDB()
+	column := searchSortColumns[req.sort]
+	direction, compare := "DESC", "<"
+	if !req.desc {
+		direction, compare = "ASC", ">"
+	}
+
+	query := req.filtered(db, c)
+	if req.cursor != nil {
+		value, err := req.cursorValue()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页游标"})
+			return
+		}
+		query = query.Where(
+			"("+column+" "+compare+" ? OR ("+column+" = ? AND images.id "+compare+" ?))",
+			value, value, req.cursor.ID,
+		)
+	}
+
+	var hits []struct {
+		ID    uint
+		Score float64
+	}
+	selectColumns := "images.id, 0 AS score"
+	if req.sort == "relevance" {
+		selectColumns = "images.id, hits.score AS score"
+	}
+	if err := query.Select(selectColumns).
+		Order(column + " " + direction).Order("images.id " + direction).
+		Limit(req.limit + 1).Scan(&hits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+
+	hasMore := len(hits) > req.limit
+	if hasMore {
+		hits = hits[:req.limit]
+	}
+
+	images := make([]models.Image, 0, len(hits))
+	if len(hits) > 0 {
+		ids := make([]uint, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		var found []models.Image
+		if err := db.Preload("Owner").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+			return
+		}
+		byID := make(map[uint]models.Image, len(found))
+		for _, image := range found {
+			byID[image.ID] = image
+		}
+		for _, id := range ids {
+			if image, ok := byID[id]; ok {
+				image.URL = imageDeliveryURL(c, &image)
+				images = append(images, image)
+			}
+		}
+	}
+
+	var nextCursor string
+	if hasMore && len(images) > 0 {
+		last := images[len(images)-1]
+		var value interface{}
+		switch req.sort {
+		case "relevance":
+			value = hits[len(hits)-1].Score
+		case "date":
+			value = last.CreatedAt
+		case "views":
+			value = last.ViewCount
+		case "size":
+			value = last.FileSize
+		}
+		nextCursor = encodeSearchCursor(req.sort, value, last.ID)
+	}
+
+	var total int64
+	if err := req.filtered(db, c).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+
+	response := gin.H{
+		"data":       images,
+		"total":      total,
+		"hasMore":    hasMore,
+		"nextCursor": nextCursor,
+		"engine":     search.EngineName(),
+	}
+
+	// 翻页时通常不需要重新统计分面
+	if c.DefaultQuery("facets", "true") != "false" {
+		facets, err := searchFacets(db, c, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "统计搜索结果失败"})
+			return
+		}
+		response["facets"] = facets
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// searchFacets 按相册、格式、标签、月份和文件大小统计搜索结果
+func searchFacets(db *gorm.DB, c *gin.Context, req *searchRequest) (*SearchFacets, error) {
+	facets := &SearchFacets{}
+
+	var albumRows []struct {
+		AlbumID uint
+		Name    string
+		Count   int64
+	}
+	if err := req.filtered(db, c).
+		Select("images.album_id, albums.name, COUNT(*) AS count").
+		Joins("LEFT JOIN albums ON albums.id = images.album_id").
+		Group("images.album_id, albums.name").
+		Order("count DESC").
+		Scan(&albumRows).Error; err != nil {
+		return nil, err
+	}
+	facets.Album = make([]SearchFacet, 0, len(albumRows))
+	for _, row := range albumRows {
+		facets.Album = append(facets.Album, SearchFacet{
+			Value: strconv.FormatUint(uint64(row.AlbumID), 10),
+			Label: row.Name,
+			Count: row.Count,
+		})
+	}
+
+	var err error
+	if facets.Format, err = groupFacet(req.filtered(db, c), "images.mime_type", "count DESC", 0); err != nil {
+		return nil, err
+	}
+
+	tagged := req.filtered(db, c).
+		Joins("JOIN image_tags ON image_tags.image_id = images.id").
+		Joins("JOIN tags ON tags.id = image_tags.tag_id")
+	if facets.Tag, err = groupFacet(tagged, "tags.name", "count DESC", maxTagFacets); err != nil {
+		return nil, err
+	}
+
+	if facets.Date, err = groupFacet(req.filtered(db, c), search.MonthExpr("images.created_at"), "value DESC", 0); err != nil {
+		return nil, err
+	}
+
+	// 文件大小按区间统计，按区间顺序返回，没有结果的区间计数为 0
+	cases := make([]string, 0, len(sizeBuckets))
+	args := make([]interface{}, 0, len(sizeBuckets)*3)
+	for _, bucket := range sizeBuckets {
+		if bucket.Max > 0 {
+			cases = append(cases, "WHEN images.file_size >= ? AND images.file_size < ? THEN ?")
+			args = append(args, bucket.Min, bucket.Max, bucket.Label)
+		} else {
+			cases = append(cases, "WHEN images.file_size >= ? THEN ?")
+			args = append(args, bucket.Min, bucket.Label)
+		}
+	}
+	var sizeRows []SearchFacet
+	if err := req.filtered(db, c).
+		Select("CASE "+strings.Join(cases, " ")+" END AS value, COUNT(*) AS count", args...).
+		Group("value").
+		Scan(&sizeRows).Error; err != nil {
+		return nil, err
+	}
+	sizeCounts := make(map[string]int64, len(sizeRows))
+	for _, row := range sizeRows {
+		sizeCounts[row.Value] = row.Count
+	}
+	facets.Size = make([]SearchFacet, 0, len(sizeBuckets))
+	for _, bucket := range sizeBuckets {
+		facets.Size = append(facets.Size, SearchFacet{Value: bucket.Label, Count: sizeCounts[bucket.Label]})
+	}
+
+	return facets, nil
+}
+
+// groupFacet 按表达式分组计数，limit 为 0 表示不限制数量
+func groupFacet(query *gorm.DB, expr, order string, limit int) ([]SearchFacet, error) {
+	query = query.Select(expr + " AS value, COUNT(*) AS count").Group("value").Order(order)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	facets := make([]SearchFacet, 0)
+	if err := query.Scan(&facets).Error; err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
@@ -0,0 +1,469 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/middleware"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareAccessTTL 访问分享相册后，图片地址的有效期
+const shareAccessTTL = time.Hour
+
+// shareVisitTTL 一次访问的有效期，有效期内翻页不重复计入访问次数
+const shareVisitTTL = 30 * time.Minute
+
+// CreateShareLinkRequest 创建分享链接请求
+type CreateShareLinkRequest struct {
+	Name           string `json:"name" binding:"omitempty,max=100"`
+	Password       string `json:"password" binding:"omitempty,max=72"`
+	Permission     string `json:"permission"`     // view / download，默认 view
+	ExpiresInHours int    `json:"expiresInHours"` // 有效小时数，0 表示永不过期
+	MaxViews       int64  `json:"maxViews"`       // 最大访问次数，0 表示不限制
+}
+
+// ShareLinkResponse 分享链接信息
+type ShareLinkResponse struct {
+	models.ShareLink
+	HasPassword bool   `json:"hasPassword"`
+	Active      bool   `json:"active"`
+	URL         string `json:"url"`
+}
+
+func newShareLinkResponse(link *models.ShareLink) ShareLinkResponse {
+	return ShareLinkResponse{
+		ShareLink:   *link,
+		HasPassword: link.HasPassword(),
+		Active:      link.IsActive() && !link.ViewsExhausted(),
+		URL:         buildImageURL("/api/share/" + link.Token),
+	}
+}
+
+// SharedImage 分享相册中的图片，不包含存储路径等内部信息
+type SharedImage struct {
+	ID           uint   `json:"id"`
+	OriginalName string `json:"originalName"`
+	FileSize     int64  `json:"fileSize"`
+	MimeType     string `json:"mimeType"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	DownloadURL  string `json:"downloadUrl,omitempty"`
+}
+
+// CreateShareLink 为相册创建分享链接
+func CreateShareLink(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	albumVal, _ := c.Get("album")
+	album := albumVal.(*models.Album)
+
+	if !album.AllowShare {
+		c.JSON(http.StatusForbidden, gin.H{"error": "此相册不允许分享"})
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	if req.Permission == "" {
+		req.Permission = models.SharePermissionView
+	}
+	if !models.IsValidSharePermission(req.Permission) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分享权限，可选值: view、download"})
+		return
+	}
+	if req.ExpiresInHours < 0 || req.MaxViews < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效期和访问次数不能为负数"})
+		return
+	}
+
+	token, err := utils.GenerateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成分享链接失败"})
+		return
+	}
+
+	link := models.ShareLink{
+		AlbumID:    album.ID,
+		CreatedBy:  userID.(uint),
+		Name:       req.Name,
+		Token:      token,
+		Permission: req.Permission,
+		MaxViews:   req.MaxViews,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+	if err := link.SetPassword(req.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成分享链接失败"})
+		return
+	}
+
+	if err := database.GetDB().Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分享链接失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": newShareLinkResponse(&link)})
+}
+
+// GetAlbumShareLinks 获取相册的分享链接
+func GetAlbumShareLinks(c *gin.Context) {
+	albumVal, _ := c.Get("album")
+	album := albumVal.(*models.Album)
+
+	var links []models.ShareLink
+	if err := database.GetDB().Where("album_id = ?", album.ID).Order("created_at DESC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取分享链接失败"})
+		return
+	}
+
+	data := make([]ShareLinkResponse, 0, len(links))
+	for i := range links {
+		data = append(data, newShareLinkResponse(&links[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// GetMyShareLinks 获取当前用户相册的全部分享链接
+func GetMyShareLinks(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var links []models.ShareLink
+	if err := database.GetDB().Preload("Album").
+		Joins("JOIN albums ON albums.id = share_links.album_id AND albums.deleted_at IS NULL").
+		Where("albums.owner_id = ?", userID).
+		Order("share_links.created_at DESC").Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取分享链接失败"})
+		return
+	}
+
+	data := make([]ShareLinkResponse, 0, len(links))
+	for i := range links {
+		data = append(data, newShareLinkResponse(&links[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// RevokeShareLink 吊销分享链接（相册所有者或管理员）
+func RevokeShareLink(c *gin.Context) {
+	userID, isAdmin := requestUser(c)
+	db := database.GetDB()
+
+	var link models.ShareLink
+	if err := db.Preload("Album").First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在"})
+		return
+	}
+
+	// 相册已删除时只有创建者和管理员能看到
+	canModify := link.CreatedBy == userID || isAdmin
+	if link.Album != nil {
+		canModify = canModify || link.Album.CanManage(userID, isAdmin)
+	}
+	if !canModify {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在"})
+		return
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		if err := db.Model(&link).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销分享链接失败"})
+			return
+		}
+		link.RevokedAt = &now
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "分享链接已吊销", "data": newShareLinkResponse(&link)})
+}
+
+// loadShareLink 加载有效的分享链接及其相册，无效时输出错误响应
+func loadShareLink(c *gin.Context) (*models.ShareLink, bool) {
+	var link models.ShareLink
+	if err := database.GetDB().Preload("Album").Where("token = ?", c.Param("token")).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在"})
+		return nil, false
+	}
+
+	// 相册被删除或关闭分享后链接随之失效
+	if !link.IsActive() || link.Album == nil || !link.Album.AllowShare {
+		c.JSON(http.StatusGone, gin.H{"error": "分享链接已失效"})
+		return nil, false
+	}
+
+	return &link, true
+}
+
+// shareAccessSignature 访问分享相册后签发的图片访问签名
+// 签名包含链接令牌，吊销或删除链接后立即失效
+func shareAccessSignature(link *models.ShareLink, expires int64) string {
+	h := hmac.New(sha256.New, []byte(config.GetConfig().SecretKey))
+	h.Write([]byte(fmt.Sprintf("share:%d:%s:%d", link.ID, link.Token, expires)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyShareAccess 校验图片地址中的访问签名
+func verifyShareAccess(c *gin.Context, link *models.ShareLink) bool {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(c.Query("access")), []byte(shareAccessSignature(link, expires)))
+}
+
+// shareVisitSignature 计入访问次数后签发的访问凭证签名
+func shareVisitSignature(link *models.ShareLink, expires int64) string {
+	h := hmac.New(sha256.New, []byte(config.GetConfig().SecretKey))
+	h.Write([]byte(fmt.Sprintf("share-visit:%d:%s:%d", link.ID, link.Token, expires)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// issueShareVisit 签发访问凭证，格式为 过期时间.签名
+func issueShareVisit(link *models.ShareLink) string {
+	expires := time.Now().Add(shareVisitTTL).Unix()
+	return fmt.Sprintf("%d.%s", expires, shareVisitSignature(link, expires))
+}
+
+// verifyShareVisit 校验访问凭证，有效时说明本次访问已经计入访问次数
+func verifyShareVisit(link *models.ShareLink, visit string) bool {
+	expiresText, signature, ok := strings.Cut(visit, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(shareVisitSignature(link, expires)))
+}
+
+// SharedAlbumRequest 通过 POST 访问分享相册时的请求体
+type SharedAlbumRequest struct {
+	Password string `json:"password"`
+	Visit    string `json:"visit"`
+}
+
+// GetSharedAlbum 访客通过分享链接查看相册
+// 有密码的链接通过 X-Share-Password 请求头或 POST 请求体提供密码，不接受查询参数，避免密码出现在访问日志中
+// 首次访问计入访问次数并返回访问凭证 visitToken，之后翻页通过 X-Share-Visit 请求头或请求体携带凭证，不重复计数；
+// 没有有效凭证的请求无论第几页都计入访问次数
+func GetSharedAlbum(c *gin.Context) {
+	link, ok := loadShareLink(c)
+	if !ok {
+		return
+	}
+
+	req := SharedAlbumRequest{
+		Password: c.GetHeader("X-Share-Password"),
+		Visit:    c.GetHeader("X-Share-Visit"),
+	}
+	if c.Request.Method == http.MethodPost && c.Request.ContentLength != 0 {
+		var body SharedAlbumRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+		if body.Password != "" {
+			req.Password = body.Password
+		}
+		if body.Visit != "" {
+			req.Visit = body.Visit
+		}
+	}
+
+	if link.HasPassword() {
+		if req.Password == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要输入分享密码", "passwordRequired": true})
+			return
+		}
+		if !link.CheckPassword(req.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "分享密码错误", "passwordRequired": true})
+			return
+		}
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "24"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 24
+	}
+
+	db := database.GetDB()
+
+	// 没有有效访问凭证时原子地检查并增加访问次数
+	visit := req.Visit
+	newVisit := !verifyShareVisit(link, visit)
+	if newVisit {
+		result := db.Model(&models.ShareLink{}).
+			Where("id = ? AND (max_views = 0 OR view_count < max_views)", link.ID).
+			Updates(map[string]interface{}{
+				"view_count":   gorm.Expr("view_count + ?", 1),
+				"last_view_at": time.Now(),
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "访问分享链接失败"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusGone, gin.H{"error": "分享链接访问次数已用完"})
+			return
+		}
+		visit = issueShareVisit(link)
+	}
+
+	query := db.Model(&models.Image{}).Where("album_id = ?", link.AlbumID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图片列表失败"})
+		return
+	}
+
+	var images []models.Image
+	if err := query.Session(&gorm.Session{}).Order("created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取图片列表失败"})
+		return
+	}
+
+	expires := time.Now().Add(shareAccessTTL).Unix()
+	if link.ExpiresAt != nil && link.ExpiresAt.Unix() < expires {
+		expires = link.ExpiresAt.Unix()
+	}
+	accessQuery := fmt.Sprintf("access=%s&expires=%d", shareAccessSignature(link, expires), expires)
+	basePath := "/api/share/" + link.Token + "/images/"
+
+	data := make([]SharedImage, 0, len(images))
+	for _, img := range images {
+		imagePath := basePath + strconv.FormatUint(uint64(img.ID), 10)
+		item := SharedImage{
+			ID:           img.ID,
+			OriginalName: img.OriginalName,
+			FileSize:     img.FileSize,
+			MimeType:     img.MimeType,
+			Width:        img.Width,
+			Height:       img.Height,
+			URL:          buildImageURL(imagePath + "?" + accessQuery),
+			ThumbnailURL: buildImageURL(imagePath + "/thumbnail?" + accessQuery),
+		}
+		if link.AllowDownload() && img.AllowDownload {
+			item.DownloadURL = buildImageURL(imagePath + "?" + accessQuery + "&download=1")
+		}
+		data = append(data, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"album": gin.H{
+				"id":          link.Album.ID,
+				"name":        link.Album.Name,
+				"description": link.Album.Description,
+			},
+			"name":       link.Name,
+			"permission": link.Permission,
+			"expiresAt":  link.ExpiresAt,
+			"images":     data,
+			"visitToken": visit,
+		},
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+
+	if newVisit {
+		middleware.RecordOperation(c, "share_view", "album", link.AlbumID,
+			fmt.Sprintf("通过分享链接 #%d 访问相册 %s", link.ID, link.Album.Name))
+	}
+}
+
+// loadSharedImage 校验访问签名并加载分享相册中的图片
+func loadSharedImage(c *gin.Context) (*models.ShareLink, *models.Image, bool) {
+	link, ok := loadShareLink(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !verifyShareAccess(c, link) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "访问签名无效或已过期，请重新打开分享链接"})
+		return nil, nil, false
+	}
+
+	var imageRecord models.Image
+	if err := database.GetDB().Where("id = ? AND album_id = ?", c.Param("imageId"), link.AlbumID).
+		First(&imageRecord).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return nil, nil, false
+	}
+
+	return link, &imageRecord, true
+}
+
+// GetSharedImage 通过分享链接查看或下载图片，download=1 时以附件形式下载
+func GetSharedImage(c *gin.Context) {
+	link, imageRecord, ok := loadSharedImage(c)
+	if !ok {
+		return
+	}
+
+	download := wantsDownload(c)
+	if download && !(link.AllowDownload() && imageRecord.AllowDownload) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "此分享链接不允许下载"})
+		return
+	}
+
+	mark, ok := deliveryWatermark(c, imageRecord)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	serveOriginalImage(c, imageRecord, mark)
+
+	// 图片浏览请求较多，只记录下载
+	if download {
+		middleware.RecordOperation(c, "share_download", "image", imageRecord.ID,
+			fmt.Sprintf("通过分享链接 #%d 下载图片 %s", link.ID, imageRecord.OriginalName))
+	}
+}
+
+// GetSharedImageThumbnail 通过分享链接查看缩略图
+func GetSharedImageThumbnail(c *gin.Context) {
+	_, imageRecord, ok := loadSharedImage(c)
+	if !ok {
+		return
+	}
+
+	thumbnailPath := imageRecord.Thumbnail
+	if thumbnailPath == "" || !storedFileExists(thumbnailPath) {
+		thumbnailPath = imageRecord.FilePath
+	}
+
+	mark, ok := deliveryWatermark(c, imageRecord)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	serveThumbnailFile(c, imageRecord, thumbnailPath, mark)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestShareLink 为新建的可分享相册创建分享链接
+func createTestShareLink(t *testing.T, owner *models.User, link models.ShareLink, password string) *models.ShareLink {
+	db := database.GetDB()
+
+	album := models.Album{Name: "分享测试", OwnerID: owner.ID, AllowShare: true}
+	if err := db.Create(&album).Error; err != nil {
+		t.Fatalf("创建相册失败: %v", err)
+	}
+
+	token, err := utils.GenerateShareToken()
+	if err != nil {
+		t.Fatalf("生成分享令牌失败: %v", err)
+	}
+	link.AlbumID = album.ID
+	link.CreatedBy = owner.ID
+	link.Token = token
+	link.Permission = models.SharePermissionView
+	if err := link.SetPassword(password); err != nil {
+		t.Fatalf("设置分享密码失败: %v", err)
+	}
+	if err := db.Create(&link).Error; err != nil {
+		t.Fatalf("创建分享链接失败: %v", err)
+	}
+
+	t.Cleanup(func() { db.Delete(&link) })
+	return &link
+}
+
+// viewSharedAlbum 访问分享相册，body 为空时使用 GET 请求
+func viewSharedAlbum(r *gin.Engine, token string, body map[string]string) (int, map[string]interface{}) {
+	method := "GET"
+	var payload []byte
+	if body != nil {
+		method = "POST"
+		payload, _ = json.Marshal(body)
+	}
+
+	req, _ := http.NewRequest(method, "/share/"+token, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func newShareRouter() *gin.Engine {
+	r := gin.Default()
+	r.GET("/share/:token", GetSharedAlbum)
+	r.POST("/share/:token", GetSharedAlbum)
+	return r
+}
+
+// TestSharedAlbumExpired 测试过期和吊销的分享链接
+func TestSharedAlbumExpired(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	owner := createTestUser("shareexpired", "password123")
+	assert.NotNil(t, owner)
+	r := newShareRouter()
+
+	expiredAt := time.Now().Add(-time.Minute)
+	expired := createTestShareLink(t, owner, models.ShareLink{ExpiresAt: &expiredAt}, "")
+	code, response := viewSharedAlbum(r, expired.Token, nil)
+	assert.Equal(t, http.StatusGone, code)
+	assert.Equal(t, "分享链接已失效", response["error"])
+
+	revokedAt := time.Now()
+	revoked := createTestShareLink(t, owner, models.ShareLink{RevokedAt: &revokedAt}, "")
+	code, _ = viewSharedAlbum(r, revoked.Token, nil)
+	assert.Equal(t, http.StatusGone, code)
+
+	code, _ = viewSharedAlbum(r, "not-exists", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// TestSharedAlbumViewsExhausted 测试访问次数用完后拒绝新的访问，已有的访问凭证翻页不计数
+func TestSharedAlbumViewsExhausted(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	owner := createTestUser("shareviews", "password123")
+	assert.NotNil(t, owner)
+	r := newShareRouter()
+	link := createTestShareLink(t, owner, models.ShareLink{MaxViews: 1}, "")
+
+	code, response := viewSharedAlbum(r, link.Token, nil)
+	assert.Equal(t, http.StatusOK, code)
+	data, _ := response["data"].(map[string]interface{})
+	visit, _ := data["visitToken"].(string)
+	assert.NotEmpty(t, visit)
+
+	// 携带访问凭证翻页不计入访问次数
+	code, _ = viewSharedAlbum(r, link.Token, map[string]string{"visit": visit})
+	assert.Equal(t, http.StatusOK, code)
+
+	// 没有凭证的新访问被拒绝
+	code, response = viewSharedAlbum(r, link.Token, nil)
+	assert.Equal(t, http.StatusGone, code)
+	assert.Equal(t, "分享链接访问次数已用完", response["error"])
+
+	var stored models.ShareLink
+	database.GetDB().First(&stored, link.ID)
+	assert.Equal(t, int64(1), stored.ViewCount)
+}
+
+// TestSharedAlbumPassword 测试有密码的分享链接
+func TestSharedAlbumPassword(t *testing.T) {
+	setupTestDB(t)
+	defer cleanupTestDB()
+
+	owner := createTestUser("sharepassword", "password123")
+	assert.NotNil(t, owner)
+	r := newShareRouter()
+	link := createTestShareLink(t, owner, models.ShareLink{}, "secret")
+
+	code, response := viewSharedAlbum(r, link.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, true, response["passwordRequired"])
+
+	code, response = viewSharedAlbum(r, link.Token, map[string]string{"password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "分享密码错误", response["error"])
+
+	// 不接受查询参数中的密码
+	req, _ := http.NewRequest("GET", "/share/"+link.Token+"?password=secret", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	code, _ = viewSharedAlbum(r, link.Token, map[string]string{"password": "secret"})
+	assert.Equal(t, http.StatusOK, code)
+
+	// 密码错误不计入访问次数
+	var stored models.ShareLink
+	database.GetDB().First(&stored, link.ID)
+	assert.Equal(t, int64(1), stored.ViewCount)
+}
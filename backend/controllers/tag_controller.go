@@ -1,14 +1,71 @@
 package controllers
 
 import (
+	"errors"
 	"imagebed/database"
 	"imagebed/models"
+	"imagebed/search"
+	"imagebed/utils"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// TagInfo 标签及其使用次数
+type TagInfo struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// RenameTagRequest 重命名标签请求
+type RenameTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagsRequest 合并标签请求，源标签合并到目标标签后被删除
+type MergeTagsRequest struct {
+	SourceIDs []uint `json:"sourceIds" binding:"required"`
+	TargetID  uint   `json:"targetId" binding:"required"`
+}
+
+// BatchUpdateTagsRequest 批量添加、移除图片标签请求
+type BatchUpdateTagsRequest struct {
+	ImageIDs []uint   `json:"imageIds" binding:"required"`
+	Add      []string `json:"add"`
+	Remove   []string `json:"remove"`
+}
+
+// tagNamespace 当前请求的标签命名空间，管理员可以通过 userId 参数查看其他用户的标签
+func tagNamespace(c *gin.Context) uint {
+	userID, isAdmin := requestUser(c)
+	if isAdmin {
+		if id, err := strconv.ParseUint(c.Query("userId"), 10, 32); err == nil && id > 0 {
+			return uint(id)
+		}
+	}
+	return userID
+}
+
+// loadTag 加载当前用户可以管理的标签，管理员可以管理所有标签
+func loadTag(c *gin.Context, id interface{}) (*models.Tag, bool) {
+	var tag models.Tag
+	if err := database.GetDB().First(&tag, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return nil, false
+	}
+
+	userID, isAdmin := requestUser(c)
+	if tag.OwnerID != userID && !isAdmin {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return nil, false
+	}
+	return &tag, true
+}
+
 // UpdateImageTags 更新图片标签
 func UpdateImageTags(c *gin.Context) {
 	imageID := c.Param("id")
@@ -29,85 +86,309 @@ func UpdateImageTags(c *gin.Context) {
 		return
 	}
 
-	// 更新标签
-	if err := db.Model(&image).Update("tags", req.Tags).Error; err != nil {
+	// 更新标签，标签属于图片所有者的命名空间
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return models.SetImageTags(tx, &image, models.ParseTagNames(req.Tags))
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
 		return
 	}
 
+	db.Select("tags").First(&image, image.ID)
+	clearImageListCache(uint64(image.AlbumID))
+	search.IndexImages(image.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "标签更新成功",
-		"data":    gin.H{"tags": req.Tags},
+		"data":    gin.H{"tags": image.Tags},
 	})
 }
 
-// GetAllTags 获取所有标签（去重）
+// GetAllTags 获取当前用户的所有标签及使用次数（不统计回收站中的图片）
 func GetAllTags(c *gin.Context) {
 	db := database.GetDB()
 
-	var images []models.Image
-	db.Where("tags != ''").Select("tags").Find(&images)
-
-	tagMap := make(map[string]int)
-	for _, img := range images {
-		if img.Tags != "" {
-			tags := strings.Split(img.Tags, ",")
-			for _, tag := range tags {
-				tag = strings.TrimSpace(tag)
-				if tag != "" {
-					tagMap[tag]++
-				}
-			}
-		}
-	}
-
-	// 转换为数组
-	type TagInfo struct {
-		Name  string `json:"name"`
-		Count int    `json:"count"`
-	}
-
-	tags := make([]TagInfo, 0, len(tagMap))
-	for name, count := range tagMap {
-		tags = append(tags, TagInfo{
-			Name:  name,
-			Count: count,
-		})
+	tags := make([]TagInfo, 0)
+	if err := db.Model(&models.Tag{}).
+		Select("tags.id, tags.name, COUNT(images.id) AS count").
+		Joins("LEFT JOIN image_tags ON image_tags.tag_id = tags.id").
+		Joins("LEFT JOIN images ON images.id = image_tags.image_id AND images.deleted_at IS NULL").
+		Where("tags.owner_id = ?", tagNamespace(c)).
+		Group("tags.id, tags.name").
+		Order("count DESC, tags.name ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签失败"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": tags})
 }
 
 // SearchImagesByTag 按标签搜索图片
+// tags 为逗号分隔的标签，mode=and 时需要包含全部标签（默认），mode=or 时包含任一标签即可；
+// exclude 为需要排除的标签；兼容旧的单标签参数 tag
 func SearchImagesByTag(c *gin.Context) {
-	tag := c.Query("tag")
 	albumID := c.Query("albumId")
 	db := database.GetDB()
 
-	query := db.Model(&models.Image{})
+	include := models.ParseTagNames(c.Query("tags"))
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		include = models.NormalizeTagNames(append(include, tag))
+	}
+	exclude := models.ParseTagNames(c.Query("exclude"))
+	mode := strings.ToLower(c.DefaultQuery("mode", "and"))
+	if mode != "and" && mode != "or" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode 只能是 and 或 or"})
+		return
+	}
+
+	query := visibleImages(db, db.Model(&models.Image{}), c)
 
 	if albumID != "" {
-		query = query.Where("album_id = ?", albumID)
+		query = query.Where("images.album_id = ?", albumID)
 	}
 
-	if tag != "" {
-		// 使用 LIKE 查询包含该标签的图片
-		query = query.Where("tags LIKE ?", "%"+tag+"%")
+	// 标签属于图片所有者的命名空间，按名称匹配即可覆盖共享给自己的图片
+	taggedWith := func(names []string) *gorm.DB {
+		return db.Table("image_tags").Select("image_tags.image_id").
+			Joins("JOIN tags ON tags.id = image_tags.tag_id").
+			Where("tags.name IN ?", names)
+	}
+	if len(include) > 0 {
+		sub := taggedWith(include)
+		if mode == "and" {
+			sub = sub.Group("image_tags.image_id").Having("COUNT(DISTINCT tags.name) = ?", len(include))
+		}
+		query = query.Where("images.id IN (?)", sub)
+	}
+	if len(exclude) > 0 {
+		query = query.Where("images.id NOT IN (?)", taggedWith(exclude))
 	}
 
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	params := utils.GetPaginationParams(c)
 	var images []models.Image
-	if err := query.Find(&images).Error; err != nil {
+	if err := query.Order("images.created_at DESC").
+		Limit(params.PageSize).Offset((params.Page - 1) * params.PageSize).
+		Find(&images).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
 		return
 	}
 
 	// 为每个图片生成 URL
 	for i := range images {
-		images[i].URL = generateImageURL(images[i].UUID)
+		images[i].URL = imageDeliveryURL(c, &images[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     images,
+		"total":    total,
+		"page":     params.Page,
+		"pageSize": params.PageSize,
+	})
+}
+
+// RenameTag 重命名标签，同名标签已存在时需要使用合并
+func RenameTag(c *gin.Context) {
+	tag, ok := loadTag(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req RenameTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	names := models.NormalizeTagNames([]string{req.Name})
+	if len(names) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签名称不能为空"})
+		return
+	}
+	if names[0] == tag.Name {
+		c.JSON(http.StatusOK, gin.H{"data": tag})
+		return
+	}
+
+	db := database.GetDB()
+	var existing models.Tag
+	if err := db.Where("owner_id = ? AND name = ?", tag.OwnerID, names[0]).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "标签已存在，请使用合并", "data": existing})
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名标签失败"})
+		return
+	}
+
+	var imageIDs []uint
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tag).Update("name", names[0]).Error; err != nil {
+			return err
+		}
+		var err error
+		if imageIDs, err = models.TaggedImageIDs(tx, tag.ID); err != nil {
+			return err
+		}
+		return models.RefreshImageTagsColumn(tx, imageIDs...)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名标签失败"})
+		return
+	}
+	clearImageListCache(0)
+	search.IndexImages(imageIDs...)
+
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// MergeTags 将源标签合并到目标标签，源标签会被删除
+func MergeTags(c *gin.Context) {
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	target, ok := loadTag(c, req.TargetID)
+	if !ok {
+		return
+	}
+
+	sourceIDs := make([]uint, 0, len(req.SourceIDs))
+	seen := map[uint]bool{target.ID: true}
+	for _, id := range req.SourceIDs {
+		if !seen[id] {
+			seen[id] = true
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要合并的标签"})
+		return
+	}
+
+	// 只能合并同一命名空间中的标签
+	db := database.GetDB()
+	var count int64
+	db.Model(&models.Tag{}).Where("id IN ? AND owner_id = ?", sourceIDs, target.OwnerID).Count(&count)
+	if int(count) != len(sourceIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	var imageIDs []uint
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if imageIDs, err = models.TaggedImageIDs(tx, sourceIDs...); err != nil {
+			return err
+		}
+		var images []models.Image
+		if err := tx.Unscoped().Select("id", "owner_id").Where("id IN ?", imageIDs).Find(&images).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id IN ?", sourceIDs).Delete(&models.ImageTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", sourceIDs).Delete(&models.Tag{}).Error; err != nil {
+			return err
+		}
+		return models.AddImageTags(tx, images, []string{target.Name})
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "合并标签失败"})
+		return
+	}
+	clearImageListCache(0)
+	search.IndexImages(imageIDs...)
+
+	c.JSON(http.StatusOK, gin.H{"message": "标签已合并", "data": target})
+}
+
+// DeleteTag 删除标签并从所有图片上移除
+func DeleteTag(c *gin.Context) {
+	tag, ok := loadTag(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var imageIDs []uint
+	if err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if imageIDs, err = models.TaggedImageIDs(tx, tag.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.ImageTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(tag).Error; err != nil {
+			return err
+		}
+		return models.RefreshImageTagsColumn(tx, imageIDs...)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败"})
+		return
+	}
+	clearImageListCache(0)
+	search.IndexImages(imageIDs...)
+
+	c.JSON(http.StatusOK, gin.H{"message": "标签已删除"})
+}
+
+// BatchUpdateImageTags 为选中的图片批量添加、移除标签
+func BatchUpdateImageTags(c *gin.Context) {
+	var req BatchUpdateTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	add := models.NormalizeTagNames(req.Add)
+	remove := models.NormalizeTagNames(req.Remove)
+	if len(req.ImageIDs) == 0 || (len(add) == 0 && len(remove) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择图片和标签"})
+		return
+	}
+	if len(req.ImageIDs) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "一次最多处理500张图片"})
+		return
+	}
+
+	db := database.GetDB()
+	var images []models.Image
+	if err := db.Where("id IN ?", req.ImageIDs).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询图片失败"})
+		return
+	}
+
+	userID, isAdmin := requestUser(c)
+	for i := range images {
+		if !images[i].CanModify(userID, isAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "无权限修改部分图片的标签"})
+			return
+		}
 	}
 
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := models.AddImageTags(tx, images, add); err != nil {
+			return err
+		}
+		return models.RemoveImageTags(tx, images, remove)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "批量更新标签失败"})
+		return
+	}
+	clearImageListCache(0)
+	imageIDs := make([]uint, 0, len(images))
+	for _, image := range images {
+		imageIDs = append(imageIDs, image.ID)
+	}
+	search.IndexImages(imageIDs...)
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":  images,
-		"total": len(images),
+		"message": "标签更新成功",
+		"data":    gin.H{"updated": len(images)},
 	})
 }
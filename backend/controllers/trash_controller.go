@@ -0,0 +1,471 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/search"
+	"imagebed/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultAlbumName 用户默认相册的名称，所属相册已删除或已无权上传的图片恢复到这里
+const defaultAlbumName = "默认相册"
+
+// TrashedImage 回收站中的图片
+type TrashedImage struct {
+	models.Image
+	DeletedAt time.Time  `json:"deletedAt"`
+	PurgeAt   *time.Time `json:"purgeAt"` // 预计彻底删除时间
+}
+
+// TrashedAlbum 回收站中的相册
+type TrashedAlbum struct {
+	models.Album
+	DeletedAt time.Time  `json:"deletedAt"`
+	PurgeAt   *time.Time `json:"purgeAt"`
+}
+
+// trashRetention 回收站保留时长，0 表示不保留
+func trashRetention() time.Duration {
+	days := config.GetConfig().TrashRetentionDays
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// purgeTime 计算回收站中的项目何时被彻底删除
+func purgeTime(deletedAt gorm.DeletedAt) *time.Time {
+	retention := trashRetention()
+	if !deletedAt.Valid || retention == 0 {
+		return nil
+	}
+	t := deletedAt.Time.Add(retention)
+	return &t
+}
+
+// trashImage 将图片移入回收站，文件和配额保留到彻底删除时再释放
+func trashImage(imageRecord *models.Image) error {
+	db := database.GetDB()
+
+	if err := db.Delete(imageRecord).Error; err != nil {
+		return err
+	}
+
+	db.Model(&models.Album{}).Where("id = ? AND image_count > 0", imageRecord.AlbumID).
+		Update("image_count", gorm.Expr("image_count - ?", 1))
+
+	if trashRetention() == 0 {
+		return purgeImage(imageRecord)
+	}
+	return nil
+}
+
+// purgeImage 彻底删除图片：删除记录、短链并释放文件引用和配额
+func purgeImage(imageRecord *models.Image) error {
+	if err := database.GetDB().Unscoped().Delete(imageRecord).Error; err != nil {
+		return err
+	}
+	database.GetDB().Where("image_id = ?", imageRecord.ID).Delete(&models.ImageTag{})
+	database.GetDB().Where("image_id = ?", imageRecord.ID).Delete(&models.ImageMetadata{})
+	search.RemoveImages(imageRecord.ID)
+
+	// 如果有短链,删除短链(硬删除)
+	if imageRecord.ShortLinkCode != "" {
+		cfg := config.GetConfig()
+		if cfg.ShortLinkEnabled {
+			shortLinkClient := utils.NewShortLinkClient(cfg.ShortLinkBaseURL, cfg.ShortLinkAPIKey)
+			if err := shortLinkClient.DeleteShortLinkWithMode(imageRecord.ShortLinkCode, true); err != nil {
+				// 记录错误但继续删除图片
+				fmt.Printf("删除短链失败 %s: %v\n", imageRecord.ShortLinkCode, err)
+			}
+		}
+	}
+
+	// 释放文件引用，最后一个引用删除时才删除物理文件
+	releaseImageFiles(imageRecord)
+	releaseQuota(imageRecord.OwnerID, imageRecord.FileSize, 1)
+
+	return nil
+}
+
+// trashAlbum 将相册及其中的图片作为整体移入回收站
+// 只有相册所有者的图片随相册删除，其他成员上传的图片单独移入各自的回收站，由上传者自行恢复
+func trashAlbum(album *models.Album) error {
+	var contributedIDs []uint
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.Image{}).Where("album_id = ? AND owner_id = ?", album.ID, album.OwnerID).
+			Updates(map[string]interface{}{
+				"deleted_at":         now,
+				"deleted_with_album": true,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Image{}).Where("album_id = ? AND owner_id <> ?", album.ID, album.OwnerID).
+			Pluck("id", &contributedIDs).Error; err != nil {
+			return err
+		}
+		if len(contributedIDs) > 0 {
+			if err := tx.Model(&models.Image{}).Where("id IN ?", contributedIDs).
+				Updates(map[string]interface{}{
+					"deleted_at":         now,
+					"deleted_with_album": false,
+				}).Error; err != nil {
+				return err
+			}
+			// 恢复相册时这些图片不随相册恢复，不再计入相册图片数
+			if err := tx.Model(album).
+				Update("image_count", gorm.Expr("image_count - ?", len(contributedIDs))).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(album).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if trashRetention() == 0 {
+		if len(contributedIDs) > 0 {
+			var images []models.Image
+			if err := database.GetDB().Unscoped().Where("id IN ?", contributedIDs).Find(&images).Error; err != nil {
+				return err
+			}
+			for i := range images {
+				if err := purgeImage(&images[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return purgeAlbum(album)
+	}
+	return nil
+}
+
+// userDefaultAlbum 获取用户自己的默认相册，不存在时创建一个私有相册
+func userDefaultAlbum(userID uint) (*models.Album, error) {
+	db := database.GetDB()
+
+	var album models.Album
+	err := db.Where("owner_id = ? AND name = ?", userID, defaultAlbumName).Order("id").First(&album).Error
+	if err == nil {
+		return &album, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	album = models.Album{
+		Name:        defaultAlbumName,
+		Description: "用户默认相册",
+		OwnerID:     userID,
+		IsPrivate:   true,
+		AllowShare:  true,
+	}
+	if err := db.Create(&album).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// purgeAlbum 彻底删除相册及随相册删除的图片
+func purgeAlbum(album *models.Album) error {
+	db := database.GetDB()
+
+	var images []models.Image
+	if err := db.Unscoped().Where("album_id = ? AND deleted_with_album = ?", album.ID, true).
+		Find(&images).Error; err != nil {
+		return err
+	}
+
+	for i := range images {
+		if err := purgeImage(&images[i]); err != nil {
+			return err
+		}
+	}
+
+	// 相册成员和分享链接随相册一起删除
+	if err := db.Where("album_id = ?", album.ID).Delete(&models.AlbumMember{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("album_id = ?", album.ID).Delete(&models.ShareLink{}).Error; err != nil {
+		return err
+	}
+
+	return db.Unscoped().Delete(album).Error
+}
+
+// findTrashedImage 查找当前用户回收站中的图片
+func findTrashedImage(c *gin.Context) (*models.Image, bool) {
+	userID, _ := c.Get("userID")
+
+	var imageRecord models.Image
+	if err := database.GetDB().Unscoped().
+		Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL", c.Param("id"), userID).
+		First(&imageRecord).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中不存在该图片"})
+		return nil, false
+	}
+	return &imageRecord, true
+}
+
+// findTrashedAlbum 查找当前用户回收站中的相册
+func findTrashedAlbum(c *gin.Context) (*models.Album, bool) {
+	userID, _ := c.Get("userID")
+
+	var album models.Album
+	if err := database.GetDB().Unscoped().
+		Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL", c.Param("id"), userID).
+		First(&album).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中不存在该相册"})
+		return nil, false
+	}
+	return &album, true
+}
+
+// GetTrash 获取当前用户的回收站
+// 随相册删除的图片归入相册，不单独列出
+func GetTrash(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	db := database.GetDB()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	imageQuery := db.Unscoped().Model(&models.Image{}).
+		Where("owner_id = ? AND deleted_at IS NOT NULL AND deleted_with_album = ?", userID, false)
+
+	var total int64
+	if err := imageQuery.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	var images []models.Image
+	if err := imageQuery.Order("deleted_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	var albums []models.Album
+	if err := db.Unscoped().Where("owner_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").Find(&albums).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+
+	trashedImages := make([]TrashedImage, 0, len(images))
+	for _, img := range images {
+		trashedImages = append(trashedImages, TrashedImage{
+			Image:     img,
+			DeletedAt: img.DeletedAt.Time,
+			PurgeAt:   purgeTime(img.DeletedAt),
+		})
+	}
+
+	trashedAlbums := make([]TrashedAlbum, 0, len(albums))
+	for _, album := range albums {
+		trashedAlbums = append(trashedAlbums, TrashedAlbum{
+			Album:     album,
+			DeletedAt: album.DeletedAt.Time,
+			PurgeAt:   purgeTime(album.DeletedAt),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"images": trashedImages,
+			"albums": trashedAlbums,
+		},
+		"total":         total,
+		"page":          page,
+		"pageSize":      pageSize,
+		"retentionDays": config.GetConfig().TrashRetentionDays,
+	})
+}
+
+// RestoreImage 从回收站恢复图片
+// 所属相册已不存在或当前用户已无权上传到该相册时，恢复到用户自己的默认相册
+func RestoreImage(c *gin.Context) {
+	imageRecord, ok := findTrashedImage(c)
+	if !ok {
+		return
+	}
+
+	userID, isAdmin := requestUser(c)
+	db := database.GetDB()
+	albumID := imageRecord.AlbumID
+	var album models.Album
+	if err := db.Preload("Members").First(&album, albumID).Error; err != nil || !album.CanUpload(userID, isAdmin) {
+		defaultAlbum, err := userDefaultAlbum(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复图片失败"})
+			return
+		}
+		albumID = defaultAlbum.ID
+	}
+
+	if err := db.Unscoped().Model(imageRecord).Updates(map[string]interface{}{
+		"deleted_at":         nil,
+		"deleted_with_album": false,
+		"album_id":           albumID,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复图片失败"})
+		return
+	}
+
+	db.Model(&models.Album{}).Where("id = ?", albumID).
+		Update("image_count", gorm.Expr("image_count + ?", 1))
+	clearImageListCache(uint64(albumID))
+
+	db.First(imageRecord, imageRecord.ID)
+	c.JSON(http.StatusOK, gin.H{"data": imageRecord, "message": "恢复成功"})
+}
+
+// PurgeImage 从回收站彻底删除图片
+func PurgeImage(c *gin.Context) {
+	imageRecord, ok := findTrashedImage(c)
+	if !ok {
+		return
+	}
+
+	if err := purgeImage(imageRecord); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "彻底删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已彻底删除"})
+}
+
+// RestoreAlbum 从回收站恢复相册及随相册删除的图片
+func RestoreAlbum(c *gin.Context) {
+	album, ok := findTrashedAlbum(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Image{}).
+			Where("album_id = ? AND deleted_with_album = ?", album.ID, true).
+			Updates(map[string]interface{}{
+				"deleted_at":         nil,
+				"deleted_with_album": false,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(album).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复相册失败"})
+		return
+	}
+
+	clearImageListCache(uint64(album.ID))
+
+	c.JSON(http.StatusOK, gin.H{"data": album, "message": "恢复成功"})
+}
+
+// PurgeAlbum 从回收站彻底删除相册
+func PurgeAlbum(c *gin.Context) {
+	album, ok := findTrashedAlbum(c)
+	if !ok {
+		return
+	}
+
+	if err := purgeAlbum(album); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "彻底删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已彻底删除"})
+}
+
+// EmptyTrash 清空当前用户的回收站
+func EmptyTrash(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	purged, err := purgeTrash(database.GetDB().Unscoped().Where("owner_id = ?", userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清空回收站失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "回收站已清空", "purged": purged})
+}
+
+// purgeTrash 彻底删除满足条件的回收站项目，返回删除的数量
+func purgeTrash(scope *gorm.DB) (int, error) {
+	purged := 0
+
+	var albums []models.Album
+	if err := scope.Session(&gorm.Session{}).Where("deleted_at IS NOT NULL").Find(&albums).Error; err != nil {
+		return purged, err
+	}
+	for i := range albums {
+		if err := purgeAlbum(&albums[i]); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	var images []models.Image
+	if err := scope.Session(&gorm.Session{}).
+		Where("deleted_at IS NOT NULL AND deleted_with_album = ?", false).Find(&images).Error; err != nil {
+		return purged, err
+	}
+	for i := range images {
+		if err := purgeImage(&images[i]); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// PurgeExpiredTrash 彻底删除超过保留期的回收站项目
+func PurgeExpiredTrash() (int, error) {
+	retention := trashRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-retention)
+	return purgeTrash(database.GetDB().Unscoped().Where("deleted_at < ?", cutoff))
+}
+
+// StartTrashPurger 启动回收站定时清理
+func StartTrashPurger(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if purged, err := PurgeExpiredTrash(); err != nil {
+				logger.Error("清理回收站失败", zap.Error(err))
+			} else if purged > 0 {
+				logger.Info("已清理过期的回收站项目", zap.Int("count", purged))
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/models"
+	"imagebed/storage"
+	"imagebed/utils"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// maxUploadSessionsPerUser 每个用户同时进行的分片上传数量上限
+const maxUploadSessionsPerUser = 20
+
+// sha256Pattern 十六进制的 SHA-256
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// InitUploadRequest 创建分片上传会话请求
+type InitUploadRequest struct {
+	FileName    string `json:"fileName" binding:"required"`
+	FileSize    int64  `json:"fileSize" binding:"required"`
+	ContentType string `json:"contentType"`
+	AlbumID     uint   `json:"albumId"`  // 为空时上传到默认相册
+	Checksum    string `json:"checksum"` // 整个文件的 SHA-256（十六进制），合并后校验，可选
+}
+
+// uploadSessionView 上传会话和已收到的分片序号，断线后按此继续上传缺少的分片
+type uploadSessionView struct {
+	*models.UploadSession
+	UploadedChunks []int `json:"uploadedChunks"`
+}
+
+// chunkObjectPath 分片在存储中的暂存路径
+func chunkObjectPath(sessionID string, index int) string {
+	return path.Join("chunks", sessionID, strconv.Itoa(index))
+}
+
+// chunkLength 分片的大小，最后一个分片可能小于分片大小
+func chunkLength(session *models.UploadSession, index int) int64 {
+	if index == session.ChunkCount-1 {
+		return session.FileSize - int64(index)*session.ChunkSize
+	}
+	return session.ChunkSize
+}
+
+// newUploadSessionView 查询会话已收到的分片
+func newUploadSessionView(session *models.UploadSession) uploadSessionView {
+	indexes := []int{}
+	database.GetDB().Model(&models.UploadChunk{}).Where("session_id = ?", session.ID).
+		Order("chunk_index").Pluck("chunk_index", &indexes)
+	return uploadSessionView{UploadSession: session, UploadedChunks: indexes}
+}
+
+// loadUploadAlbum 加载上传的目标相册并检查上传权限，失败时输出错误响应
+func loadUploadAlbum(c *gin.Context, albumID uint) (*models.Album, bool) {
+	var album models.Album
+	if err := database.GetDB().Preload("Members").First(&album, albumID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+		return nil, false
+	}
+
+	userID, isAdmin := requestUser(c)
+	if !album.CanUpload(userID, isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权限上传到此相册"})
+		return nil, false
+	}
+	return &album, true
+}
+
+// loadUploadSession 加载当前用户未过期的上传会话，不存在时输出错误响应
+func loadUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	userID, _ := requestUser(c)
+
+	var session models.UploadSession
+	if err := database.GetDB().Where("id = ? AND user_id = ? AND expires_at > ?", c.Param("id"), userID, time.Now()).
+		First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "上传会话不存在或已过期"})
+		return nil, false
+	}
+	return &session, true
+}
+
+// deleteUploadSession 删除上传会话和暂存的分片
+func deleteUploadSession(session *models.UploadSession) {
+	db := database.GetDB()
+	store := storage.GetStorage()
+
+	var chunks []models.UploadChunk
+	db.Where("session_id = ?", session.ID).Find(&chunks)
+	for _, chunk := range chunks {
+		if err := store.Delete(chunk.FilePath); err != nil {
+			logger.Warn("删除上传分片失败", zap.String("path", chunk.FilePath), zap.Error(err))
+		}
+	}
+	db.Where("session_id = ?", session.ID).Delete(&models.UploadChunk{})
+	db.Delete(&models.UploadSession{}, "id = ?", session.ID)
+}
+
+// validateUploadRequest 校验上传的文件名、大小、校验值、用户配额和目标相册权限，失败时输出错误响应
+func validateUploadRequest(c *gin.Context, req *InitUploadRequest) (userID uint, ok bool) {
+	cfg := config.GetConfig()
+	req.FileName = path.Base(strings.ReplaceAll(strings.TrimSpace(req.FileName), "\\", "/"))
+	if ext := path.Ext(req.FileName); !utils.IsSupportedFormat(ext) {
+		respondUploadError(c, unsupportedFormatError(ext))
+		return 0, false
+	}
+	if req.FileSize <= 0 || req.FileSize > cfg.MaxFileSize*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("文件大小不能超过 %dMB", cfg.MaxFileSize),
+		})
+		return 0, false
+	}
+	req.Checksum = strings.ToLower(strings.TrimSpace(req.Checksum))
+	if req.Checksum != "" && !sha256Pattern.MatchString(req.Checksum) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件校验值必须是十六进制的 SHA-256"})
+		return 0, false
+	}
+	if req.AlbumID == 0 {
+		req.AlbumID = 1 // 默认相册
+	}
+
+	userID, _ = requestUser(c)
+	quota, err := loadUserQuota(userID)
+	if err == nil {
+		err = checkFileSizeQuota(quota, req.FileSize)
+	}
+	if err != nil {
+		respondUploadError(c, err)
+		return 0, false
+	}
+
+	if _, ok := loadUploadAlbum(c, req.AlbumID); !ok {
+		return 0, false
+	}
+	return userID, true
+}
+
+// InitUploadSession 创建分片上传会话，返回分片大小和分片数量
+func InitUploadSession(c *gin.Context) {
+	var req InitUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, ok := validateUploadRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	db := database.GetDB()
+	var active int64
+	db.Model(&models.UploadSession{}).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Count(&active)
+	if active >= maxUploadSessionsPerUser {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": fmt.Sprintf("最多同时进行 %d 个分片上传，请先完成或取消其他上传", maxUploadSessionsPerUser),
+		})
+		return
+	}
+
+	chunkSize := cfg.UploadChunkSize * 1024 * 1024
+	session := models.UploadSession{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		AlbumID:     req.AlbumID,
+		FileName:    req.FileName,
+		ContentType: req.ContentType,
+		FileSize:    req.FileSize,
+		ChunkSize:   chunkSize,
+		ChunkCount:  int((req.FileSize + chunkSize - 1) / chunkSize),
+		Checksum:    req.Checksum,
+		ExpiresAt:   time.Now().Add(cfg.UploadSessionTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": uploadSessionView{UploadSession: &session, UploadedChunks: []int{}}})
+}
+
+// GetUploadSession 查询上传会话和已收到的分片，用于断线后继续上传
+func GetUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": newUploadSessionView(session)})
+}
+
+// UploadChunk 上传一个分片，请求体为分片内容
+// 分片内容的 SHA-256 通过 X-Chunk-Checksum 头或 checksum 参数传递，校验失败时不保存
+// 重复上传同一分片会覆盖之前的内容
+func UploadChunk(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= session.ChunkCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("分片序号取值范围为 0-%d", session.ChunkCount-1)})
+		return
+	}
+
+	checksum := c.GetHeader("X-Chunk-Checksum")
+	if checksum == "" {
+		checksum = c.Query("checksum")
+	}
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if !sha256Pattern.MatchString(checksum) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少分片校验值（十六进制的 SHA-256）"})
+		return
+	}
+
+	expected := chunkLength(session, index)
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, expected+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取分片失败"})
+		return
+	}
+	if int64(len(data)) != expected {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("分片 %d 的大小应为 %d 字节", index, expected),
+		})
+		return
+	}
+	if contentHash(data) != checksum {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("分片 %d 校验失败，请重新上传", index)})
+		return
+	}
+
+	objectPath := chunkObjectPath(session.ID, index)
+	if _, err := storage.GetStorage().SaveFromReader(objectPath, bytes.NewReader(data), expected); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分片失败"})
+		return
+	}
+
+	db := database.GetDB()
+	chunk := models.UploadChunk{
+		SessionID: session.ID,
+		Index:     index,
+		Size:      expected,
+		Checksum:  checksum,
+		FilePath:  objectPath,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}, {Name: "chunk_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "checksum", "file_path"}),
+	}).Create(&chunk).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分片失败"})
+		return
+	}
+	db.Model(session).Update("expires_at", time.Now().Add(config.GetConfig().UploadSessionTTL))
+
+	var received int64
+	db.Model(&models.UploadChunk{}).Where("session_id = ?", session.ID).Count(&received)
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"index":      index,
+			"size":       expected,
+			"checksum":   checksum,
+			"received":   received,
+			"chunkCount": session.ChunkCount,
+		},
+	})
+}
+
+// CompleteUploadSession 合并全部分片，按普通上传流程创建图片记录
+// 支持与普通上传相同的 onDuplicate、enableShortLink 参数；创建失败时保留会话，可以重试
+func CompleteUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var chunks []models.UploadChunk
+	db.Where("session_id = ?", session.ID).Order("chunk_index").Find(&chunks)
+	if len(chunks) != session.ChunkCount {
+		missing := make([]int, 0, session.ChunkCount-len(chunks))
+		next := 0
+		for i := 0; i < session.ChunkCount; i++ {
+			if next < len(chunks) && chunks[next].Index == i {
+				next++
+				continue
+			}
+			missing = append(missing, i)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         fmt.Sprintf("还有 %d 个分片未上传", len(missing)),
+			"missingChunks": missing,
+		})
+		return
+	}
+
+	album, ok := loadUploadAlbum(c, session.AlbumID)
+	if !ok {
+		return
+	}
+
+	// 删除会话记录占用本次合并，避免重复完成时创建多条图片记录
+	if db.Delete(&models.UploadSession{}, "id = ?", session.ID).RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "上传会话不存在或已过期"})
+		return
+	}
+
+	data := make([]byte, 0, session.FileSize)
+	for _, chunk := range chunks {
+		content, err := readStoredFile(chunk.FilePath)
+		if err != nil || contentHash(content) != chunk.Checksum {
+			// 暂存的分片丢失或损坏，需要重新上传该分片
+			db.Delete(&chunk)
+			db.Create(session)
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         fmt.Sprintf("分片 %d 已损坏，请重新上传", chunk.Index),
+				"missingChunks": []int{chunk.Index},
+			})
+			return
+		}
+		data = append(data, content...)
+	}
+
+	if session.Checksum != "" && contentHash(data) != session.Checksum {
+		deleteUploadSession(session)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件校验失败，合并后的内容与上传前不一致，请重新上传"})
+		return
+	}
+
+	// 内容校验不通过时重试也无法成功，直接删除会话
+	if _, err := validateUploadContent(strings.ToLower(path.Ext(session.FileName)), data); err != nil {
+		deleteUploadSession(session)
+		respondUploadError(c, err)
+		return
+	}
+
+	saveUploadedImage(c, album, session.UserID, session.FileName, data)
+	if c.Writer.Status() >= http.StatusBadRequest {
+		// 配额不足等原因创建失败，恢复会话以便重试
+		db.Create(session)
+		return
+	}
+	deleteUploadSession(session)
+}
+
+// AbortUploadSession 取消分片上传，删除已上传的分片
+func AbortUploadSession(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	deleteUploadSession(session)
+	c.JSON(http.StatusOK, gin.H{"message": "已取消上传"})
+}
+
+// PurgeExpiredUploadSessions 删除过期的上传会话、直传记录和暂存的文件
+// 合并时中断等原因留下的、没有会话的分片超过保留时间后一并删除
+func PurgeExpiredUploadSessions() (int, error) {
+	db := database.GetDB()
+	now := time.Now()
+
+	var sessions []models.UploadSession
+	if err := db.Where("expires_at < ?", now).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+	for i := range sessions {
+		deleteUploadSession(&sessions[i])
+	}
+
+	var directUploads []models.DirectUpload
+	if err := db.Where("expires_at < ?", now).Find(&directUploads).Error; err != nil {
+		return len(sessions), err
+	}
+	for i := range directUploads {
+		deleteDirectUpload(&directUploads[i])
+	}
+	purged := len(sessions) + len(directUploads)
+
+	var orphans []models.UploadChunk
+	if err := db.Where("created_at < ? AND session_id NOT IN (?)",
+		now.Add(-config.GetConfig().UploadSessionTTL), db.Model(&models.UploadSession{}).Select("id")).
+		Find(&orphans).Error; err != nil {
+		return purged, err
+	}
+	store := storage.GetStorage()
+	for _, chunk := range orphans {
+		if err := store.Delete(chunk.FilePath); err != nil {
+			logger.Warn("删除上传分片失败", zap.String("path", chunk.FilePath), zap.Error(err))
+		}
+		db.Delete(&chunk)
+	}
+
+	return purged, nil
+}
+
+// StartUploadSessionCleaner 启动过期上传会话的定时清理
+func StartUploadSessionCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if purged, err := PurgeExpiredUploadSessions(); err != nil {
+				logger.Error("清理上传会话失败", zap.Error(err))
+			} else if purged > 0 {
+				logger.Info("已清理过期的上传会话", zap.Int("count", purged))
+			}
+			<-ticker.C
+		}
+	}()
+}
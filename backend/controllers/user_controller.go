@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"imagebed/database"
+	"imagebed/middleware"
 	"imagebed/models"
 	"imagebed/utils"
 	"net/http"
@@ -100,6 +101,12 @@ func GetUserStats(c *gin.Context) {
 	// 统计下载量
 	db.Model(&models.Image{}).Where("owner_id = ?", userID).Select("COALESCE(SUM(download_count), 0)").Scan(&stats.TotalDownloads)
 
+	// 配额及用量
+	var user models.User
+	if err := db.First(&user, userID).Error; err == nil {
+		stats.Quota = userQuota(&user)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": stats})
 }
 
@@ -335,6 +342,14 @@ func UpdateUserStatus(c *gin.Context) {
 		return
 	}
 
+	// 禁用后立即使该用户已登录的会话失效
+	if user.Status == "disabled" {
+		if err := middleware.RevokeUserTokens(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销用户令牌失败"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": user})
 }
 
@@ -372,13 +387,20 @@ func ResetUserPassword(c *gin.Context) {
 		return
 	}
 
+	// 密码变更后，之前签发的令牌全部失效
+	if err := middleware.RevokeUserTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销用户令牌失败"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "密码重置成功"})
 }
 
 // UpdateProfileRequest 更新个人资料请求
 type UpdateProfileRequest struct {
-	Avatar string `json:"avatar"`
-	Bio    string `json:"bio"`
+	Avatar        string `json:"avatar"`
+	Bio           string `json:"bio"`
+	StripMetadata *bool  `json:"stripMetadata"` // 为空时不修改
 }
 
 // UpdateProfile 更新个人资料
@@ -401,6 +423,9 @@ func UpdateProfile(c *gin.Context) {
 
 	user.Avatar = req.Avatar
 	user.Bio = req.Bio
+	if req.StripMetadata != nil {
+		user.StripMetadata = *req.StripMetadata
+	}
 
 	if err := db.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新资料失败"})
@@ -451,5 +476,18 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功"})
+	// 密码变更后，之前签发的令牌全部失效
+	if err := middleware.RevokeUserTokens(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销用户令牌失败"})
+		return
+	}
+
+	// 为当前会话签发新的令牌，其他设备需要重新登录
+	resp, err := issueAuthTokens(c, &user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码修改成功", "data": resp})
 }
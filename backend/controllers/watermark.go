@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/middleware"
+	"imagebed/models"
+	"imagebed/storage"
+	"imagebed/utils"
+	"imagebed/utils/imageprocessor"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// 水印设置的默认值和取值范围
+const (
+	defaultWatermarkPosition = "southeast"
+	defaultWatermarkOpacity  = 0.5
+	defaultWatermarkScale    = 0.25
+	defaultWatermarkColor    = "#ffffff"
+	maxWatermarkTextLength   = 100
+	watermarkQuality         = 90
+	watermarkCacheSize       = 64
+)
+
+// cleanDeliveryKey 请求上下文中的标记：图片设置了水印，但当前请求可以获取无水印的版本
+const cleanDeliveryKey = "cleanDelivery"
+
+var (
+	// watermarkCache 已创建的水印，按水印设置的键复用
+	watermarkCache   = make(map[string]*imageprocessor.Watermark)
+	watermarkCacheMu sync.Mutex
+)
+
+// deliveryMark 输出图片时叠加的水印
+type deliveryMark struct {
+	key       string // 区分水印设置和水印图片内容，参与输出结果的缓存键
+	watermark *imageprocessor.Watermark
+}
+
+// parseWatermarkSettings 将请求中的水印设置转换为结构，value 为空表示清除设置
+func parseWatermarkSettings(value interface{}) (*models.WatermarkSettings, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.New("水印设置格式错误")
+	}
+	var settings models.WatermarkSettings
+	if err := json.Unmarshal(encoded, &settings); err != nil {
+		return nil, errors.New("水印设置格式错误")
+	}
+	return &settings, nil
+}
+
+// validateWatermarkSettings 校验启用的水印设置并补全默认值
+// 图片水印只能使用 ownerID 的图片，管理员不受限制
+func validateWatermarkSettings(settings *models.WatermarkSettings, ownerID uint, isAdmin bool) error {
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+
+	if settings.Position == "" {
+		settings.Position = defaultWatermarkPosition
+	}
+	if !imageprocessor.ValidWatermarkPosition(settings.Position) {
+		return fmt.Errorf("不支持的水印位置: %s", settings.Position)
+	}
+	if settings.Opacity == 0 {
+		settings.Opacity = defaultWatermarkOpacity
+	}
+	if settings.Scale == 0 {
+		settings.Scale = defaultWatermarkScale
+	}
+	if settings.Opacity < 0 || settings.Opacity > 1 || settings.Scale < 0 || settings.Scale > 1 {
+		return errors.New("水印不透明度和缩放比例的取值范围为 0-1")
+	}
+
+	switch settings.Type {
+	case imageprocessor.WatermarkText:
+		settings.Text = strings.TrimSpace(settings.Text)
+		if settings.Text == "" || utf8.RuneCountInString(settings.Text) > maxWatermarkTextLength {
+			return fmt.Errorf("水印文字不能为空且不能超过 %d 个字符", maxWatermarkTextLength)
+		}
+		if settings.Color == "" {
+			settings.Color = defaultWatermarkColor
+		}
+		if _, err := imageprocessor.ParseHexColor(settings.Color); err != nil {
+			return err
+		}
+		settings.ImageID = 0
+	case imageprocessor.WatermarkImage:
+		if settings.ImageID == 0 {
+			return errors.New("请选择水印图片")
+		}
+		var mark models.Image
+		if err := database.GetDB().Select("id", "owner_id").First(&mark, settings.ImageID).Error; err != nil {
+			return errors.New("水印图片不存在")
+		}
+		if !isAdmin && mark.OwnerID != ownerID {
+			return errors.New("只能使用自己的图片作为水印")
+		}
+		settings.Text, settings.Color = "", ""
+	default:
+		return errors.New("水印类型必须为 text 或 image")
+	}
+	return nil
+}
+
+// enabledWatermark 启用时返回水印设置，否则返回 nil
+func enabledWatermark(settings *models.WatermarkSettings) *models.WatermarkSettings {
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+	return settings
+}
+
+// imageWatermarkSettings 图片生效的水印设置，没有时返回 nil
+// 相册设置了水印（包括设置为未启用）时使用相册的设置，否则使用相册处理配置中的水印
+func imageWatermarkSettings(imageRecord *models.Image) *models.WatermarkSettings {
+	var album models.Album
+	if err := database.GetDB().Select("id", "watermark", "processing_profile_id").
+		First(&album, imageRecord.AlbumID).Error; err == nil && album.Watermark != nil {
+		return enabledWatermark(album.Watermark)
+	}
+	return enabledWatermark(albumProcessingProfile(&album).Watermark)
+}
+
+// loadWatermark 按设置创建水印，设置和水印图片内容都不变时复用已创建的水印
+func loadWatermark(settings *models.WatermarkSettings) (*deliveryMark, error) {
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	key := string(encoded)
+
+	var markImage models.Image
+	if settings.Type == imageprocessor.WatermarkImage {
+		if err := database.GetDB().First(&markImage, settings.ImageID).Error; err != nil {
+			return nil, fmt.Errorf("水印图片 %d 不存在: %w", settings.ImageID, err)
+		}
+		key += "|" + variantBase(&markImage)
+	} else {
+		key += "|" + config.GetConfig().WatermarkFontPath
+	}
+	key = "watermark=" + variantID(key)
+
+	watermarkCacheMu.Lock()
+	cached := watermarkCache[key]
+	watermarkCacheMu.Unlock()
+	if cached != nil {
+		return &deliveryMark{key: key, watermark: cached}, nil
+	}
+
+	opts := imageprocessor.WatermarkOptions{
+		Position: settings.Position,
+		Opacity:  settings.Opacity,
+		Scale:    settings.Scale,
+		Tile:     settings.Tile,
+	}
+
+	var watermark *imageprocessor.Watermark
+	if settings.Type == imageprocessor.WatermarkImage {
+		data, err := readStoredFile(markImage.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("读取水印图片失败: %w", err)
+		}
+		img, err := utils.DecodeImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("解码水印图片失败: %w", err)
+		}
+		if watermark, err = imageprocessor.NewWatermark(img, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		textColor, err := imageprocessor.ParseHexColor(settings.Color)
+		if err != nil {
+			return nil, err
+		}
+		var fontData []byte
+		if fontPath := config.GetConfig().WatermarkFontPath; fontPath != "" {
+			if fontData, err = os.ReadFile(fontPath); err != nil {
+				return nil, fmt.Errorf("读取水印字体失败: %w", err)
+			}
+		}
+		if watermark, err = imageprocessor.NewTextWatermark(settings.Text, textColor, fontData, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	watermarkCacheMu.Lock()
+	if len(watermarkCache) >= watermarkCacheSize {
+		watermarkCache = make(map[string]*imageprocessor.Watermark)
+	}
+	watermarkCache[key] = watermark
+	watermarkCacheMu.Unlock()
+
+	return &deliveryMark{key: key, watermark: watermark}, nil
+}
+
+// deliveryWatermark 当前请求输出图片时需要叠加的水印，不需要时返回 nil
+// 所有者、管理员和持无水印签名URL的请求获取无水印的图片，普通签名URL仍然叠加水印
+// 无法创建水印时输出错误响应并返回 ok=false，不输出无水印的图片
+func deliveryWatermark(c *gin.Context, imageRecord *models.Image) (mark *deliveryMark, ok bool) {
+	settings := imageWatermarkSettings(imageRecord)
+	if settings == nil {
+		return nil, true
+	}
+
+	userID, isAdmin := requestUser(c)
+	if imageRecord.CanModify(userID, isAdmin) ||
+		middleware.VerifyCleanToken(imageRecord.UUID, c.Query("token"), c.Query("expires")) == nil {
+		c.Set(cleanDeliveryKey, true)
+		return nil, true
+	}
+
+	mark, err := loadWatermark(settings)
+	if err != nil {
+		logger.Error("创建水印失败", zap.Uint("imageId", imageRecord.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成水印失败"})
+		return nil, false
+	}
+	return mark, true
+}
+
+// deliveryCacheControl 输出图片时的缓存策略
+// 设置了水印的图片输出无水印版本时不允许 CDN 缓存，避免返回给其他访客
+func deliveryCacheControl(c *gin.Context, imageRecord *models.Image) string {
+	if c.GetBool(cleanDeliveryKey) {
+		return "private, no-cache"
+	}
+	return imageCacheControl(imageRecord)
+}
+
+// serveWatermarkedFile 输出叠加水印后的原图或缩略图，结果按来源文件和水印缓存在存储中
+// 动图逐帧叠加水印，重新编码后不再包含原图中的元数据
+func serveWatermarkedFile(c *gin.Context, imageRecord *models.Image, sourcePath string, mark *deliveryMark) {
+	db := database.GetDB()
+	store := storage.GetStorage()
+	base := variantBase(imageRecord)
+	key := mark.key + "&source=" + path.Base(sourcePath)
+	ext := strings.ToLower(path.Ext(sourcePath))
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		mimeType = imageRecord.MimeType
+	}
+
+	setContentDisposition(c, imageRecord, ext)
+	setValidators(c, imageETag(imageRecord, variantID(key)), imageRecord.UpdatedAt)
+	if checkNotModified(c) {
+		return
+	}
+
+	var variant models.ImageVariant
+	if err := db.Where("content_hash = ? AND transform_key = ?", base, key).First(&variant).Error; err == nil {
+		if storedFileExists(variant.FilePath) {
+			serveStoredFile(c, variant.FilePath, variant.FileSize, variant.MimeType)
+			return
+		}
+		// 缓存文件丢失，重新生成
+		db.Delete(&variant)
+	}
+
+	release := acquireTransformSlot()
+	data, err := readStoredFile(sourcePath)
+	if err != nil {
+		release()
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	output, err := utils.MapImageBytes(data, ext, watermarkQuality, animationLimits(), mark.watermark.Apply)
+	release()
+	if err != nil {
+		logger.Error("添加水印失败", zap.Uint("imageId", imageRecord.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成水印失败"})
+		return
+	}
+
+	// 写入缓存失败不影响本次响应
+	objectPath := variantObjectPath(base, key, ext)
+	if _, err := store.SaveFromReader(objectPath, bytes.NewReader(output), int64(len(output))); err != nil {
+		fmt.Printf("保存水印图片失败: %v\n", err)
+	} else {
+		db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ImageVariant{
+			ContentHash: base,
+			Key:         key,
+			FilePath:    objectPath,
+			FileSize:    int64(len(output)),
+			MimeType:    mimeType,
+		})
+	}
+
+	c.Data(http.StatusOK, mimeType, output)
+}
+
+// serveThumbnailFile 输出缩略图，缩略图就是原图时按原图输出，mark 不为空时输出叠加水印的版本
+func serveThumbnailFile(c *gin.Context, imageRecord *models.Image, thumbnailPath string, mark *deliveryMark) {
+	switch {
+	case thumbnailPath == imageRecord.FilePath:
+		serveOriginalImage(c, imageRecord, mark)
+	case mark != nil:
+		serveWatermarkedFile(c, imageRecord, thumbnailPath, mark)
+	default:
+		serveStoredFile(c, thumbnailPath, -1, "")
+	}
+}
@@ -60,12 +60,36 @@ func InitDatabase() error {
 
 	log.Printf("数据库连接池配置: MaxIdle=%d, MaxOpen=%d, MaxLifetime=%v", 10, 100, time.Hour)
 
+	// 配额用量字段首次创建时需要根据已有图片回填
+	needQuotaBackfill := DB.Migrator().HasTable(&models.User{}) &&
+		!DB.Migrator().HasColumn(&models.User{}, "storage_used")
+
+	// 标签表首次创建时需要从 images.tags 转换已有标签
+	needTagBackfill := DB.Migrator().HasTable(&models.Image{}) &&
+		!DB.Migrator().HasTable(&models.ImageTag{})
+
 	// 自动迁移数据库表
 	// 注意：对于已经手动迁移过权限字段的表，AutoMigrate会检测到并跳过
 	err = DB.AutoMigrate(
 		&models.User{}, // 先迁移User，因为Album和Image依赖它
 		&models.Album{},
 		&models.Image{},
+		&models.Blob{},
+		&models.APIToken{},
+		&models.RevokedToken{},
+		&models.RefreshToken{},
+		&models.Job{},
+		&models.ImageVariant{},
+		&models.ShareLink{},
+		&models.AlbumMember{},
+		&models.Tag{},
+		&models.ImageTag{},
+		&models.SearchDocument{},
+		&models.ImageMetadata{},
+		&models.ProcessingProfile{},
+		&models.UploadSession{},
+		&models.UploadChunk{},
+		&models.DirectUpload{},
 		&models.Statistics{},
 		&models.OperationLog{},
 		&models.SystemLog{},
@@ -74,6 +98,22 @@ func InitDatabase() error {
 		return fmt.Errorf("数据库迁移失败: %v", err)
 	}
 
+	if needQuotaBackfill {
+		if err := backfillQuotaUsage(); err != nil {
+			log.Printf("⚠️  回填用户配额用量失败: %v", err)
+		} else {
+			log.Println("已根据现有图片回填用户配额用量")
+		}
+	}
+
+	if needTagBackfill {
+		if migrated, err := MigrateImageTags(DB); err != nil {
+			log.Printf("⚠️  转换图片标签失败: %v", err)
+		} else if migrated > 0 {
+			log.Printf("已将 %d 张图片的标签转换为标签表", migrated)
+		}
+	}
+
 	// 创建默认管理员账号
 	var userCount int64
 	DB.Model(&models.User{}).Count(&userCount)
@@ -116,6 +156,13 @@ func InitDatabase() error {
 	return nil
 }
 
+// backfillQuotaUsage 根据已有图片统计每个用户的存储用量和图片数量
+func backfillQuotaUsage() error {
+	return DB.Exec(`UPDATE users SET
+		storage_used = (SELECT COALESCE(SUM(file_size), 0) FROM images WHERE images.owner_id = users.id AND images.deleted_at IS NULL),
+		image_count = (SELECT COUNT(*) FROM images WHERE images.owner_id = users.id AND images.deleted_at IS NULL)`).Error
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return DB
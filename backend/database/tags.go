@@ -0,0 +1,31 @@
+package database
+
+import (
+	"imagebed/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateImageTags 将 images.tags 中逗号分隔的标签转换为 tags 和 image_tags 记录，返回处理的图片数量
+// 已经存在的关联会被保留，可以重复执行
+func MigrateImageTags(db *gorm.DB) (int, error) {
+	migrated := 0
+	// 写入使用独立会话，避免继承查询条件
+	writer := db.Session(&gorm.Session{NewDB: true})
+	var images []models.Image
+	err := db.Unscoped().Select("id", "owner_id", "tags").
+		Where("tags IS NOT NULL AND tags <> ''").
+		FindInBatches(&images, 200, func(_ *gorm.DB, _ int) error {
+			for i := range images {
+				names := models.ParseTagNames(images[i].Tags)
+				if err := writer.Transaction(func(tx *gorm.DB) error {
+					return models.AddImageTags(tx, images[i:i+1], names)
+				}); err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		}).Error
+	return migrated, err
+}
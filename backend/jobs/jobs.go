@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imagebed/cache"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/models"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// queueKey Redis 中的任务队列
+const queueKey = "jobs:queue"
+
+// pollInterval 轮询数据库中到期任务的间隔（包括等待重试的任务）
+const pollInterval = 2 * time.Second
+
+// heartbeatInterval 执行中任务的心跳间隔，保证任务超时前至少上报数次心跳
+func heartbeatInterval(timeout time.Duration) time.Duration {
+	interval := timeout / 4
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// Handler 任务处理函数，返回值序列化为 JSON 后保存为任务结果
+type Handler func(ctx context.Context, job *models.Job) (interface{}, error)
+
+// permanentError 不需要重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 包装不可重试的错误，任务会直接进入死信列表
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]Handler)
+
+	managerMu sync.Mutex
+	manager   *Manager
+)
+
+// Register 注册任务处理函数
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+func getHandler(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[jobType]
+	return handler, ok
+}
+
+// EnqueueOption 提交任务的可选参数
+type EnqueueOption func(*models.Job)
+
+// WithUser 设置提交任务的用户
+func WithUser(userID uint) EnqueueOption {
+	return func(job *models.Job) {
+		job.UserID = userID
+	}
+}
+
+// WithMaxAttempts 设置最大尝试次数
+func WithMaxAttempts(attempts int) EnqueueOption {
+	return func(job *models.Job) {
+		job.MaxAttempts = attempts
+	}
+}
+
+// Enqueue 提交任务，任务先写入数据库再通知 worker
+// 任务管理器未启动时任务保持等待状态，启动后再执行
+func Enqueue(jobType string, payload interface{}, opts ...EnqueueOption) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务参数失败: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobStatusPending,
+		MaxAttempts: config.GetConfig().JobMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+
+	if err := database.GetDB().Create(job).Error; err != nil {
+		return nil, fmt.Errorf("保存任务失败: %w", err)
+	}
+
+	notify(job.ID)
+	return job, nil
+}
+
+// Get 获取任务
+func Get(id uint) (*models.Job, error) {
+	var job models.Job
+	if err := database.GetDB().First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Retry 重新执行死信列表中的任务
+func Retry(id uint) (*models.Job, error) {
+	db := database.GetDB()
+
+	result := db.Model(&models.Job{}).
+		Where("id = ? AND status = ?", id, models.JobStatusDead).
+		Updates(map[string]interface{}{
+			"status":      models.JobStatusPending,
+			"attempts":    0,
+			"run_at":      time.Now(),
+			"finished_at": nil,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("只能重试死信列表中的任务")
+	}
+
+	notify(id)
+	return Get(id)
+}
+
+// notify 通知 worker 有新任务
+func notify(id uint) {
+	if cache.IsEnabled() {
+		if err := cache.LPush(queueKey, id); err == nil {
+			return
+		}
+	}
+
+	managerMu.Lock()
+	m := manager
+	managerMu.Unlock()
+	if m != nil {
+		m.wakeup()
+	}
+}
+
+// backoff 第 attempt 次失败后的重试等待时间
+func backoff(attempt int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempt && delay < 5*time.Minute; i++ {
+		delay *= 2
+	}
+	if delay > 5*time.Minute {
+		delay = 5 * time.Minute
+	}
+	return delay
+}
+
+// newInstanceID 生成当前进程的实例ID
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
+// parseJobID 解析队列中的任务ID
+func parseJobID(value string) (uint, bool) {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		logger.Warn("无效的任务ID", zap.String("value", value))
+		return 0, false
+	}
+	return uint(id), true
+}
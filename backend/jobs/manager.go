@@ -0,0 +1,321 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"imagebed/cache"
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Manager 任务管理器，负责调度和执行任务
+type Manager struct {
+	id      string // 实例ID，记录在执行中的任务上
+	workers int
+	timeout time.Duration
+
+	work   chan uint
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start 启动任务管理器
+// 启动时将心跳已超时的运行中任务重新放回队列，其他实例正在执行的任务不受影响
+func Start(workers int, timeout time.Duration) *Manager {
+	if workers <= 0 {
+		workers = 1
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		id:      newInstanceID(),
+		workers: workers,
+		timeout: timeout,
+		work:    make(chan uint),
+		wake:    make(chan struct{}, 1),
+		cancel:  cancel,
+	}
+
+	if recovered := recoverStale(time.Now().Add(-timeout)); recovered > 0 {
+		logger.Info("已恢复中断的任务", zap.Int64("count", recovered))
+	}
+
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.worker(ctx)
+	}
+
+	go m.dispatch(ctx)
+	if cache.IsEnabled() {
+		go m.listen(ctx)
+	}
+
+	managerMu.Lock()
+	manager = m
+	managerMu.Unlock()
+
+	logger.Info("任务管理器已启动", zap.String("instance", m.id), zap.Int("workers", workers), zap.Bool("redis", cache.IsEnabled()))
+	return m
+}
+
+// Stop 停止调度新任务并等待执行中的任务完成
+// 超时仍未完成的任务保持运行状态，心跳超时后由其他实例或下次启动时重新执行
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cancel()
+
+	managerMu.Lock()
+	if manager == m {
+		manager = nil
+	}
+	managerMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) wakeup() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 定期从数据库取出到期的任务分发给 worker
+func (m *Manager) dispatch(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	lastRecover := time.Now()
+	for {
+		if time.Since(lastRecover) > time.Minute {
+			recoverStale(time.Now().Add(-m.timeout))
+			lastRecover = time.Now()
+		}
+
+		var ids []uint
+		database.GetDB().Model(&models.Job{}).
+			Where("status = ? AND run_at <= ?", models.JobStatusPending, time.Now()).
+			Order("run_at ASC, id ASC").Limit(m.workers*2).Pluck("id", &ids)
+
+		for _, id := range ids {
+			select {
+			case m.work <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// listen 从 Redis 队列接收新任务，降低任务的调度延迟
+func (m *Manager) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		value, err := cache.BRPop(time.Second, queueKey)
+		if err != nil {
+			continue
+		}
+
+		id, ok := parseJobID(value)
+		if !ok {
+			continue
+		}
+
+		select {
+		case m.work <- id:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// worker 执行任务，执行中的任务不受 ctx 取消影响
+func (m *Manager) worker(ctx context.Context) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.work:
+			if job, ok := claim(id, m.id); ok {
+				m.run(job)
+			}
+		}
+	}
+}
+
+// claim 原子地将任务标记为运行中，多个 worker 或实例只有一个能成功
+func claim(id uint, owner string) (*models.Job, bool) {
+	db := database.GetDB()
+	now := time.Now()
+
+	result := db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND run_at <= ?", id, models.JobStatusPending, now).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusRunning,
+			"attempts":     gorm.Expr("attempts + ?", 1),
+			"started_at":   now,
+			"owner":        owner,
+			"heartbeat_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	job, err := Get(id)
+	if err != nil {
+		return nil, false
+	}
+	return job, true
+}
+
+// run 执行任务并记录结果
+func (m *Manager) run(job *models.Job) {
+	handler, ok := getHandler(job.Type)
+	if !ok {
+		m.fail(job, Permanent(fmt.Errorf("未注册的任务类型: %s", job.Type)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	// 处理函数返回前持续上报心跳，即使已超时也不会被其他实例重新执行
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.heartbeat(job.ID, stop)
+
+	result, err := safeRun(ctx, handler, job)
+	if err != nil {
+		m.fail(job, err)
+		return
+	}
+
+	var resultJSON string
+	if result != nil {
+		if data, err := json.Marshal(result); err == nil {
+			resultJSON = string(data)
+		}
+	}
+
+	now := time.Now()
+	m.owned(job.ID).Updates(map[string]interface{}{
+		"status":      models.JobStatusSucceeded,
+		"result":      resultJSON,
+		"last_error":  "",
+		"finished_at": now,
+	})
+}
+
+// safeRun 执行任务处理函数，处理函数 panic 视为执行失败
+func safeRun(ctx context.Context, handler Handler, job *models.Job) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行异常: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// fail 记录任务失败，未超过最大尝试次数时按退避时间重新调度
+func (m *Manager) fail(job *models.Job, err error) {
+	var permanent *permanentError
+	updates := map[string]interface{}{
+		"last_error": err.Error(),
+	}
+
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		updates["status"] = models.JobStatusDead
+		updates["finished_at"] = time.Now()
+		logger.Error("任务失败，已进入死信列表",
+			zap.Uint("id", job.ID), zap.String("type", job.Type),
+			zap.Int("attempts", job.Attempts), zap.Error(err))
+	} else {
+		delay := backoff(job.Attempts)
+		updates["status"] = models.JobStatusPending
+		updates["run_at"] = time.Now().Add(delay)
+		logger.Warn("任务失败，等待重试",
+			zap.Uint("id", job.ID), zap.String("type", job.Type),
+			zap.Int("attempts", job.Attempts), zap.Duration("delay", delay), zap.Error(err))
+	}
+
+	m.owned(job.ID).Updates(updates)
+}
+
+// owned 当前实例仍在执行的任务
+// 心跳中断期间任务可能已被其他实例重新执行，此时不再更新任务状态
+func (m *Manager) owned(id uint) *gorm.DB {
+	return database.GetDB().Model(&models.Job{}).
+		Where("id = ? AND status = ? AND owner = ?", id, models.JobStatusRunning, m.id)
+}
+
+// heartbeat 定期更新执行中任务的心跳时间，直到 stop 关闭
+func (m *Manager) heartbeat(id uint, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval(m.timeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := m.owned(id).Update("heartbeat_at", time.Now()).Error; err != nil {
+				logger.Warn("更新任务心跳失败", zap.Uint("id", id), zap.Error(err))
+			}
+		}
+	}
+}
+
+// recoverStale 恢复心跳在 before 之前且仍处于运行状态的任务
+// 这些任务所在的实例已经退出或失去响应，无论属于哪个实例都重新执行
+func recoverStale(before time.Time) int64 {
+	db := database.GetDB()
+	// 升级前开始执行的任务没有心跳，按开始时间判断
+	stale := db.Where("heartbeat_at < ? OR (heartbeat_at IS NULL AND started_at < ?)", before, before)
+
+	// 尝试次数已用尽的直接进入死信列表
+	db.Model(&models.Job{}).
+		Where("status = ? AND attempts >= max_attempts", models.JobStatusRunning).Where(stale).
+		Updates(map[string]interface{}{
+			"status":      models.JobStatusDead,
+			"last_error":  "任务执行中断",
+			"finished_at": time.Now(),
+		})
+
+	result := db.Model(&models.Job{}).
+		Where("status = ?", models.JobStatusRunning).Where(stale).
+		Updates(map[string]interface{}{
+			"status": models.JobStatusPending,
+			"run_at": time.Now(),
+		})
+	return result.RowsAffected
+}
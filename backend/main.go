@@ -8,8 +8,10 @@ import (
 	"imagebed/controllers"
 	"imagebed/database"
 	_ "imagebed/docs" // Swagger 文档
+	"imagebed/jobs"
 	"imagebed/logger"
 	"imagebed/routes"
+	"imagebed/search"
 	"imagebed/storage"
 	"log"
 	"net/http"
@@ -67,6 +69,9 @@ func main() {
 		zap.String("dsn", maskSensitiveInfo(cfg.DatabaseDSN)),
 	)
 
+	// 初始化全文搜索
+	search.Init(cfg.DatabaseType)
+
 	// 初始化存储系统
 	if err := storage.InitStorageFromMap(cfg.GetStorageConfig().(map[string]interface{})); err != nil {
 		logger.Fatal("存储系统初始化失败", zap.Error(err))
@@ -86,6 +91,16 @@ func main() {
 	// 初始化控制器
 	controllers.InitImageController(cfg)
 
+	// 定时清理回收站中超过保留期的项目
+	controllers.StartTrashPurger(time.Hour)
+
+	// 定时清理过期的分片上传会话
+	controllers.StartUploadSessionCleaner(time.Hour)
+
+	// 启动后台任务
+	controllers.RegisterJobHandlers()
+	jobManager := jobs.Start(cfg.JobWorkers, cfg.JobTimeout)
+
 	// 设置路由
 	r := routes.SetupRoutes()
 
@@ -116,7 +131,7 @@ func main() {
 	}()
 
 	// 优雅关闭
-	gracefulShutdown(srv, cfg)
+	gracefulShutdown(srv, cfg, jobManager)
 }
 
 // maskSensitiveInfo 隐藏敏感信息(密码等)
@@ -128,7 +143,7 @@ func maskSensitiveInfo(dsn string) string {
 }
 
 // gracefulShutdown 优雅关闭服务器
-func gracefulShutdown(srv *http.Server, cfg *config.Config) {
+func gracefulShutdown(srv *http.Server, cfg *config.Config, jobManager *jobs.Manager) {
 	// 创建信号通道
 	quit := make(chan os.Signal, 1)
 	// 监听中断信号 (Ctrl+C) 和终止信号
@@ -152,6 +167,14 @@ func gracefulShutdown(srv *http.Server, cfg *config.Config) {
 		logger.Info("HTTP 服务器已关闭")
 	}
 
+	// 停止后台任务，未完成的任务下次启动时继续执行
+	logger.Info("正在停止后台任务...")
+	if err := jobManager.Stop(ctx); err != nil {
+		logger.Warn("等待后台任务完成超时", zap.Error(err))
+	} else {
+		logger.Info("后台任务已停止")
+	}
+
 	// 关闭数据库连接
 	logger.Info("正在关闭数据库连接...")
 	if sqlDB, err := database.DB.DB(); err == nil {
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authenticateAPIToken 校验个人访问令牌
+func authenticateAPIToken(raw, clientIP string) (*models.APIToken, *models.User, error) {
+	db := database.GetDB()
+
+	var token models.APIToken
+	if err := db.Where("token_hash = ?", utils.HashAPIToken(raw)).First(&token).Error; err != nil {
+		return nil, nil, errors.New("无效的访问令牌")
+	}
+	if token.IsExpired() {
+		return nil, nil, errors.New("访问令牌已过期")
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil {
+		return nil, nil, errors.New("用户不存在")
+	}
+	if user.Status == "disabled" {
+		return nil, nil, errors.New("用户已被禁用")
+	}
+
+	// 更新最后使用时间（每分钟最多写一次，避免频繁写库）
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		db.Model(&token).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": clientIP,
+		})
+	}
+
+	return &token, &user, nil
+}
+
+// tokenScopeAllowed 检查令牌是否满足接口要求的权限
+// 接口未声明权限时，读取类请求需要 read 权限，其余请求需要 admin 权限
+func tokenScopeAllowed(c *gin.Context, token *models.APIToken, scopes []string) bool {
+	if len(scopes) == 0 {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scopes = []string{models.ScopeRead}
+		default:
+			scopes = []string{models.ScopeAdmin}
+		}
+	}
+
+	for _, scope := range scopes {
+		if token.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAPITokenContext 将令牌对应的用户信息存储到上下文中
+// 只有管理员用户且令牌拥有 admin 权限时才具备管理员身份
+func setAPITokenContext(c *gin.Context, token *models.APIToken, user *models.User) {
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("isAdmin", user.Role == "admin" && token.HasScope(models.ScopeAdmin))
+	c.Set("authType", "token")
+	c.Set("apiTokenID", token.ID)
+	c.Set("tokenScopes", token.ScopeList())
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestAPIToken 为新建的用户创建个人访问令牌，返回令牌明文
+func createTestAPIToken(t *testing.T, username, role, scopes string) string {
+	db := database.GetDB()
+
+	user := models.User{Username: username, Email: username + "@test.com", Role: role}
+	if err := user.HashPassword("password123"); err != nil {
+		t.Fatalf("设置密码失败: %v", err)
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	raw, hash, prefix, err := utils.GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("生成访问令牌失败: %v", err)
+	}
+	token := models.APIToken{UserID: user.ID, Name: "test", TokenHash: hash, Prefix: prefix, Scopes: scopes}
+	if err := db.Create(&token).Error; err != nil {
+		t.Fatalf("创建访问令牌失败: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Unscoped().Delete(&token)
+		db.Unscoped().Delete(&user)
+	})
+	return raw
+}
+
+// newAPITokenRouter 与正式路由相同的权限声明：上传需要 upload 权限，管理接口需要管理员身份
+func newAPITokenRouter() *gin.Engine {
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"isAdmin": c.GetBool("isAdmin")}) }
+
+	r := gin.New()
+	r.GET("/images", AuthMiddleware(), ok)
+	r.POST("/images/upload", AuthMiddleware(models.ScopeUpload), ok)
+	r.DELETE("/images/1", AuthMiddleware(), ok)
+	r.GET("/admin/users", AuthMiddleware(), AdminMiddleware(), ok)
+	return r
+}
+
+func requestWithAPIToken(r *gin.Engine, method, path, token string) int {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+// TestAPITokenReadOnly 测试只读令牌可以读取但不能上传和修改
+func TestAPITokenReadOnly(t *testing.T) {
+	setupTestDB(t)
+	r := newAPITokenRouter()
+	token := createTestAPIToken(t, "readonlytoken", "user", models.ScopeRead)
+
+	assert.Equal(t, http.StatusOK, requestWithAPIToken(r, "GET", "/images", token))
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "POST", "/images/upload", token))
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "DELETE", "/images/1", token))
+
+	upload := createTestAPIToken(t, "uploadtoken", "user", models.ScopeRead+","+models.ScopeUpload)
+	assert.Equal(t, http.StatusOK, requestWithAPIToken(r, "POST", "/images/upload", upload))
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "DELETE", "/images/1", upload))
+}
+
+// TestAPITokenAdminScope 测试管理员用户的令牌只有拥有 admin 权限时才具备管理员身份
+func TestAPITokenAdminScope(t *testing.T) {
+	setupTestDB(t)
+	r := newAPITokenRouter()
+
+	limited := createTestAPIToken(t, "adminlimited", "admin", models.ScopeRead+","+models.ScopeUpload)
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "GET", "/admin/users", limited))
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "DELETE", "/images/1", limited))
+
+	full := createTestAPIToken(t, "adminfull", "admin", models.ScopeAdmin)
+	assert.Equal(t, http.StatusOK, requestWithAPIToken(r, "GET", "/admin/users", full))
+	assert.Equal(t, http.StatusOK, requestWithAPIToken(r, "DELETE", "/images/1", full))
+
+	// 普通用户即使拥有 admin 权限也不是管理员
+	user := createTestAPIToken(t, "useradminscope", "user", models.ScopeAdmin)
+	assert.Equal(t, http.StatusForbidden, requestWithAPIToken(r, "GET", "/admin/users", user))
+}
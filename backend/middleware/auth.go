@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"imagebed/models"
 	"imagebed/utils"
 	"net/http"
 	"strings"
@@ -9,7 +10,8 @@ import (
 )
 
 // AuthMiddleware JWT认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// 同时支持个人访问令牌，scopes 为令牌访问该接口需要的权限（满足其一即可）
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从 Authorization header 获取 token
 		authHeader := c.GetHeader("Authorization")
@@ -27,6 +29,25 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 个人访问令牌
+		if utils.IsAPIToken(parts[1]) {
+			token, user, err := authenticateAPIToken(parts[1], c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if !tokenScopeAllowed(c, token, scopes) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "访问令牌权限不足"})
+				c.Abort()
+				return
+			}
+
+			setAPITokenContext(c, token, user)
+			c.Next()
+			return
+		}
+
 		// 解析 token
 		claims, err := utils.ParseToken(parts[1])
 		if err != nil {
@@ -34,6 +55,17 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		revoked, err := IsTokenRevoked(claims)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "认证服务暂不可用，请稍后重试"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "认证令牌已失效，请重新登录"})
+			c.Abort()
+			return
+		}
 
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.UserID)
@@ -63,6 +95,16 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 个人访问令牌需要 read 权限
+		if utils.IsAPIToken(parts[1]) {
+			token, user, err := authenticateAPIToken(parts[1], c.ClientIP())
+			if err == nil && token.HasScope(models.ScopeRead) {
+				setAPITokenContext(c, token, user)
+			}
+			c.Next()
+			return
+		}
+
 		// 解析 token
 		claims, err := utils.ParseToken(parts[1])
 		if err != nil {
@@ -70,6 +112,11 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		if revoked, err := IsTokenRevoked(claims); err != nil || revoked {
+			// token 已吊销或无法确认吊销状态，继续执行但不设置用户信息
+			c.Next()
+			return
+		}
 
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.UserID)
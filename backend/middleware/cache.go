@@ -94,13 +94,10 @@ func (w *bodyLogWriter) Write(b []byte) (int, error) {
 // generateCacheKey 生成缓存键
 func generateCacheKey(c *gin.Context) string {
 	// 使用 URL、查询参数和用户ID生成唯一键
+	// 不同用户可见的内容不同（私有图片、签名地址），必须区分
 	userID := ""
-	if user, exists := c.Get("user"); exists {
-		if u, ok := user.(map[string]interface{}); ok {
-			if id, ok := u["id"]; ok {
-				userID = fmt.Sprintf("%v", id)
-			}
-		}
+	if id, exists := c.Get("userID"); exists {
+		userID = fmt.Sprintf("%v", id)
 	}
 
 	key := fmt.Sprintf("%s:%s:%s:%s",
@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"imagebed/config"
 	"net/http"
@@ -57,51 +58,83 @@ func HotlinkProtection() gin.HandlerFunc {
 	}
 }
 
+// 签名URL校验错误
+var (
+	ErrSignedURLMissing        = errors.New("Missing token or expires parameter")
+	ErrSignedURLInvalidExpires = errors.New("Invalid expires parameter")
+	ErrSignedURLExpired        = errors.New("Token expired")
+	ErrSignedURLInvalid        = errors.New("Invalid token")
+)
+
 // TokenProtection Token 验证中间件
 func TokenProtection() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取 token 和过期时间
-		token := c.Query("token")
-		expiresStr := c.Query("expires")
-
-		if token == "" || expiresStr == "" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Missing token or expires parameter"})
-			c.Abort()
-			return
-		}
-
-		// 验证过期时间
-		expires, err := strconv.ParseInt(expiresStr, 10, 64)
+		err := VerifySignedToken(c.Param("uuid"), c.Query("token"), c.Query("expires"))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires parameter"})
+			status := http.StatusForbidden
+			if errors.Is(err, ErrSignedURLInvalidExpires) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		if time.Now().Unix() > expires {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Token expired"})
-			c.Abort()
-			return
-		}
+		c.Next()
+	}
+}
 
-		// 验证签名
-		uuid := c.Param("uuid")
-		expectedToken := GenerateToken(uuid, expires)
+// VerifySignedToken 校验签名URL中的 token 和过期时间
+// 无水印的签名同样可以用来访问图片
+func VerifySignedToken(uuid, token, expiresStr string) error {
+	err := verifySignature(uuid, token, expiresStr, GenerateToken)
+	if errors.Is(err, ErrSignedURLInvalid) && verifySignature(uuid, token, expiresStr, GenerateCleanToken) == nil {
+		return nil
+	}
+	return err
+}
 
-		if token != expectedToken {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+// VerifyCleanToken 校验无水印签名，普通签名URL校验失败
+func VerifyCleanToken(uuid, token, expiresStr string) error {
+	return verifySignature(uuid, token, expiresStr, GenerateCleanToken)
+}
 
-		c.Next()
+// verifySignature 按指定的签名方式校验 token 和过期时间
+func verifySignature(uuid, token, expiresStr string, sign func(uuid string, expires int64) string) error {
+	if token == "" || expiresStr == "" {
+		return ErrSignedURLMissing
+	}
+
+	// 验证过期时间
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return ErrSignedURLInvalidExpires
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrSignedURLExpired
+	}
+
+	// 验证签名
+	if !hmac.Equal([]byte(token), []byte(sign(uuid, expires))) {
+		return ErrSignedURLInvalid
 	}
+
+	return nil
 }
 
 // GenerateToken 生成访问令牌
 func GenerateToken(uuid string, expires int64) string {
+	return signMessage(fmt.Sprintf("%s:%d", uuid, expires))
+}
+
+// GenerateCleanToken 生成无水印的访问令牌，与普通令牌使用不同的签名内容，只签发给能修改图片的用户
+func GenerateCleanToken(uuid string, expires int64) string {
+	return signMessage(fmt.Sprintf("clean:%s:%d", uuid, expires))
+}
+
+func signMessage(message string) string {
 	cfg := config.GetConfig()
-	message := fmt.Sprintf("%s:%d", uuid, expires)
 	h := hmac.New(sha256.New, []byte(cfg.SecretKey))
 	h.Write([]byte(message))
 	return hex.EncodeToString(h.Sum(nil))
@@ -113,3 +146,10 @@ func GenerateSignedURL(uuid string, ttl time.Duration) string {
 	token := GenerateToken(uuid, expires)
 	return fmt.Sprintf("/i/%s?token=%s&expires=%d", uuid, token, expires)
 }
+
+// GenerateCleanSignedURL 生成无水印的签名 URL
+func GenerateCleanSignedURL(uuid string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+	token := GenerateCleanToken(uuid, expires)
+	return fmt.Sprintf("/i/%s?token=%s&expires=%d", uuid, token, expires)
+}
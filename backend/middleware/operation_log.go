@@ -59,6 +59,30 @@ func OperationLogMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RecordOperation 主动记录一条操作日志，用于中间件不会记录的操作（如访客通过分享链接访问）
+func RecordOperation(c *gin.Context, action, module string, resourceID uint, description string) {
+	userID, _ := c.Get("userID")
+	username, _ := c.Get("username")
+
+	log := models.OperationLog{
+		UserID:      getUintValue(userID),
+		Username:    getStringValue(username),
+		Action:      action,
+		Module:      module,
+		ResourceID:  resourceID,
+		Description: description,
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		Status:      c.Writer.Status(),
+	}
+
+	go func() {
+		database.GetDB().Create(&log)
+	}()
+}
+
 // shouldLogOperation 判断是否需要记录操作日志
 func shouldLogOperation(c *gin.Context) bool {
 	path := c.Request.URL.Path
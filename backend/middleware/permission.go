@@ -9,8 +9,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// CheckAlbumOwnership 检查相册所有权中间件
+// CheckAlbumOwnership 检查相册所有权中间件（仅所有者和管理员）
 func CheckAlbumOwnership() gin.HandlerFunc {
+	return checkAlbumPermission((*models.Album).CanManage)
+}
+
+// CheckAlbumEditPermission 检查相册编辑权限中间件（所有者、编辑者和管理员）
+func CheckAlbumEditPermission() gin.HandlerFunc {
+	return checkAlbumPermission((*models.Album).CanModify)
+}
+
+// checkAlbumPermission 按 allowed 检查当前用户对相册的权限
+func checkAlbumPermission(allowed func(*models.Album, uint, bool) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, _ := c.Get("userID")
 		isAdmin, _ := c.Get("isAdmin")
@@ -25,14 +35,14 @@ func CheckAlbumOwnership() gin.HandlerFunc {
 
 		db := database.GetDB()
 		var album models.Album
-		if err := db.First(&album, albumID).Error; err != nil {
+		if err := db.Preload("Members").First(&album, albumID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
 			c.Abort()
 			return
 		}
 
 		// 检查权限
-		if !album.CanModify(userID.(uint), isAdmin.(bool)) {
+		if !allowed(&album, userID.(uint), isAdmin.(bool)) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "无权限操作此相册"})
 			c.Abort()
 			return
@@ -104,7 +114,7 @@ func CheckAlbumAccess() gin.HandlerFunc {
 
 		db := database.GetDB()
 		var album models.Album
-		if err := db.Preload("Owner").First(&album, albumID).Error; err != nil {
+		if err := db.Preload("Owner").Preload("Members").First(&album, albumID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
 			c.Abort()
 			return
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"imagebed/cache"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm/clause"
+)
+
+// 吊销列表在 Redis 中的键
+func revokedTokenKey(jti string) string {
+	return fmt.Sprintf("auth:revoked:%s", jti)
+}
+
+func revokedUserKey(userID uint) string {
+	return fmt.Sprintf("auth:revoked:user:%d", userID)
+}
+
+// userRevocationJTI 用户级吊销记录在数据库中的 JTI
+func userRevocationJTI(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// RevokeToken 吊销单个 JWT，直到其自然过期
+func RevokeToken(claims *utils.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if cache.IsEnabled() {
+		return cache.Set(revokedTokenKey(claims.ID), claims.UserID, ttl)
+	}
+
+	db := database.GetDB()
+	// 顺便清理已过期的吊销记录
+	db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error
+}
+
+// RevokeUserTokens 吊销用户当前已签发的全部 JWT 和刷新令牌
+// 用于修改密码、重置密码和禁用用户；吊销时间取下一毫秒并等到该时刻才返回，
+// 之前签发的令牌签发时间都早于吊销时间，之后签发的令牌不会被误判为已吊销
+func RevokeUserTokens(userID uint) error {
+	revokedAt := time.Now().Truncate(time.Millisecond).Add(time.Millisecond)
+	time.Sleep(time.Until(revokedAt))
+
+	// 刷新令牌只保存在数据库中
+	if err := database.GetDB().Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error; err != nil {
+		return err
+	}
+
+	if cache.IsEnabled() {
+		return cache.Set(revokedUserKey(userID), revokedAt.UnixMilli(), utils.TokenTTL())
+	}
+
+	return database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "jti"}},
+		DoUpdates: clause.AssignmentColumns([]string{"created_at", "expires_at"}),
+	}).Create(&models.RevokedToken{
+		JTI:       userRevocationJTI(userID),
+		UserID:    userID,
+		ExpiresAt: revokedAt.Add(utils.TokenTTL()),
+		CreatedAt: revokedAt,
+	}).Error
+}
+
+// IsTokenRevoked 检查 JWT 是否已被吊销
+// 签发时间（毫秒）早于用户级吊销时间的令牌视为已吊销；查询吊销列表失败时返回错误，由调用方拒绝请求
+func IsTokenRevoked(claims *utils.Claims) (bool, error) {
+	issuedAt := claims.IssuedAtMillis()
+
+	if cache.IsEnabled() {
+		if claims.ID != "" && cache.Exists(revokedTokenKey(claims.ID)) {
+			return true, nil
+		}
+		var revokedAt int64
+		if err := cache.Get(revokedUserKey(claims.UserID), &revokedAt); err != nil {
+			if errors.Is(err, redis.Nil) {
+				return false, nil
+			}
+			return false, err
+		}
+		return issuedAt < revokedAt, nil
+	}
+
+	jtis := []string{userRevocationJTI(claims.UserID)}
+	if claims.ID != "" {
+		jtis = append(jtis, claims.ID)
+	}
+
+	var records []models.RevokedToken
+	if err := database.GetDB().Where("jti IN ? AND expires_at > ?", jtis, time.Now()).Find(&records).Error; err != nil {
+		return false, err
+	}
+
+	for _, record := range records {
+		if record.JTI != userRevocationJTI(claims.UserID) {
+			return true, nil
+		}
+		if issuedAt < record.CreatedAt.UnixMilli() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"imagebed/cache"
+	"imagebed/config"
+	"imagebed/database"
+	"imagebed/models"
+	"imagebed/utils"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	// 配置加载时会在当前目录创建 data、logs 等目录，测试在临时目录中运行
+	dir, err := os.MkdirTemp("", "imagebed-middleware-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Chdir(dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestDB 初始化测试数据库，并关闭 Redis
+func setupTestDB(t *testing.T) {
+	config.LoadConfig()
+	if err := database.InitDatabase(); err != nil {
+		t.Fatalf("初始化测试数据库失败: %v", err)
+	}
+	database.GetDB().Exec("DELETE FROM revoked_tokens")
+	database.GetDB().Exec("DELETE FROM refresh_tokens")
+}
+
+// fakeRedis 仅实现吊销列表用到的命令的 Redis 服务
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	data     map[string]string
+	failGet  atomic.Bool
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动测试 Redis 失败: %v", err)
+	}
+	server := &fakeRedis{listener: listener, data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, s.handle(args))
+	}
+}
+
+func (s *fakeRedis) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		s.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		if s.failGet.Load() {
+			return "-ERR unavailable\r\n"
+		}
+		value, ok := s.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "EXISTS":
+		count := 0
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				count++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", count)
+	case "CLIENT":
+		return "+OK\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// readCommand 读取一条 RESP 数组格式的命令
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid command: %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// enableFakeRedis 让吊销列表改用测试 Redis
+func enableFakeRedis(t *testing.T) *fakeRedis {
+	server := startFakeRedis(t)
+	cfg := config.GetConfig()
+	cfg.RedisEnabled = true
+	cfg.RedisAddr = server.listener.Addr().String()
+	if err := cache.InitRedis(); err != nil {
+		t.Fatalf("连接测试 Redis 失败: %v", err)
+	}
+	t.Cleanup(func() { cfg.RedisEnabled = false })
+	return server
+}
+
+// requestWithToken 使用 JWT 访问需要登录的接口
+func requestWithToken(token string) int {
+	r := gin.New()
+	r.GET("/me", AuthMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"userID": c.GetUint("userID")})
+	})
+
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func generateTestToken(t *testing.T, userID uint) (string, *utils.Claims) {
+	token, err := utils.GenerateToken(userID, "revoketest", "user")
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	claims, err := utils.ParseToken(token)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	return token, claims
+}
+
+// assertRevocation 检查退出登录和用户级吊销（修改密码、禁用用户）的效果
+func assertRevocation(t *testing.T, userID uint) {
+	token, claims := generateTestToken(t, userID)
+	other, _ := generateTestToken(t, userID)
+	assert.Equal(t, http.StatusOK, requestWithToken(token))
+
+	// 退出登录只吊销当前令牌
+	assert.NoError(t, RevokeToken(claims))
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(token))
+	assert.Equal(t, http.StatusOK, requestWithToken(other))
+
+	// 用户级吊销使之前签发的令牌全部失效，之后立即签发的令牌仍然有效
+	assert.NoError(t, RevokeUserTokens(userID))
+	fresh, _ := generateTestToken(t, userID)
+	assert.Equal(t, http.StatusUnauthorized, requestWithToken(other))
+	assert.Equal(t, http.StatusOK, requestWithToken(fresh))
+
+	// 其他用户不受影响
+	unrelated, _ := generateTestToken(t, userID+1)
+	assert.Equal(t, http.StatusOK, requestWithToken(unrelated))
+}
+
+// TestTokenRevocationDatabase 测试未启用 Redis 时基于数据库的吊销列表
+func TestTokenRevocationDatabase(t *testing.T) {
+	setupTestDB(t)
+	assertRevocation(t, 1001)
+}
+
+// TestTokenRevocationRedis 测试基于 Redis 的吊销列表
+func TestTokenRevocationRedis(t *testing.T) {
+	setupTestDB(t)
+	enableFakeRedis(t)
+	assertRevocation(t, 2001)
+}
+
+// TestTokenRevocationLookupFailure 测试无法查询吊销列表时拒绝请求
+func TestTokenRevocationLookupFailure(t *testing.T) {
+	setupTestDB(t)
+
+	t.Run("database", func(t *testing.T) {
+		token, _ := generateTestToken(t, 3001)
+		db := database.GetDB()
+		assert.NoError(t, db.Migrator().DropTable(&models.RevokedToken{}))
+		defer db.AutoMigrate(&models.RevokedToken{})
+
+		assert.Equal(t, http.StatusServiceUnavailable, requestWithToken(token))
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := enableFakeRedis(t)
+		token, _ := generateTestToken(t, 3002)
+		server.failGet.Store(true)
+
+		assert.Equal(t, http.StatusServiceUnavailable, requestWithToken(token))
+	})
+}
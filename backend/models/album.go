@@ -1,8 +1,6 @@
 package models
 
 import (
-	"strconv"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -16,18 +14,30 @@ type Album struct {
 	CoverImage  string `json:"coverImage" gorm:"type:varchar(500)"`
 	ImageCount  int    `json:"imageCount" gorm:"default:0"`
 	// 权限控制字段
-	OwnerID     uint   `json:"ownerId" gorm:"index;not null"`             // 所有者ID
-	Owner       *User  `json:"owner,omitempty" gorm:"foreignKey:OwnerID"` // 所有者信息
-	IsPrivate   bool   `json:"isPrivate" gorm:"default:false;index"`      // 是否私有
-	IsPublic    bool   `json:"isPublic" gorm:"default:true"`              // 是否公开（可被其他人查看）
-	AllowShare  bool   `json:"allowShare" gorm:"default:true"`            // 是否允许分享链接
-	SharedUsers string `json:"sharedUsers,omitempty" gorm:"type:text"`    // 共享给的用户ID列表，逗号分隔
+	OwnerID    uint  `json:"ownerId" gorm:"index;not null"`             // 所有者ID
+	Owner      *User `json:"owner,omitempty" gorm:"foreignKey:OwnerID"` // 所有者信息
+	IsPrivate  bool  `json:"isPrivate" gorm:"default:false;index"`      // 是否私有
+	IsPublic   bool  `json:"isPublic" gorm:"default:true"`              // 是否公开（可被其他人查看）
+	AllowShare bool  `json:"allowShare" gorm:"default:true"`            // 是否允许分享链接
+	// 相册成员，判断成员权限前需要预加载
+	Members     []AlbumMember `json:"members,omitempty" gorm:"foreignKey:AlbumID"`
+	MyRole      string        `json:"myRole,omitempty" gorm:"-"` // 当前用户作为成员的角色
+	MemberCount int           `json:"memberCount" gorm:"-"`      // 成员数量
 	// 短链配置字段
 	EnableShortLink bool           `json:"enableShortLink" gorm:"default:false"` // 是否自动为上传的图片生成短链
 	CreatedAt       time.Time      `json:"createdAt"`
 	UpdatedAt       time.Time      `json:"updatedAt"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 	Images          []Image        `json:"images,omitempty" gorm:"foreignKey:AlbumID"`
+
+	// 访问原图时是否删除 GPS 等敏感元数据，为空时使用所有者的设置
+	StripMetadata *bool `json:"stripMetadata"`
+
+	// 上传和后台处理使用的处理配置，为空时使用系统默认配置
+	ProcessingProfileID *uint `json:"processingProfileId" gorm:"index"`
+
+	// 未登录访客和其他用户访问图片时叠加的水印，为空时使用处理配置中的水印
+	Watermark *WatermarkSettings `json:"watermark" gorm:"type:text;serializer:json"`
 }
 
 // Image 图片模型
@@ -40,6 +50,7 @@ type Image struct {
 	FilePath      string     `json:"filePath" gorm:"type:varchar(500);not null"`
 	FileSize      int64      `json:"fileSize"`
 	MimeType      string     `json:"mimeType" gorm:"type:varchar(100)"`
+	ContentHash   string     `json:"contentHash" gorm:"type:varchar(64);index"` // 文件内容 SHA-256，对应 blobs.hash
 	Width         int        `json:"width"`
 	Height        int        `json:"height"`
 	Thumbnail     string     `json:"thumbnail" gorm:"type:varchar(500)"`
@@ -47,19 +58,34 @@ type Image struct {
 	ViewCount     int64      `json:"viewCount" gorm:"default:0;index"`     // 访问次数
 	DownloadCount int64      `json:"downloadCount" gorm:"default:0;index"` // 下载次数
 	LastViewAt    *time.Time `json:"lastViewAt"`                           // 最后访问时间
-	Tags          string     `json:"tags" gorm:"type:text"`                // 标签，逗号分隔
+	Tags          string     `json:"tags" gorm:"type:text"`                // 标签，逗号分隔，由 image_tags 同步
 	// 权限控制字段
 	OwnerID       uint  `json:"ownerId" gorm:"index;not null"`             // 所有者ID
 	Owner         *User `json:"owner,omitempty" gorm:"foreignKey:OwnerID"` // 所有者信息
 	IsPrivate     bool  `json:"isPrivate" gorm:"default:false;index"`      // 是否私有
 	IsPublic      bool  `json:"isPublic" gorm:"default:true"`              // 是否公开
 	AllowDownload bool  `json:"allowDownload" gorm:"default:true"`         // 是否允许下载
+	// 描述信息，参与搜索
+	Description string `json:"description" gorm:"type:text"`     // 图片描述
+	AltText     string `json:"altText" gorm:"type:varchar(500)"` // 替代文本，用于无障碍访问
+	// 上传时解析的 EXIF/XMP 元数据
+	Metadata *ImageMetadata `json:"metadata,omitempty" gorm:"foreignKey:ImageID"`
 	// 短链字段
 	ShortLinkCode string         `json:"shortLinkCode" gorm:"type:varchar(50);index"` // 短链代码
 	ShortLinkURL  string         `json:"shortLinkUrl" gorm:"type:varchar(255)"`       // 短链完整URL
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
 	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 回收站：是否随相册一起删除，恢复相册时一并恢复
+	DeletedWithAlbum bool `json:"-" gorm:"default:false;index"`
+
+	// 感知哈希（dHash，16 位十六进制），用于查找相似图片，对应 blobs.perceptual_hash
+	PerceptualHash string `json:"perceptualHash,omitempty" gorm:"type:varchar(16);index"`
+
+	// 动图信息：帧数（静态图片为 1，0 表示未知）和播放一遍的时长（毫秒），对应 blobs 中的同名字段
+	FrameCount int `json:"frameCount,omitempty"`
+	Duration   int `json:"duration,omitempty"`
 }
 
 // TableName 指定表名
@@ -88,6 +114,25 @@ func (Statistics) TableName() string {
 	return "statistics"
 }
 
+// Member 查找用户的成员记录，需要预加载 Members
+func (a *Album) Member(userID uint) *AlbumMember {
+	if userID == 0 {
+		return nil
+	}
+	for i := range a.Members {
+		if a.Members[i].UserID == userID {
+			return &a.Members[i]
+		}
+	}
+	return nil
+}
+
+// hasMemberRole 用户是否为相册成员且角色不低于指定角色
+func (a *Album) hasMemberRole(userID uint, role string) bool {
+	member := a.Member(userID)
+	return member != nil && member.HasRole(role)
+}
+
 // CanAccess 检查用户是否可以访问相册
 func (a *Album) CanAccess(userID uint, isAdmin bool) bool {
 	// 管理员可以访问所有相册
@@ -102,11 +147,13 @@ func (a *Album) CanAccess(userID uint, isAdmin bool) bool {
 	if a.IsPublic && !a.IsPrivate {
 		return true
 	}
-	// 检查是否在共享列表中
-	if a.SharedUsers != "" && userID > 0 {
-		return contains(a.SharedUsers, userID)
-	}
-	return false
+	// 相册成员可以访问
+	return a.hasMemberRole(userID, AlbumRoleViewer)
+}
+
+// CanUpload 检查用户是否可以上传图片到相册
+func (a *Album) CanUpload(userID uint, isAdmin bool) bool {
+	return a.CanModify(userID, isAdmin) || a.hasMemberRole(userID, AlbumRoleContributor)
 }
 
 // CanModify 检查用户是否可以修改相册
@@ -115,8 +162,14 @@ func (a *Album) CanModify(userID uint, isAdmin bool) bool {
 	if isAdmin {
 		return true
 	}
-	// 只有所有者可以修改
-	return a.OwnerID == userID
+	// 所有者和编辑者可以修改
+	return a.OwnerID == userID || a.hasMemberRole(userID, AlbumRoleEditor)
+}
+
+// CanManage 检查用户是否可以管理相册（删除相册、管理成员和分享链接）
+func (a *Album) CanManage(userID uint, isAdmin bool) bool {
+	// 只有管理员和所有者可以管理
+	return isAdmin || a.OwnerID == userID
 }
 
 // CanAccess 检查用户是否可以访问图片
@@ -163,35 +216,3 @@ func (i *Image) CanDownload(userID uint, isAdmin bool) bool {
 	// 公开图片可以下载
 	return i.IsPublic && !i.IsPrivate
 }
-
-// contains 检查用户ID是否在逗号分隔的字符串中
-func contains(list string, userID uint) bool {
-	if list == "" {
-		return false
-	}
-	ids := splitToUints(list)
-	for _, id := range ids {
-		if id == userID {
-			return true
-		}
-	}
-	return false
-}
-
-// splitToUints 将逗号分隔的字符串转换为uint数组
-func splitToUints(s string) []uint {
-	if s == "" {
-		return []uint{}
-	}
-	parts := strings.Split(s, ",")
-	result := make([]uint, 0, len(parts))
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			if id, err := strconv.ParseUint(p, 10, 32); err == nil {
-				result = append(result, uint(id))
-			}
-		}
-	}
-	return result
-}
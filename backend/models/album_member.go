@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// 相册成员角色
+const (
+	AlbumRoleViewer      = "viewer"      // 只能查看相册和图片
+	AlbumRoleContributor = "contributor" // 可以查看并上传图片
+	AlbumRoleEditor      = "editor"      // 可以修改相册信息
+)
+
+// albumRoleLevels 角色等级，高等级包含低等级的权限
+var albumRoleLevels = map[string]int{
+	AlbumRoleViewer:      1,
+	AlbumRoleContributor: 2,
+	AlbumRoleEditor:      3,
+}
+
+// AlbumMember 相册成员，相册所有者将相册共享给其他用户
+type AlbumMember struct {
+	ID        uint        `json:"id" gorm:"primarykey"`
+	AlbumID   uint        `json:"albumId" gorm:"uniqueIndex:idx_album_members_album_user;not null"`
+	UserID    uint        `json:"userId" gorm:"uniqueIndex:idx_album_members_album_user;index;not null"`
+	User      *MemberUser `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role      string      `json:"role" gorm:"type:varchar(20);not null;default:viewer"` // viewer / contributor / editor
+	AddedBy   uint        `json:"addedBy"`                                              // 添加者ID
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+func (AlbumMember) TableName() string {
+	return "album_members"
+}
+
+// MemberUser 成员的公开信息，避免向其他成员暴露邮箱等字段
+type MemberUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+func (MemberUser) TableName() string {
+	return "users"
+}
+
+// IsValidAlbumRole 检查相册成员角色是否有效
+func IsValidAlbumRole(role string) bool {
+	_, ok := albumRoleLevels[role]
+	return ok
+}
+
+// HasRole 成员的角色是否不低于指定角色
+func (m *AlbumMember) HasRole(role string) bool {
+	required, ok := albumRoleLevels[role]
+	return ok && albumRoleLevels[m.Role] >= required
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API 令牌权限范围
+const (
+	ScopeRead   = "read"   // 读取图片、相册等信息
+	ScopeUpload = "upload" // 上传图片
+	ScopeAdmin  = "admin"  // 完全访问（管理员用户可访问管理接口）
+)
+
+// ValidScopes 所有可用的权限范围
+var ValidScopes = []string{ScopeRead, ScopeUpload, ScopeAdmin}
+
+// APIToken 个人访问令牌，用于脚本和 CI 等非交互式调用
+type APIToken struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	UserID     uint           `json:"userId" gorm:"index;not null"`
+	User       *User          `json:"-" gorm:"foreignKey:UserID"`
+	Name       string         `json:"name" gorm:"type:varchar(100);not null"`
+	TokenHash  string         `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"` // 令牌的 SHA-256，明文只在创建时返回一次
+	Prefix     string         `json:"prefix" gorm:"type:varchar(20)"`                 // 令牌前缀，便于识别
+	Scopes     string         `json:"scopes" gorm:"type:varchar(255)"`                // 权限范围，逗号分隔
+	ExpiresAt  *time.Time     `json:"expiresAt"`                                      // 过期时间，为空表示永不过期
+	LastUsedAt *time.Time     `json:"lastUsedAt"`                                     // 最后使用时间
+	LastUsedIP string         `json:"lastUsedIp" gorm:"type:varchar(50)"`             // 最后使用IP
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// ScopeList 返回权限范围列表
+func (t *APIToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// HasScope 检查令牌是否拥有指定权限，admin 拥有全部权限
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired 检查令牌是否已过期
+func (t *APIToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// IsValidScope 检查权限范围是否有效
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Blob 内容寻址的物理文件
+// 相同内容（SHA-256 相同）的图片共享同一份存储文件，通过引用计数决定何时删除
+type Blob struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Hash      string    `json:"hash" gorm:"type:varchar(64);uniqueIndex;not null"` // 上传内容的 SHA-256
+	FilePath  string    `json:"filePath" gorm:"type:varchar(500);not null"`        // 存储路径
+	Thumbnail string    `json:"thumbnail" gorm:"type:varchar(500)"`                // 缩略图存储路径
+	FileSize  int64     `json:"fileSize"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	RefCount  int64     `json:"refCount" gorm:"default:0;index"` // 引用该文件的图片数量
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	PerceptualHash string `json:"perceptualHash" gorm:"type:varchar(16)"` // 感知哈希，后台处理时计算
+
+	FrameCount int `json:"frameCount"` // 帧数，动图大于 1
+	Duration   int `json:"duration"`   // 动图播放一遍的时长（毫秒）
+
+	// 后台处理已生成的缩略图尺寸名称，逗号分隔，为空时为默认尺寸
+	ThumbnailSizes string `json:"thumbnailSizes" gorm:"type:varchar(255)"`
+}
+
+func (Blob) TableName() string {
+	return "blobs"
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ImageMetadata 上传时从图片 EXIF/XMP 中解析出的元数据，每张图片一条
+type ImageMetadata struct {
+	ID      uint `json:"-" gorm:"primarykey"`
+	ImageID uint `json:"imageId" gorm:"uniqueIndex;not null"`
+	// 相机和镜头
+	CameraMake  string `json:"cameraMake,omitempty" gorm:"type:varchar(100);index"`
+	CameraModel string `json:"cameraModel,omitempty" gorm:"type:varchar(100);index"`
+	LensMake    string `json:"lensMake,omitempty" gorm:"type:varchar(100)"`
+	LensModel   string `json:"lensModel,omitempty" gorm:"type:varchar(255)"`
+	Software    string `json:"software,omitempty" gorm:"type:varchar(255)"`
+	Artist      string `json:"artist,omitempty" gorm:"type:varchar(255)"`
+	Copyright   string `json:"copyright,omitempty" gorm:"type:varchar(500)"`
+	// 曝光参数
+	ExposureTime    string  `json:"exposureTime,omitempty" gorm:"type:varchar(20)"` // 如 1/125
+	FNumber         float64 `json:"fNumber,omitempty"`                              // 光圈值
+	ISO             int     `json:"iso,omitempty"`
+	FocalLength     float64 `json:"focalLength,omitempty"`     // 焦距（毫米）
+	FocalLength35mm int     `json:"focalLength35mm,omitempty"` // 等效 35mm 焦距
+	ExposureBias    float64 `json:"exposureBias,omitempty"`    // 曝光补偿（EV）
+	Flash           bool    `json:"flash"`
+	// 拍摄时间和方向
+	TakenAt     *time.Time `json:"takenAt,omitempty" gorm:"index"`
+	Orientation int        `json:"orientation,omitempty"` // EXIF Orientation，1-8
+	// GPS 位置，属于敏感信息
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Altitude  *float64 `json:"altitude,omitempty"` // 海拔（米）
+	// XMP 信息
+	HasXMP      bool   `json:"hasXmp"`
+	Title       string `json:"title,omitempty" gorm:"type:varchar(500)"`
+	Description string `json:"description,omitempty" gorm:"type:text"`
+	Keywords    string `json:"keywords,omitempty" gorm:"type:text"` // 关键词，逗号分隔
+	Rating      int    `json:"rating,omitempty"`
+	// 原图包含 GPS、设备序列号、XMP/IPTC 等敏感信息
+	Sensitive bool      `json:"hasSensitive"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (ImageMetadata) TableName() string {
+	return "image_metadata"
+}
+
+// HideLocation 隐藏 GPS 位置
+func (m *ImageMetadata) HideLocation() {
+	m.Latitude = nil
+	m.Longitude = nil
+	m.Altitude = nil
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ImageVariant 按需生成并缓存在存储中的图片变换结果
+// 以文件内容哈希区分，内容相同的图片共享缓存，文件删除时一并清理
+type ImageVariant struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	ContentHash string    `json:"contentHash" gorm:"type:varchar(100);uniqueIndex:idx_variant_key;not null"`              // 原图内容哈希
+	Key         string    `json:"key" gorm:"column:transform_key;type:varchar(255);uniqueIndex:idx_variant_key;not null"` // 规范化的变换参数
+	FilePath    string    `json:"filePath" gorm:"type:varchar(500);not null"`
+	FileSize    int64     `json:"fileSize"`
+	MimeType    string    `json:"mimeType" gorm:"type:varchar(100)"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (ImageVariant) TableName() string {
+	return "image_variants"
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// 任务状态
+const (
+	JobStatusPending   = "pending"   // 等待执行（包括等待重试）
+	JobStatusRunning   = "running"   // 执行中
+	JobStatusSucceeded = "succeeded" // 执行成功
+	JobStatusDead      = "dead"      // 重试次数用尽，进入死信列表
+)
+
+// Job 后台任务
+type Job struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	Type        string     `json:"type" gorm:"type:varchar(50);index;not null"`
+	Payload     string     `json:"payload" gorm:"type:text"` // 任务参数（JSON）
+	Result      string     `json:"result" gorm:"type:text"`  // 执行结果（JSON）
+	Status      string     `json:"status" gorm:"type:varchar(20);index;not null;default:pending"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"maxAttempts" gorm:"default:3"`
+	LastError   string     `json:"lastError" gorm:"type:text"`
+	UserID      uint       `json:"userId" gorm:"index"` // 提交任务的用户，系统任务为 0
+	RunAt       time.Time  `json:"runAt" gorm:"index"`  // 最早执行时间，用于重试退避
+	StartedAt   *time.Time `json:"startedAt"`           // 最近一次开始执行的时间
+	FinishedAt  *time.Time `json:"finishedAt"`          // 完成或进入死信列表的时间
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	// 执行任务的实例和最近一次心跳时间，心跳超时的任务才会被重新执行
+	Owner       string     `json:"owner" gorm:"type:varchar(100);index"`
+	HeartbeatAt *time.Time `json:"heartbeatAt" gorm:"index"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// IsFinished 任务是否已结束
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusDead
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ProfileThumbnail 处理配置中的缩略图尺寸，缩略图等比缩放到宽高以内
+type ProfileThumbnail struct {
+	Name   string `json:"name"` // 尺寸名称，作为缩略图文件名的后缀
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ProcessingProfile 图片处理配置
+// 相册可以指定使用的配置，未指定时使用系统默认配置
+type ProcessingProfile struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	Name        string `json:"name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description string `json:"description" gorm:"type:text"`
+	IsDefault   bool   `json:"isDefault" gorm:"index"` // 系统默认配置，最多一个
+
+	// 后台处理时生成的缩略图和其他格式版本
+	ThumbnailSizes []ProfileThumbnail `json:"thumbnailSizes" gorm:"type:text;serializer:json"`
+	Formats        []string           `json:"formats" gorm:"type:varchar(100);serializer:json"`
+	Quality        int                `json:"quality"` // 有损格式的压缩质量 1-100
+
+	// 上传时原图超过最大尺寸则等比缩小，0 表示不限制
+	MaxWidth  int `json:"maxWidth"`
+	MaxHeight int `json:"maxHeight"`
+	// 上传时删除原图中的 GPS 等敏感元数据，存储的原图不再包含这些信息
+	StripMetadata bool `json:"stripMetadata"`
+
+	// 输出图片时叠加的水印，所有者和持签名URL的访问不叠加
+	Watermark *WatermarkSettings `json:"watermark" gorm:"type:text;serializer:json"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (ProcessingProfile) TableName() string {
+	return "processing_profiles"
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// RefreshToken 刷新令牌
+// 每次刷新都会签发新令牌并标记旧令牌已使用，同一次登录产生的令牌属于同一个 FamilyID
+// 已使用的令牌再次出现说明令牌可能被盗用，此时整组令牌全部吊销
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"userId" gorm:"index;not null"`
+	FamilyID  string     `json:"familyId" gorm:"type:varchar(36);index;not null"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"` // 令牌的 SHA-256
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"index"`
+	UsedAt    *time.Time `json:"usedAt"`    // 已轮换的时间
+	RevokedAt *time.Time `json:"revokedAt"` // 吊销时间
+	ClientIP  string     `json:"clientIp" gorm:"type:varchar(50)"`
+	UserAgent string     `json:"userAgent" gorm:"type:varchar(255)"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsActive 令牌是否仍可用于刷新
+func (t *RefreshToken) IsActive() bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
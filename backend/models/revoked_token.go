@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RevokedToken 已吊销的 JWT（未启用 Redis 时使用数据库保存吊销列表）
+// JTI 为 "user:<id>" 的记录表示该用户在 CreatedAt 之前签发的令牌全部失效
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JTI       string    `json:"jti" gorm:"column:jti;type:varchar(100);uniqueIndex;not null"`
+	UserID    uint      `json:"userId" gorm:"index"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"index"` // 超过该时间后令牌本身已过期，记录可清理
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
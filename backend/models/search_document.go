@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SearchDocument 图片的搜索文档，汇总了图片可被搜索的文本字段
+// 由 search 包在图片、标签或相册变化时维护，各数据库的全文索引建立在这张表上
+type SearchDocument struct {
+	ImageID     uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name        string    `gorm:"type:text"` // 原始文件名和存储文件名
+	Tags        string    `gorm:"type:text"` // 标签
+	Album       string    `gorm:"type:text"` // 相册名称
+	Description string    `gorm:"type:text"` // 描述和替代文本
+	Exif        string    `gorm:"type:text"` // EXIF 元数据（相机、镜头等）
+	UpdatedAt   time.Time `gorm:"index"`
+}
+
+func (SearchDocument) TableName() string {
+	return "image_search_documents"
+}
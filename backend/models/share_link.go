@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 分享链接权限
+const (
+	SharePermissionView     = "view"     // 只能查看
+	SharePermissionDownload = "download" // 可以查看和下载
+)
+
+// ShareLink 相册分享链接，未登录的访客通过令牌只读访问相册
+type ShareLink struct {
+	ID           uint       `json:"id" gorm:"primarykey"`
+	AlbumID      uint       `json:"albumId" gorm:"index;not null"`
+	Album        *Album     `json:"album,omitempty" gorm:"foreignKey:AlbumID"`
+	CreatedBy    uint       `json:"createdBy" gorm:"index;not null"`                    // 创建者ID
+	Name         string     `json:"name" gorm:"type:varchar(100)"`                      // 备注名称
+	Token        string     `json:"token" gorm:"type:varchar(64);uniqueIndex;not null"` // 访问令牌，出现在分享地址中
+	PasswordHash string     `json:"-" gorm:"type:varchar(100)"`                         // 访问密码，为空表示无需密码
+	Permission   string     `json:"permission" gorm:"type:varchar(20);default:view"`    // view / download
+	ExpiresAt    *time.Time `json:"expiresAt"`                                          // 过期时间，为空表示永不过期
+	MaxViews     int64      `json:"maxViews" gorm:"default:0"`                          // 最大访问次数，0 表示不限制
+	ViewCount    int64      `json:"viewCount" gorm:"default:0"`                         // 已访问次数
+	LastViewAt   *time.Time `json:"lastViewAt"`                                         // 最后访问时间
+	RevokedAt    *time.Time `json:"revokedAt" gorm:"index"`                             // 吊销时间
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+// IsValidSharePermission 检查分享权限是否有效
+func IsValidSharePermission(permission string) bool {
+	return permission == SharePermissionView || permission == SharePermissionDownload
+}
+
+// SetPassword 设置访问密码，空字符串表示取消密码
+func (l *ShareLink) SetPassword(password string) error {
+	if password == "" {
+		l.PasswordHash = ""
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	l.PasswordHash = string(hash)
+	return nil
+}
+
+// HasPassword 是否需要访问密码
+func (l *ShareLink) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// CheckPassword 验证访问密码
+func (l *ShareLink) CheckPassword(password string) bool {
+	if !l.HasPassword() {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(l.PasswordHash), []byte(password)) == nil
+}
+
+// IsExpired 是否已过期
+func (l *ShareLink) IsExpired() bool {
+	return l.ExpiresAt != nil && time.Now().After(*l.ExpiresAt)
+}
+
+// IsActive 未吊销且未过期
+func (l *ShareLink) IsActive() bool {
+	return l.RevokedAt == nil && !l.IsExpired()
+}
+
+// ViewsExhausted 访问次数是否已用完
+func (l *ShareLink) ViewsExhausted() bool {
+	return l.MaxViews > 0 && l.ViewCount >= l.MaxViews
+}
+
+// AllowDownload 是否允许下载
+func (l *ShareLink) AllowDownload() bool {
+	return l.Permission == SharePermissionDownload
+}
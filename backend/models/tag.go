@@ -0,0 +1,216 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxTagNameLength 标签名称的最大长度（字符数）
+const MaxTagNameLength = 50
+
+// Tag 标签，每个用户有独立的标签命名空间
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	OwnerID   uint      `json:"ownerId" gorm:"uniqueIndex:idx_tags_owner_name;not null"`
+	Name      string    `json:"name" gorm:"type:varchar(100);uniqueIndex:idx_tags_owner_name;index;not null"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// ImageTag 图片与标签的关联
+type ImageTag struct {
+	ImageID   uint      `json:"imageId" gorm:"primaryKey;autoIncrement:false"`
+	TagID     uint      `json:"tagId" gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (ImageTag) TableName() string {
+	return "image_tags"
+}
+
+// ParseTagNames 解析逗号分隔的标签，去除空白、空值和重复项，过长的标签会被截断
+func ParseTagNames(s string) []string {
+	return NormalizeTagNames(strings.Split(s, ","))
+}
+
+// NormalizeTagNames 规范化标签列表，保持原有顺序
+func NormalizeTagNames(names []string) []string {
+	result := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if utf8.RuneCountInString(name) > MaxTagNameLength {
+			name = string([]rune(name)[:MaxTagNameLength])
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// EnsureTags 在用户的命名空间中查找标签，不存在的标签会被创建
+func EnsureTags(tx *gorm.DB, ownerID uint, names []string) ([]Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, Tag{OwnerID: ownerID, Name: name})
+	}
+	// 并发创建同名标签时以唯一索引为准
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var existing []Tag
+	if err := tx.Where("owner_id = ? AND name IN ?", ownerID, names).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// SetImageTags 将图片的标签替换为 names
+func SetImageTags(tx *gorm.DB, image *Image, names []string) error {
+	tags, err := EnsureTags(tx, image.OwnerID, names)
+	if err != nil {
+		return err
+	}
+
+	tagIDs := make([]uint, 0, len(tags))
+	for _, tag := range tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	remove := tx.Where("image_id = ?", image.ID)
+	if len(tagIDs) > 0 {
+		remove = remove.Where("tag_id NOT IN ?", tagIDs)
+	}
+	if err := remove.Delete(&ImageTag{}).Error; err != nil {
+		return err
+	}
+
+	if err := linkImageTags(tx, []uint{image.ID}, tagIDs); err != nil {
+		return err
+	}
+	return RefreshImageTagsColumn(tx, image.ID)
+}
+
+// AddImageTags 为多张图片添加标签，标签创建在各图片所有者的命名空间中
+func AddImageTags(tx *gorm.DB, images []Image, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	for ownerID, imageIDs := range groupImagesByOwner(images) {
+		tags, err := EnsureTags(tx, ownerID, names)
+		if err != nil {
+			return err
+		}
+		tagIDs := make([]uint, 0, len(tags))
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.ID)
+		}
+		if err := linkImageTags(tx, imageIDs, tagIDs); err != nil {
+			return err
+		}
+		if err := RefreshImageTagsColumn(tx, imageIDs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveImageTags 从多张图片移除标签，标签本身保留
+func RemoveImageTags(tx *gorm.DB, images []Image, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	for ownerID, imageIDs := range groupImagesByOwner(images) {
+		tagIDs := tx.Model(&Tag{}).Select("id").Where("owner_id = ? AND name IN ?", ownerID, names)
+		if err := tx.Where("image_id IN ? AND tag_id IN (?)", imageIDs, tagIDs).
+			Delete(&ImageTag{}).Error; err != nil {
+			return err
+		}
+		if err := RefreshImageTagsColumn(tx, imageIDs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshImageTagsColumn 根据 image_tags 重新生成 images.tags 列
+// images.tags 只是展示用的冗余字段，查询和统计都使用 image_tags
+func RefreshImageTagsColumn(tx *gorm.DB, imageIDs ...uint) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		ImageID uint
+		Name    string
+	}
+	if err := tx.Table("image_tags").
+		Select("image_tags.image_id, tags.name").
+		Joins("JOIN tags ON tags.id = image_tags.tag_id").
+		Where("image_tags.image_id IN ?", imageIDs).
+		Order("image_tags.created_at, tags.name").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	names := make(map[uint][]string, len(imageIDs))
+	for _, row := range rows {
+		names[row.ImageID] = append(names[row.ImageID], row.Name)
+	}
+	for _, id := range imageIDs {
+		if err := tx.Unscoped().Model(&Image{}).Where("id = ?", id).
+			UpdateColumn("tags", strings.Join(names[id], ",")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaggedImageIDs 查询使用了指定标签的图片ID
+func TaggedImageIDs(tx *gorm.DB, tagIDs ...uint) ([]uint, error) {
+	var imageIDs []uint
+	err := tx.Model(&ImageTag{}).Distinct("image_id").Where("tag_id IN ?", tagIDs).Pluck("image_id", &imageIDs).Error
+	return imageIDs, err
+}
+
+// linkImageTags 为图片关联标签，已存在的关联会被忽略
+func linkImageTags(tx *gorm.DB, imageIDs, tagIDs []uint) error {
+	if len(imageIDs) == 0 || len(tagIDs) == 0 {
+		return nil
+	}
+
+	links := make([]ImageTag, 0, len(imageIDs)*len(tagIDs))
+	for _, imageID := range imageIDs {
+		for _, tagID := range tagIDs {
+			links = append(links, ImageTag{ImageID: imageID, TagID: tagID})
+		}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&links, 500).Error
+}
+
+// groupImagesByOwner 按所有者分组图片ID
+func groupImagesByOwner(images []Image) map[uint][]uint {
+	groups := make(map[uint][]uint)
+	for _, image := range images {
+		groups[image.OwnerID] = append(groups[image.OwnerID], image.ID)
+	}
+	return groups
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// UploadSession 分片上传会话，大文件分成多个分片上传，断线后可以查询已收到的分片继续上传
+// 全部分片上传完成后合并为一个文件，按普通上传流程创建图片记录
+type UploadSession struct {
+	ID          string    `json:"id" gorm:"type:varchar(36);primarykey"` // UUID
+	UserID      uint      `json:"userId" gorm:"index;not null"`
+	AlbumID     uint      `json:"albumId"`
+	FileName    string    `json:"fileName" gorm:"type:varchar(255);not null"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(100)"`
+	FileSize    int64     `json:"fileSize"`
+	ChunkSize   int64     `json:"chunkSize"`
+	ChunkCount  int       `json:"chunkCount"`
+	Checksum    string    `json:"checksum" gorm:"type:varchar(64)"` // 整个文件的 SHA-256，为空时不校验
+	ExpiresAt   time.Time `json:"expiresAt" gorm:"index"`           // 每次收到分片后延长，过期后清理
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// UploadChunk 分片上传会话中已收到的分片，分片内容暂存在存储中
+type UploadChunk struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	SessionID string    `json:"-" gorm:"type:varchar(36);uniqueIndex:idx_upload_chunk;not null"`
+	Index     int       `json:"index" gorm:"column:chunk_index;uniqueIndex:idx_upload_chunk"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum" gorm:"type:varchar(64)"` // 分片内容的 SHA-256
+	FilePath  string    `json:"-" gorm:"type:varchar(500);not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (UploadChunk) TableName() string {
+	return "upload_chunks"
+}
+
+// DirectUpload 客户端直传存储的上传凭证，文件直接上传到云存储中的暂存路径，不经过服务器
+// 客户端上传完成后确认，服务器读取暂存文件，按普通上传流程创建图片记录
+type DirectUpload struct {
+	ID          string    `json:"id" gorm:"type:varchar(36);primarykey"` // UUID
+	UserID      uint      `json:"userId" gorm:"index;not null"`
+	AlbumID     uint      `json:"albumId"`
+	FileName    string    `json:"fileName" gorm:"type:varchar(255);not null"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(100)"` // 按扩展名确定，上传时必须使用该类型
+	FileSize    int64     `json:"fileSize"`
+	Checksum    string    `json:"checksum" gorm:"type:varchar(64)"` // 文件的 SHA-256，为空时不校验
+	ObjectPath  string    `json:"-" gorm:"type:varchar(500);not null"`
+	ExpiresAt   time.Time `json:"expiresAt" gorm:"index"` // 过期后删除记录和暂存文件
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (DirectUpload) TableName() string {
+	return "direct_uploads"
+}
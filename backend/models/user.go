@@ -21,16 +21,58 @@ type User struct {
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 配额字段：0 表示使用系统默认值，-1 表示不限制
+	StorageQuota int64 `gorm:"default:0" json:"storageQuota"` // 存储空间配额（字节）
+	ImageQuota   int64 `gorm:"default:0" json:"imageQuota"`   // 图片数量配额
+	MaxFileSize  int64 `gorm:"default:0" json:"maxFileSize"`  // 单文件大小上限（字节）
+	StorageUsed  int64 `gorm:"default:0" json:"storageUsed"`  // 已用存储空间（字节）
+	ImageCount   int64 `gorm:"default:0" json:"imageCount"`   // 已上传图片数量
+
+	// 访问原图时删除 GPS 等敏感元数据，相册可以单独设置
+	StripMetadata bool `gorm:"default:true" json:"stripMetadata"`
+}
+
+// UserQuota 用户生效的配额，0 表示不限制
+type UserQuota struct {
+	StorageQuota int64 `json:"storageQuota"` // 字节
+	ImageQuota   int64 `json:"imageQuota"`
+	MaxFileSize  int64 `json:"maxFileSize"` // 字节
+	StorageUsed  int64 `json:"storageUsed"` // 字节
+	ImageCount   int64 `json:"imageCount"`
+}
+
+// EffectiveQuota 结合系统默认值计算用户生效的配额
+func (u *User) EffectiveQuota(defaultStorage, defaultImages, defaultMaxFileSize int64) UserQuota {
+	return UserQuota{
+		StorageQuota: resolveQuota(u.StorageQuota, defaultStorage),
+		ImageQuota:   resolveQuota(u.ImageQuota, defaultImages),
+		MaxFileSize:  resolveQuota(u.MaxFileSize, defaultMaxFileSize),
+		StorageUsed:  u.StorageUsed,
+		ImageCount:   u.ImageCount,
+	}
+}
+
+// resolveQuota 0 使用默认值，负数表示不限制
+func resolveQuota(value, defaultValue int64) int64 {
+	if value == 0 {
+		value = defaultValue
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
 }
 
 // UserStats 用户统计信息
 type UserStats struct {
-	UserID         uint  `json:"userId"`
-	TotalImages    int64 `json:"totalImages"`
-	TotalAlbums    int64 `json:"totalAlbums"`
-	TotalStorage   int64 `json:"totalStorage"` // 字节
-	TotalViews     int64 `json:"totalViews"`
-	TotalDownloads int64 `json:"totalDownloads"`
+	UserID         uint      `json:"userId"`
+	TotalImages    int64     `json:"totalImages"`
+	TotalAlbums    int64     `json:"totalAlbums"`
+	TotalStorage   int64     `json:"totalStorage"` // 字节
+	TotalViews     int64     `json:"totalViews"`
+	TotalDownloads int64     `json:"totalDownloads"`
+	Quota          UserQuota `json:"quota"` // 配额及用量
 }
 
 // HashPassword 加密密码
@@ -0,0 +1,15 @@
+package models
+
+// WatermarkSettings 输出图片时叠加的水印配置，相册和处理配置都可以设置
+// 相册的设置优先于处理配置，相册设置为未启用时不使用处理配置中的水印
+type WatermarkSettings struct {
+	Enabled  bool    `json:"enabled"`
+	Type     string  `json:"type"`              // text 或 image
+	Text     string  `json:"text,omitempty"`    // 文字水印的内容
+	Color    string  `json:"color,omitempty"`   // 文字颜色 #RRGGBB 或 #RRGGBBAA
+	ImageID  uint    `json:"imageId,omitempty"` // 图片水印使用的图片
+	Position string  `json:"position"`          // center/north/south/east/west/northeast/northwest/southeast/southwest
+	Opacity  float64 `json:"opacity"`           // 不透明度 0-1
+	Scale    float64 `json:"scale"`             // 水印宽度占图片宽度的比例 0-1
+	Tile     bool    `json:"tile"`              // 平铺整张图片
+}
@@ -4,6 +4,7 @@ import (
 	"imagebed/config"
 	"imagebed/controllers"
 	"imagebed/middleware"
+	"imagebed/models"
 	v1 "imagebed/routes/v1"
 	"time"
 
@@ -52,7 +53,7 @@ func SetupRoutes() *gin.Engine {
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// 优雅的图片访问路径 - 使用 UUID
-	r.GET("/i/:uuid", controllers.ServeImage)
+	r.GET("/i/:uuid", middleware.OptionalAuthMiddleware(), controllers.ServeImage)
 
 	// 设置 API v1 路由（新版本）
 	v1.SetupRoutes(r)
@@ -67,6 +68,7 @@ func SetupRoutes() *gin.Engine {
 		{
 			auth.POST("/register", controllers.Register)
 			auth.POST("/login", controllers.Login)
+			auth.POST("/refresh", controllers.RefreshToken)
 			auth.POST("/logout", controllers.Logout)
 			auth.GET("/me", middleware.AuthMiddleware(), controllers.GetCurrentUser)
 		}
@@ -85,6 +87,8 @@ func SetupRoutes() *gin.Engine {
 			users.PUT("/:id/role", controllers.UpdateUserRole)               // 更新用户角色
 			users.PUT("/:id/status", controllers.UpdateUserStatus)           // 更新用户状态
 			users.POST("/:id/reset-password", controllers.ResetUserPassword) // 重置用户密码
+			users.GET("/:id/quota", controllers.GetUserQuota)                // 获取用户配额
+			users.PUT("/:id/quota", controllers.UpdateUserQuota)             // 调整用户配额
 		}
 
 		// 用户个人资料路由（需要登录）
@@ -93,6 +97,12 @@ func SetupRoutes() *gin.Engine {
 		{
 			profile.PUT("", controllers.UpdateProfile)                   // 更新个人资料
 			profile.POST("/change-password", controllers.ChangePassword) // 修改密码
+
+			// 个人访问令牌
+			profile.GET("/tokens", controllers.GetAPITokens)          // 获取访问令牌列表
+			profile.POST("/tokens", controllers.CreateAPIToken)       // 创建访问令牌
+			profile.PUT("/tokens/:id", controllers.UpdateAPIToken)    // 更新访问令牌
+			profile.DELETE("/tokens/:id", controllers.DeleteAPIToken) // 吊销访问令牌
 		}
 
 		// 相册相关路由
@@ -103,9 +113,40 @@ func SetupRoutes() *gin.Engine {
 			albums.GET("/:id", middleware.OptionalAuthMiddleware(), middleware.CacheMiddleware(10*time.Minute), controllers.GetAlbum) // 获取相册详情(缓存10分钟)
 
 			// 写入操作 - 必须登录
-			albums.POST("", middleware.AuthMiddleware(), controllers.CreateAlbum)                                         // 创建相册
-			albums.PUT("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.UpdateAlbum)    // 更新相册
-			albums.DELETE("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.DeleteAlbum) // 删除相册
+			albums.POST("", middleware.AuthMiddleware(), controllers.CreateAlbum)                                           // 创建相册
+			albums.PUT("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumEditPermission(), controllers.UpdateAlbum) // 更新相册
+			albums.DELETE("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.DeleteAlbum)   // 删除相册
+
+			// 分享链接管理
+			albums.GET("/:id/shares", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.GetAlbumShareLinks) // 获取相册的分享链接
+			albums.POST("/:id/shares", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.CreateShareLink)   // 创建分享链接
+
+			// 相册成员管理
+			albums.GET("/:id/members", middleware.AuthMiddleware(), middleware.CheckAlbumAccess(), controllers.GetAlbumMembers)              // 获取相册成员
+			albums.POST("/:id/members", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.AddAlbumMember)           // 添加相册成员
+			albums.PUT("/:id/members/:userId", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.UpdateAlbumMember) // 修改成员角色
+			albums.DELETE("/:id/members/:userId", middleware.AuthMiddleware(), middleware.CheckAlbumAccess(), controllers.RemoveAlbumMember) // 移除成员或退出相册
+
+			// 按相册当前的处理配置重新处理已有图片
+			albums.POST("/:id/reprocess", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.ReprocessAlbum)
+		}
+
+		// 我的分享链接（需要登录）
+		shares := api.Group("/shares")
+		shares.Use(middleware.AuthMiddleware())
+		{
+			shares.GET("", controllers.GetMyShareLinks)        // 获取我的分享链接
+			shares.DELETE("/:id", controllers.RevokeShareLink) // 吊销分享链接
+		}
+
+		// 访客通过分享链接只读访问相册（无需登录）
+		share := api.Group("/share")
+		share.Use(middleware.RateLimitMiddleware())
+		{
+			share.GET("/:token", controllers.GetSharedAlbum)                                    // 查看分享的相册
+			share.POST("/:token", controllers.GetSharedAlbum)                                   // 查看分享的相册（通过请求体提供密码）
+			share.GET("/:token/images/:imageId", controllers.GetSharedImage)                    // 查看/下载图片
+			share.GET("/:token/images/:imageId/thumbnail", controllers.GetSharedImageThumbnail) // 查看缩略图
 		}
 
 		// 图片相关路由
@@ -120,9 +161,10 @@ func SetupRoutes() *gin.Engine {
 			images.GET("/formats", middleware.CacheMiddleware(1*time.Hour), controllers.GetSupportedFormats)                          // 获取支持的格式(缓存1小时)
 
 			// 写入操作 - 必须登录
-			images.POST("/upload", middleware.AuthMiddleware(), controllers.UploadImage)                                              // 上传图片
-			images.POST("/batch-upload", middleware.AuthMiddleware(), controllers.BatchUpload)                                        // 批量上传
+			images.POST("/upload", middleware.AuthMiddleware(models.ScopeUpload), controllers.UploadImage)                            // 上传图片
+			images.POST("/batch-upload", middleware.AuthMiddleware(models.ScopeUpload), controllers.BatchUpload)                      // 批量上传
 			images.POST("/batch-convert", middleware.AuthMiddleware(), controllers.BatchConvertFormat)                                // 批量格式转换
+			images.POST("/batch-tags", middleware.AuthMiddleware(), controllers.BatchUpdateImageTags)                                 // 批量添加/移除标签
 			images.PUT("/:id/move", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.MoveImage)             // 移动图片
 			images.PUT("/:id/rename", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.RenameImage)         // 重命名
 			images.PUT("/:id/file", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.UpdateImageFile)       // 更新文件
@@ -130,17 +172,95 @@ func SetupRoutes() *gin.Engine {
 			images.PUT("/:id/convert", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.ConvertImageFormat) // 转换格式
 			images.DELETE("/:id", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.DeleteImage)             // 删除图片
 
+			images.PUT("/:id/description", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.UpdateImageDescription) // 更新描述和替代文本
+			images.GET("/:id/metadata", middleware.OptionalAuthMiddleware(), controllers.GetImageMetadata)                                    // 获取 EXIF/XMP 元数据
+
+			images.GET("/:id/similar", middleware.OptionalAuthMiddleware(), controllers.GetSimilarImages) // 查找视觉相似的图片
+
+			// 从远程地址导入图片，服务器下载，只允许访问公网地址
+			images.POST("/import-url", middleware.AuthMiddleware(models.ScopeUpload), controllers.ImportImageFromURL)
+
+			// 生成带签名的变换URL（/i/:uuid?w=&h=...）
+			images.GET("/:id/transform-url", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.GetTransformURL)
+
 			// 短链管理路由
 			images.POST("/:id/shortlink", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.GenerateShortLink)    // 生成短链
 			images.DELETE("/:id/shortlink", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.UnbindShortLink)    // 删除短链
 			images.PUT("/:id/shortlink", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.UpdateShortLinkTarget) // 转移短链
 		}
 
+		// 分片上传路由（需要上传权限），断线后查询已收到的分片继续上传
+		uploads := api.Group("/uploads")
+		uploads.Use(middleware.AuthMiddleware(models.ScopeUpload))
+		{
+			uploads.POST("", controllers.InitUploadSession)                  // 创建上传会话
+			uploads.GET("/:id", controllers.GetUploadSession)                // 查询已收到的分片
+			uploads.PUT("/:id/chunks/:index", controllers.UploadChunk)       // 上传分片
+			uploads.POST("/:id/complete", controllers.CompleteUploadSession) // 合并分片并创建图片
+			uploads.DELETE("/:id", controllers.AbortUploadSession)           // 取消上传
+		}
+
+		// 直传路由（需要上传权限），云存储下客户端按凭证将文件直接上传到存储，完成后确认
+		directUploads := api.Group("/direct-uploads")
+		directUploads.Use(middleware.AuthMiddleware(models.ScopeUpload))
+		{
+			directUploads.POST("", controllers.CreateDirectUpload)                // 申请上传凭证
+			directUploads.POST("/:id/complete", controllers.CompleteDirectUpload) // 确认上传并创建图片
+			directUploads.DELETE("/:id", controllers.AbortDirectUpload)           // 取消上传
+		}
+
+		// 后台任务路由（需要登录）
+		jobRoutes := api.Group("/jobs")
+		jobRoutes.Use(middleware.AuthMiddleware())
+		{
+			jobRoutes.GET("/:id", controllers.GetJob)                                        // 查询任务状态
+			jobRoutes.GET("", middleware.AdminMiddleware(), controllers.GetJobs)             // 任务列表（status=dead 为死信列表）
+			jobRoutes.POST("/:id/retry", middleware.AdminMiddleware(), controllers.RetryJob) // 重试死信任务
+		}
+
+		// 回收站路由（需要登录）
+		trash := api.Group("/trash")
+		trash.Use(middleware.AuthMiddleware())
+		{
+			trash.GET("", controllers.GetTrash)                         // 获取回收站
+			trash.DELETE("", controllers.EmptyTrash)                    // 清空回收站
+			trash.POST("/images/:id/restore", controllers.RestoreImage) // 恢复图片
+			trash.DELETE("/images/:id", controllers.PurgeImage)         // 彻底删除图片
+			trash.POST("/albums/:id/restore", controllers.RestoreAlbum) // 恢复相册
+			trash.DELETE("/albums/:id", controllers.PurgeAlbum)         // 彻底删除相册
+		}
+
+		// 重复图片管理（需要管理员权限）
+		duplicates := api.Group("/duplicates")
+		duplicates.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			duplicates.GET("", controllers.GetDuplicateImages)                    // 相似图片分组报告
+			duplicates.POST("/resolve", controllers.ResolveDuplicates)            // 每组保留一张，删除其余图片
+			duplicates.POST("/backfill", controllers.StartPerceptualHashBackfill) // 为旧图片计算感知哈希
+		}
+
+		// 图片处理配置（需要管理员权限）
+		processingProfiles := api.Group("/processing-profiles")
+		processingProfiles.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			processingProfiles.GET("", controllers.GetProcessingProfiles)          // 获取处理配置列表
+			processingProfiles.POST("", controllers.CreateProcessingProfile)       // 创建处理配置
+			processingProfiles.GET("/:id", controllers.GetProcessingProfile)       // 获取处理配置详情
+			processingProfiles.PUT("/:id", controllers.UpdateProcessingProfile)    // 修改处理配置
+			processingProfiles.DELETE("/:id", controllers.DeleteProcessingProfile) // 删除处理配置，相册改用默认配置
+		}
+
+		// 全文搜索
+		api.GET("/search", middleware.OptionalAuthMiddleware(), controllers.SearchImages)
+
 		// 标签相关路由
 		tags := api.Group("/tags")
 		{
-			tags.GET("", controllers.GetAllTags)
-			tags.GET("/search", controllers.SearchImagesByTag)
+			tags.GET("", middleware.AuthMiddleware(), controllers.GetAllTags)                       // 获取我的标签及使用次数
+			tags.GET("/search", middleware.OptionalAuthMiddleware(), controllers.SearchImagesByTag) // 按标签搜索（and/or/exclude）
+			tags.PUT("/:id", middleware.AuthMiddleware(), controllers.RenameTag)                    // 重命名标签
+			tags.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteTag)                 // 删除标签
+			tags.POST("/merge", middleware.AuthMiddleware(), controllers.MergeTags)                 // 合并标签
 		}
 
 		// 统计相关路由
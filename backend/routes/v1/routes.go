@@ -3,6 +3,7 @@ package v1
 import (
 	"imagebed/controllers"
 	"imagebed/middleware"
+	"imagebed/models"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,6 +19,7 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			auth.POST("/register", controllers.Register)
 			auth.POST("/login", controllers.Login)
+			auth.POST("/refresh", controllers.RefreshToken)
 			auth.POST("/logout", controllers.Logout)
 			auth.GET("/me", middleware.AuthMiddleware(), controllers.GetCurrentUser)
 		}
@@ -40,8 +42,38 @@ func SetupRoutes(r *gin.Engine) {
 
 			// 写入操作 - 必须登录
 			albums.POST("", middleware.AuthMiddleware(), controllers.CreateAlbum)
-			albums.PUT("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.UpdateAlbum)
+			albums.PUT("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumEditPermission(), controllers.UpdateAlbum)
 			albums.DELETE("/:id", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.DeleteAlbum)
+
+			// 分享链接管理
+			albums.GET("/:id/shares", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.GetAlbumShareLinks)
+			albums.POST("/:id/shares", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), middleware.RateLimitMiddleware(), controllers.CreateShareLink)
+
+			// 相册成员管理
+			albums.GET("/:id/members", middleware.AuthMiddleware(), middleware.CheckAlbumAccess(), controllers.GetAlbumMembers)
+			albums.POST("/:id/members", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.AddAlbumMember)
+			albums.PUT("/:id/members/:userId", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.UpdateAlbumMember)
+			albums.DELETE("/:id/members/:userId", middleware.AuthMiddleware(), middleware.CheckAlbumAccess(), controllers.RemoveAlbumMember)
+
+			// 按处理配置重新处理已有图片
+			albums.POST("/:id/reprocess", middleware.AuthMiddleware(), middleware.CheckAlbumOwnership(), controllers.ReprocessAlbum)
+		}
+
+		// 我的分享链接（需要登录）
+		shares := v1.Group("/shares")
+		shares.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware())
+		{
+			shares.GET("", controllers.GetMyShareLinks)
+			shares.DELETE("/:id", controllers.RevokeShareLink)
+		}
+
+		// 访客通过分享链接只读访问相册（无需登录）
+		share := v1.Group("/share")
+		share.Use(middleware.RateLimitMiddleware())
+		{
+			share.GET("/:token", controllers.GetSharedAlbum)
+			share.GET("/:token/images/:imageId", controllers.GetSharedImage)
+			share.GET("/:token/images/:imageId/thumbnail", controllers.GetSharedImageThumbnail)
 		}
 
 		// 图片路由（带缓存和速率限制）
@@ -53,27 +85,101 @@ func SetupRoutes(r *gin.Engine) {
 			images.GET("/:id/file", middleware.OptionalAuthMiddleware(), controllers.GetImageFile)
 			images.GET("/:id/thumbnail", middleware.OptionalAuthMiddleware(), controllers.GetImageThumbnail)
 			images.GET("/:id/signed-url", middleware.OptionalAuthMiddleware(), controllers.GetSignedURL)
+			images.GET("/:id/transform-url", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), controllers.GetTransformURL)
 			images.GET("/formats", middleware.CacheMiddleware(1*time.Hour), controllers.GetSupportedFormats)
 
 			// 上传接口使用上传速率限制
-			images.POST("/upload", middleware.AuthMiddleware(), middleware.UploadRateLimitMiddleware(), controllers.UploadImage)
-			images.POST("/batch-upload", middleware.AuthMiddleware(), middleware.UploadRateLimitMiddleware(), controllers.BatchUpload)
+			images.POST("/upload", middleware.AuthMiddleware(models.ScopeUpload), middleware.UploadRateLimitMiddleware(), controllers.UploadImage)
+			images.POST("/batch-upload", middleware.AuthMiddleware(models.ScopeUpload), middleware.UploadRateLimitMiddleware(), controllers.BatchUpload)
+			images.POST("/import-url", middleware.AuthMiddleware(models.ScopeUpload), middleware.UploadRateLimitMiddleware(), controllers.ImportImageFromURL)
 
 			// 修改操作 - 必须登录并拥有权限 - 使用普通速率限制
 			images.POST("/batch-convert", middleware.AuthMiddleware(), middleware.APIRateLimitMiddleware(), controllers.BatchConvertFormat)
+			images.POST("/batch-tags", middleware.AuthMiddleware(), middleware.RateLimitMiddleware(), controllers.BatchUpdateImageTags)
 			images.PUT("/:id/move", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.MoveImage)
 			images.PUT("/:id/rename", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.RenameImage)
 			images.PUT("/:id/file", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.UpdateImageFile)
 			images.PUT("/:id/tags", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.UpdateImageTags)
 			images.PUT("/:id/convert", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.APIRateLimitMiddleware(), controllers.ConvertImageFormat)
 			images.DELETE("/:id", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.DeleteImage)
+
+			images.PUT("/:id/description", middleware.AuthMiddleware(), middleware.CheckImageOwnership(), middleware.RateLimitMiddleware(), controllers.UpdateImageDescription)
+			images.GET("/:id/metadata", middleware.OptionalAuthMiddleware(), controllers.GetImageMetadata)
+			images.GET("/:id/similar", middleware.OptionalAuthMiddleware(), middleware.RateLimitMiddleware(), controllers.GetSimilarImages)
 		}
 
+		// 后台任务路由（需要登录）
+		jobRoutes := v1.Group("/jobs")
+		jobRoutes.Use(middleware.AuthMiddleware())
+		{
+			jobRoutes.GET("/:id", controllers.GetJob)
+			jobRoutes.GET("", middleware.AdminMiddleware(), controllers.GetJobs)
+			jobRoutes.POST("/:id/retry", middleware.AdminMiddleware(), controllers.RetryJob)
+		}
+
+		// 回收站路由（需要登录）
+		trash := v1.Group("/trash")
+		trash.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware())
+		{
+			trash.GET("", controllers.GetTrash)
+			trash.DELETE("", controllers.EmptyTrash)
+			trash.POST("/images/:id/restore", controllers.RestoreImage)
+			trash.DELETE("/images/:id", controllers.PurgeImage)
+			trash.POST("/albums/:id/restore", controllers.RestoreAlbum)
+			trash.DELETE("/albums/:id", controllers.PurgeAlbum)
+		}
+
+		// 重复图片管理（需要管理员权限）
+		duplicates := v1.Group("/duplicates")
+		duplicates.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			duplicates.GET("", controllers.GetDuplicateImages)
+			duplicates.POST("/resolve", controllers.ResolveDuplicates)
+			duplicates.POST("/backfill", controllers.StartPerceptualHashBackfill)
+		}
+
+		// 分片上传：创建会话和合并使用上传速率限制，分片不限制
+		uploads := v1.Group("/uploads")
+		uploads.Use(middleware.AuthMiddleware(models.ScopeUpload))
+		{
+			uploads.POST("", middleware.UploadRateLimitMiddleware(), controllers.InitUploadSession)
+			uploads.GET("/:id", controllers.GetUploadSession)
+			uploads.PUT("/:id/chunks/:index", controllers.UploadChunk)
+			uploads.POST("/:id/complete", middleware.UploadRateLimitMiddleware(), controllers.CompleteUploadSession)
+			uploads.DELETE("/:id", controllers.AbortUploadSession)
+		}
+
+		// 直传：申请凭证和确认使用上传速率限制，文件直接上传到云存储
+		directUploads := v1.Group("/direct-uploads")
+		directUploads.Use(middleware.AuthMiddleware(models.ScopeUpload))
+		{
+			directUploads.POST("", middleware.UploadRateLimitMiddleware(), controllers.CreateDirectUpload)
+			directUploads.POST("/:id/complete", middleware.UploadRateLimitMiddleware(), controllers.CompleteDirectUpload)
+			directUploads.DELETE("/:id", controllers.AbortDirectUpload)
+		}
+
+		// 图片处理配置（需要管理员权限）
+		processingProfiles := v1.Group("/processing-profiles")
+		processingProfiles.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			processingProfiles.GET("", controllers.GetProcessingProfiles)
+			processingProfiles.POST("", controllers.CreateProcessingProfile)
+			processingProfiles.GET("/:id", controllers.GetProcessingProfile)
+			processingProfiles.PUT("/:id", controllers.UpdateProcessingProfile)
+			processingProfiles.DELETE("/:id", controllers.DeleteProcessingProfile)
+		}
+
+		// 全文搜索
+		v1.GET("/search", middleware.OptionalAuthMiddleware(), middleware.RateLimitMiddleware(), controllers.SearchImages)
+
 		// 标签路由
 		tags := v1.Group("/tags")
 		{
-			tags.GET("", controllers.GetAllTags)
-			tags.GET("/search", controllers.SearchImagesByTag)
+			tags.GET("", middleware.AuthMiddleware(), controllers.GetAllTags)
+			tags.GET("/search", middleware.OptionalAuthMiddleware(), controllers.SearchImagesByTag)
+			tags.PUT("/:id", middleware.AuthMiddleware(), middleware.RateLimitMiddleware(), controllers.RenameTag)
+			tags.DELETE("/:id", middleware.AuthMiddleware(), middleware.RateLimitMiddleware(), controllers.DeleteTag)
+			tags.POST("/merge", middleware.AuthMiddleware(), middleware.RateLimitMiddleware(), controllers.MergeTags)
 		}
 
 		// 统计路由（缓存5分钟）
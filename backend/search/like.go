@@ -0,0 +1,60 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// likeEngine 没有可用的全文索引时使用 LIKE 查询搜索文档表
+// 每个关键词都需要出现在任一字段中，按命中的字段加权计算相关度
+type likeEngine struct{}
+
+// likeWeights 各字段的相关度权重
+var likeWeights = []struct {
+	column string
+	weight int
+}{
+	{"name", 10},
+	{"tags", 6},
+	{"description", 4},
+	{"album", 3},
+	{"exif", 1},
+}
+
+func (likeEngine) Name() string { return "like" }
+
+func (likeEngine) setup(*gorm.DB) error          { return nil }
+func (likeEngine) stale(*gorm.DB) bool           { return false }
+func (likeEngine) index(*gorm.DB, []uint) error  { return nil }
+func (likeEngine) remove(*gorm.DB, []uint) error { return nil }
+
+// monthExpr LIKE 只在 SQLite 未编译 FTS5 时使用
+func (likeEngine) monthExpr(column string) string {
+	return "strftime('%Y-%m', " + column + ")"
+}
+
+func (likeEngine) match(db *gorm.DB, terms []string) *gorm.DB {
+	scores := make([]string, 0, len(terms)*len(likeWeights))
+	scoreArgs := make([]interface{}, 0, len(terms)*len(likeWeights))
+	query := db.Table("image_search_documents")
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		conditions := make([]string, 0, len(likeWeights))
+		args := make([]interface{}, 0, len(likeWeights))
+		for _, w := range likeWeights {
+			conditions = append(conditions, "LOWER("+w.column+") LIKE ? ESCAPE '\\'")
+			args = append(args, pattern)
+			scores = append(scores, "CASE WHEN LOWER("+w.column+") LIKE ? ESCAPE '\\' THEN "+strconv.Itoa(w.weight)+" ELSE 0 END")
+			scoreArgs = append(scoreArgs, pattern)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	return query.Select("image_id, ("+strings.Join(scores, " + ")+") AS score", scoreArgs...)
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
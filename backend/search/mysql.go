@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// mysqlEngine 使用 MySQL FULLTEXT 索引，ngram 解析器支持中文等没有空格分词的文本
+type mysqlEngine struct{}
+
+const mysqlFulltextColumns = "name, tags, album, description, exif"
+
+func (mysqlEngine) Name() string { return "mysql-fulltext" }
+
+func (mysqlEngine) setup(db *gorm.DB) error {
+	var count int64
+	if err := db.Raw(`SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'image_search_documents' AND index_name = 'ft_image_search'`).
+		Scan(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Exec("ALTER TABLE image_search_documents ADD FULLTEXT INDEX ft_image_search (" +
+		mysqlFulltextColumns + ") WITH PARSER ngram").Error
+}
+
+// FULLTEXT 索引由数据库维护，不需要单独同步
+func (mysqlEngine) stale(*gorm.DB) bool            { return false }
+func (mysqlEngine) index(*gorm.DB, []uint) error   { return nil }
+func (mysqlEngine) remove(*gorm.DB, []uint) error  { return nil }
+func (mysqlEngine) monthExpr(column string) string { return "DATE_FORMAT(" + column + ", '%Y-%m')" }
+
+// match 布尔模式下所有关键词都需要匹配（前缀匹配），MATCH 的返回值作为相关度
+// 短于 ngram_token_size 的中文关键词也需要前缀匹配才能命中
+func (mysqlEngine) match(db *gorm.DB, terms []string) *gorm.DB {
+	required := make([]string, 0, len(terms))
+	for _, term := range terms {
+		// 关键词只包含字母和数字，不会破坏布尔模式语法
+		required = append(required, "+"+term+"*")
+	}
+	query := strings.Join(required, " ")
+	against := "MATCH(" + mysqlFulltextColumns + ") AGAINST (? IN BOOLEAN MODE)"
+	return db.Table("image_search_documents").
+		Select("image_id, "+against+" AS score", query).
+		Where(against, query)
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// postgresEngine 使用 PostgreSQL tsvector 生成列和 GIN 索引
+// 使用 simple 词典，不做词干处理，适合文件名、标签等混合语言的短文本
+type postgresEngine struct{}
+
+func (postgresEngine) Name() string { return "postgres-tsvector" }
+
+// pgVector 字段的加权 tsvector 表达式，常用中日韩文字先逐字切分
+func pgVector(column, weight string) string {
+	return `setweight(to_tsvector('simple', regexp_replace(coalesce(` + column +
+		`, ''), '([\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uac00-\ud7af])', ' \1 ', 'g')), '` + weight + `')`
+}
+
+func (postgresEngine) setup(db *gorm.DB) error {
+	vector := strings.Join([]string{
+		pgVector("name", "A"),
+		pgVector("tags", "B"),
+		pgVector("description", "B"),
+		pgVector("album", "C"),
+		pgVector("exif", "D"),
+	}, " || ")
+	if err := db.Exec("ALTER TABLE image_search_documents ADD COLUMN IF NOT EXISTS search_vector tsvector " +
+		"GENERATED ALWAYS AS (" + vector + ") STORED").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_image_search_vector ON image_search_documents USING GIN (search_vector)").Error
+}
+
+// 生成列由数据库维护，不需要单独同步
+func (postgresEngine) stale(*gorm.DB) bool            { return false }
+func (postgresEngine) index(*gorm.DB, []uint) error   { return nil }
+func (postgresEngine) remove(*gorm.DB, []uint) error  { return nil }
+func (postgresEngine) monthExpr(column string) string { return "to_char(" + column + ", 'YYYY-MM')" }
+
+// match 所有关键词都需要匹配（前缀匹配），按 ts_rank 计算相关度
+// 中日韩关键词逐字切分后作为短语（<->）匹配
+func (postgresEngine) match(db *gorm.DB, terms []string) *gorm.DB {
+	prefixes := make([]string, 0, len(terms))
+	for _, term := range terms {
+		// 关键词只包含字母和数字，不会破坏 tsquery 语法
+		prefixes = append(prefixes, strings.Join(termTokens(term), " <-> ")+":*")
+	}
+	query := strings.Join(prefixes, " & ")
+	return db.Table("image_search_documents").
+		Select("image_id, ts_rank(search_vector, to_tsquery('simple', ?)) AS score", query).
+		Where("search_vector @@ to_tsquery('simple', ?)", query)
+}
@@ -0,0 +1,324 @@
+// Package search 维护图片的全文搜索索引
+// 根据数据库类型选择 SQLite FTS5、PostgreSQL tsvector 或 MySQL FULLTEXT，不可用时退化为 LIKE 查询
+package search
+
+import (
+	"imagebed/database"
+	"imagebed/logger"
+	"imagebed/models"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTerms 单次搜索最多使用的关键词数量
+const maxTerms = 10
+
+// rebuildBatchSize 重建索引时每批处理的图片数量
+const rebuildBatchSize = 500
+
+// Engine 全文搜索引擎
+type Engine interface {
+	// Name 引擎名称
+	Name() string
+	// setup 创建索引所需的表和索引
+	setup(db *gorm.DB) error
+	// stale 索引是否需要重建（例如索引表刚刚创建）
+	stale(db *gorm.DB) bool
+	// index 搜索文档更新后同步索引
+	index(tx *gorm.DB, imageIDs []uint) error
+	// remove 从索引中删除图片
+	remove(tx *gorm.DB, imageIDs []uint) error
+	// match 返回匹配关键词的子查询，包含 image_id 和相关度 score 两列
+	match(db *gorm.DB, terms []string) *gorm.DB
+	// monthExpr 将时间列截断到月份的 SQL 表达式，格式为 YYYY-MM
+	monthExpr(column string) string
+}
+
+var engine Engine = likeEngine{}
+
+// Init 根据数据库类型初始化搜索引擎，索引为空或过期时在后台重建
+func Init(dbType string) {
+	db := database.GetDB()
+
+	var candidate Engine
+	switch dbType {
+	case "postgres":
+		candidate = postgresEngine{}
+	case "mysql":
+		candidate = mysqlEngine{}
+	default:
+		candidate = sqliteEngine{}
+	}
+
+	if err := candidate.setup(db); err != nil {
+		logger.Warn("全文索引不可用，使用 LIKE 搜索", zap.String("engine", candidate.Name()), zap.Error(err))
+		candidate = likeEngine{}
+	}
+	engine = candidate
+	logger.Info("搜索引擎已初始化", zap.String("engine", engine.Name()))
+
+	if needsRebuild(db) {
+		go func() {
+			count, err := Rebuild()
+			if err != nil {
+				logger.Error("重建搜索索引失败", zap.Error(err))
+				return
+			}
+			logger.Info("搜索索引已重建", zap.Int("images", count))
+		}()
+	}
+}
+
+// EngineName 当前使用的搜索引擎名称
+func EngineName() string {
+	return engine.Name()
+}
+
+// needsRebuild 搜索文档数量与图片数量不一致或索引过期时需要重建
+func needsRebuild(db *gorm.DB) bool {
+	var images, docs int64
+	db.Unscoped().Model(&models.Image{}).Count(&images)
+	db.Model(&models.SearchDocument{}).Count(&docs)
+	return images != docs || engine.stale(db)
+}
+
+// Rebuild 重建所有图片的搜索索引，返回处理的图片数量
+func Rebuild() (int, error) {
+	db := database.GetDB()
+	total := 0
+	var lastID uint
+	for {
+		var ids []uint
+		if err := db.Unscoped().Model(&models.Image{}).Where("id > ?", lastID).
+			Order("id").Limit(rebuildBatchSize).Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := indexImages(db, ids); err != nil {
+			return total, err
+		}
+		total += len(ids)
+		lastID = ids[len(ids)-1]
+	}
+
+	// 清理已经不存在的图片
+	orphans := db.Unscoped().Model(&models.Image{}).Select("id")
+	var orphanIDs []uint
+	if err := db.Model(&models.SearchDocument{}).Where("image_id NOT IN (?)", orphans).
+		Pluck("image_id", &orphanIDs).Error; err != nil {
+		return total, err
+	}
+	return total, removeImages(db, orphanIDs)
+}
+
+// IndexImages 更新图片的搜索索引，失败时只记录日志，不影响调用方
+func IndexImages(imageIDs ...uint) {
+	if len(imageIDs) == 0 {
+		return
+	}
+	if err := indexImages(database.GetDB(), imageIDs); err != nil {
+		logger.Error("更新搜索索引失败", zap.Uints("image_ids", imageIDs), zap.Error(err))
+	}
+}
+
+// IndexAlbum 相册名称变化后更新相册中所有图片的索引
+func IndexAlbum(albumID uint) {
+	var ids []uint
+	database.GetDB().Unscoped().Model(&models.Image{}).Where("album_id = ?", albumID).Pluck("id", &ids)
+	for start := 0; start < len(ids); start += rebuildBatchSize {
+		end := start + rebuildBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		IndexImages(ids[start:end]...)
+	}
+}
+
+// RemoveImages 从搜索索引中删除图片，用于彻底删除图片后
+func RemoveImages(imageIDs ...uint) {
+	if len(imageIDs) == 0 {
+		return
+	}
+	if err := removeImages(database.GetDB(), imageIDs); err != nil {
+		logger.Error("删除搜索索引失败", zap.Uints("image_ids", imageIDs), zap.Error(err))
+	}
+}
+
+// Match 返回匹配关键词的子查询（image_id, score），关键词为空时返回 nil
+func Match(db *gorm.DB, query string) *gorm.DB {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	return engine.match(db, terms)
+}
+
+// MonthExpr 将时间列截断到月份（YYYY-MM）的 SQL 表达式
+func MonthExpr(column string) string {
+	return engine.monthExpr(column)
+}
+
+// Terms 将搜索内容拆分为关键词：按非字母数字字符分割、转为小写并去重
+func Terms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		terms = append(terms, field)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// isCJK 中日韩文字之间没有空格分隔，需要逐字切分后才能被 unicode61、simple 等分词器识别
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// segment 在中日韩文字两侧插入空格，使每个字成为单独的词
+func segment(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if isCJK(r) {
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// termTokens 关键词切分后的词，中日韩关键词需要按短语（相邻的字）匹配
+func termTokens(term string) []string {
+	return strings.Fields(segment(term))
+}
+
+// indexImages 根据图片、标签和相册生成搜索文档并同步索引
+func indexImages(db *gorm.DB, imageIDs []uint) error {
+	var rows []struct {
+		ID           uint
+		FileName     string
+		OriginalName string
+		Tags         string
+		Description  string
+		AltText      string
+		AlbumName    string
+		// EXIF/XMP 元数据，不包括 GPS 位置
+		CameraMake  string
+		CameraModel string
+		LensModel   string
+		Title       string
+		Keywords    string
+	}
+	if err := db.Unscoped().Model(&models.Image{}).
+		Select("images.id, images.file_name, images.original_name, images.tags, images.description, images.alt_text, albums.name AS album_name, "+
+			"image_metadata.camera_make, image_metadata.camera_model, image_metadata.lens_model, image_metadata.title, image_metadata.keywords").
+		Joins("LEFT JOIN albums ON albums.id = images.album_id").
+		Joins("LEFT JOIN image_metadata ON image_metadata.image_id = images.id").
+		Where("images.id IN ?", imageIDs).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	docs := make([]models.SearchDocument, 0, len(rows))
+	found := make(map[uint]bool, len(rows))
+	for _, row := range rows {
+		found[row.ID] = true
+		docs = append(docs, models.SearchDocument{
+			ImageID:     row.ID,
+			Name:        joinText(row.OriginalName, row.FileName),
+			Tags:        strings.ReplaceAll(row.Tags, ",", " "),
+			Album:       row.AlbumName,
+			Description: joinText(row.Description, row.AltText),
+			Exif: joinText(row.CameraMake, row.CameraModel, row.LensModel, row.Title,
+				strings.ReplaceAll(row.Keywords, ",", " ")),
+		})
+	}
+
+	// 已经被彻底删除的图片
+	missing := make([]uint, 0)
+	for _, id := range imageIDs {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if len(docs) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "image_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name", "tags", "album", "description", "exif", "updated_at"}),
+			}).Create(&docs).Error; err != nil {
+				return err
+			}
+			ids := make([]uint, 0, len(docs))
+			for _, doc := range docs {
+				ids = append(ids, doc.ImageID)
+			}
+			if err := engine.index(tx, ids); err != nil {
+				return err
+			}
+		}
+		if len(missing) > 0 {
+			return removeDocuments(tx, missing)
+		}
+		return nil
+	})
+}
+
+// removeImages 删除图片的搜索文档和索引
+func removeImages(db *gorm.DB, imageIDs []uint) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return removeDocuments(tx, imageIDs)
+	})
+}
+
+func removeDocuments(tx *gorm.DB, imageIDs []uint) error {
+	if err := engine.remove(tx, imageIDs); err != nil {
+		return err
+	}
+	return tx.Where("image_id IN ?", imageIDs).Delete(&models.SearchDocument{}).Error
+}
+
+// joinText 用空格连接非空且不重复的文本
+func joinText(values ...string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		duplicate := false
+		for _, p := range parts {
+			if p == v {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"imagebed/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sqliteEngine 使用 SQLite FTS5，需要使用 sqlite_fts5 构建标签编译
+// FTS5 表保存搜索文档的副本（中日韩文字逐字切分），rowid 为图片ID
+type sqliteEngine struct{}
+
+func (sqliteEngine) Name() string { return "sqlite-fts5" }
+
+func (sqliteEngine) setup(db *gorm.DB) error {
+	return db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS image_search_fts USING fts5(
+		name, tags, album, description, exif,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`).Error
+}
+
+func (sqliteEngine) stale(db *gorm.DB) bool {
+	var docs, indexed int64
+	db.Table("image_search_documents").Count(&docs)
+	db.Raw("SELECT COUNT(*) FROM image_search_fts").Scan(&indexed)
+	return docs != indexed
+}
+
+func (e sqliteEngine) index(tx *gorm.DB, imageIDs []uint) error {
+	if err := e.remove(tx, imageIDs); err != nil {
+		return err
+	}
+	var docs []models.SearchDocument
+	if err := tx.Where("image_id IN ?", imageIDs).Find(&docs).Error; err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := tx.Exec(`INSERT INTO image_search_fts (rowid, name, tags, album, description, exif)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			doc.ImageID, segment(doc.Name), segment(doc.Tags), segment(doc.Album),
+			segment(doc.Description), segment(doc.Exif)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sqliteEngine) remove(tx *gorm.DB, imageIDs []uint) error {
+	return tx.Exec("DELETE FROM image_search_fts WHERE rowid IN ?", imageIDs).Error
+}
+
+// match 所有关键词都需要匹配（前缀匹配），按 bm25 计算相关度，文件名权重最高
+// 索引中的中日韩文字已逐字切分，关键词作为短语匹配相邻的字
+func (sqliteEngine) match(db *gorm.DB, terms []string) *gorm.DB {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.Join(termTokens(term), " ")+`"*`)
+	}
+	return db.Table("image_search_fts").
+		Select("rowid AS image_id, -bm25(image_search_fts, 10.0, 6.0, 3.0, 4.0, 1.0) AS score").
+		Where("image_search_fts MATCH ?", strings.Join(quoted, " "))
+}
+
+func (sqliteEngine) monthExpr(column string) string {
+	return "strftime('%Y-%m', " + column + ")"
+}
@@ -7,7 +7,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
 )
@@ -17,6 +19,9 @@ type COSStorage struct {
 	client   *cos.Client
 	basePath string
 	baseURL  string
+
+	secretID  string
+	secretKey string
 }
 
 // NewCOSStorage 创建COS存储实例
@@ -46,6 +51,9 @@ func NewCOSStorage(cfg *Config) (*COSStorage, error) {
 		client:   client,
 		basePath: strings.Trim(cfg.COSBasePath, "/"),
 		baseURL:  bucketURL,
+
+		secretID:  cfg.COSSecretID,
+		secretKey: cfg.COSSecretKey,
 	}, nil
 }
 
@@ -91,6 +99,19 @@ func (s *COSStorage) Get(path string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// GetRange 获取文件的一部分
+func (s *COSStorage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	objectKey := s.getObjectKey(path)
+
+	opt := &cos.ObjectGetOptions{Range: rangeHeader(offset, length)}
+	resp, err := s.client.Object.Get(context.Background(), objectKey, opt)
+	if err != nil {
+		return nil, fmt.Errorf("从COS获取文件失败: %w", err)
+	}
+
+	return resp.Body, nil
+}
+
 // Delete 删除文件
 func (s *COSStorage) Delete(path string) error {
 	objectKey := s.getObjectKey(path)
@@ -118,12 +139,46 @@ func (s *COSStorage) Exists(path string) (bool, error) {
 	return true, nil
 }
 
+// Size 获取文件大小
+func (s *COSStorage) Size(path string) (int64, error) {
+	objectKey := s.getObjectKey(path)
+
+	resp, err := s.client.Object.Head(context.Background(), objectKey, nil)
+	if err != nil {
+		return 0, fmt.Errorf("获取COS文件信息失败: %w", err)
+	}
+	return resp.ContentLength, nil
+}
+
 // GetURL 获取访问URL
 func (s *COSStorage) GetURL(path string) string {
 	objectKey := s.getObjectKey(path)
 	return fmt.Sprintf("%s/%s", strings.TrimRight(s.baseURL, "/"), objectKey)
 }
 
+// PresignUpload 生成 PUT 直传的预签名URL
+// Content-Type 和 Content-Length 都参与签名，COS 拒绝大小或类型不一致的上传
+func (s *COSStorage) PresignUpload(path, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	expiresAt := time.Now().Add(expires)
+
+	header := &http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	u, err := s.client.Object.GetPresignedURL(context.Background(), http.MethodPut, s.getObjectKey(path),
+		s.secretID, s.secretKey, expires, &cos.PresignedURLOptions{Header: header})
+	if err != nil {
+		return nil, fmt.Errorf("生成COS上传凭证失败: %w", err)
+	}
+
+	return &PresignedUpload{
+		Method: http.MethodPut,
+		URL:    u.String(),
+		// Content-Length 由客户端按文件内容自动设置
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
 // GetType 获取存储类型
 func (s *COSStorage) GetType() StorageType {
 	return StorageTypeCOS
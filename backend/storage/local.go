@@ -108,6 +108,24 @@ func (s *LocalStorage) Get(path string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// GetRange 获取文件的一部分
+func (s *LocalStorage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	safePath := s.sanitizePath(path)
+	fullPath := filepath.Join(s.basePath, safePath)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+
+	reader, err := seekRange(file, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("定位文件失败: %w", err)
+	}
+
+	return reader, nil
+}
+
 // Delete 删除文件
 func (s *LocalStorage) Delete(path string) error {
 	safePath := s.sanitizePath(path)
@@ -135,6 +153,18 @@ func (s *LocalStorage) Exists(path string) (bool, error) {
 	return false, err
 }
 
+// Size 获取文件大小
+func (s *LocalStorage) Size(path string) (int64, error) {
+	safePath := s.sanitizePath(path)
+	fullPath := filepath.Join(s.basePath, safePath)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
 // GetURL 获取访问URL
 func (s *LocalStorage) GetURL(path string) string {
 	// 统一使用正斜杠
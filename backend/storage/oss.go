@@ -1,10 +1,17 @@
 package storage
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
@@ -14,6 +21,9 @@ type OSSStorage struct {
 	client   *oss.Client
 	bucket   *oss.Bucket
 	basePath string
+
+	accessKeyID     string
+	accessKeySecret string
 }
 
 // NewOSSStorage 创建OSS存储实例
@@ -37,6 +47,9 @@ func NewOSSStorage(cfg *Config) (*OSSStorage, error) {
 		client:   client,
 		bucket:   bucket,
 		basePath: strings.Trim(cfg.OSSBasePath, "/"),
+
+		accessKeyID:     cfg.OSSAccessKeyID,
+		accessKeySecret: cfg.OSSAccessKeySecret,
 	}, nil
 }
 
@@ -80,6 +93,18 @@ func (s *OSSStorage) Get(path string) (io.ReadCloser, error) {
 	return body, nil
 }
 
+// GetRange 获取文件的一部分
+func (s *OSSStorage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	objectKey := s.getObjectKey(path)
+
+	body, err := s.bucket.GetObject(objectKey, oss.Range(offset, offset+length-1))
+	if err != nil {
+		return nil, fmt.Errorf("从OSS获取文件失败: %w", err)
+	}
+
+	return body, nil
+}
+
 // Delete 删除文件
 func (s *OSSStorage) Delete(path string) error {
 	objectKey := s.getObjectKey(path)
@@ -103,14 +128,68 @@ func (s *OSSStorage) Exists(path string) (bool, error) {
 	return exists, nil
 }
 
+// Size 获取文件大小
+func (s *OSSStorage) Size(path string) (int64, error) {
+	objectKey := s.getObjectKey(path)
+
+	header, err := s.bucket.GetObjectMeta(objectKey)
+	if err != nil {
+		return 0, fmt.Errorf("获取OSS文件信息失败: %w", err)
+	}
+	return strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+}
+
 // GetURL 获取访问URL
 func (s *OSSStorage) GetURL(path string) string {
 	objectKey := s.getObjectKey(path)
 	// 返回公共访问URL
 	// 格式: https://{bucket}.{endpoint}/{objectKey}
+	return fmt.Sprintf("%s/%s", s.bucketURL(), objectKey)
+}
+
+// PresignUpload 生成 POST 表单直传凭证，由 OSS 按上传策略校验文件大小和 Content-Type
+func (s *OSSStorage) PresignUpload(path, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	expiresAt := time.Now().Add(expires)
+	objectKey := s.getObjectKey(path)
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": expiresAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": []interface{}{
+			map[string]string{"bucket": s.bucket.BucketName},
+			map[string]string{"key": objectKey},
+			map[string]string{"Content-Type": contentType},
+			[]interface{}{"content-length-range", size, size},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成OSS上传策略失败: %w", err)
+	}
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+
+	mac := hmac.New(sha1.New, []byte(s.accessKeySecret))
+	mac.Write([]byte(encodedPolicy))
+
+	return &PresignedUpload{
+		Method: http.MethodPost,
+		URL:    s.bucketURL(),
+		FormData: map[string]string{
+			"key":                   objectKey,
+			"OSSAccessKeyId":        s.accessKeyID,
+			"policy":                encodedPolicy,
+			"Signature":             base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+			"Content-Type":          contentType,
+			"success_action_status": "200",
+		},
+		FileField: "file",
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// bucketURL Bucket 的访问地址
+func (s *OSSStorage) bucketURL() string {
 	endpoint := strings.TrimPrefix(s.client.Config.Endpoint, "http://")
 	endpoint = strings.TrimPrefix(endpoint, "https://")
-	return fmt.Sprintf("https://%s.%s/%s", s.bucket.BucketName, endpoint, objectKey)
+	return fmt.Sprintf("https://%s.%s", s.bucket.BucketName, endpoint)
 }
 
 // GetType 获取存储类型
@@ -0,0 +1,29 @@
+package storage
+
+import "time"
+
+// PresignedUpload 客户端直传存储的上传凭证
+// Method 为 PUT 时将文件内容作为请求体发送到 URL，并携带 Headers 中的请求头
+// Method 为 POST 时以 multipart/form-data 表单提交到 URL，先写入 FormData 中的字段，文件作为最后一个字段 FileField
+type PresignedUpload struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	FormData  map[string]string `json:"formData,omitempty"`
+	FileField string            `json:"fileField,omitempty"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// PresignedUploader 支持客户端直传的存储，文件大小和类型由存储服务按凭证校验
+// 本地、WebDAV 和 SFTP 存储没有实现该接口，只能经过服务器上传
+type PresignedUploader interface {
+	// PresignUpload 生成上传到 path 的凭证
+	// 文件必须正好 size 字节，Content-Type 必须为 contentType，凭证在 expires 后失效
+	PresignUpload(path, contentType string, size int64, expires time.Duration) (*PresignedUpload, error)
+}
+
+// GetPresignedUploader 当前存储支持客户端直传时返回 ok=true
+func GetPresignedUploader() (PresignedUploader, bool) {
+	uploader, ok := globalStorage.(PresignedUploader)
+	return uploader, ok
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"github.com/qiniu/go-sdk/v7/storage"
@@ -101,9 +103,53 @@ func (s *QiniuStorage) SaveFromReader(path string, reader io.Reader, size int64)
 }
 
 // Get 获取文件
+// 七牛云没有直接读取对象的接口，这里通过绑定域名的私有下载链接读取
 func (s *QiniuStorage) Get(path string) (io.ReadCloser, error) {
-	// 七牛云通过CDN直接访问,这里返回错误提示使用GetURL
-	return nil, fmt.Errorf("七牛云存储请使用GetURL获取访问链接")
+	deadline := time.Now().Add(10 * time.Minute).Unix()
+	downloadURL := storage.MakePrivateURL(s.mac, s.domain, s.getObjectKey(path), deadline)
+
+	resp, err := http.Get(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("从七牛云获取文件失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("从七牛云获取文件失败: HTTP %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// GetRange 获取文件的一部分
+func (s *QiniuStorage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	deadline := time.Now().Add(10 * time.Minute).Unix()
+	downloadURL := storage.MakePrivateURL(s.mac, s.domain, s.getObjectKey(path), deadline)
+
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("从七牛云获取文件失败: %w", err)
+	}
+	req.Header.Set("Range", rangeHeader(offset, length))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("从七牛云获取文件失败: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// CDN 忽略 Range 时返回完整内容，跳过 offset 之前的部分
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("从七牛云获取文件失败: %w", err)
+		}
+		return limitedReadCloser{Reader: io.LimitReader(resp.Body, length), Closer: resp.Body}, nil
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("从七牛云获取文件失败: HTTP %d", resp.StatusCode)
+	}
 }
 
 // Delete 删除文件
@@ -143,12 +189,56 @@ func (s *QiniuStorage) Exists(path string) (bool, error) {
 	return true, nil
 }
 
+// Size 获取文件大小
+func (s *QiniuStorage) Size(path string) (int64, error) {
+	objectKey := s.getObjectKey(path)
+
+	cfg := storage.Config{
+		Zone: s.region,
+	}
+
+	bucketManager := storage.NewBucketManager(s.mac, &cfg)
+	info, err := bucketManager.Stat(s.bucket, objectKey)
+	if err != nil {
+		return 0, fmt.Errorf("获取七牛云文件信息失败: %w", err)
+	}
+	return info.Fsize, nil
+}
+
 // GetURL 获取访问URL
 func (s *QiniuStorage) GetURL(path string) string {
 	objectKey := s.getObjectKey(path)
 	return fmt.Sprintf("%s/%s", s.domain, objectKey)
 }
 
+// PresignUpload 生成表单直传的上传凭证，由七牛云按上传策略校验文件大小，并侦测文件内容校验类型
+func (s *QiniuStorage) PresignUpload(path, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	if len(s.region.SrcUpHosts) == 0 {
+		return nil, fmt.Errorf("七牛云存储区域没有上传地址")
+	}
+	objectKey := s.getObjectKey(path)
+
+	putPolicy := storage.PutPolicy{
+		Scope:      s.bucket + ":" + objectKey,
+		Expires:    uint64(expires.Seconds()),
+		InsertOnly: 1,
+		FsizeMin:   size,
+		FsizeLimit: size,
+		MimeLimit:  contentType,
+	}
+
+	return &PresignedUpload{
+		Method: http.MethodPost,
+		URL:    "https://" + s.region.SrcUpHosts[0],
+		FormData: map[string]string{
+			"token": putPolicy.UploadToken(s.mac),
+			"key":   objectKey,
+		},
+		FileField: "file",
+		ExpiresAt: time.Now().Add(expires),
+	}, nil
+}
+
 // GetType 获取存储类型
 func (s *QiniuStorage) GetType() StorageType {
 	return StorageTypeQiniu
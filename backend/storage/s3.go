@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -102,6 +104,24 @@ func (s *S3Storage) Get(path string) (io.ReadCloser, error) {
 	return obj, nil
 }
 
+// GetRange 获取文件的一部分
+func (s *S3Storage) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	objectKey := s.getObjectKey(path)
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, fmt.Errorf("无效的读取范围: %w", err)
+	}
+
+	ctx := context.Background()
+	obj, err := s.client.GetObject(ctx, s.bucket, objectKey, opts)
+	if err != nil {
+		return nil, fmt.Errorf("从S3获取文件失败: %w", err)
+	}
+
+	return obj, nil
+}
+
 // Delete 删除文件
 func (s *S3Storage) Delete(path string) error {
 	objectKey := s.getObjectKey(path)
@@ -132,6 +152,17 @@ func (s *S3Storage) Exists(path string) (bool, error) {
 	return true, nil
 }
 
+// Size 获取文件大小
+func (s *S3Storage) Size(path string) (int64, error) {
+	objectKey := s.getObjectKey(path)
+
+	info, err := s.client.StatObject(context.Background(), s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("获取S3文件信息失败: %w", err)
+	}
+	return info.Size, nil
+}
+
 // GetURL 获取访问URL
 func (s *S3Storage) GetURL(path string) string {
 	objectKey := s.getObjectKey(path)
@@ -142,6 +173,37 @@ func (s *S3Storage) GetURL(path string) string {
 	return fmt.Sprintf("%s://%s/%s/%s", protocol, s.endpoint, s.bucket, objectKey)
 }
 
+// PresignUpload 生成 POST 表单直传凭证，由 S3 按上传策略校验文件大小和 Content-Type
+func (s *S3Storage) PresignUpload(path, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	expiresAt := time.Now().Add(expires)
+
+	policy := minio.NewPostPolicy()
+	for _, err := range []error{
+		policy.SetBucket(s.bucket),
+		policy.SetKey(s.getObjectKey(path)),
+		policy.SetExpires(expiresAt),
+		policy.SetContentType(contentType),
+		policy.SetContentLengthRange(size, size),
+	} {
+		if err != nil {
+			return nil, fmt.Errorf("生成S3上传策略失败: %w", err)
+		}
+	}
+
+	u, formData, err := s.client.PresignedPostPolicy(context.Background(), policy)
+	if err != nil {
+		return nil, fmt.Errorf("生成S3上传凭证失败: %w", err)
+	}
+
+	return &PresignedUpload{
+		Method:    http.MethodPost,
+		URL:       u.String(),
+		FormData:  formData,
+		FileField: "file",
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
 // GetType 获取存储类型
 func (s *S3Storage) GetType() StorageType {
 	return StorageTypeS3
@@ -169,6 +169,24 @@ func (s *SFTPStorage) Get(filePath string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// GetRange 获取文件的一部分
+func (s *SFTPStorage) GetRange(filePath string, offset, length int64) (io.ReadCloser, error) {
+	safePath := s.sanitizePath(filePath)
+	fullPath := path.Join(s.basePath, safePath)
+
+	file, err := s.client.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("从SFTP打开文件失败: %w", err)
+	}
+
+	reader, err := seekRange(file, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("定位SFTP文件失败: %w", err)
+	}
+
+	return reader, nil
+}
+
 // Delete 删除文件
 func (s *SFTPStorage) Delete(filePath string) error {
 	safePath := s.sanitizePath(filePath)
@@ -198,6 +216,18 @@ func (s *SFTPStorage) Exists(filePath string) (bool, error) {
 	return true, nil
 }
 
+// Size 获取文件大小
+func (s *SFTPStorage) Size(filePath string) (int64, error) {
+	safePath := s.sanitizePath(filePath)
+	fullPath := path.Join(s.basePath, safePath)
+
+	info, err := s.client.Stat(fullPath)
+	if err != nil {
+		return 0, fmt.Errorf("获取SFTP文件信息失败: %w", err)
+	}
+	return info.Size(), nil
+}
+
 // GetURL 获取文件访问URL
 func (s *SFTPStorage) GetURL(filePath string) string {
 	safePath := s.sanitizePath(filePath)
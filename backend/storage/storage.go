@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"io"
 	"mime/multipart"
 	"strconv"
@@ -35,12 +36,20 @@ type Storage interface {
 	// 返回: 文件内容, 错误
 	Get(path string) (io.ReadCloser, error)
 
+	// GetRange 获取文件的一部分
+	// offset: 起始位置
+	// length: 读取长度，必须大于 0
+	GetRange(path string, offset, length int64) (io.ReadCloser, error)
+
 	// Delete 删除文件
 	Delete(path string) error
 
 	// Exists 检查文件是否存在
 	Exists(path string) (bool, error)
 
+	// Size 获取文件大小，不读取文件内容
+	Size(path string) (int64, error)
+
 	// GetURL 获取访问URL
 	// path: 存储路径
 	// 返回: 可访问的URL
@@ -186,6 +195,26 @@ func GetStorage() Storage {
 	return globalStorage
 }
 
+// rangeHeader 生成 HTTP Range 请求头
+func rangeHeader(offset, length int64) string {
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// limitedReadCloser 限制读取长度并保留原 Closer
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// seekRange 定位到 offset 并只读取 length 字节，用于支持 Seek 的文件
+func seekRange(file io.ReadSeekCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
 // 辅助函数
 func getStringFromMap(m map[string]interface{}, key string, defaultValue string) string {
 	if v, ok := m[key]; ok {
@@ -118,6 +118,20 @@ func (s *WebDAVStorage) Get(filePath string) (io.ReadCloser, error) {
 	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
+// GetRange 获取文件的一部分
+// 服务器不支持 Range 时由 gowebdav 跳过多余的内容
+func (s *WebDAVStorage) GetRange(filePath string, offset, length int64) (io.ReadCloser, error) {
+	safePath := s.sanitizePath(filePath)
+	fullPath := path.Join(s.basePath, safePath)
+
+	reader, err := s.client.ReadStreamRange(fullPath, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("从WebDAV读取文件失败: %w", err)
+	}
+
+	return reader, nil
+}
+
 // Delete 删除文件
 func (s *WebDAVStorage) Delete(filePath string) error {
 	safePath := s.sanitizePath(filePath)
@@ -148,6 +162,18 @@ func (s *WebDAVStorage) Exists(filePath string) (bool, error) {
 	return true, nil
 }
 
+// Size 获取文件大小
+func (s *WebDAVStorage) Size(filePath string) (int64, error) {
+	safePath := s.sanitizePath(filePath)
+	fullPath := path.Join(s.basePath, safePath)
+
+	info, err := s.client.Stat(fullPath)
+	if err != nil {
+		return 0, fmt.Errorf("获取WebDAV文件信息失败: %w", err)
+	}
+	return info.Size(), nil
+}
+
 // GetURL 获取文件访问URL
 func (s *WebDAVStorage) GetURL(filePath string) string {
 	safePath := s.sanitizePath(filePath)
@@ -0,0 +1,179 @@
+// Package animation 解析和编码 GIF、WebP 动图
+// 解码时按每帧的偏移、混合和清除方式合成完整画面，编码时每帧都是完整画面
+package animation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	// ErrTooLarge 动图帧数或像素总数超过处理上限
+	ErrTooLarge = errors.New("动图帧数或像素总数超过处理上限")
+	// ErrStaticFormat 目标格式不支持动画，转换会丢失动画
+	ErrStaticFormat = errors.New("目标格式不支持动画")
+)
+
+// Limits 逐帧处理动图的资源上限，0 表示不限制
+type Limits struct {
+	MaxFrames int   // 最大帧数
+	MaxPixels int64 // 全部帧的像素总数（画布宽 x 高 x 帧数）
+}
+
+// DefaultLimits 默认的处理上限，解码后约占用 200MB 内存
+var DefaultLimits = Limits{MaxFrames: 1000, MaxPixels: 50000000}
+
+// Allows 动图是否在上限以内
+func (l Limits) Allows(info *Info) bool {
+	if l.MaxFrames > 0 && info.FrameCount > l.MaxFrames {
+		return false
+	}
+	return l.MaxPixels <= 0 || info.Pixels() <= l.MaxPixels
+}
+
+// Info 不解码像素即可读取的帧信息
+type Info struct {
+	Format     string // gif 或 webp
+	Width      int    // 画布尺寸
+	Height     int
+	FrameCount int
+	Duration   int // 播放一遍的总时长（毫秒），单帧图片为 0
+	LoopCount  int // 播放次数，0 表示无限循环
+}
+
+// Animated 是否包含多帧
+func (i *Info) Animated() bool {
+	return i != nil && i.FrameCount > 1
+}
+
+// Pixels 逐帧处理时需要解码的像素总数
+func (i *Info) Pixels() int64 {
+	return int64(i.Width) * int64(i.Height) * int64(i.FrameCount)
+}
+
+// Inspect 读取 GIF、WebP 的帧数和时长，其他格式或无法解析时返回 nil
+func Inspect(data []byte) *Info {
+	switch {
+	case isGIF(data):
+		info, _, err := inspectGIF(data)
+		if err != nil {
+			return nil
+		}
+		return info
+	case isWebP(data):
+		info, _, err := parseWebP(data)
+		if err != nil {
+			return nil
+		}
+		return info
+	}
+	return nil
+}
+
+// IsAnimated 图片是否为多帧动图
+func IsAnimated(data []byte) bool {
+	return Inspect(data).Animated()
+}
+
+// SupportsFormat 目标格式能否保存动画
+func SupportsFormat(format string) bool {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "gif", "webp":
+		return true
+	}
+	return false
+}
+
+// Animation 解码后的动图，每帧都是与画布同尺寸的完整画面
+type Animation struct {
+	Frames    []*image.NRGBA
+	Delays    []int // 每帧显示时长（毫秒）
+	LoopCount int   // 播放次数，0 表示无限循环
+}
+
+// Decode 解码动图的全部帧，帧数或像素总数超过上限时返回 ErrTooLarge
+func Decode(data []byte, limits Limits) (*Animation, error) {
+	info := Inspect(data)
+	if info == nil {
+		return nil, errors.New("不是有效的 GIF 或 WebP 图片")
+	}
+	if !limits.Allows(info) {
+		return nil, fmt.Errorf("%w: %d 帧，%dx%d", ErrTooLarge, info.FrameCount, info.Width, info.Height)
+	}
+
+	if info.Format == "gif" {
+		return decodeGIF(data)
+	}
+	return decodeWebP(data)
+}
+
+// DecodePoster 解码封面（第一帧的完整画面），不是动图时按普通图片解码
+func DecodePoster(data []byte) (image.Image, error) {
+	switch {
+	case isGIF(data):
+		return decodeGIFPoster(data)
+	case isWebP(data):
+		if IsAnimated(data) {
+			return decodeWebPPoster(data)
+		}
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
+
+// Map 对每一帧执行变换，返回新的动图
+func (a *Animation) Map(fn func(image.Image) image.Image) *Animation {
+	result := &Animation{
+		Frames:    make([]*image.NRGBA, len(a.Frames)),
+		Delays:    a.Delays,
+		LoopCount: a.LoopCount,
+	}
+	for i, frame := range a.Frames {
+		out := fn(frame)
+		if nrgba, ok := out.(*image.NRGBA); ok {
+			result.Frames[i] = nrgba
+		} else {
+			result.Frames[i] = imaging.Clone(out)
+		}
+	}
+	return result
+}
+
+// Opaque 是否所有帧都不透明
+func (a *Animation) Opaque() bool {
+	for _, frame := range a.Frames {
+		if !frame.Opaque() {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode 按目标格式编码动图，只支持 gif 和 webp
+// quality 为 WebP 的压缩质量 1-100
+func Encode(w io.Writer, a *Animation, format string, quality int) error {
+	if len(a.Frames) == 0 {
+		return errors.New("动图没有帧")
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "gif":
+		return encodeGIF(w, a)
+	case "webp":
+		return encodeWebP(w, a, quality)
+	default:
+		return fmt.Errorf("%w: %s", ErrStaticFormat, format)
+	}
+}
+
+// cloneNRGBA 复制当前画面
+func cloneNRGBA(src *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	return dst
+}
@@ -0,0 +1,229 @@
+package animation
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	chaiwebp "github.com/chai2010/webp"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	green = color.RGBA{0, 255, 0, 255}
+)
+
+// solidFrame 构造纯色的调色板帧
+func solidFrame(rect image.Rectangle, c color.Color) *image.Paletted {
+	frame := image.NewPaletted(rect, color.Palette{color.Transparent, red, blue, green})
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			frame.Set(x, y, c)
+		}
+	}
+	return frame
+}
+
+// buildGIF 构造 8x8 的三帧 GIF：红色底图、左上角蓝色（显示后清除）、右下角绿色
+func buildGIF(t *testing.T) []byte {
+	t.Helper()
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			solidFrame(image.Rect(0, 0, 8, 8), red),
+			solidFrame(image.Rect(0, 0, 4, 4), blue),
+			solidFrame(image.Rect(6, 6, 8, 8), green),
+		},
+		Delay:    []int{5, 0, 20},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 8, Height: 8},
+	}
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// closeTo 颜色是否接近（有损压缩允许误差）
+func closeTo(c color.Color, want color.RGBA) bool {
+	r, g, b, a := c.RGBA()
+	diff := func(x uint32, y uint8) bool {
+		d := int(x>>8) - int(y)
+		return d > -40 && d < 40
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B) && diff(a, want.A)
+}
+
+func TestInspectGIF(t *testing.T) {
+	info := Inspect(buildGIF(t))
+	if info == nil {
+		t.Fatal("无法解析 GIF")
+	}
+	if info.FrameCount != 3 || info.Width != 8 || info.Height != 8 {
+		t.Errorf("帧信息错误: %+v", info)
+	}
+	// 50ms + 0 按 100ms 播放 + 200ms
+	if info.Duration != 350 {
+		t.Errorf("时长应为 350ms，得到 %d", info.Duration)
+	}
+	if info.LoopCount != 0 {
+		t.Errorf("应为无限循环，得到 %d", info.LoopCount)
+	}
+	if !info.Animated() {
+		t.Error("应识别为动图")
+	}
+}
+
+func TestInspectStaticImages(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+
+	pngBuf := new(bytes.Buffer)
+	png.Encode(pngBuf, img)
+	if info := Inspect(pngBuf.Bytes()); info != nil {
+		t.Errorf("PNG 不应返回帧信息: %+v", info)
+	}
+
+	webpBuf := new(bytes.Buffer)
+	chaiwebp.Encode(webpBuf, img, &chaiwebp.Options{Quality: 80})
+	info := Inspect(webpBuf.Bytes())
+	if info == nil || info.FrameCount != 1 || info.Width != 5 || info.Height != 3 || info.Animated() {
+		t.Errorf("静态 WebP 帧信息错误: %+v", info)
+	}
+	if IsAnimatedWebPHeader(webpBuf.Bytes()) {
+		t.Error("静态 WebP 的文件头不应识别为动图")
+	}
+
+	gifBuf := new(bytes.Buffer)
+	gif.Encode(gifBuf, img, nil)
+	if IsAnimated(gifBuf.Bytes()) {
+		t.Error("单帧 GIF 不应识别为动图")
+	}
+
+	if Inspect([]byte("GIF89a\x08\x00")) != nil || Inspect([]byte("RIFF\xff\xff\xff\xffWEBPVP8X")) != nil {
+		t.Error("截断的文件应返回 nil")
+	}
+}
+
+func TestDecodeGIFDisposal(t *testing.T) {
+	anim, err := Decode(buildGIF(t), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 3 || anim.LoopCount != 0 {
+		t.Fatalf("解码结果错误: %d 帧，循环 %d", len(anim.Frames), anim.LoopCount)
+	}
+	if want := []int{50, 100, 200}; anim.Delays[0] != want[0] || anim.Delays[1] != want[1] || anim.Delays[2] != want[2] {
+		t.Errorf("帧时长应为 %v，得到 %v", want, anim.Delays)
+	}
+
+	checks := []struct {
+		frame int
+		x, y  int
+		want  color.RGBA
+	}{
+		{1, 0, 0, blue},
+		{1, 7, 7, red},
+		{2, 0, 0, color.RGBA{}}, // 第二帧显示后清除为透明
+		{2, 5, 5, red},
+		{2, 7, 7, green},
+	}
+	for _, c := range checks {
+		if got := anim.Frames[c.frame].At(c.x, c.y); !closeTo(got, c.want) {
+			t.Errorf("第 %d 帧 (%d,%d) 应为 %v，得到 %v", c.frame+1, c.x, c.y, c.want, got)
+		}
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	data := buildGIF(t)
+
+	if _, err := Decode(data, Limits{MaxFrames: 2}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("超过帧数上限应返回 ErrTooLarge，得到 %v", err)
+	}
+	if _, err := Decode(data, Limits{MaxPixels: 8 * 8 * 2}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("超过像素上限应返回 ErrTooLarge，得到 %v", err)
+	}
+	if _, err := Decode(data, Limits{MaxFrames: 3, MaxPixels: 8 * 8 * 3}); err != nil {
+		t.Errorf("刚好在上限以内应能解码: %v", err)
+	}
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	anim, err := Decode(buildGIF(t), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim.LoopCount = 3
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, anim, "webp", 90); err != nil {
+		t.Fatal(err)
+	}
+
+	info := Inspect(buf.Bytes())
+	if info == nil || info.Format != "webp" || info.FrameCount != 3 || info.Duration != 350 ||
+		info.LoopCount != 3 || info.Width != 8 || info.Height != 8 {
+		t.Fatalf("WebP 动图帧信息错误: %+v", info)
+	}
+	if !IsAnimatedWebPHeader(buf.Bytes()[:WebPHeaderSize]) {
+		t.Error("WebP 动图的文件头应识别为动图")
+	}
+
+	decoded, err := Decode(buf.Bytes(), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(decoded.Frames[1].At(0, 0), blue) || !closeTo(decoded.Frames[2].At(7, 7), green) ||
+		!closeTo(decoded.Frames[2].At(0, 0), color.RGBA{}) {
+		t.Error("WebP 动图的帧内容与原图不一致")
+	}
+
+	poster, err := DecodePoster(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(poster.At(1, 1), red) || poster.Bounds().Dx() != 8 {
+		t.Errorf("封面应为第一帧，得到 %v", poster.At(1, 1))
+	}
+}
+
+func TestEncodeGIFRoundTrip(t *testing.T) {
+	anim, err := Decode(buildGIF(t), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, anim, "gif", 0); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(buf.Bytes(), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Frames) != 3 || decoded.LoopCount != 0 || decoded.Delays[2] != 200 {
+		t.Fatalf("GIF 帧信息错误: %d 帧，循环 %d，时长 %v", len(decoded.Frames), decoded.LoopCount, decoded.Delays)
+	}
+	if !closeTo(decoded.Frames[2].At(0, 0), color.RGBA{}) || !closeTo(decoded.Frames[2].At(7, 7), green) {
+		t.Error("透明区域或帧内容错误")
+	}
+
+	if err := Encode(new(bytes.Buffer), anim, "jpeg", 80); err == nil {
+		t.Error("JPEG 不支持动画，应返回错误")
+	}
+}
+
+func TestDecodeGIFPoster(t *testing.T) {
+	poster, err := DecodePoster(buildGIF(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(poster.At(0, 0), red) || poster.Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Errorf("封面应为第一帧，得到 %v %v", poster.Bounds(), poster.At(0, 0))
+	}
+}
@@ -0,0 +1,247 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// errInvalidGIF GIF 结构无法解析
+var errInvalidGIF = errors.New("无效的 GIF 文件")
+
+// isGIF 检查文件头是否为 GIF
+func isGIF(data []byte) bool {
+	return len(data) >= 6 && (string(data[:6]) == "GIF87a" || string(data[:6]) == "GIF89a")
+}
+
+// gifDelay 帧延迟（百分之一秒）转换为毫秒
+// 与浏览器一致，不超过 10ms 的延迟按 100ms 播放
+func gifDelay(delay int) int {
+	if delay <= 1 {
+		return 100
+	}
+	return delay * 10
+}
+
+// inspectGIF 遍历 GIF 的数据块统计帧数和时长，不解压像素
+// 同时返回第一帧数据结束的位置，用于只解码封面
+func inspectGIF(data []byte) (*Info, int, error) {
+	if len(data) < 13 {
+		return nil, 0, errInvalidGIF
+	}
+	info := &Info{
+		Format:    "gif",
+		Width:     int(binary.LittleEndian.Uint16(data[6:8])),
+		Height:    int(binary.LittleEndian.Uint16(data[8:10])),
+		LoopCount: 1, // 没有 NETSCAPE 扩展时只播放一次
+	}
+
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	firstEnd, delay := 0, 0
+	duration := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // 扩展块
+			if pos+2 >= len(data) {
+				return nil, 0, errInvalidGIF
+			}
+			label, block := data[pos+1], data[pos+2:]
+			switch {
+			case label == 0xF9 && len(block) >= 5 && block[0] == 4: // 图形控制扩展
+				delay = int(binary.LittleEndian.Uint16(block[2:4]))
+			case label == 0xFF && len(block) >= 17 && block[0] == 11 &&
+				(string(block[1:12]) == "NETSCAPE2.0" || string(block[1:12]) == "ANIMEXTS1.0") &&
+				block[12] >= 3 && block[13] == 1:
+				if loops := int(binary.LittleEndian.Uint16(block[14:16])); loops == 0 {
+					info.LoopCount = 0
+				} else {
+					info.LoopCount = loops + 1
+				}
+			}
+			next, err := skipSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = next
+
+		case 0x2C: // 图像描述符
+			if pos+10 > len(data) {
+				return nil, 0, errInvalidGIF
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			next, err := skipSubBlocks(data, pos+1) // 跳过 LZW 最小码长
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = next
+
+			info.FrameCount++
+			duration += gifDelay(delay)
+			delay = 0
+			if info.FrameCount == 1 {
+				firstEnd = pos
+			}
+
+		case 0x3B: // 文件结束
+			pos = len(data)
+
+		default:
+			if info.FrameCount == 0 {
+				return nil, 0, errInvalidGIF
+			}
+			// 末尾的多余数据不影响已读取的帧
+			pos = len(data)
+		}
+	}
+
+	if info.FrameCount == 0 {
+		return nil, 0, errInvalidGIF
+	}
+	if info.FrameCount > 1 {
+		info.Duration = duration
+	}
+	return info, firstEnd, nil
+}
+
+// skipSubBlocks 跳过从 pos 开始的数据子块序列，返回结束后的位置
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errInvalidGIF
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
+
+// decodeGIF 解码 GIF 的全部帧并按清除方式合成完整画面
+func decodeGIF(data []byte) (*Animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		for _, frame := range g.Image {
+			bounds = bounds.Union(frame.Bounds())
+		}
+	}
+
+	anim := &Animation{
+		Frames: make([]*image.NRGBA, 0, len(g.Image)),
+		Delays: make([]int, 0, len(g.Image)),
+	}
+	switch {
+	case g.LoopCount == 0:
+		anim.LoopCount = 0
+	case g.LoopCount < 0:
+		anim.LoopCount = 1
+	default:
+		anim.LoopCount = g.LoopCount + 1
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, cloneNRGBA(canvas))
+		anim.Delays = append(anim.Delays, gifDelay(g.Delay[i]))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return anim, nil
+}
+
+// decodeGIFPoster 只解码 GIF 的第一帧
+func decodeGIFPoster(data []byte) (image.Image, error) {
+	_, firstEnd, err := inspectGIF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	first := make([]byte, firstEnd+1)
+	copy(first, data[:firstEnd])
+	first[firstEnd] = 0x3B
+
+	anim, err := decodeGIF(first)
+	if err != nil {
+		return nil, err
+	}
+	return anim.Frames[0], nil
+}
+
+// gifPalette Plan9 调色板的前 255 种颜色加一个透明色
+var gifPalette = append(append(color.Palette{}, palette.Plan9[:255]...), color.Transparent)
+
+// encodeGIF 将动图编码为 GIF，每帧抖动量化到固定调色板
+func encodeGIF(w io.Writer, a *Animation) error {
+	bounds := a.Frames[0].Bounds()
+	g := &gif.GIF{
+		Image:    make([]*image.Paletted, 0, len(a.Frames)),
+		Delay:    make([]int, 0, len(a.Frames)),
+		Disposal: make([]byte, 0, len(a.Frames)),
+		Config: image.Config{
+			ColorModel: gifPalette,
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+		},
+	}
+	switch a.LoopCount {
+	case 0:
+		g.LoopCount = 0
+	case 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = a.LoopCount - 1
+	}
+
+	// 每帧都是完整画面，有透明像素时需要先清除上一帧
+	disposal := byte(gif.DisposalNone)
+	if !a.Opaque() {
+		disposal = gif.DisposalBackground
+	}
+
+	for i, frame := range a.Frames {
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), gifPalette)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, frame.Bounds().Min)
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, max((a.Delays[i]+5)/10, 2))
+		g.Disposal = append(g.Disposal, disposal)
+	}
+
+	return gif.EncodeAll(w, g)
+}
@@ -0,0 +1,315 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+
+	chaiwebp "github.com/chai2010/webp"
+	"golang.org/x/image/webp"
+)
+
+// WebP VP8X 块中的标志位
+const (
+	vp8xAnimationFlag = 0x02
+	vp8xAlphaFlag     = 0x10
+)
+
+// ANMF 块中的标志位
+const (
+	anmfDisposeFlag = 0x01 // 显示后将帧区域清除为透明
+	anmfNoBlendFlag = 0x02 // 直接覆盖，不按透明度混合
+)
+
+// maxWebPDuration ANMF 块中帧时长的上限（24 位）
+const maxWebPDuration = 1<<24 - 1
+
+// errInvalidWebP WebP 结构无法解析
+var errInvalidWebP = errors.New("无效的 WebP 文件")
+
+// isWebP 检查文件头是否为 WebP
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// WebPHeaderSize 判断 WebP 是否为动图需要读取的文件头长度
+const WebPHeaderSize = 21
+
+// IsAnimatedWebPHeader 根据扩展格式（VP8X）块的动画标志判断 WebP 是否为动图
+// 只需要文件头，用于不读取完整文件时判断存储中的 WebP 版本
+func IsAnimatedWebPHeader(header []byte) bool {
+	return isWebP(header) && len(header) >= WebPHeaderSize &&
+		string(header[12:16]) == "VP8X" && header[20]&vp8xAnimationFlag != 0
+}
+
+// webpFrame WebP 动图中的一帧
+type webpFrame struct {
+	rect     image.Rectangle // 帧在画布中的位置
+	duration int             // 毫秒
+	blend    bool
+	dispose  bool
+	data     []byte // ALPH、VP8/VP8L 块
+}
+
+// webpChunk RIFF 块
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+	raw     []byte // 包含块头和填充字节
+}
+
+// walkWebPChunks 遍历 RIFF 块，块长度超出数据范围时返回错误
+func walkWebPChunks(data []byte, fn func(chunk webpChunk)) error {
+	for pos := 0; pos < len(data); {
+		if pos+8 > len(data) {
+			return errInvalidWebP
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || size > len(data)-pos-8 {
+			return errInvalidWebP
+		}
+		end := pos + 8 + size + size&1
+		if end > len(data) {
+			end = len(data)
+		}
+		fn(webpChunk{
+			fourCC:  string(data[pos : pos+4]),
+			payload: data[pos+8 : pos+8+size],
+			raw:     data[pos:end],
+		})
+		pos = end
+	}
+	return nil
+}
+
+// parseWebP 读取 WebP 的画布尺寸和动画帧，静态图片没有帧
+func parseWebP(data []byte) (*Info, []webpFrame, error) {
+	if !isWebP(data) {
+		return nil, nil, errInvalidWebP
+	}
+	body := data[12:]
+	if size := int(binary.LittleEndian.Uint32(data[4:8])); size >= 4 && size-4 < len(body) {
+		body = body[:size-4]
+	}
+
+	info := &Info{Format: "webp"}
+	var frames []webpFrame
+	animated := false
+	err := walkWebPChunks(body, func(chunk webpChunk) {
+		p := chunk.payload
+		switch chunk.fourCC {
+		case "VP8X":
+			if len(p) >= 10 {
+				animated = p[0]&vp8xAnimationFlag != 0
+				info.Width = int(uint24(p[4:])) + 1
+				info.Height = int(uint24(p[7:])) + 1
+			}
+		case "ANIM":
+			if len(p) >= 6 {
+				info.LoopCount = int(binary.LittleEndian.Uint16(p[4:]))
+			}
+		case "ANMF":
+			if len(p) < 16 {
+				return
+			}
+			x, y := int(uint24(p[0:]))*2, int(uint24(p[3:]))*2
+			frame := webpFrame{
+				rect:     image.Rect(x, y, x+int(uint24(p[6:]))+1, y+int(uint24(p[9:]))+1),
+				duration: int(uint24(p[12:])),
+				blend:    p[15]&anmfNoBlendFlag == 0,
+				dispose:  p[15]&anmfDisposeFlag != 0,
+				data:     p[16:],
+			}
+			frames = append(frames, frame)
+			info.Duration += frame.duration
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !animated || len(frames) == 0 {
+		cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+		if err != nil && info.Width == 0 {
+			return nil, nil, err
+		}
+		if err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+		info.FrameCount, info.Duration, info.LoopCount = 1, 0, 0
+		return info, nil, nil
+	}
+
+	info.FrameCount = len(frames)
+	if info.FrameCount == 1 {
+		info.Duration = 0
+	}
+	return info, frames, nil
+}
+
+// decodeWebPFrame 将 ANMF 中的帧数据封装为独立的 WebP 后解码
+func decodeWebPFrame(frame webpFrame) (image.Image, error) {
+	hasAlpha := false
+	if err := walkWebPChunks(frame.data, func(chunk webpChunk) {
+		if chunk.fourCC == "ALPH" {
+			hasAlpha = true
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	if hasAlpha {
+		// 带 ALPH 块的有损帧需要 VP8X 头
+		writeWebPChunk(body, "VP8X", vp8xPayload(vp8xAlphaFlag, frame.rect.Dx(), frame.rect.Dy()))
+	}
+	body.Write(frame.data)
+
+	return webp.Decode(bytes.NewReader(riffWebP(body.Bytes())))
+}
+
+// decodeWebPFrames 解码前 count 帧并按混合和清除方式合成完整画面
+func decodeWebPFrames(data []byte, count int) (*Animation, error) {
+	info, frames, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("不是 WebP 动图")
+	}
+	if count > 0 && count < len(frames) {
+		frames = frames[:count]
+	}
+
+	anim := &Animation{
+		Frames:    make([]*image.NRGBA, 0, len(frames)),
+		Delays:    make([]int, 0, len(frames)),
+		LoopCount: info.LoopCount,
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, info.Width, info.Height))
+	for i, frame := range frames {
+		if i > 0 && frames[i-1].dispose {
+			draw.Draw(canvas, frames[i-1].rect, image.Transparent, image.Point{}, draw.Src)
+		}
+
+		img, err := decodeWebPFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		op := draw.Src
+		if frame.blend {
+			op = draw.Over
+		}
+		draw.Draw(canvas, frame.rect, img, img.Bounds().Min, op)
+
+		anim.Frames = append(anim.Frames, cloneNRGBA(canvas))
+		anim.Delays = append(anim.Delays, frame.duration)
+	}
+	return anim, nil
+}
+
+// decodeWebP 解码 WebP 动图的全部帧
+func decodeWebP(data []byte) (*Animation, error) {
+	return decodeWebPFrames(data, 0)
+}
+
+// decodeWebPPoster 只解码 WebP 动图的第一帧
+func decodeWebPPoster(data []byte) (image.Image, error) {
+	anim, err := decodeWebPFrames(data, 1)
+	if err != nil {
+		return nil, err
+	}
+	return anim.Frames[0], nil
+}
+
+// encodeWebP 将动图编码为 WebP 动图
+// 每帧单独压缩为静态 WebP，取出其中的 ALPH、VP8/VP8L 块封装为 ANMF 块
+func encodeWebP(w io.Writer, a *Animation, quality int) error {
+	if quality <= 0 || quality > 100 {
+		quality = 85
+	}
+	bounds := a.Frames[0].Bounds()
+
+	frames := new(bytes.Buffer)
+	for i, frame := range a.Frames {
+		encoded := new(bytes.Buffer)
+		if err := chaiwebp.Encode(encoded, frame, &chaiwebp.Options{Quality: float32(quality)}); err != nil {
+			return err
+		}
+		if !isWebP(encoded.Bytes()) {
+			return errInvalidWebP
+		}
+
+		payload := make([]byte, 16, 16+encoded.Len())
+		putUint24(payload[6:], uint32(bounds.Dx()-1))
+		putUint24(payload[9:], uint32(bounds.Dy()-1))
+		putUint24(payload[12:], uint32(min(max(a.Delays[i], 0), maxWebPDuration)))
+		// 每帧都是完整画面，直接覆盖上一帧
+		payload[15] = anmfNoBlendFlag
+		if err := walkWebPChunks(encoded.Bytes()[12:], func(chunk webpChunk) {
+			switch chunk.fourCC {
+			case "ALPH", "VP8 ", "VP8L":
+				payload = append(payload, chunk.raw...)
+			}
+		}); err != nil {
+			return err
+		}
+		writeWebPChunk(frames, "ANMF", payload)
+	}
+
+	flags := byte(vp8xAnimationFlag)
+	if !a.Opaque() {
+		flags |= vp8xAlphaFlag
+	}
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(min(a.LoopCount, 0xFFFF)))
+
+	body := new(bytes.Buffer)
+	writeWebPChunk(body, "VP8X", vp8xPayload(flags, bounds.Dx(), bounds.Dy()))
+	writeWebPChunk(body, "ANIM", anim)
+	body.Write(frames.Bytes())
+
+	_, err := w.Write(riffWebP(body.Bytes()))
+	return err
+}
+
+// vp8xPayload VP8X 块内容
+func vp8xPayload(flags byte, width, height int) []byte {
+	p := make([]byte, 10)
+	p[0] = flags
+	putUint24(p[4:], uint32(width-1))
+	putUint24(p[7:], uint32(height-1))
+	return p
+}
+
+// writeWebPChunk 写入 RIFF 块，奇数长度补齐一个字节
+func writeWebPChunk(buf *bytes.Buffer, fourCC string, payload []byte) {
+	header := make([]byte, 8)
+	copy(header, fourCC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	buf.Write(header)
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// riffWebP 为 WebP 块添加 RIFF 文件头
+func riffWebP(body []byte) []byte {
+	out := make([]byte, 12, 12+len(body))
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+len(body)))
+	copy(out[8:], "WEBP")
+	return append(out, body...)
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APITokenPrefix 个人访问令牌前缀，用于和 JWT 区分
+const APITokenPrefix = "imb_"
+
+// GenerateAPIToken 生成个人访问令牌，返回明文、哈希和显示用前缀
+func GenerateAPIToken() (token, hash, prefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	token = APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashAPIToken(token), token[:len(APITokenPrefix)+8], nil
+}
+
+// GenerateRefreshToken 生成刷新令牌，返回明文和哈希
+func GenerateRefreshToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashAPIToken(token), nil
+}
+
+// GenerateShareToken 生成分享链接令牌
+func GenerateShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashAPIToken 计算令牌哈希，数据库只保存哈希
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken 判断是否为个人访问令牌
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"imagebed/utils/animation"
+	"imagebed/utils/metadata"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,6 +22,7 @@ import (
 )
 
 // SupportedFormats 支持的图片格式
+// SVG 可以包含脚本，且无法按像素图片校验内容，不支持上传
 var SupportedFormats = map[string]bool{
 	".jpg":  true,
 	".jpeg": true,
@@ -28,15 +34,34 @@ var SupportedFormats = map[string]bool{
 	".tif":  true,
 }
 
-// AnimatedFormats 动态图片格式(不生成缩略图)
+// AnimatedFormats 可以保存动画的格式，动图逐帧处理
 var AnimatedFormats = map[string]bool{
-	".gif": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// DecodeImage 解码图片并按 EXIF Orientation 标签旋转到正确方向，动图取第一帧
+func DecodeImage(data []byte) (image.Image, error) {
+	img, err := animation.DecodePoster(data)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AutoOrient(img, data), nil
+}
+
+// OpenImage 打开图片文件并按 EXIF Orientation 标签旋转到正确方向
+func OpenImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeImage(data)
 }
 
 // GenerateThumbnail 生成缩略图
 func GenerateThumbnail(sourcePath string, thumbnailPath string, maxWidth int) error {
 	// 打开原图
-	img, err := imaging.Open(sourcePath)
+	img, err := OpenImage(sourcePath)
 	if err != nil {
 		return err
 	}
@@ -55,7 +80,7 @@ func GenerateThumbnail(sourcePath string, thumbnailPath string, maxWidth int) er
 // ConvertImageFormat 转换图片格式
 func ConvertImageFormat(sourcePath string, targetPath string, targetFormat string, quality int) error {
 	// 打开原图
-	img, err := imaging.Open(sourcePath)
+	img, err := OpenImage(sourcePath)
 	if err != nil {
 		return fmt.Errorf("打开图片失败: %w", err)
 	}
@@ -66,11 +91,6 @@ func ConvertImageFormat(sourcePath string, targetPath string, targetFormat strin
 		return fmt.Errorf("创建目录失败: %w", err)
 	}
 
-	// 设置默认质量
-	if quality <= 0 || quality > 100 {
-		quality = 90
-	}
-
 	// 创建输出文件
 	outFile, err := os.Create(targetPath)
 	if err != nil {
@@ -78,23 +98,31 @@ func ConvertImageFormat(sourcePath string, targetPath string, targetFormat strin
 	}
 	defer outFile.Close()
 
-	// 根据目标格式保存
+	return EncodeImage(outFile, img, targetFormat, quality)
+}
+
+// EncodeImage 按目标格式编码图片
+func EncodeImage(w io.Writer, img image.Image, targetFormat string, quality int) error {
+	if quality <= 0 || quality > 100 {
+		quality = 90
+	}
+
 	targetFormat = strings.ToLower(strings.TrimPrefix(targetFormat, "."))
 
 	switch targetFormat {
 	case "jpg", "jpeg":
-		return jpeg.Encode(outFile, img, &jpeg.Options{Quality: quality})
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
 	case "png":
-		return png.Encode(outFile, img)
+		return png.Encode(w, img)
 	case "gif":
-		return gif.Encode(outFile, img, nil)
+		return gif.Encode(w, img, nil)
 	case "bmp":
-		return bmp.Encode(outFile, img)
+		return bmp.Encode(w, img)
 	case "tiff", "tif":
-		return tiff.Encode(outFile, img, nil)
+		return tiff.Encode(w, img, nil)
 	case "webp":
 		// WebP 支持，质量范围 0-100
-		return webp.Encode(outFile, img, &webp.Options{
+		return webp.Encode(w, img, &webp.Options{
 			Lossless: false,
 			Quality:  float32(quality),
 		})
@@ -103,9 +131,151 @@ func ConvertImageFormat(sourcePath string, targetPath string, targetFormat strin
 	}
 }
 
+// TransformAnimation 逐帧变换动图并编码为目标格式，fn 为 nil 时只转换格式
+// 帧数或像素总数超过上限时返回 animation.ErrTooLarge
+func TransformAnimation(data []byte, targetFormat string, quality int, limits animation.Limits, fn func(image.Image) image.Image) ([]byte, error) {
+	anim, err := animation.Decode(data, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	anim = anim.Map(func(frame image.Image) image.Image {
+		frame = metadata.AutoOrient(frame, data)
+		if fn != nil {
+			frame = fn(frame)
+		}
+		return frame
+	})
+
+	buf := new(bytes.Buffer)
+	if err := animation.Encode(buf, anim, targetFormat, quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateThumbnailFromBytes 根据图片内容生成缩略图，输出格式与扩展名一致
+// 动图在处理上限以内时生成动态缩略图，超过上限时使用第一帧作为封面
+func GenerateThumbnailFromBytes(data []byte, ext string, maxWidth int, limits animation.Limits) ([]byte, error) {
+	resize := func(img image.Image) image.Image {
+		return imaging.Resize(img, maxWidth, 0, imaging.Lanczos)
+	}
+
+	if animation.SupportsFormat(ext) && animation.IsAnimated(data) {
+		thumb, err := TransformAnimation(data, ext, 80, limits, resize)
+		if !errors.Is(err, animation.ErrTooLarge) {
+			return thumb, err
+		}
+	}
+
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成缩略图（保持宽高比）
+	thumbnail := resize(img)
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, thumbnail, ext, 80); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertImageBytes 转换内存中图片的格式
+// 动图只能转换为支持动画的格式，超过处理上限时返回错误，不会只保留第一帧
+func ConvertImageBytes(data []byte, targetFormat string, quality int, limits animation.Limits) ([]byte, error) {
+	if animation.IsAnimated(data) {
+		if !animation.SupportsFormat(targetFormat) {
+			return nil, fmt.Errorf("%w: %s", animation.ErrStaticFormat, targetFormat)
+		}
+		return TransformAnimation(data, targetFormat, quality, limits, nil)
+	}
+
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("打开图片失败: %w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, img, targetFormat, quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FitImageBytes 将超过最大尺寸的图片等比缩小到最大尺寸以内并按扩展名对应的格式编码，0 表示不限制
+// 没有超过时原样返回，changed 为 false；重新编码后原图中的 EXIF 等元数据不再保留
+func FitImageBytes(data []byte, ext string, maxWidth, maxHeight, quality int, limits animation.Limits) (output []byte, changed bool, err error) {
+	if maxWidth <= 0 && maxHeight <= 0 {
+		return data, false, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("读取图片尺寸失败: %w", err)
+	}
+	width, height := metadata.OrientedSize(config.Width, config.Height, metadata.Orientation(data))
+	if maxWidth <= 0 {
+		maxWidth = width
+	}
+	if maxHeight <= 0 {
+		maxHeight = height
+	}
+	if width <= maxWidth && height <= maxHeight {
+		return data, false, nil
+	}
+
+	fit := func(img image.Image) image.Image {
+		return imaging.Fit(img, maxWidth, maxHeight, imaging.Lanczos)
+	}
+
+	if animation.SupportsFormat(ext) && animation.IsAnimated(data) {
+		output, err := TransformAnimation(data, ext, quality, limits, fit)
+		if err != nil {
+			return nil, false, err
+		}
+		return output, true, nil
+	}
+
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("打开图片失败: %w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, fit(img), ext, quality); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// MapImageBytes 对图片执行变换并按扩展名对应的格式编码
+// 动图在处理上限以内时逐帧变换并保留动画，超过上限时只输出变换后的第一帧
+func MapImageBytes(data []byte, ext string, quality int, limits animation.Limits, fn func(image.Image) image.Image) ([]byte, error) {
+	if animation.SupportsFormat(ext) && animation.IsAnimated(data) {
+		output, err := TransformAnimation(data, ext, quality, limits, fn)
+		if !errors.Is(err, animation.ErrTooLarge) {
+			return output, err
+		}
+	}
+
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, fmt.Errorf("打开图片失败: %w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, fn(img), ext, quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // GetImageDimensions 获取图片尺寸
 func GetImageDimensions(imagePath string) (int, int, error) {
-	img, err := imaging.Open(imagePath)
+	img, err := OpenImage(imagePath)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -143,7 +313,7 @@ func GetFormatList() ([]string, []string) {
 
 // OptimizeImage 优化图片(压缩质量)
 func OptimizeImage(sourcePath string, targetPath string, quality int) error {
-	img, err := imaging.Open(sourcePath)
+	img, err := OpenImage(sourcePath)
 	if err != nil {
 		return fmt.Errorf("打开图片失败: %w", err)
 	}
@@ -170,7 +340,7 @@ func OptimizeImage(sourcePath string, targetPath string, quality int) error {
 
 // ResizeImage 调整图片大小
 func ResizeImage(sourcePath string, targetPath string, width, height int) error {
-	img, err := imaging.Open(sourcePath)
+	img, err := OpenImage(sourcePath)
 	if err != nil {
 		return fmt.Errorf("打开图片失败: %w", err)
 	}
@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+)
+
+var (
+	// ErrUnknownFormat 文件内容不是支持的图片格式
+	ErrUnknownFormat = errors.New("文件内容不是支持的图片格式")
+	// ErrFormatMismatch 扩展名与文件内容识别出的格式不一致
+	ErrFormatMismatch = errors.New("文件扩展名与内容不一致")
+	// ErrCorruptImage 图片头无法解析
+	ErrCorruptImage = errors.New("图片已损坏或格式不正确")
+	// ErrTooManyPixels 图片像素数超过上限，解码时可能占用过多内存
+	ErrTooManyPixels = errors.New("图片尺寸过大")
+	// ErrPolyglot 图片中夹带了脚本、压缩包等其他格式的内容
+	ErrPolyglot = errors.New("图片中包含脚本或其他格式的内容")
+)
+
+// ImageContent 按文件内容识别出的图片
+type ImageContent struct {
+	Format   string // 格式名，与 image.DecodeConfig 返回的一致，如 jpeg、png
+	MimeType string
+	Width    int
+	Height   int
+	Data     []byte // 去掉图片结束标记之后附加数据的内容
+}
+
+// imageSignatures 各格式的文件头，WebP 另外检查 RIFF 头中的 WEBP 标记
+var imageSignatures = []struct {
+	format string
+	magic  []byte
+}{
+	{"jpeg", []byte{0xFF, 0xD8, 0xFF}},
+	{"png", []byte("\x89PNG\r\n\x1a\n")},
+	{"gif", []byte("GIF87a")},
+	{"gif", []byte("GIF89a")},
+	{"bmp", []byte("BM")},
+	{"tiff", []byte("II*\x00")},
+	{"tiff", []byte("MM\x00*")},
+}
+
+// formatExtensions 各格式允许使用的扩展名
+var formatExtensions = map[string][]string{
+	"jpeg": {".jpg", ".jpeg"},
+	"png":  {".png"},
+	"gif":  {".gif"},
+	"webp": {".webp"},
+	"bmp":  {".bmp"},
+	"tiff": {".tiff", ".tif"},
+}
+
+// trailerSignatures 附加在图片之后时说明文件同时是另一种格式（压缩包、PDF、可执行文件等）
+var trailerSignatures = [][]byte{
+	[]byte("PK\x03\x04"),
+	[]byte("Rar!\x1a\x07"),
+	[]byte("7z\xbc\xaf\x27\x1c"),
+	[]byte("\x1f\x8b"),
+	[]byte("%PDF"),
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	[]byte("#!"),
+	[]byte("<"),
+}
+
+// scriptMarkers 出现在图片任何位置都视为夹带了脚本，比较时不区分大小写
+// 标记都足够长，压缩后的图片数据中偶然出现的概率可以忽略
+var scriptMarkers = [][]byte{
+	[]byte("<script"),
+	[]byte("<iframe"),
+	[]byte("<!doctype html"),
+	[]byte("<?php "),
+	[]byte("<?php\n"),
+	[]byte("<?php\r"),
+	[]byte("<?php\t"),
+}
+
+// zipTailSize ZIP 文件末尾目录记录的最大长度，压缩包软件从文件末尾的这个范围内查找目录
+const zipTailSize = 22 + 65535
+
+// DetectImageFormat 按文件头识别图片格式，不依赖扩展名，无法识别时返回空字符串
+func DetectImageFormat(data []byte) string {
+	for _, sig := range imageSignatures {
+		if bytes.HasPrefix(data, sig.magic) {
+			return sig.format
+		}
+	}
+	if len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")) {
+		return "webp"
+	}
+	return ""
+}
+
+// FormatExt 格式对应的默认扩展名
+func FormatExt(format string) string {
+	if exts := formatExtensions[format]; len(exts) > 0 {
+		return exts[0]
+	}
+	return "." + format
+}
+
+// FormatMimeType 格式对应的 MIME 类型
+func FormatMimeType(format string) string {
+	return "image/" + format
+}
+
+// ExtMatchesFormat 扩展名是否属于该格式，不区分大小写
+func ExtMatchesFormat(ext, format string) bool {
+	ext = strings.ToLower(ext)
+	for _, allowed := range formatExtensions[format] {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateImageContent 按文件内容校验上传的图片，不信任扩展名和客户端提供的类型
+// 依次检查：文件头识别的格式是支持的格式且与扩展名一致，图片头可以解析且格式与文件头一致，
+// 像素数不超过 maxPixels（0 表示不限制），图片中没有夹带脚本，图片结束后没有附加压缩包等其他格式
+// 图片结束后的其他附加数据会被去掉，返回的 Data 为去掉后的内容
+func ValidateImageContent(data []byte, ext string, maxPixels int64) (*ImageContent, error) {
+	format := DetectImageFormat(data)
+	if format == "" || !IsSupportedFormat(FormatExt(format)) {
+		return nil, ErrUnknownFormat
+	}
+	if !ExtMatchesFormat(ext, format) {
+		return nil, fmt.Errorf("%w: 扩展名为 %s，内容为 %s", ErrFormatMismatch, ext, format)
+	}
+
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrCorruptImage
+	}
+	if decoded != format {
+		return nil, fmt.Errorf("%w: 文件头为 %s，图片头为 %s", ErrFormatMismatch, format, decoded)
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d 超过 %d 像素", ErrTooManyPixels, cfg.Width, cfg.Height, maxPixels)
+	}
+
+	if containsScriptMarker(data) || hasEmbeddedArchive(data) {
+		return nil, ErrPolyglot
+	}
+	if end := imageEnd(format, data); end > 0 && end < len(data) {
+		trailer := data[end:]
+		if hasTrailerSignature(bytes.TrimLeft(trailer, "\x00\r\n\t ")) || bytes.Contains(trailer, []byte("PK\x03\x04")) {
+			return nil, ErrPolyglot
+		}
+		data = data[:end]
+	}
+
+	return &ImageContent{
+		Format:   format,
+		MimeType: FormatMimeType(format),
+		Width:    cfg.Width,
+		Height:   cfg.Height,
+		Data:     data,
+	}, nil
+}
+
+// containsScriptMarker 图片中是否夹带了 HTML 或 PHP 脚本
+func containsScriptMarker(data []byte) bool {
+	for _, marker := range scriptMarkers {
+		if indexFold(data, marker) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEmbeddedArchive 文件开头或末尾是否包含其他格式的标记
+// PDF 阅读器在前 1024 字节中查找文件头，压缩包软件在末尾查找目录记录，都不要求标记位于文件的最开始
+func hasEmbeddedArchive(data []byte) bool {
+	head := data[:min(len(data), 1024)]
+	tail := data[max(0, len(data)-zipTailSize):]
+	return bytes.Contains(head, []byte("%PDF-")) || bytes.Contains(tail, []byte("PK\x05\x06"))
+}
+
+// hasTrailerSignature 图片结束后的附加数据是否为其他格式的文件
+func hasTrailerSignature(trailer []byte) bool {
+	for _, sig := range trailerSignatures {
+		if bytes.HasPrefix(trailer, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFold 不区分大小写查找 ASCII 标记，marker 必须为小写
+func indexFold(data, marker []byte) int {
+	for i := 0; i+len(marker) <= len(data); {
+		next := bytes.IndexByte(data[i:], marker[0])
+		if next < 0 {
+			return -1
+		}
+		i += next
+		if i+len(marker) > len(data) {
+			return -1
+		}
+		if bytes.EqualFold(data[i:i+len(marker)], marker) {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// imageEnd 图片结束标记之后的偏移，无法确定时返回 -1
+// BMP、TIFF 没有明确的结束位置，不检查附加数据
+func imageEnd(format string, data []byte) int {
+	switch format {
+	case "jpeg":
+		return jpegEnd(data)
+	case "png":
+		return pngEnd(data)
+	case "gif":
+		return gifEnd(data)
+	case "webp":
+		size := int64(binary.LittleEndian.Uint32(data[4:8]))
+		end := 8 + size + size%2
+		if end > int64(len(data)) {
+			return -1
+		}
+		return int(end)
+	}
+	return -1
+}
+
+// jpegEnd 跳过各个段和熵编码数据找到 EOI 标记
+func jpegEnd(data []byte) int {
+	i := 2
+	for i+2 <= len(data) {
+		if data[i] != 0xFF {
+			return -1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			i++
+			continue
+		case marker == 0xD9: // EOI
+			return i + 2
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // 没有长度的标记
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return -1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 {
+			return -1
+		}
+		i += 2 + length
+
+		if marker == 0xDA {
+			// SOS 之后是熵编码数据，其中 0xFF 后跟 0x00 或 RST 标记不是段的开始
+			for i+1 < len(data) {
+				if data[i] == 0xFF && data[i+1] != 0x00 && (data[i+1] < 0xD0 || data[i+1] > 0xD7) {
+					break
+				}
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+// pngEnd 逐个跳过数据块找到 IEND 块的结尾
+func pngEnd(data []byte) int {
+	i := int64(8)
+	for i+12 <= int64(len(data)) {
+		length := int64(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		i += 12 + length
+		if chunkType == "IEND" {
+			if i > int64(len(data)) {
+				return -1
+			}
+			return int(i)
+		}
+	}
+	return -1
+}
+
+// gifEnd 逐个跳过扩展块和图像块找到结束符 0x3B
+func gifEnd(data []byte) int {
+	if len(data) < 13 {
+		return -1
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks 跳过以长度为 0 的子块结尾的数据子块
+	skipSubBlocks := func(i int) int {
+		for i < len(data) {
+			size := int(data[i])
+			i++
+			if size == 0 {
+				return i
+			}
+			i += size
+		}
+		return -1
+	}
+
+	for i >= 0 && i < len(data) {
+		switch data[i] {
+		case 0x3B:
+			return i + 1
+		case 0x21: // 扩展块：标记、类型、数据子块
+			i = skipSubBlocks(i + 2)
+		case 0x2C: // 图像块：描述符、局部颜色表、LZW 最小码长、数据子块
+			if i+10 > len(data) {
+				return -1
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i = skipSubBlocks(i + 1)
+		default:
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// encodeTestImage 生成指定格式的测试图片
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White})
+	for x := 0; x < width; x++ {
+		img.SetColorIndex(x, x%height, 1)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("生成 %s 图片失败: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateImageContent(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		data := encodeTestImage(t, format, 40, 30)
+		content, err := ValidateImageContent(data, FormatExt(format), 0)
+		if err != nil {
+			t.Fatalf("%s 校验失败: %v", format, err)
+		}
+		if content.Format != format || content.MimeType != "image/"+format || content.Width != 40 || content.Height != 30 {
+			t.Errorf("%s 识别结果不正确: %+v", format, content)
+		}
+		if !bytes.Equal(content.Data, data) {
+			t.Errorf("%s 没有附加数据时内容不应改变", format)
+		}
+	}
+
+	if _, err := ValidateImageContent(encodeTestImage(t, "jpeg", 4, 4), ".JPEG", 0); err != nil {
+		t.Errorf("大写的 .JPEG 扩展名应被接受: %v", err)
+	}
+}
+
+func TestValidateImageContentRejects(t *testing.T) {
+	pngData := encodeTestImage(t, "png", 20, 20)
+	gifData := encodeTestImage(t, "gif", 20, 20)
+	jpegData := encodeTestImage(t, "jpeg", 20, 20)
+
+	// 在 JPEG 的注释段中写入 PHP 代码
+	withComment := append([]byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x14}, []byte("<?php system($x); ")...)
+	withComment = append(withComment, jpegData[2:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		ext  string
+		want error
+	}{
+		{"扩展名与内容不一致", pngData, ".jpg", ErrFormatMismatch},
+		{"不是图片", []byte("hello world"), ".png", ErrUnknownFormat},
+		{"HTML 文件", []byte("<!DOCTYPE html><html><body>x</body></html>"), ".png", ErrUnknownFormat},
+		{"文件头正确但无法解析", []byte("\x89PNG\r\n\x1a\nbroken"), ".png", ErrCorruptImage},
+		{"像素数超过上限", encodeTestImage(t, "png", 40, 30), ".png", ErrTooManyPixels},
+		{"GIF 后附加压缩包", append(append([]byte{}, gifData...), "PK\x03\x04zipdata"...), ".gif", ErrPolyglot},
+		{"PNG 后附加 HTML", append(append([]byte{}, pngData...), "<html><body>x</body></html>"...), ".png", ErrPolyglot},
+		{"JPEG 注释中夹带 PHP", withComment, ".jpg", ErrPolyglot},
+		{"不支持 SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`), ".svg", ErrUnknownFormat},
+		{"包含脚本的 SVG", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), ".png", ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateImageContent(tt.data, tt.ext, 1000); !errors.Is(err, tt.want) {
+				t.Errorf("期望 %v，得到 %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateImageContentStripsTrailer(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		data := encodeTestImage(t, format, 40, 30)
+		padded := append(append([]byte{}, data...), make([]byte, 64)...)
+		content, err := ValidateImageContent(padded, FormatExt(format), 0)
+		if err != nil {
+			t.Fatalf("%s 附加填充数据时校验失败: %v", format, err)
+		}
+		if !bytes.Equal(content.Data, data) {
+			t.Errorf("%s 应去掉图片结束后的附加数据，得到 %d 字节，期望 %d 字节", format, len(content.Data), len(data))
+		}
+	}
+}
+
+func TestDetectImageFormat(t *testing.T) {
+	tests := map[string]string{
+		"\xff\xd8\xff\xe0":            "jpeg",
+		"\x89PNG\r\n\x1a\n":           "png",
+		"GIF89a":                      "gif",
+		"RIFF\x00\x00\x00\x00WEBPVP8": "webp",
+		"II*\x00":                     "tiff",
+		"<svg></svg>":                 "",
+		"plain text":                  "",
+	}
+	for data, want := range tests {
+		if got := DetectImageFormat([]byte(data)); got != want {
+			t.Errorf("DetectImageFormat(%q) = %q, 期望 %q", data, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package imageprocessor
+
+import (
+	"bytes"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// NegotiableFormat 可按 Accept 头协商输出的预生成格式
+type NegotiableFormat struct {
+	MimeType string
+	Ext      string
+	sniff    func(header []byte) bool
+}
+
+// NegotiableFormats 按优先级排列的可协商格式
+// WebP 版本由图片处理任务生成；AVIF 版本需由外部工具生成，存在时优先使用
+var NegotiableFormats = []NegotiableFormat{
+	{MimeType: "image/avif", Ext: ".avif", sniff: IsAVIF},
+	{MimeType: "image/webp", Ext: ".webp", sniff: IsWebP},
+}
+
+// ObjectPath 该格式版本在存储中的路径
+func (f NegotiableFormat) ObjectPath(objectPath string) string {
+	ext := path.Ext(objectPath)
+	return objectPath[:len(objectPath)-len(ext)] + f.Ext
+}
+
+// Matches 检查文件头是否为该格式
+// 早期版本生成的 .webp 文件实际是 JPEG，不能按 WebP 输出
+func (f NegotiableFormat) Matches(header []byte) bool {
+	return f.sniff(header)
+}
+
+// IsWebP 检查文件头是否为 WebP
+func IsWebP(header []byte) bool {
+	return len(header) >= 12 &&
+		bytes.Equal(header[0:4], []byte("RIFF")) &&
+		bytes.Equal(header[8:12], []byte("WEBP"))
+}
+
+// IsAVIF 检查文件头是否为 AVIF
+func IsAVIF(header []byte) bool {
+	if len(header) < 12 || !bytes.Equal(header[4:8], []byte("ftyp")) {
+		return false
+	}
+	brand := string(header[8:12])
+	return brand == "avif" || brand == "avis"
+}
+
+// AcceptsMIME 检查 Accept 头是否明确接受指定的 MIME 类型
+// 只认可明确列出的类型，image/* 和 */* 不算，避免向不支持的客户端输出新格式
+func AcceptsMIME(accept, mimeType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), mimeType) {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
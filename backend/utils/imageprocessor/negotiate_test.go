@@ -0,0 +1,43 @@
+package imageprocessor
+
+import "testing"
+
+func TestAcceptsMIME(t *testing.T) {
+	tests := []struct {
+		accept   string
+		mimeType string
+		expected bool
+	}{
+		{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "image/webp", true},
+		{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "image/avif", true},
+		{"image/webp;q=0.9, image/png", "image/webp", true},
+		{"image/webp;q=0", "image/webp", false},
+		{"image/*,*/*;q=0.8", "image/webp", false},
+		{"", "image/webp", false},
+		{"IMAGE/WEBP", "image/webp", true},
+	}
+
+	for _, tt := range tests {
+		if got := AcceptsMIME(tt.accept, tt.mimeType); got != tt.expected {
+			t.Errorf("AcceptsMIME(%q, %q) = %v，期望 %v", tt.accept, tt.mimeType, got, tt.expected)
+		}
+	}
+}
+
+func TestNegotiableFormatSniff(t *testing.T) {
+	webpHeader := []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")
+	avifHeader := []byte("\x00\x00\x00\x20ftypavif\x00\x00")
+	jpegHeader := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F', 0, 1}
+
+	if !IsWebP(webpHeader) || IsWebP(jpegHeader) {
+		t.Error("WebP 文件头识别错误")
+	}
+	if !IsAVIF(avifHeader) || IsAVIF(webpHeader) {
+		t.Error("AVIF 文件头识别错误")
+	}
+
+	format := NegotiableFormats[len(NegotiableFormats)-1]
+	if got := format.ObjectPath("blobs/ab/abc.jpg"); got != "blobs/ab/abc.webp" {
+		t.Errorf("期望路径 blobs/ab/abc.webp，得到 %s", got)
+	}
+}
@@ -0,0 +1,116 @@
+package imageprocessor
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// DHash 计算图片的差异哈希（dHash）
+// 图片缩小为 9x8 的灰度图后比较每行相邻像素的亮度，得到 64 位哈希
+// 缩放、重新压缩、轻微调色后的图片哈希相同或汉明距离很小
+func DHash(img image.Image) uint64 {
+	small := imaging.Resize(img, 9, 8, imaging.Box)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luminance(small, x, y) < luminance(small, x+1, y) {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+// luminance 像素亮度，透明像素按白色背景合成
+func luminance(img *image.NRGBA, x, y int) int {
+	i := img.PixOffset(x, y)
+	r, g, b, a := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2]), int(img.Pix[i+3])
+	l := (299*r + 587*g + 114*b) / 1000
+	return (l*a + 255*(255-a)) / 255
+}
+
+// HammingDistance 两个哈希的汉明距离（不同的位数）
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash 哈希格式化为 16 位十六进制字符串
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseHash 解析 16 位十六进制哈希
+func ParseHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("无效的哈希: %q", s)
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// HashIndex 按汉明距离检索哈希的 BK 树
+type HashIndex struct {
+	root *bkNode
+}
+
+type bkNode struct {
+	hash     uint64
+	ids      []uint // 哈希相同的图片
+	children map[int]*bkNode
+}
+
+// Add 添加图片的哈希
+func (x *HashIndex) Add(id uint, hash uint64) {
+	if x.root == nil {
+		x.root = &bkNode{hash: hash, ids: []uint{id}}
+		return
+	}
+
+	node := x.root
+	for {
+		d := HammingDistance(node.hash, hash)
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []uint{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// Search 查找与哈希距离不超过 maxDistance 的全部图片
+func (x *HashIndex) Search(hash uint64, maxDistance int, fn func(id uint, distance int)) {
+	if x.root == nil {
+		return
+	}
+
+	stack := []*bkNode{x.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		d := HammingDistance(node.hash, hash)
+		if d <= maxDistance {
+			for _, id := range node.ids {
+				fn(id, d)
+			}
+		}
+		// 三角不等式：只有距离在 [d-max, d+max] 范围内的子树可能包含结果
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package imageprocessor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testPattern 生成带渐变和色块的测试图片
+func testPattern(width, height int, invert bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(255 * x / width)
+			if (x*4/width+y*3/height)%2 == 0 {
+				v = 255 - v/2
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.NRGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHashNearDuplicates(t *testing.T) {
+	original := testPattern(640, 480, false)
+	hash := DHash(original)
+
+	// 缩小后重新压缩为 JPEG
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, imaging.Resize(original, 320, 0, imaging.Lanczos), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	resized, err := jpeg.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := HammingDistance(hash, DHash(resized)); d > 4 {
+		t.Errorf("缩放并重新压缩后的距离 = %d, 期望不超过 4", d)
+	}
+
+	// 调亮后的图片
+	if d := HammingDistance(hash, DHash(imaging.AdjustBrightness(original, 10))); d > 4 {
+		t.Errorf("调整亮度后的距离 = %d, 期望不超过 4", d)
+	}
+
+	if d := HammingDistance(hash, DHash(testPattern(640, 480, true))); d < 20 {
+		t.Errorf("不同图片的距离 = %d, 期望至少 20", d)
+	}
+}
+
+func TestHashFormat(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0xfedcba9876543210} {
+		s := FormatHash(hash)
+		if len(s) != 16 {
+			t.Errorf("FormatHash(%x) = %q", hash, s)
+		}
+		if parsed, err := ParseHash(s); err != nil || parsed != hash {
+			t.Errorf("ParseHash(%q) = %x, %v", s, parsed, err)
+		}
+	}
+	for _, s := range []string{"", "abc", "zzzzzzzzzzzzzzzz", "0123456789abcdef0"} {
+		if _, err := ParseHash(s); err == nil {
+			t.Errorf("ParseHash(%q) 应该失败", s)
+		}
+	}
+}
+
+func TestHashIndexSearch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]uint64, 500)
+	var index HashIndex
+	for i := range hashes {
+		if i > 0 && i%10 == 0 {
+			// 部分哈希只差几位，部分完全相同
+			hashes[i] = hashes[i-1] ^ (1 << uint(rng.Intn(64)))
+		} else {
+			hashes[i] = rng.Uint64()
+		}
+		index.Add(uint(i), hashes[i])
+	}
+	index.Add(1000, hashes[0])
+
+	for _, maxDistance := range []int{0, 3, 20} {
+		for q := 0; q < 50; q++ {
+			query := hashes[rng.Intn(len(hashes))]
+			want := map[uint]int{}
+			for i, h := range hashes {
+				if d := HammingDistance(query, h); d <= maxDistance {
+					want[uint(i)] = d
+				}
+			}
+			if query == hashes[0] {
+				want[1000] = 0
+			}
+
+			got := map[uint]int{}
+			index.Search(query, maxDistance, func(id uint, distance int) {
+				got[id] = distance
+			})
+			if len(got) != len(want) {
+				t.Fatalf("距离 %d: 找到 %d 个, 期望 %d 个", maxDistance, len(got), len(want))
+			}
+			for id, d := range want {
+				if got[id] != d {
+					t.Errorf("图片 %d 距离 = %d, 期望 %d", id, got[id], d)
+				}
+			}
+		}
+	}
+}
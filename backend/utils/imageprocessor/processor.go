@@ -10,10 +10,15 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
+	chaiwebp "github.com/chai2010/webp"
 	"github.com/nfnt/resize"
 	"golang.org/x/image/webp"
+
+	"imagebed/utils/animation"
+	"imagebed/utils/metadata"
 )
 
 // ThumbnailSize 缩略图尺寸定义
@@ -24,7 +29,7 @@ type ThumbnailSize struct {
 }
 
 var (
-	// 预定义的缩略图尺寸
+	// 默认的缩略图尺寸，处理配置可以指定其他尺寸
 	ThumbnailSizes = []ThumbnailSize{
 		{"small", 150, 150},
 		{"medium", 500, 500},
@@ -34,13 +39,20 @@ var (
 	// 支持的图片格式
 	SupportedFormats = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
 
+	// 处理时可以预生成的其他格式版本
+	OutputFormats = []string{"webp"}
+
 	// 图片处理并发控制
 	processingPool = make(chan struct{}, 5) // 最多同时处理5张图片
 )
 
 // ImageProcessor 图片处理器
 type ImageProcessor struct {
-	Quality int // JPEG 质量 (1-100)
+	Quality   int              // JPEG 质量 (1-100)
+	Animation animation.Limits // 逐帧处理动图的上限
+
+	ThumbnailSizes []ThumbnailSize // 生成的缩略图尺寸
+	WebP           bool            // 是否生成 WebP 版本
 }
 
 // NewImageProcessor 创建新的图片处理器
@@ -49,7 +61,10 @@ func NewImageProcessor(quality int) *ImageProcessor {
 		quality = 85 // 默认质量
 	}
 	return &ImageProcessor{
-		Quality: quality,
+		Quality:        quality,
+		Animation:      animation.DefaultLimits,
+		ThumbnailSizes: ThumbnailSizes,
+		WebP:           true,
 	}
 }
 
@@ -60,7 +75,17 @@ func (p *ImageProcessor) ProcessImage(sourcePath string) error {
 	defer func() { <-processingPool }()
 
 	// 读取原始图片
-	img, format, err := p.loadImage(sourcePath)
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("加载图片失败: %w", err)
+	}
+
+	// 动图逐帧处理，不能按第一帧压缩或转换
+	if animation.IsAnimated(data) {
+		return p.processAnimation(sourcePath, data)
+	}
+
+	img, format, err := loadImage(data, filepath.Ext(sourcePath))
 	if err != nil {
 		return fmt.Errorf("加载图片失败: %w", err)
 	}
@@ -87,59 +112,118 @@ func (p *ImageProcessor) ProcessImage(sourcePath string) error {
 	}()
 
 	// 3. 转换为 WebP
+	if p.WebP {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.convertToWebP(sourcePath, img); err != nil {
+				errChan <- fmt.Errorf("WebP转换失败: %w", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// 收集错误
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("图片处理部分失败: %v", errs)
+	}
+
+	return nil
+}
+
+// processAnimation 逐帧处理动图：生成动态缩略图和 WebP 动图
+// 超过处理上限时缩略图使用第一帧，且不生成 WebP 版本，避免输出丢失动画的文件
+func (p *ImageProcessor) processAnimation(sourcePath string, data []byte) error {
+	anim, err := animation.Decode(data, p.Animation)
+	if errors.Is(err, animation.ErrTooLarge) {
+		return p.generatePosterThumbnails(sourcePath, data)
+	}
+	if err != nil {
+		return fmt.Errorf("加载动图失败: %w", err)
+	}
+	anim = anim.Map(func(frame image.Image) image.Image {
+		return metadata.AutoOrient(frame, data)
+	})
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2)
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if err := p.convertToWebP(sourcePath, img); err != nil {
-			errChan <- fmt.Errorf("WebP转换失败: %w", err)
+		// 扩展名与内容不符（如 .png）时无法保存动画，缩略图使用第一帧
+		generate := func() error { return p.generatePosterThumbnails(sourcePath, data) }
+		if animation.SupportsFormat(filepath.Ext(sourcePath)) {
+			generate = func() error { return p.generateAnimatedThumbnails(sourcePath, anim) }
+		}
+		if err := generate(); err != nil {
+			errChan <- fmt.Errorf("生成缩略图失败: %w", err)
 		}
 	}()
 
+	// 原图本身就是 WebP 时不需要转换
+	if p.WebP && strings.ToLower(filepath.Ext(sourcePath)) != ".webp" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.convertAnimationToWebP(sourcePath, anim); err != nil {
+				errChan <- fmt.Errorf("WebP转换失败: %w", err)
+			}
+		}()
+	}
+
 	wg.Wait()
 	close(errChan)
 
-	// 收集错误
 	var errs []error
 	for err := range errChan {
 		errs = append(errs, err)
 	}
-
 	if len(errs) > 0 {
-		return fmt.Errorf("图片处理部分失败: %v", errs)
+		return fmt.Errorf("动图处理部分失败: %v", errs)
 	}
 
 	return nil
 }
 
-// loadImage 加载图片
-func (p *ImageProcessor) loadImage(path string) (image.Image, string, error) {
-	file, err := os.Open(path)
+// loadImage 解码图片，按 EXIF Orientation 标签旋转到正确方向
+func loadImage(data []byte, ext string) (image.Image, string, error) {
+	img, format, err := decodeImage(data, ext)
 	if err != nil {
 		return nil, "", err
 	}
-	defer file.Close()
+	return metadata.AutoOrient(img, data), format, nil
+}
 
-	// 检测格式
-	ext := filepath.Ext(path)
+// decodeImage 按扩展名解码图片
+func decodeImage(data []byte, ext string) (image.Image, string, error) {
+	r := bytes.NewReader(data)
 	var img image.Image
+	var err error
 
 	switch ext {
 	case ".jpg", ".jpeg":
-		img, err = jpeg.Decode(file)
+		img, err = jpeg.Decode(r)
 		return img, "jpeg", err
 	case ".png":
-		img, err = png.Decode(file)
+		img, err = png.Decode(r)
 		return img, "png", err
 	case ".gif":
-		img, err = gif.Decode(file)
+		img, err = gif.Decode(r)
 		return img, "gif", err
 	case ".webp":
-		img, err = webp.Decode(file)
+		img, err = webp.Decode(r)
 		return img, "webp", err
 	default:
 		// 尝试自动检测
-		img, format, err := image.Decode(file)
-		return img, format, err
+		return image.Decode(r)
 	}
 }
 
@@ -173,9 +257,9 @@ func (p *ImageProcessor) generateThumbnails(sourcePath string, img image.Image)
 	nameWithoutExt := filename[:len(filename)-len(ext)]
 
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(ThumbnailSizes))
+	errChan := make(chan error, len(p.ThumbnailSizes))
 
-	for _, size := range ThumbnailSizes {
+	for _, size := range p.ThumbnailSizes {
 		wg.Add(1)
 		go func(s ThumbnailSize) {
 			defer wg.Done()
@@ -204,6 +288,48 @@ func (p *ImageProcessor) generateThumbnails(sourcePath string, img image.Image)
 	return nil
 }
 
+// generatePosterThumbnails 使用动图的第一帧生成所有尺寸的缩略图
+func (p *ImageProcessor) generatePosterThumbnails(sourcePath string, data []byte) error {
+	poster, err := animation.DecodePoster(data)
+	if err != nil {
+		return fmt.Errorf("加载动图失败: %w", err)
+	}
+	return p.generateThumbnails(sourcePath, metadata.AutoOrient(poster, data))
+}
+
+// generateAnimatedThumbnails 生成所有尺寸的动态缩略图，格式与原图一致
+func (p *ImageProcessor) generateAnimatedThumbnails(sourcePath string, anim *animation.Animation) error {
+	ext := filepath.Ext(sourcePath)
+
+	for _, size := range p.ThumbnailSizes {
+		thumbnail := anim.Map(func(frame image.Image) image.Image {
+			return resize.Thumbnail(size.Width, size.Height, frame, resize.Lanczos3)
+		})
+
+		file, err := os.Create(GetThumbnailPath(sourcePath, size.Name))
+		if err != nil {
+			return err
+		}
+		err = animation.Encode(file, thumbnail, ext, p.Quality)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertAnimationToWebP 将动图转换为 WebP 动图
+func (p *ImageProcessor) convertAnimationToWebP(sourcePath string, anim *animation.Animation) error {
+	var buf bytes.Buffer
+	if err := animation.Encode(&buf, anim, "webp", p.Quality); err != nil {
+		return err
+	}
+
+	return os.WriteFile(GetWebPPath(sourcePath), buf.Bytes(), 0644)
+}
+
 // convertToWebP 转换为 WebP 格式
 func (p *ImageProcessor) convertToWebP(sourcePath string, img image.Image) error {
 	dir := filepath.Dir(sourcePath)
@@ -213,14 +339,8 @@ func (p *ImageProcessor) convertToWebP(sourcePath string, img image.Image) error
 
 	webpPath := filepath.Join(dir, nameWithoutExt+".webp")
 
-	// 注意：golang.org/x/image/webp 只支持解码，不支持编码
-	// 这里我们使用一个占位实现，实际项目中可以使用 cwebp 工具或其他库
-	// 例如: github.com/chai2010/webp 或调用系统命令
-
-	// 临时方案：将图片编码为 JPEG 并重命名（仅作示例）
-	// 生产环境建议使用专门的 WebP 编码库
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.Quality}); err != nil {
+	if err := chaiwebp.Encode(&buf, img, &chaiwebp.Options{Quality: float32(p.Quality)}); err != nil {
 		return err
 	}
 
@@ -256,6 +376,15 @@ func (p *ImageProcessor) getFileSize(path string) int {
 	return int(info.Size())
 }
 
+// ThumbnailSizeNames 缩略图尺寸的名称列表
+func ThumbnailSizeNames(sizes []ThumbnailSize) []string {
+	names := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		names = append(names, size.Name)
+	}
+	return names
+}
+
 // GetThumbnailPath 获取缩略图路径
 func GetThumbnailPath(originalPath string, size string) string {
 	dir := filepath.Dir(originalPath)
@@ -78,6 +78,38 @@ func TestProcessImage(t *testing.T) {
 	}
 }
 
+func TestProcessImageCustomSizes(t *testing.T) {
+	tmpDir := t.TempDir()
+	testImagePath := filepath.Join(tmpDir, "test.jpg")
+	if err := createTestImage(testImagePath, 400, 300); err != nil {
+		t.Fatalf("创建测试图片失败: %v", err)
+	}
+
+	processor := NewImageProcessor(70)
+	processor.ThumbnailSizes = []ThumbnailSize{{"tiny", 40, 40}}
+	processor.WebP = false
+	if err := processor.ProcessImage(testImagePath); err != nil {
+		t.Fatalf("处理图片失败: %v", err)
+	}
+
+	file, err := os.Open(GetThumbnailPath(testImagePath, "tiny"))
+	if err != nil {
+		t.Fatalf("缩略图 tiny 未生成: %v", err)
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil || cfg.Width != 40 || cfg.Height != 30 {
+		t.Errorf("缩略图应等比缩放到 40x30，得到 %dx%d (%v)", cfg.Width, cfg.Height, err)
+	}
+
+	if _, err := os.Stat(GetThumbnailPath(testImagePath, "small")); !os.IsNotExist(err) {
+		t.Error("不应生成配置以外的缩略图")
+	}
+	if _, err := os.Stat(GetWebPPath(testImagePath)); !os.IsNotExist(err) {
+		t.Error("关闭 WebP 时不应生成 WebP 文件")
+	}
+}
+
 func TestGetThumbnailPath(t *testing.T) {
 	originalPath := filepath.Join("path", "to", "image.jpg")
 	expectedPath := filepath.Join("path", "to", "image_small.jpg")
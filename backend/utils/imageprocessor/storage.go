@@ -0,0 +1,127 @@
+package imageprocessor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"imagebed/storage"
+)
+
+// ProcessStoredImage 处理存储后端中的图片
+// 先将原图下载到临时目录处理，再把缩略图和 WebP 上传回存储
+func (p *ImageProcessor) ProcessStoredImage(store storage.Storage, objectPath string) error {
+	tmpDir, err := os.MkdirTemp("", "imagebed-process-*")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, path.Base(objectPath))
+	if err := downloadObject(store, objectPath, localPath); err != nil {
+		return err
+	}
+
+	if err := p.ProcessImage(localPath); err != nil {
+		return err
+	}
+
+	// 原图按内容哈希寻址，可能被多张图片共享，压缩结果不回写原图
+	// 缩略图
+	for _, size := range p.ThumbnailSizes {
+		localThumb := GetThumbnailPath(localPath, size.Name)
+		if err := uploadObject(store, localThumb, ThumbnailObjectPath(objectPath, size.Name)); err != nil {
+			return err
+		}
+	}
+
+	// WebP 版本（原图本身就是 WebP 时不覆盖，动图超过处理上限时不生成）
+	if webpObject := WebPObjectPath(objectPath); webpObject != objectPath {
+		localWebP := GetWebPPath(localPath)
+		if _, err := os.Stat(localWebP); err == nil {
+			if err := uploadObject(store, localWebP, webpObject); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ThumbnailObjectPath 获取缩略图在存储中的路径
+func ThumbnailObjectPath(objectPath string, size string) string {
+	ext := path.Ext(objectPath)
+	return fmt.Sprintf("%s_%s%s", objectPath[:len(objectPath)-len(ext)], size, ext)
+}
+
+// WebPObjectPath 获取 WebP 版本在存储中的路径
+func WebPObjectPath(objectPath string) string {
+	ext := path.Ext(objectPath)
+	return objectPath[:len(objectPath)-len(ext)] + ".webp"
+}
+
+// CleanupStoredFiles 清理存储中处理生成的文件，sizes 为生成过的缩略图尺寸名称
+func CleanupStoredFiles(store storage.Storage, objectPath string, sizes []string) error {
+	var errs []error
+
+	for _, size := range sizes {
+		if err := store.Delete(ThumbnailObjectPath(objectPath, size)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if webpObject := WebPObjectPath(objectPath); webpObject != objectPath {
+		if err := store.Delete(webpObject); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("清理文件时发生部分错误: %v", errs)
+	}
+
+	return nil
+}
+
+// downloadObject 将存储中的文件下载到本地路径
+func downloadObject(store storage.Storage, objectPath, localPath string) error {
+	reader, err := store.Get(objectPath)
+	if err != nil {
+		return fmt.Errorf("读取存储文件失败: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("下载文件失败: %w", err)
+	}
+
+	return nil
+}
+
+// uploadObject 将本地文件上传到存储
+func uploadObject(store storage.Storage, localPath, objectPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.SaveFromReader(objectPath, file, info.Size()); err != nil {
+		return fmt.Errorf("上传处理结果失败: %w", err)
+	}
+
+	return nil
+}
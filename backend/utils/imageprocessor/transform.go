@@ -0,0 +1,337 @@
+package imageprocessor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+
+	"imagebed/utils/animation"
+	"imagebed/utils/metadata"
+)
+
+// 缩放模式
+const (
+	FitCover   = "cover"   // 等比缩放后按重心裁剪，填满目标尺寸
+	FitContain = "contain" // 等比缩放到目标尺寸以内，不裁剪
+	FitFill    = "fill"    // 拉伸到目标尺寸，不保持宽高比
+)
+
+// transformParams 会触发图片变换的查询参数
+var transformParams = []string{"w", "h", "fit", "gravity", "format", "q", "blur", "rotate"}
+
+// gravityAnchors 裁剪重心
+var gravityAnchors = map[string]imaging.Anchor{
+	"center":    imaging.Center,
+	"north":     imaging.Top,
+	"south":     imaging.Bottom,
+	"east":      imaging.Right,
+	"west":      imaging.Left,
+	"northeast": imaging.TopRight,
+	"northwest": imaging.TopLeft,
+	"southeast": imaging.BottomRight,
+	"southwest": imaging.BottomLeft,
+}
+
+// TransformLimits 变换参数的允许范围
+type TransformLimits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxBlur   float64
+}
+
+// TransformOptions 图片变换参数
+type TransformOptions struct {
+	Width   int     // 目标宽度，0 表示按高度等比缩放
+	Height  int     // 目标高度，0 表示按宽度等比缩放
+	Fit     string  // 缩放模式，同时指定宽高时生效
+	Gravity string  // 裁剪重心，cover 模式生效
+	Format  string  // 输出格式 webp/jpeg/png
+	Quality int     // 输出质量 1-100，png 无效
+	Blur    float64 // 高斯模糊 sigma
+	Rotate  int     // 顺时针旋转角度 0/90/180/270
+
+	// Overlay 变换后叠加到图片上的内容（如水印），不参与缓存键和签名
+	Overlay func(image.Image) image.Image
+}
+
+// HasTransformParams 查询参数中是否包含变换参数
+func HasTransformParams(values url.Values) bool {
+	for _, name := range transformParams {
+		if values.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTransformOptions 解析并校验变换参数，没有变换参数时返回 nil
+func ParseTransformOptions(values url.Values, limits TransformLimits) (*TransformOptions, error) {
+	if !HasTransformParams(values) {
+		return nil, nil
+	}
+
+	opts := &TransformOptions{
+		Fit:     strings.ToLower(values.Get("fit")),
+		Gravity: strings.ToLower(values.Get("gravity")),
+		Format:  strings.ToLower(values.Get("format")),
+	}
+
+	var err error
+	if opts.Width, err = parseIntParam(values, "w", 0, limits.MaxWidth); err != nil {
+		return nil, err
+	}
+	if opts.Height, err = parseIntParam(values, "h", 0, limits.MaxHeight); err != nil {
+		return nil, err
+	}
+	if opts.Quality, err = parseIntParam(values, "q", 1, 100); err != nil {
+		return nil, err
+	}
+	if opts.Rotate, err = parseIntParam(values, "rotate", 0, 359); err != nil {
+		return nil, err
+	}
+	if opts.Rotate%90 != 0 {
+		return nil, errors.New("rotate 只支持 0、90、180、270")
+	}
+
+	if s := values.Get("blur"); s != "" {
+		blur, err := strconv.ParseFloat(s, 64)
+		if err != nil || blur < 0 || blur > limits.MaxBlur {
+			return nil, fmt.Errorf("blur 取值范围为 0-%g", limits.MaxBlur)
+		}
+		opts.Blur = blur
+	}
+
+	switch opts.Fit {
+	case "", FitCover, FitContain, FitFill:
+	default:
+		return nil, fmt.Errorf("不支持的 fit: %s，可选 cover/contain/fill", opts.Fit)
+	}
+
+	if opts.Gravity != "" {
+		if _, ok := gravityAnchors[opts.Gravity]; !ok {
+			return nil, fmt.Errorf("不支持的 gravity: %s", opts.Gravity)
+		}
+	}
+
+	switch opts.Format {
+	case "", "webp", "png", "jpeg":
+	case "jpg":
+		opts.Format = "jpeg"
+	default:
+		return nil, fmt.Errorf("不支持的 format: %s，可选 webp/jpeg/png", opts.Format)
+	}
+
+	return opts, nil
+}
+
+// parseIntParam 解析整数参数，缺省时返回 0
+func parseIntParam(values url.Values, name string, min, max int) (int, error) {
+	s := values.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || (max > 0 && v > max) {
+		if max > 0 {
+			return 0, fmt.Errorf("%s 取值范围为 %d-%d", name, min, max)
+		}
+		return 0, fmt.Errorf("%s 必须是不小于 %d 的整数", name, min)
+	}
+	return v, nil
+}
+
+// Normalize 填充默认值并清除不起作用的参数，使等价的参数得到相同的缓存键
+// sourceExt 为原图扩展名，未指定输出格式时尽量保持原格式，GIF 输出为 WebP 以保留动画
+func (o *TransformOptions) Normalize(sourceExt string) {
+	if o.Format == "" {
+		switch strings.ToLower(strings.TrimPrefix(sourceExt, ".")) {
+		case "png":
+			o.Format = "png"
+		case "webp", "gif":
+			o.Format = "webp"
+		default:
+			o.Format = "jpeg"
+		}
+	}
+
+	if o.Format == "png" {
+		o.Quality = 0
+	} else if o.Quality == 0 {
+		o.Quality = 85
+	}
+
+	if o.Width > 0 && o.Height > 0 {
+		if o.Fit == "" {
+			o.Fit = FitContain
+		}
+	} else {
+		o.Fit = ""
+	}
+
+	if o.Fit == FitCover {
+		if o.Gravity == "" {
+			o.Gravity = "center"
+		}
+	} else {
+		o.Gravity = ""
+	}
+
+	o.Rotate %= 360
+}
+
+// CacheKey 规范化后的参数键，调用前需要先 Normalize
+func (o *TransformOptions) CacheKey() string {
+	return o.Query().Encode()
+}
+
+// Query 规范化后的查询参数，只包含起作用的参数
+func (o *TransformOptions) Query() url.Values {
+	values := url.Values{}
+	if o.Width > 0 {
+		values.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		values.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" {
+		values.Set("fit", o.Fit)
+	}
+	if o.Gravity != "" {
+		values.Set("gravity", o.Gravity)
+	}
+	if o.Quality > 0 {
+		values.Set("q", strconv.Itoa(o.Quality))
+	}
+	if o.Blur > 0 {
+		values.Set("blur", strconv.FormatFloat(o.Blur, 'f', -1, 64))
+	}
+	if o.Rotate != 0 {
+		values.Set("rotate", strconv.Itoa(o.Rotate))
+	}
+	values.Set("format", o.Format)
+	return values
+}
+
+// Extension 输出文件扩展名
+func (o *TransformOptions) Extension() string {
+	if o.Format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + o.Format
+}
+
+// ContentType 输出文件的 MIME 类型
+func (o *TransformOptions) ContentType() string {
+	return "image/" + o.Format
+}
+
+// Apply 对图片执行变换
+func (o *TransformOptions) Apply(img image.Image) image.Image {
+	// imaging 的旋转方向为逆时针
+	switch o.Rotate {
+	case 90:
+		img = imaging.Rotate270(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate90(img)
+	}
+
+	switch {
+	case o.Width > 0 && o.Height > 0:
+		switch o.Fit {
+		case FitCover:
+			img = imaging.Fill(img, o.Width, o.Height, gravityAnchors[o.Gravity], imaging.Lanczos)
+		case FitFill:
+			img = imaging.Resize(img, o.Width, o.Height, imaging.Lanczos)
+		default:
+			img = imaging.Fit(img, o.Width, o.Height, imaging.Lanczos)
+		}
+	case o.Width > 0 || o.Height > 0:
+		img = imaging.Resize(img, o.Width, o.Height, imaging.Lanczos)
+	}
+
+	if o.Blur > 0 {
+		img = imaging.Blur(img, o.Blur)
+	}
+
+	if o.Overlay != nil {
+		img = o.Overlay(img)
+	}
+
+	return img
+}
+
+// Transform 解码、变换并编码图片
+// 动图输出为 WebP 时逐帧变换并保留动画，帧数或像素总数超过 limits 时返回错误
+// 明确指定 JPEG、PNG 等静态格式时使用第一帧
+func (o *TransformOptions) Transform(data []byte, limits animation.Limits) ([]byte, error) {
+	if animation.SupportsFormat(o.Format) && animation.IsAnimated(data) {
+		return o.transformAnimation(data, limits)
+	}
+
+	img, err := animation.DecodePoster(data)
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+
+	img = o.Apply(metadata.AutoOrient(img, data))
+
+	buf := new(bytes.Buffer)
+	switch o.Format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "webp":
+		err = webp.Encode(buf, img, &webp.Options{Quality: float32(o.Quality)})
+	default:
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: o.Quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码图片失败: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// transformAnimation 逐帧变换动图
+func (o *TransformOptions) transformAnimation(data []byte, limits animation.Limits) ([]byte, error) {
+	anim, err := animation.Decode(data, limits)
+	if err != nil {
+		return nil, fmt.Errorf("解码动图失败: %w", err)
+	}
+
+	anim = anim.Map(func(frame image.Image) image.Image {
+		return o.Apply(metadata.AutoOrient(frame, data))
+	})
+
+	buf := new(bytes.Buffer)
+	if err := animation.Encode(buf, anim, o.Format, o.Quality); err != nil {
+		return nil, fmt.Errorf("编码动图失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SignTransform 计算变换参数的签名
+func SignTransform(secret, imageUUID string, o *TransformOptions) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(imageUUID + "?" + o.CacheKey()))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// VerifyTransformSignature 校验变换参数的签名
+func VerifyTransformSignature(secret, imageUUID string, o *TransformOptions, signature string) bool {
+	return hmac.Equal([]byte(SignTransform(secret, imageUUID, o)), []byte(signature))
+}
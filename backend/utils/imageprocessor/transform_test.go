@@ -0,0 +1,139 @@
+package imageprocessor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+
+	"imagebed/utils/animation"
+)
+
+var testLimits = TransformLimits{MaxWidth: 1000, MaxHeight: 1000, MaxBlur: 20}
+
+func TestParseTransformOptions(t *testing.T) {
+	opts, err := ParseTransformOptions(url.Values{"foo": {"bar"}}, testLimits)
+	if err != nil || opts != nil {
+		t.Fatalf("没有变换参数时应返回 nil，得到 %+v, %v", opts, err)
+	}
+
+	invalid := []url.Values{
+		{"w": {"2000"}},
+		{"w": {"-1"}},
+		{"h": {"abc"}},
+		{"fit": {"stretch"}},
+		{"gravity": {"middle"}},
+		{"format": {"gif"}},
+		{"q": {"0"}},
+		{"blur": {"30"}},
+		{"rotate": {"45"}},
+	}
+	for _, values := range invalid {
+		if _, err := ParseTransformOptions(values, testLimits); err == nil {
+			t.Errorf("参数 %v 应该校验失败", values)
+		}
+	}
+
+	opts, err = ParseTransformOptions(url.Values{"w": {"300"}, "format": {"jpg"}, "rotate": {"90"}}, testLimits)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if opts.Width != 300 || opts.Format != "jpeg" || opts.Rotate != 90 {
+		t.Errorf("解析结果错误: %+v", opts)
+	}
+}
+
+func TestTransformCacheKeyNormalization(t *testing.T) {
+	keyOf := func(values url.Values, ext string) string {
+		opts, err := ParseTransformOptions(values, testLimits)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+		opts.Normalize(ext)
+		return opts.CacheKey()
+	}
+
+	// contain 模式下 gravity 不起作用，默认 fit 为 contain
+	a := keyOf(url.Values{"w": {"100"}, "h": {"100"}, "gravity": {"north"}}, ".jpg")
+	b := keyOf(url.Values{"h": {"100"}, "w": {"100"}, "fit": {"contain"}}, ".jpg")
+	if a != b {
+		t.Errorf("等价参数的缓存键不同: %s != %s", a, b)
+	}
+
+	// png 没有质量参数，未指定格式时保持原格式
+	c := keyOf(url.Values{"w": {"100"}, "q": {"50"}}, ".png")
+	d := keyOf(url.Values{"w": {"100"}, "format": {"png"}}, ".jpg")
+	if c != d {
+		t.Errorf("等价参数的缓存键不同: %s != %s", c, d)
+	}
+
+	if keyOf(url.Values{"w": {"100"}}, ".jpg") == keyOf(url.Values{"w": {"100"}, "q": {"50"}}, ".jpg") {
+		t.Error("不同质量的缓存键不应相同")
+	}
+}
+
+func TestTransformApply(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          TransformOptions
+		width, height int
+	}{
+		{"cover", TransformOptions{Width: 50, Height: 50, Fit: FitCover, Gravity: "center"}, 50, 50},
+		{"contain", TransformOptions{Width: 50, Height: 50, Fit: FitContain}, 50, 25},
+		{"fill", TransformOptions{Width: 50, Height: 50, Fit: FitFill}, 50, 50},
+		{"width only", TransformOptions{Width: 100}, 100, 50},
+		{"rotate", TransformOptions{Rotate: 90}, 100, 200},
+		{"blur", TransformOptions{Blur: 2}, 200, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounds := tt.opts.Apply(src).Bounds()
+			if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+				t.Errorf("期望尺寸 %dx%d，得到 %dx%d", tt.width, tt.height, bounds.Dx(), bounds.Dy())
+			}
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+	opts := &TransformOptions{Width: 40, Format: "webp"}
+	opts.Normalize(".png")
+	output, err := opts.Transform(buf.Bytes(), animation.DefaultLimits)
+	if err != nil {
+		t.Fatalf("变换失败: %v", err)
+	}
+	if !bytes.HasPrefix(output, []byte("RIFF")) {
+		t.Error("输出应为 WebP 格式")
+	}
+}
+
+func TestTransformSignature(t *testing.T) {
+	opts := &TransformOptions{Width: 100}
+	opts.Normalize(".jpg")
+
+	signature := SignTransform("secret", "uuid-1", opts)
+	if !VerifyTransformSignature("secret", "uuid-1", opts, signature) {
+		t.Error("签名校验失败")
+	}
+	if VerifyTransformSignature("secret", "uuid-2", opts, signature) {
+		t.Error("不同图片的签名不应通过校验")
+	}
+
+	other := &TransformOptions{Width: 200}
+	other.Normalize(".jpg")
+	if VerifyTransformSignature("secret", "uuid-1", other, signature) {
+		t.Error("修改参数后签名不应通过校验")
+	}
+}
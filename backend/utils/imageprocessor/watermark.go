@@ -0,0 +1,207 @@
+package imageprocessor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 水印类型
+const (
+	WatermarkText  = "text"  // 文字水印
+	WatermarkImage = "image" // 图片水印
+)
+
+// watermarkFontSize 渲染文字水印的字号，叠加时再按比例缩放
+const watermarkFontSize = 64
+
+// WatermarkOptions 水印的位置、透明度、缩放和平铺方式
+type WatermarkOptions struct {
+	Position string  // 位置，取值同裁剪重心 center/north/south/east/west/northeast/northwest/southeast/southwest
+	Opacity  float64 // 不透明度 0-1
+	Scale    float64 // 水印宽度占图片宽度的比例 0-1
+	Tile     bool    // 平铺整张图片，此时忽略位置
+}
+
+// Watermark 可以叠加到图片上的水印
+type Watermark struct {
+	mark *image.NRGBA
+	opts WatermarkOptions
+}
+
+// ValidWatermarkPosition 位置是否有效
+func ValidWatermarkPosition(position string) bool {
+	_, ok := gravityAnchors[position]
+	return ok
+}
+
+// NewWatermark 使用图片创建水印
+func NewWatermark(mark image.Image, opts WatermarkOptions) (*Watermark, error) {
+	if mark.Bounds().Empty() {
+		return nil, errors.New("水印图片为空")
+	}
+	if !ValidWatermarkPosition(opts.Position) {
+		return nil, fmt.Errorf("不支持的水印位置: %s", opts.Position)
+	}
+	if opts.Opacity <= 0 || opts.Opacity > 1 || opts.Scale <= 0 || opts.Scale > 1 {
+		return nil, errors.New("水印不透明度和缩放比例必须在 0-1 之间")
+	}
+	return &Watermark{mark: imaging.Clone(mark), opts: opts}, nil
+}
+
+// NewTextWatermark 渲染文字创建水印，fontData 为空时使用内置字体（不包含中文字形）
+func NewTextWatermark(text string, textColor color.Color, fontData []byte, opts WatermarkOptions) (*Watermark, error) {
+	mark, err := RenderWatermarkText(text, textColor, fontData)
+	if err != nil {
+		return nil, err
+	}
+	return NewWatermark(mark, opts)
+}
+
+// RenderWatermarkText 将一行文字渲染为透明背景的图片，文字带半透明阴影以便在浅色背景上辨认
+func RenderWatermarkText(text string, textColor color.Color, fontData []byte) (*image.NRGBA, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("水印文字为空")
+	}
+	if len(fontData) == 0 {
+		fontData = goregular.TTF
+	}
+
+	parsed, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体失败: %w", err)
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: watermarkFontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("加载字体失败: %w", err)
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	shadow := watermarkFontSize / 32
+	padding := watermarkFontSize / 8
+	width := font.MeasureString(face, text).Ceil() + padding*2 + shadow
+	height := (metrics.Ascent + metrics.Descent).Ceil() + padding*2 + shadow
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	drawer := &font.Drawer{Dst: canvas, Face: face}
+	baseline := padding + metrics.Ascent.Ceil()
+
+	drawer.Src = image.NewUniform(color.NRGBA{A: 96})
+	drawer.Dot = fixed.P(padding+shadow, baseline+shadow)
+	drawer.DrawString(text)
+
+	drawer.Src = image.NewUniform(textColor)
+	drawer.Dot = fixed.P(padding, baseline)
+	drawer.DrawString(text)
+
+	return canvas, nil
+}
+
+// ParseHexColor 解析 #RRGGBB 或 #RRGGBBAA 形式的颜色
+func ParseHexColor(value string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("颜色格式无效: %s", value)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("颜色格式无效: %s", value)
+	}
+	if len(hex) == 6 {
+		n = n<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// Apply 将水印叠加到图片上，返回新图片
+// 水印按图片宽度等比缩放，高度超过图片时缩小到图片高度以内
+func (w *Watermark) Apply(img image.Image) image.Image {
+	bounds := img.Bounds()
+	source := w.mark.Bounds().Size()
+	width := int(float64(bounds.Dx()) * w.opts.Scale)
+	height := source.Y * width / source.X
+	if height > bounds.Dy() {
+		height = bounds.Dy()
+		width = source.X * height / source.Y
+	}
+	if width < 1 || height < 1 {
+		return img
+	}
+	mark := imaging.Resize(w.mark, width, height, imaging.Lanczos)
+	fadeAlpha(mark, w.opts.Opacity)
+
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	markSize := mark.Bounds().Size()
+	if w.opts.Tile {
+		// 水印之间留出半个水印的间距，相邻行错开半个间隔
+		stepX := markSize.X + markSize.X/2
+		stepY := markSize.Y * 2
+		for row, y := 0, markSize.Y/2; y < dst.Rect.Dy(); row, y = row+1, y+stepY {
+			x := 0
+			if row%2 == 1 {
+				x = -stepX / 2
+			}
+			for ; x < dst.Rect.Dx(); x += stepX {
+				draw.Draw(dst, mark.Bounds().Add(image.Pt(x, y)), mark, image.Point{}, draw.Over)
+			}
+		}
+		return dst
+	}
+
+	at := watermarkPosition(w.opts.Position, dst.Rect.Size(), markSize)
+	draw.Draw(dst, mark.Bounds().Add(at), mark, image.Point{}, draw.Over)
+	return dst
+}
+
+// watermarkPosition 按位置计算水印左上角的坐标，边缘留出短边 3% 的边距
+func watermarkPosition(position string, canvas, mark image.Point) image.Point {
+	margin := min(canvas.X, canvas.Y) * 3 / 100
+	left, top := margin, margin
+	right, bottom := canvas.X-mark.X-margin, canvas.Y-mark.Y-margin
+	centerX, centerY := (canvas.X-mark.X)/2, (canvas.Y-mark.Y)/2
+
+	switch position {
+	case "north":
+		return image.Pt(centerX, top)
+	case "south":
+		return image.Pt(centerX, bottom)
+	case "east":
+		return image.Pt(right, centerY)
+	case "west":
+		return image.Pt(left, centerY)
+	case "northeast":
+		return image.Pt(right, top)
+	case "northwest":
+		return image.Pt(left, top)
+	case "southwest":
+		return image.Pt(left, bottom)
+	case "southeast":
+		return image.Pt(right, bottom)
+	default:
+		return image.Pt(centerX, centerY)
+	}
+}
+
+// fadeAlpha 按不透明度降低图片的 alpha 通道
+func fadeAlpha(img *image.NRGBA, opacity float64) {
+	if opacity >= 1 {
+		return
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(float64(img.Pix[i]) * opacity)
+	}
+}
@@ -0,0 +1,112 @@
+package imageprocessor
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestWatermarkPosition(t *testing.T) {
+	background := imaging.New(200, 100, color.NRGBA{0, 0, 0, 255})
+	mark := imaging.New(10, 10, color.NRGBA{255, 255, 255, 255})
+
+	watermark, err := NewWatermark(mark, WatermarkOptions{Position: "southeast", Opacity: 1, Scale: 0.2})
+	if err != nil {
+		t.Fatalf("创建水印失败: %v", err)
+	}
+	result := watermark.Apply(background)
+
+	if result.Bounds() != background.Bounds() {
+		t.Fatalf("叠加水印后尺寸应不变，得到 %v", result.Bounds())
+	}
+	// 水印按宽度 20% 缩放为 40x40，右下角留出 3px 边距
+	if r, _, _, _ := result.At(190, 90).RGBA(); r>>8 != 255 {
+		t.Errorf("右下角应叠加水印，得到 R=%d", r>>8)
+	}
+	if r, _, _, _ := result.At(10, 10).RGBA(); r != 0 {
+		t.Error("左上角不应叠加水印")
+	}
+	if r, _, _, _ := result.At(199, 99).RGBA(); r != 0 {
+		t.Error("水印与边缘之间应留出边距")
+	}
+}
+
+func TestWatermarkOpacity(t *testing.T) {
+	background := imaging.New(100, 100, color.NRGBA{0, 0, 0, 255})
+	mark := imaging.New(10, 10, color.NRGBA{255, 255, 255, 255})
+
+	watermark, err := NewWatermark(mark, WatermarkOptions{Position: "center", Opacity: 0.5, Scale: 0.5})
+	if err != nil {
+		t.Fatalf("创建水印失败: %v", err)
+	}
+	r, _, _, _ := watermark.Apply(background).At(50, 50).RGBA()
+	if value := r >> 8; value < 120 || value > 135 {
+		t.Errorf("50%% 不透明度的白色水印叠加到黑色背景上应为灰色，得到 %d", value)
+	}
+}
+
+func TestWatermarkTile(t *testing.T) {
+	background := imaging.New(300, 300, color.NRGBA{0, 0, 0, 255})
+	mark := imaging.New(10, 10, color.NRGBA{255, 255, 255, 255})
+
+	watermark, err := NewWatermark(mark, WatermarkOptions{Position: "center", Opacity: 1, Scale: 0.1, Tile: true})
+	if err != nil {
+		t.Fatalf("创建水印失败: %v", err)
+	}
+	result := watermark.Apply(background)
+
+	// 统计每个 100x100 区域是否都有水印
+	for _, cell := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {2, 2}} {
+		found := false
+		for y := cell.Y * 100; y < (cell.Y+1)*100 && !found; y++ {
+			for x := cell.X * 100; x < (cell.X+1)*100; x++ {
+				if r, _, _, _ := result.At(x, y).RGBA(); r>>8 == 255 {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			t.Errorf("平铺时区域 %v 应有水印", cell)
+		}
+	}
+}
+
+func TestTextWatermark(t *testing.T) {
+	mark, err := RenderWatermarkText("shortimg", color.White, nil)
+	if err != nil {
+		t.Fatalf("渲染文字失败: %v", err)
+	}
+	if mark.Bounds().Dx() <= mark.Bounds().Dy() {
+		t.Errorf("单行文字水印应为横向图片，得到 %v", mark.Bounds())
+	}
+
+	if _, err := RenderWatermarkText("  ", color.White, nil); err == nil {
+		t.Error("空白文字应返回错误")
+	}
+	if _, err := NewWatermark(mark, WatermarkOptions{Position: "middle", Opacity: 1, Scale: 0.2}); err == nil {
+		t.Error("无效位置应返回错误")
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected color.NRGBA
+		valid    bool
+	}{
+		{"#ff8000", color.NRGBA{255, 128, 0, 255}, true},
+		{"00000080", color.NRGBA{0, 0, 0, 128}, true},
+		{"#fff", color.NRGBA{}, false},
+		{"#gggggg", color.NRGBA{}, false},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseHexColor(tt.value)
+		if (err == nil) != tt.valid || result != tt.expected {
+			t.Errorf("ParseHexColor(%q) = %v, %v", tt.value, result, err)
+		}
+	}
+}
@@ -6,25 +6,52 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// defaultTokenTTL 未配置时的访问令牌有效期
+const defaultTokenTTL = 15 * time.Minute
+
+// TokenTTL 访问令牌有效期
+func TokenTTL() time.Duration {
+	if cfg := config.GetConfig(); cfg.JWTExpiration > 0 {
+		return cfg.JWTExpiration
+	}
+	return defaultTokenTTL
+}
+
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID     uint   `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	IssuedAtMs int64  `json:"iat_ms,omitempty"` // 毫秒精度的签发时间，iat 只精确到秒
 	jwt.RegisteredClaims
 }
 
+// IssuedAtMillis 签发时间（毫秒），旧令牌没有 iat_ms 时取 iat 所在秒的开始
+func (c *Claims) IssuedAtMillis() int64 {
+	if c.IssuedAtMs > 0 {
+		return c.IssuedAtMs
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.UnixMilli()
+	}
+	return 0
+}
+
 // GenerateToken 生成JWT token
 func GenerateToken(userID uint, username, role string) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:     userID,
+		Username:   username,
+		Role:       role,
+		IssuedAtMs: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24小时过期
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(), // jti，用于吊销
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
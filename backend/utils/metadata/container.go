@@ -0,0 +1,184 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+)
+
+// 元数据在各格式中的标识
+var (
+	jpegExifPrefix        = []byte("Exif\x00\x00")
+	jpegXMPPrefix         = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	jpegExtendedXMPPrefix = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	jpegPhotoshopPrefix   = []byte("Photoshop 3.0\x00")
+	pngSignature          = []byte("\x89PNG\r\n\x1a\n")
+	pngXMPKeyword         = []byte("XML:com.adobe.xmp")
+)
+
+// maxXMPSize 解压 XMP 的大小上限
+const maxXMPSize = 1 << 20
+
+// 文件格式
+const (
+	formatUnknown = iota
+	formatJPEG
+	formatPNG
+	formatWebP
+	formatTIFF
+)
+
+// detectFormat 根据文件头识别格式
+func detectFormat(data []byte) int {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return formatJPEG
+	case bytes.HasPrefix(data, pngSignature):
+		return formatPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return formatWebP
+	case len(data) >= 4 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*"):
+		return formatTIFF
+	}
+	return formatUnknown
+}
+
+// rawMetadata 从文件中找到的原始元数据
+type rawMetadata struct {
+	exif      []byte // TIFF 结构的 EXIF 数据
+	xmp       []byte // XMP 数据包
+	photoshop bool   // 包含 Photoshop/IPTC 数据
+}
+
+// findMetadata 在 JPEG、PNG、WebP、TIFF 文件中查找 EXIF 和 XMP 数据
+func findMetadata(data []byte) rawMetadata {
+	var raw rawMetadata
+	switch detectFormat(data) {
+	case formatJPEG:
+		walkJPEG(data, func(marker byte, payload []byte) {
+			switch {
+			case marker == 0xE1 && bytes.HasPrefix(payload, jpegExifPrefix) && raw.exif == nil:
+				raw.exif = payload[len(jpegExifPrefix):]
+			case marker == 0xE1 && bytes.HasPrefix(payload, jpegXMPPrefix) && raw.xmp == nil:
+				raw.xmp = payload[len(jpegXMPPrefix):]
+			case marker == 0xED && bytes.HasPrefix(payload, jpegPhotoshopPrefix):
+				raw.photoshop = true
+			}
+		})
+	case formatPNG:
+		walkPNG(data, func(chunkType string, payload []byte) {
+			switch {
+			case chunkType == "eXIf" && raw.exif == nil:
+				raw.exif = payload
+			case chunkType == "iTXt" && raw.xmp == nil:
+				raw.xmp = pngXMP(payload)
+			}
+		})
+	case formatWebP:
+		walkWebP(data, func(fourCC string, payload []byte) {
+			switch {
+			case fourCC == "EXIF" && raw.exif == nil:
+				raw.exif = bytes.TrimPrefix(payload, jpegExifPrefix)
+			case fourCC == "XMP " && raw.xmp == nil:
+				raw.xmp = payload
+			}
+		})
+	case formatTIFF:
+		raw.exif = data
+	}
+	return raw
+}
+
+// walkJPEG 遍历 JPEG 图像数据之前的所有段
+func walkJPEG(data []byte, fn func(marker byte, payload []byte)) {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			i++
+			continue
+		case marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			i += 2
+			continue
+		case marker == 0xD9 || marker == 0xDA: // 图像结束或图像数据开始
+			return
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return
+		}
+		fn(marker, data[i+4:i+2+length])
+		i += 2 + length
+	}
+}
+
+// walkPNG 遍历 PNG 的所有数据块
+func walkPNG(data []byte, fn func(chunkType string, payload []byte)) {
+	for i := len(pngSignature); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if length < 0 || i+12+length > len(data) || i+12+length < i {
+			return
+		}
+		chunkType := string(data[i+4 : i+8])
+		fn(chunkType, data[i+8:i+8+length])
+		if chunkType == "IEND" {
+			return
+		}
+		i += 12 + length
+	}
+}
+
+// walkWebP 遍历 WebP（RIFF）的所有数据块
+func walkWebP(data []byte, fn func(fourCC string, payload []byte)) {
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if size < 0 || i+8+size > len(data) || i+8+size < i {
+			return
+		}
+		fn(string(data[i:i+4]), data[i+8:i+8+size])
+		i += 8 + size + size&1
+	}
+}
+
+// isPNGXMP iTXt 块是否为 XMP 数据
+func isPNGXMP(payload []byte) bool {
+	keyword, _, ok := bytes.Cut(payload, []byte{0})
+	return ok && bytes.Equal(keyword, pngXMPKeyword)
+}
+
+// pngXMP 解析 iTXt 块中的 XMP 数据
+// iTXt 格式：关键字 NUL 压缩标志 压缩方法 语言 NUL 翻译关键字 NUL 文本
+func pngXMP(payload []byte) []byte {
+	keyword, rest, ok := bytes.Cut(payload, []byte{0})
+	if !ok || !bytes.Equal(keyword, pngXMPKeyword) || len(rest) < 2 {
+		return nil
+	}
+	compressed := rest[0] == 1
+	_, rest, ok = bytes.Cut(rest[2:], []byte{0})
+	if !ok {
+		return nil
+	}
+	_, text, ok := bytes.Cut(rest, []byte{0})
+	if !ok {
+		return nil
+	}
+	if !compressed {
+		return text
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(text))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	xmp, err := io.ReadAll(io.LimitReader(reader, maxXMPSize))
+	if err != nil {
+		return nil
+	}
+	return xmp
+}
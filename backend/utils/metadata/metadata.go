@@ -0,0 +1,309 @@
+// Package metadata 解析图片中的 EXIF、XMP 元数据，删除敏感信息，并按 Orientation 标签旋转图片
+// 支持 JPEG、PNG、WebP 和 TIFF 格式
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// GPS 拍摄位置
+type GPS struct {
+	Latitude  float64  // 纬度，南纬为负
+	Longitude float64  // 经度，西经为负
+	Altitude  *float64 // 海拔（米）
+}
+
+// Metadata 图片元数据
+type Metadata struct {
+	Make            string
+	Model           string
+	LensMake        string
+	LensModel       string
+	Software        string
+	Artist          string
+	Copyright       string
+	ExposureTime    string  // 曝光时间，如 1/125
+	FNumber         float64 // 光圈值
+	ISO             int
+	FocalLength     float64 // 焦距（毫米）
+	FocalLength35mm int     // 等效 35mm 焦距
+	ExposureBias    float64 // 曝光补偿（EV）
+	Flash           bool    // 是否闪光
+	TakenAt         *time.Time
+	Orientation     int // 1-8，0 表示未记录
+	GPS             *GPS
+
+	// XMP 信息
+	HasXMP      bool
+	Title       string
+	Description string
+	Keywords    []string
+	Rating      int
+
+	// Sensitive 包含 GPS 位置、设备序列号、厂商私有数据或 XMP/IPTC 等需要在输出时删除的信息
+	Sensitive bool
+}
+
+// exifDateLayout EXIF 日期格式
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// Extract 解析图片中的元数据，没有元数据时返回空的 Metadata
+func Extract(data []byte) *Metadata {
+	raw := findMetadata(data)
+	m := &Metadata{Sensitive: raw.photoshop}
+
+	if raw.exif != nil {
+		m.parseEXIF(raw.exif)
+	}
+	if raw.xmp == nil && raw.exif != nil {
+		raw.xmp = tiffXMP(raw.exif)
+	}
+	if raw.xmp != nil {
+		m.HasXMP = true
+		m.Sensitive = true
+		m.applyXMP(parseXMP(raw.xmp))
+	}
+	if m.GPS != nil {
+		m.Sensitive = true
+	}
+	return m
+}
+
+// Orientation 读取图片的 Orientation 标签，没有时返回 1
+func Orientation(data []byte) int {
+	raw := findMetadata(data)
+	if raw.exif == nil {
+		return 1
+	}
+
+	r, offset, err := newTIFFReader(raw.exif)
+	if err != nil {
+		return 1
+	}
+	entries, _, err := r.readIFD(offset)
+	if err != nil {
+		return 1
+	}
+	for _, e := range entries {
+		if e.tag == tagOrientation {
+			if v, ok := r.uint(e); ok && v >= 1 && v <= 8 {
+				return int(v)
+			}
+		}
+	}
+	return 1
+}
+
+// parseEXIF 解析 TIFF 结构的 EXIF 数据（IFD0、Exif IFD 和 GPS IFD）
+func (m *Metadata) parseEXIF(exif []byte) {
+	r, offset, err := newTIFFReader(exif)
+	if err != nil {
+		return
+	}
+	ifd0, _, err := r.readIFD(offset)
+	if err != nil {
+		return
+	}
+
+	for _, e := range ifd0 {
+		if sensitiveIFD0Tags[e.tag] {
+			m.Sensitive = true
+		}
+		switch e.tag {
+		case tagMake:
+			m.Make = r.ascii(e)
+		case tagModel:
+			m.Model = r.ascii(e)
+		case tagSoftware:
+			m.Software = r.ascii(e)
+		case tagArtist:
+			m.Artist = r.ascii(e)
+		case tagCopyright:
+			m.Copyright = r.ascii(e)
+		case tagImageDescription:
+			m.Description = r.ascii(e)
+		case tagOrientation:
+			if v, ok := r.uint(e); ok && v >= 1 && v <= 8 {
+				m.Orientation = int(v)
+			}
+		}
+	}
+
+	exifIFD, _ := r.subIFD(ifd0, tagExifIFD)
+	var dateTaken, offsetTaken string
+	for _, e := range exifIFD {
+		if sensitiveExifTags[e.tag] {
+			m.Sensitive = true
+		}
+		switch e.tag {
+		case tagExposureTime:
+			if q := r.rationals(e); len(q) > 0 {
+				m.ExposureTime = formatExposure(q[0])
+			}
+		case tagFNumber:
+			if q := r.rationals(e); len(q) > 0 {
+				m.FNumber = round(q[0].float(), 1)
+			}
+		case tagISO:
+			if v, ok := r.uint(e); ok {
+				m.ISO = int(v)
+			}
+		case tagDateTimeOriginal:
+			dateTaken = r.ascii(e)
+		case tagOffsetTimeOriginal:
+			offsetTaken = r.ascii(e)
+		case tagExposureBias:
+			if q := r.rationals(e); len(q) > 0 {
+				m.ExposureBias = round(q[0].float(), 2)
+			}
+		case tagFlash:
+			if v, ok := r.uint(e); ok {
+				m.Flash = v&1 == 1
+			}
+		case tagFocalLength:
+			if q := r.rationals(e); len(q) > 0 {
+				m.FocalLength = round(q[0].float(), 1)
+			}
+		case tagFocalLength35mm:
+			if v, ok := r.uint(e); ok {
+				m.FocalLength35mm = int(v)
+			}
+		case tagLensMake:
+			m.LensMake = r.ascii(e)
+		case tagLensModel:
+			m.LensModel = r.ascii(e)
+		}
+	}
+	m.TakenAt = parseEXIFDate(dateTaken, offsetTaken)
+
+	if gpsIFD, _ := r.subIFD(ifd0, tagGPSIFD); gpsIFD != nil {
+		m.GPS = parseGPS(r, gpsIFD)
+	}
+}
+
+// parseGPS 解析 GPS IFD 中的经纬度和海拔
+func parseGPS(r *tiffReader, entries []ifdEntry) *GPS {
+	var lat, lon, alt []rational
+	var latRef, lonRef string
+	var altRef uint32
+	for _, e := range entries {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = r.ascii(e)
+		case tagGPSLatitude:
+			lat = r.rationals(e)
+		case tagGPSLongitudeRef:
+			lonRef = r.ascii(e)
+		case tagGPSLongitude:
+			lon = r.rationals(e)
+		case tagGPSAltitudeRef:
+			altRef, _ = r.uint(e)
+		case tagGPSAltitude:
+			alt = r.rationals(e)
+		}
+	}
+	if len(lat) < 3 || len(lon) < 3 {
+		return nil
+	}
+
+	gps := &GPS{
+		Latitude:  dms(lat, latRef == "S"),
+		Longitude: dms(lon, lonRef == "W"),
+	}
+	if math.Abs(gps.Latitude) > 90 || math.Abs(gps.Longitude) > 180 {
+		return nil
+	}
+	if len(alt) > 0 {
+		altitude := round(alt[0].float(), 1)
+		if altRef == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude = &altitude
+	}
+	return gps
+}
+
+// tiffXMP TIFF 文件中 IFD0 的 XMP 标签
+func tiffXMP(exif []byte) []byte {
+	r, offset, err := newTIFFReader(exif)
+	if err != nil {
+		return nil
+	}
+	entries, _, err := r.readIFD(offset)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.tag == tagXMP {
+			if v, ok := r.value(e); ok {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// dms 度分秒转换为十进制度数
+func dms(values []rational, negative bool) float64 {
+	v := values[0].float() + values[1].float()/60 + values[2].float()/3600
+	if negative {
+		v = -v
+	}
+	return round(v, 7)
+}
+
+// formatExposure 曝光时间格式化为 1/125 或 2.5 这样的形式
+func formatExposure(q rational) string {
+	if q.num <= 0 || q.den <= 0 {
+		return ""
+	}
+	if q.num < q.den {
+		return fmt.Sprintf("1/%d", int64(math.Round(float64(q.den)/float64(q.num))))
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", q.float()), "0"), ".")
+}
+
+// parseEXIFDate 解析拍摄时间，有时区偏移（OffsetTimeOriginal）时使用该时区，否则按服务器时区处理
+func parseEXIFDate(value, offset string) *time.Time {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return nil
+	}
+	loc := time.Local
+	if offset != "" {
+		if t, err := time.Parse("-07:00", offset); err == nil {
+			loc = t.Location()
+		}
+	}
+	t, err := time.ParseInLocation(exifDateLayout, value, loc)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// parseXMPDate 解析 XMP 日期（ISO 8601，可以省略时间或时区）
+func parseXMPDate(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+func round(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
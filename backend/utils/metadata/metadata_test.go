@@ -0,0 +1,361 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+)
+
+// testTag 构造测试用 EXIF 的标签
+type testTag struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiTag(order binary.ByteOrder, tag uint16, s string) testTag {
+	return testTag{tag, typeASCII, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func shortTag(order binary.ByteOrder, tag uint16, v uint16) testTag {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+	return testTag{tag, typeShort, 1, b}
+}
+
+func rationalTag(order binary.ByteOrder, tag uint16, values ...uint32) testTag {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(b[4*i:], v)
+	}
+	return testTag{tag, typeRational, uint32(len(values) / 2), b}
+}
+
+// buildTIFF 构造包含 IFD0、Exif IFD 和 GPS IFD 的 EXIF 数据
+func buildTIFF(order binary.ByteOrder, ifd0, exif, gps []testTag) []byte {
+	ifdSize := func(n int) int { return 2 + 12*n + 4 }
+
+	ifd0Count := len(ifd0)
+	if exif != nil {
+		ifd0Count++
+	}
+	if gps != nil {
+		ifd0Count++
+	}
+	exifOffset := 8 + ifdSize(ifd0Count)
+	gpsOffset := exifOffset
+	if exif != nil {
+		gpsOffset += ifdSize(len(exif))
+	}
+	dataOffset := gpsOffset
+	if gps != nil {
+		dataOffset += ifdSize(len(gps))
+	}
+
+	buf := make([]byte, dataOffset)
+	if order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], 8)
+
+	long := func(v uint32) []byte {
+		b := make([]byte, 4)
+		order.PutUint32(b, v)
+		return b
+	}
+	writeIFD := func(offset int, tags []testTag) {
+		order.PutUint16(buf[offset:], uint16(len(tags)))
+		for i, t := range tags {
+			pos := offset + 2 + 12*i
+			order.PutUint16(buf[pos:], t.tag)
+			order.PutUint16(buf[pos+2:], t.typ)
+			order.PutUint32(buf[pos+4:], t.count)
+			if len(t.value) <= 4 {
+				copy(buf[pos+8:], t.value)
+				continue
+			}
+			order.PutUint32(buf[pos+8:], uint32(len(buf)))
+			buf = append(buf, t.value...)
+			if len(buf)%2 == 1 {
+				buf = append(buf, 0)
+			}
+		}
+	}
+
+	if exif != nil {
+		ifd0 = append(ifd0, testTag{tagExifIFD, typeLong, 1, long(uint32(exifOffset))})
+	}
+	if gps != nil {
+		ifd0 = append(ifd0, testTag{tagGPSIFD, typeLong, 1, long(uint32(gpsOffset))})
+	}
+	writeIFD(8, ifd0)
+	if exif != nil {
+		writeIFD(exifOffset, exif)
+	}
+	if gps != nil {
+		writeIFD(gpsOffset, gps)
+	}
+	return buf
+}
+
+// sampleEXIF 包含相机信息、拍摄参数、序列号和 GPS 位置的 EXIF
+func sampleEXIF(order binary.ByteOrder) []byte {
+	return buildTIFF(order,
+		[]testTag{
+			asciiTag(order, tagMake, "Canon"),
+			asciiTag(order, tagModel, "Canon EOS R5"),
+			shortTag(order, tagOrientation, 6),
+		},
+		[]testTag{
+			rationalTag(order, tagExposureTime, 1, 125),
+			rationalTag(order, tagFNumber, 28, 10),
+			shortTag(order, tagISO, 200),
+			asciiTag(order, tagDateTimeOriginal, "2024:05:01 10:11:12"),
+			asciiTag(order, tagOffsetTimeOriginal, "+08:00"),
+			rationalTag(order, tagFocalLength, 50, 1),
+			asciiTag(order, tagBodySerialNumber, "SN-123456789"),
+			asciiTag(order, tagLensModel, "RF50mm F1.2 L USM"),
+		},
+		[]testTag{
+			asciiTag(order, tagGPSLatitudeRef, "N"),
+			rationalTag(order, tagGPSLatitude, 31, 1, 14, 1, 0, 1),
+			asciiTag(order, tagGPSLongitudeRef, "E"),
+			rationalTag(order, tagGPSLongitude, 121, 1, 28, 1, 12, 1),
+			rationalTag(order, tagGPSAltitude, 105, 10),
+		},
+	)
+}
+
+const sampleXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmp:Rating="4"
+    exif:GPSLatitude="31,14.5N"
+    exif:GPSLongitude="121,28.2E">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">外滩夜景</rdf:li></rdf:Alt></dc:title>
+   <dc:subject><rdf:Bag><rdf:li>上海</rdf:li><rdf:li>night</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+// testJPEG 编码一张 JPEG 并在 SOI 之后插入 APP1 段
+func testJPEG(t *testing.T, width, height int, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	out := append([]byte{}, encoded[:2]...)
+	for _, payload := range segments {
+		header := []byte{0xFF, 0xE1, 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+		out = append(out, header...)
+		out = append(out, payload...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return append([]byte("Exif\x00\x00"), tiff...)
+}
+
+func xmpSegment(xmp string) []byte {
+	return append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmp...)
+}
+
+func TestExtractEXIF(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		data := testJPEG(t, 8, 8, exifSegment(sampleEXIF(order)))
+		m := Extract(data)
+
+		if m.Make != "Canon" || m.Model != "Canon EOS R5" {
+			t.Errorf("%v: 相机 = %q %q", order, m.Make, m.Model)
+		}
+		if m.LensModel != "RF50mm F1.2 L USM" {
+			t.Errorf("%v: 镜头 = %q", order, m.LensModel)
+		}
+		if m.Orientation != 6 {
+			t.Errorf("%v: Orientation = %d, 期望 6", order, m.Orientation)
+		}
+		if m.ExposureTime != "1/125" || m.FNumber != 2.8 || m.ISO != 200 || m.FocalLength != 50 {
+			t.Errorf("%v: 曝光参数 = %s f/%v ISO%d %vmm", order, m.ExposureTime, m.FNumber, m.ISO, m.FocalLength)
+		}
+
+		want := time.Date(2024, 5, 1, 2, 11, 12, 0, time.UTC)
+		if m.TakenAt == nil || !m.TakenAt.Equal(want) {
+			t.Errorf("%v: 拍摄时间 = %v, 期望 %v", order, m.TakenAt, want)
+		}
+
+		if m.GPS == nil {
+			t.Fatalf("%v: 没有解析到 GPS", order)
+		}
+		if math.Abs(m.GPS.Latitude-31.2333333) > 1e-6 || math.Abs(m.GPS.Longitude-121.47) > 1e-6 {
+			t.Errorf("%v: GPS = %v, %v", order, m.GPS.Latitude, m.GPS.Longitude)
+		}
+		if m.GPS.Altitude == nil || *m.GPS.Altitude != 10.5 {
+			t.Errorf("%v: 海拔 = %v", order, m.GPS.Altitude)
+		}
+		if !m.Sensitive {
+			t.Errorf("%v: 包含 GPS 和序列号时应标记为敏感", order)
+		}
+	}
+}
+
+func TestExtractXMP(t *testing.T) {
+	m := Extract(testJPEG(t, 8, 8, xmpSegment(sampleXMP)))
+
+	if !m.HasXMP || !m.Sensitive {
+		t.Errorf("HasXMP = %v, Sensitive = %v", m.HasXMP, m.Sensitive)
+	}
+	if m.Title != "外滩夜景" {
+		t.Errorf("标题 = %q", m.Title)
+	}
+	if len(m.Keywords) != 2 || m.Keywords[0] != "上海" || m.Keywords[1] != "night" {
+		t.Errorf("关键词 = %v", m.Keywords)
+	}
+	if m.Rating != 4 {
+		t.Errorf("评分 = %d", m.Rating)
+	}
+	if m.GPS == nil || math.Abs(m.GPS.Latitude-(31+14.5/60)) > 1e-9 || math.Abs(m.GPS.Longitude-(121+28.2/60)) > 1e-9 {
+		t.Errorf("XMP GPS = %+v", m.GPS)
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	data := testJPEG(t, 8, 8, exifSegment(sampleEXIF(binary.BigEndian)), xmpSegment(sampleXMP))
+
+	stripped, changed := Strip(data)
+	if !changed {
+		t.Fatal("包含敏感信息时应返回修改后的内容")
+	}
+	if bytes.Contains(stripped, []byte("SN-123456789")) || bytes.Contains(stripped, []byte("xmpmeta")) {
+		t.Error("序列号或 XMP 没有被删除")
+	}
+
+	m := Extract(stripped)
+	if m.GPS != nil || m.HasXMP || m.Sensitive {
+		t.Errorf("删除后仍有敏感信息: GPS=%v XMP=%v Sensitive=%v", m.GPS, m.HasXMP, m.Sensitive)
+	}
+	if m.Make != "Canon" || m.Orientation != 6 || m.ISO != 200 || m.LensModel != "RF50mm F1.2 L USM" {
+		t.Errorf("非敏感信息应该保留: %+v", m)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("删除后的图片无法解码: %v", err)
+	}
+
+	// 再次处理没有变化
+	if _, changed := Strip(stripped); changed {
+		t.Error("已经清理过的图片不应再修改")
+	}
+}
+
+func TestStripWithoutSensitiveData(t *testing.T) {
+	order := binary.LittleEndian
+	data := testJPEG(t, 8, 8, exifSegment(buildTIFF(order,
+		[]testTag{asciiTag(order, tagMake, "Apple"), shortTag(order, tagOrientation, 3)}, nil, nil)))
+
+	if Extract(data).Sensitive {
+		t.Error("没有敏感信息时不应标记为敏感")
+	}
+	out, changed := Strip(data)
+	if changed || !bytes.Equal(out, data) {
+		t.Error("没有敏感信息时应原样返回")
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// 在 IHDR 之后插入 eXIf 块
+	exif := sampleEXIF(binary.LittleEndian)
+	chunk := make([]byte, 8, 12+len(exif))
+	binary.BigEndian.PutUint32(chunk, uint32(len(exif)))
+	copy(chunk[4:], "eXIf")
+	chunk = append(chunk, exif...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	ihdrEnd := 8 + 12 + 13
+	data := append(append(append([]byte{}, encoded[:ihdrEnd]...), chunk...), encoded[ihdrEnd:]...)
+
+	if m := Extract(data); m.GPS == nil || m.Orientation != 6 {
+		t.Fatalf("PNG eXIf 解析失败: %+v", m)
+	}
+
+	stripped, changed := Strip(data)
+	if !changed {
+		t.Fatal("包含 GPS 时应返回修改后的内容")
+	}
+	if m := Extract(stripped); m.GPS != nil || m.Orientation != 6 {
+		t.Errorf("删除后 GPS = %v, Orientation = %d", m.GPS, m.Orientation)
+	}
+	// png 解码器会校验 CRC
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("删除后的图片无法解码: %v", err)
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	data := testJPEG(t, 8, 8, exifSegment(sampleEXIF(binary.LittleEndian)), xmpSegment(sampleXMP))
+
+	// 截断在任意位置都不应 panic
+	for n := 0; n <= len(data); n += 7 {
+		Extract(data[:n])
+		Strip(data[:n])
+		Orientation(data[:n])
+	}
+
+	// 偏移指向文件之外
+	bad := sampleEXIF(binary.LittleEndian)
+	binary.LittleEndian.PutUint32(bad[4:], 0xFFFFFF)
+	if m := Extract(testJPEG(t, 8, 8, exifSegment(bad))); m.Make != "" {
+		t.Errorf("无效偏移不应解析出数据: %+v", m)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 4x2 的图片，左下角为红色
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	red := color.NRGBA{255, 0, 0, 255}
+	img.Set(0, 1, red)
+
+	// Orientation 6 需要顺时针旋转 90 度，左下角转到左上角
+	rotated := ApplyOrientation(img, 6)
+	if b := rotated.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Fatalf("旋转后尺寸 = %dx%d, 期望 2x4", b.Dx(), b.Dy())
+	}
+	if rotated.At(0, 0) != red {
+		t.Errorf("旋转后左上角 = %v, 期望红色", rotated.At(0, 0))
+	}
+
+	if w, h := OrientedSize(4, 2, 6); w != 2 || h != 4 {
+		t.Errorf("OrientedSize = %dx%d", w, h)
+	}
+	if ApplyOrientation(img, 1) != image.Image(img) {
+		t.Error("Orientation 1 不应修改图片")
+	}
+
+	data := testJPEG(t, 8, 8, exifSegment(sampleEXIF(binary.BigEndian)))
+	if o := Orientation(data); o != 6 {
+		t.Errorf("Orientation = %d, 期望 6", o)
+	}
+}
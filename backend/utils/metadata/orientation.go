@@ -0,0 +1,41 @@
+package metadata
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ApplyOrientation 按 EXIF Orientation 值旋转、翻转图片，使其按正确方向显示
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// AutoOrient 按图片内容中的 Orientation 标签旋转解码后的图片
+func AutoOrient(img image.Image, data []byte) image.Image {
+	return ApplyOrientation(img, Orientation(data))
+}
+
+// OrientedSize 旋转后的显示尺寸，Orientation 为 5-8 时宽高互换
+func OrientedSize(width, height, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return height, width
+	}
+	return width, height
+}
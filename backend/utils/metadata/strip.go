@@ -0,0 +1,231 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// WebP VP8X 块中的元数据标志位
+const (
+	vp8xXMPFlag  = 0x04
+	vp8xEXIFFlag = 0x08
+)
+
+// Strip 删除图片中的 GPS 位置、设备序列号、厂商私有数据以及 XMP/IPTC 数据
+// 方向、相机型号、曝光参数等其他 EXIF 信息保留，图像数据不会重新编码
+// 返回处理后的内容和是否有修改，不支持的格式原样返回
+func Strip(data []byte) ([]byte, bool) {
+	switch detectFormat(data) {
+	case formatJPEG:
+		return stripJPEG(data)
+	case formatPNG:
+		return stripPNG(data)
+	case formatWebP:
+		return stripWebP(data)
+	case formatTIFF:
+		out := bytes.Clone(data)
+		changed, err := sanitizeTIFF(out)
+		if err != nil || !changed {
+			return data, false
+		}
+		return out, true
+	}
+	return data, false
+}
+
+// sanitizeTIFF 原地删除 TIFF 结构中的敏感标签
+func sanitizeTIFF(data []byte) (bool, error) {
+	r, offset, err := newTIFFReader(data)
+	if err != nil {
+		return false, err
+	}
+
+	// 先找到 Exif IFD，IFD0 中的条目移动后偏移仍然不变
+	ifd0, _, err := r.readIFD(offset)
+	if err != nil {
+		return false, err
+	}
+	_, exifOffset := r.subIFD(ifd0, tagExifIFD)
+
+	changed, err := r.removeTags(offset, sensitiveIFD0Tags)
+	if err != nil {
+		return false, err
+	}
+	if exifOffset != 0 {
+		exifChanged, err := r.removeTags(exifOffset, sensitiveExifTags)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || exifChanged
+	}
+	return changed, nil
+}
+
+// stripJPEG 清理 EXIF 段中的敏感标签，删除 XMP 和 Photoshop（IPTC）段
+// EXIF 段无法解析时整段删除
+func stripJPEG(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	changed := false
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			out = append(out, 0xFF)
+			i++
+			continue
+		}
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+		i += 2 + length
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, jpegExifPrefix):
+			cleaned := bytes.Clone(segment)
+			exifChanged, err := sanitizeTIFF(cleaned[4+len(jpegExifPrefix):])
+			if err != nil {
+				changed = true
+				continue
+			}
+			if exifChanged {
+				changed = true
+				segment = cleaned
+			}
+		case marker == 0xE1 && (bytes.HasPrefix(payload, jpegXMPPrefix) || bytes.HasPrefix(payload, jpegExtendedXMPPrefix)),
+			marker == 0xED && bytes.HasPrefix(payload, jpegPhotoshopPrefix):
+			changed = true
+			continue
+		}
+		out = append(out, segment...)
+	}
+
+	if !changed {
+		return data, false
+	}
+	// 图像数据原样保留
+	return append(out, data[i:]...), true
+}
+
+// stripPNG 清理 eXIf 块中的敏感标签，删除 XMP 块
+func stripPNG(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	changed := false
+
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		if length < 0 || i+12+length > len(data) || i+12+length < i {
+			break
+		}
+		chunk := data[i : i+12+length]
+		chunkType := string(chunk[4:8])
+		payload := chunk[8 : 8+length]
+		i += 12 + length
+
+		switch chunkType {
+		case "eXIf":
+			cleaned := bytes.Clone(chunk)
+			exifChanged, err := sanitizeTIFF(cleaned[8 : 8+length])
+			if err != nil {
+				changed = true
+				continue
+			}
+			if exifChanged {
+				binary.BigEndian.PutUint32(cleaned[8+length:], crc32.ChecksumIEEE(cleaned[4:8+length]))
+				changed = true
+				chunk = cleaned
+			}
+		case "iTXt":
+			if isPNGXMP(payload) {
+				changed = true
+				continue
+			}
+		}
+		out = append(out, chunk...)
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	if !changed {
+		return data, false
+	}
+	return append(out, data[i:]...), true
+}
+
+// stripWebP 清理 EXIF 块中的敏感标签，删除 XMP 块并更新 VP8X 标志和 RIFF 大小
+func stripWebP(data []byte) ([]byte, bool) {
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	changed := false
+	vp8x := -1 // VP8X 块数据在输出中的位置
+	var removedFlags byte
+
+	i := 12
+	for i+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if size < 0 || i+8+size > len(data) || i+8+size < i {
+			break
+		}
+		end := i + 8 + size + size&1
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[i:end]
+		fourCC := string(chunk[:4])
+		i = end
+
+		switch fourCC {
+		case "VP8X":
+			vp8x = len(out) + 8
+		case "EXIF":
+			cleaned := bytes.Clone(chunk)
+			tiff := cleaned[8 : 8+size]
+			tiff = tiff[len(tiff)-len(bytes.TrimPrefix(tiff, jpegExifPrefix)):]
+			exifChanged, err := sanitizeTIFF(tiff)
+			if err != nil {
+				changed = true
+				removedFlags |= vp8xEXIFFlag
+				continue
+			}
+			if exifChanged {
+				changed = true
+				chunk = cleaned
+			}
+		case "XMP ":
+			changed = true
+			removedFlags |= vp8xXMPFlag
+			continue
+		}
+		out = append(out, chunk...)
+	}
+
+	if !changed {
+		return data, false
+	}
+	out = append(out, data[i:]...)
+	if vp8x >= 0 && vp8x < len(out) {
+		out[vp8x] &^= removedFlags
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, true
+}
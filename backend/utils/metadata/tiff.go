@@ -0,0 +1,344 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+// errInvalidTIFF EXIF 数据不是有效的 TIFF 结构
+var errInvalidTIFF = errors.New("无效的 EXIF 数据")
+
+// maxIFDEntries 单个 IFD 允许的最大条目数，防止畸形数据导致大量分配
+const maxIFDEntries = 1000
+
+// TIFF 字段类型
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+// typeSizes 各字段类型单个值的字节数
+var typeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// 常用标签
+const (
+	tagImageDescription = 0x010E
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagArtist           = 0x013B
+	tagHostComputer     = 0x013C
+	tagXMP              = 0x02BC
+	tagCopyright        = 0x8298
+	tagIPTC             = 0x83BB
+	tagPhotoshop        = 0x8649
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+
+	tagExposureTime       = 0x829A
+	tagFNumber            = 0x829D
+	tagISO                = 0x8827
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagExposureBias       = 0x9204
+	tagFlash              = 0x9209
+	tagFocalLength        = 0x920A
+	tagMakerNote          = 0x927C
+	tagImageUniqueID      = 0xA420
+	tagFocalLength35mm    = 0xA405
+	tagCameraOwnerName    = 0xA430
+	tagBodySerialNumber   = 0xA431
+	tagLensMake           = 0xA433
+	tagLensModel          = 0xA434
+	tagLensSerialNumber   = 0xA435
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+// sensitiveIFD0Tags IFD0 中的敏感标签：位置、设备信息以及内嵌的 XMP/IPTC 数据
+var sensitiveIFD0Tags = map[uint16]bool{
+	tagGPSIFD:       true,
+	tagHostComputer: true,
+	tagXMP:          true,
+	tagIPTC:         true,
+	tagPhotoshop:    true,
+}
+
+// sensitiveExifTags Exif IFD 中可以识别设备或拍摄者的标签
+var sensitiveExifTags = map[uint16]bool{
+	tagMakerNote:        true,
+	tagImageUniqueID:    true,
+	tagCameraOwnerName:  true,
+	tagBodySerialNumber: true,
+	tagLensSerialNumber: true,
+}
+
+// tiffReader 读取 TIFF 结构（EXIF 数据使用 TIFF 格式）
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry IFD 中的一个条目，pos 为条目在数据中的位置
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	pos   int
+}
+
+// newTIFFReader 解析 TIFF 头，返回第一个 IFD 的偏移
+func newTIFFReader(data []byte) (*tiffReader, uint32, error) {
+	if len(data) < 8 {
+		return nil, 0, errInvalidTIFF
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, errInvalidTIFF
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return nil, 0, errInvalidTIFF
+	}
+
+	return &tiffReader{data: data, order: order}, order.Uint32(data[4:]), nil
+}
+
+// readIFD 读取 IFD 的全部条目和下一个 IFD 的偏移
+func (r *tiffReader) readIFD(offset uint32) ([]ifdEntry, uint32, error) {
+	start := int(offset)
+	if offset == 0 || start < 0 || start+2 > len(r.data) {
+		return nil, 0, errInvalidTIFF
+	}
+
+	n := int(r.order.Uint16(r.data[start:]))
+	if n > maxIFDEntries || start+2+12*n > len(r.data) {
+		return nil, 0, errInvalidTIFF
+	}
+
+	entries := make([]ifdEntry, 0, n)
+	for i := 0; i < n; i++ {
+		pos := start + 2 + 12*i
+		entries = append(entries, ifdEntry{
+			tag:   r.order.Uint16(r.data[pos:]),
+			typ:   r.order.Uint16(r.data[pos+2:]),
+			count: r.order.Uint32(r.data[pos+4:]),
+			pos:   pos,
+		})
+	}
+
+	// 部分文件省略了最后的下一个 IFD 偏移
+	var next uint32
+	if end := start + 2 + 12*n; end+4 <= len(r.data) {
+		next = r.order.Uint32(r.data[end:])
+	}
+	return entries, next, nil
+}
+
+// valueSize 条目值的总字节数
+func (e ifdEntry) valueSize() (int, bool) {
+	size, ok := typeSizes[e.typ]
+	if !ok || uint64(e.count)*uint64(size) > math.MaxInt32 {
+		return 0, false
+	}
+	return int(e.count) * size, true
+}
+
+// value 条目的原始值，不超过 4 字节的值直接存放在条目中
+func (r *tiffReader) value(e ifdEntry) ([]byte, bool) {
+	size, ok := e.valueSize()
+	if !ok {
+		return nil, false
+	}
+	if size <= 4 {
+		return r.data[e.pos+8 : e.pos+8+size], true
+	}
+
+	offset := int(r.order.Uint32(r.data[e.pos+8:]))
+	if offset < 0 || offset+size > len(r.data) || offset+size < offset {
+		return nil, false
+	}
+	return r.data[offset : offset+size], true
+}
+
+// ascii 读取字符串值，去掉结尾的 NUL 和空白
+func (r *tiffReader) ascii(e ifdEntry) string {
+	if e.typ != typeASCII && e.typ != typeUndefined && e.typ != typeByte {
+		return ""
+	}
+	raw, ok := r.value(e)
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexByte(string(raw), 0); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(raw), ""))
+}
+
+// uint 读取第一个整数值
+func (r *tiffReader) uint(e ifdEntry) (uint32, bool) {
+	raw, ok := r.value(e)
+	if !ok || len(raw) == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case typeByte, typeUndefined:
+		return uint32(raw[0]), true
+	case typeShort:
+		return uint32(r.order.Uint16(raw)), true
+	case typeLong, typeSLong:
+		return r.order.Uint32(raw), true
+	}
+	return 0, false
+}
+
+// rational 分数值
+type rational struct {
+	num, den int64
+}
+
+func (q rational) float() float64 {
+	if q.den == 0 {
+		return 0
+	}
+	return float64(q.num) / float64(q.den)
+}
+
+// rationals 读取分数数组
+func (r *tiffReader) rationals(e ifdEntry) []rational {
+	if e.typ != typeRational && e.typ != typeSRational {
+		return nil
+	}
+	raw, ok := r.value(e)
+	if !ok {
+		return nil
+	}
+
+	values := make([]rational, 0, e.count)
+	for i := 0; i+8 <= len(raw); i += 8 {
+		if e.typ == typeSRational {
+			values = append(values, rational{
+				num: int64(int32(r.order.Uint32(raw[i:]))),
+				den: int64(int32(r.order.Uint32(raw[i+4:]))),
+			})
+		} else {
+			values = append(values, rational{
+				num: int64(r.order.Uint32(raw[i:])),
+				den: int64(r.order.Uint32(raw[i+4:])),
+			})
+		}
+	}
+	return values
+}
+
+// subIFD 读取指向子 IFD（Exif、GPS）的条目
+func (r *tiffReader) subIFD(entries []ifdEntry, tag uint16) ([]ifdEntry, uint32) {
+	for _, e := range entries {
+		if e.tag != tag {
+			continue
+		}
+		offset, ok := r.uint(e)
+		if !ok {
+			return nil, 0
+		}
+		sub, _, err := r.readIFD(offset)
+		if err != nil {
+			return nil, 0
+		}
+		return sub, offset
+	}
+	return nil, 0
+}
+
+// removeTags 从 IFD 中删除指定标签（原地修改），被删除的值和 GPS 子 IFD 会被清零
+// 删除条目后后面的条目前移，IFD 占用的空间不变，因此不影响其他数据的偏移
+func (r *tiffReader) removeTags(offset uint32, tags map[uint16]bool) (bool, error) {
+	entries, next, err := r.readIFD(offset)
+	if err != nil {
+		return false, err
+	}
+
+	kept := make([]ifdEntry, 0, len(entries))
+	for _, e := range entries {
+		if !tags[e.tag] {
+			kept = append(kept, e)
+			continue
+		}
+		if e.tag == tagGPSIFD {
+			if gps, ok := r.uint(e); ok {
+				r.zeroIFD(gps)
+			}
+		}
+		r.zeroValue(e)
+	}
+	if len(kept) == len(entries) {
+		return false, nil
+	}
+
+	start := int(offset)
+	r.order.PutUint16(r.data[start:], uint16(len(kept)))
+	for i, e := range kept {
+		copy(r.data[start+2+12*i:start+14+12*i], r.data[e.pos:e.pos+12])
+	}
+
+	end := start + 2 + 12*len(entries)
+	pos := start + 2 + 12*len(kept)
+	if end+4 <= len(r.data) {
+		r.order.PutUint32(r.data[pos:], next)
+		pos += 4
+		end += 4
+	}
+	clear(r.data[pos:end])
+	return true, nil
+}
+
+// zeroValue 清零条目存放在 IFD 之外的值
+func (r *tiffReader) zeroValue(e ifdEntry) {
+	size, ok := e.valueSize()
+	if !ok || size <= 4 {
+		return
+	}
+	if raw, ok := r.value(e); ok {
+		clear(raw)
+	}
+}
+
+// zeroIFD 清零整个 IFD 及其条目的值
+func (r *tiffReader) zeroIFD(offset uint32) {
+	entries, _, err := r.readIFD(offset)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		r.zeroValue(e)
+	}
+
+	start := int(offset)
+	end := start + 2 + 12*len(entries) + 4
+	if end > len(r.data) {
+		end = len(r.data)
+	}
+	clear(r.data[start:end])
+}
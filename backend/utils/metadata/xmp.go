@@ -0,0 +1,179 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// XMP 命名空间
+const (
+	nsRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC        = "http://purl.org/dc/elements/1.1/"
+	nsXMP       = "http://ns.adobe.com/xap/1.0/"
+	nsPhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	nsEXIF      = "http://ns.adobe.com/exif/1.0/"
+	nsAux       = "http://ns.adobe.com/exif/1.0/aux/"
+	nsExifEX    = "http://cipa.jp/exif/1.0/"
+	nsTIFF      = "http://ns.adobe.com/tiff/1.0/"
+)
+
+// xmpProperties XMP 属性值，键为命名空间加属性名，列表属性（rdf:Seq/Bag/Alt）有多个值
+type xmpProperties map[string][]string
+
+func (p xmpProperties) first(ns, name string) string {
+	if values := p[ns+name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (p xmpProperties) add(key, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		p[key] = append(p[key], value)
+	}
+}
+
+// parseXMP 解析 XMP 数据包中 rdf:Description 的属性
+// 同时支持属性写法（<rdf:Description dc:format="...">）和元素写法（包括 rdf:li 列表）
+func parseXMP(data []byte) xmpProperties {
+	props := xmpProperties{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var stack []xml.Name
+	var current string // 正在读取的属性
+	var depth int      // 属性元素所在的层级
+	var text strings.Builder
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			parentIsDescription := len(stack) > 0 && stack[len(stack)-1] == xml.Name{Space: nsRDF, Local: "Description"}
+			stack = append(stack, t.Name)
+
+			if t.Name.Space == nsRDF && t.Name.Local == "Description" {
+				for _, attr := range t.Attr {
+					if attr.Name.Space != "" && attr.Name.Space != nsRDF && attr.Name.Space != "xmlns" {
+						props.add(attr.Name.Space+attr.Name.Local, attr.Value)
+					}
+				}
+				continue
+			}
+			if current == "" && parentIsDescription {
+				current = t.Name.Space + t.Name.Local
+				depth = len(stack)
+				text.Reset()
+			} else if current != "" && t.Name.Space == nsRDF && t.Name.Local == "li" {
+				text.Reset()
+			}
+		case xml.CharData:
+			if current != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if current != "" {
+				switch {
+				case t.Name.Space == nsRDF && t.Name.Local == "li":
+					props.add(current, text.String())
+					text.Reset()
+				case len(stack) == depth:
+					props.add(current, text.String())
+					current = ""
+				}
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return props
+}
+
+// applyXMP 用 XMP 属性补充 EXIF 中没有的信息
+func (m *Metadata) applyXMP(props xmpProperties) {
+	fill := func(field *string, values ...string) {
+		for _, v := range values {
+			if *field == "" && v != "" {
+				*field = v
+			}
+		}
+	}
+
+	fill(&m.Make, props.first(nsTIFF, "Make"))
+	fill(&m.Model, props.first(nsTIFF, "Model"))
+	fill(&m.LensModel, props.first(nsExifEX, "LensModel"), props.first(nsAux, "Lens"))
+	fill(&m.Title, props.first(nsDC, "title"))
+	fill(&m.Description, props.first(nsDC, "description"))
+	fill(&m.Artist, strings.Join(props[nsDC+"creator"], ", "))
+	fill(&m.Copyright, props.first(nsDC, "rights"))
+	fill(&m.Software, props.first(nsXMP, "CreatorTool"))
+
+	if len(m.Keywords) == 0 {
+		m.Keywords = props[nsDC+"subject"]
+	}
+	if m.Rating == 0 {
+		m.Rating, _ = strconv.Atoi(props.first(nsXMP, "Rating"))
+	}
+	if m.Orientation == 0 {
+		m.Orientation, _ = strconv.Atoi(props.first(nsTIFF, "Orientation"))
+	}
+
+	if m.TakenAt == nil {
+		for _, v := range []string{
+			props.first(nsEXIF, "DateTimeOriginal"),
+			props.first(nsPhotoshop, "DateCreated"),
+			props.first(nsXMP, "CreateDate"),
+		} {
+			if t := parseXMPDate(v); t != nil {
+				m.TakenAt = t
+				break
+			}
+		}
+	}
+
+	if m.GPS == nil {
+		lat, latOK := parseXMPCoordinate(props.first(nsEXIF, "GPSLatitude"))
+		lon, lonOK := parseXMPCoordinate(props.first(nsEXIF, "GPSLongitude"))
+		if latOK && lonOK {
+			m.GPS = &GPS{Latitude: lat, Longitude: lon}
+		}
+	}
+}
+
+// parseXMPCoordinate 解析 XMP 坐标，格式为 "DDD,MM.mmk" 或 "DDD,MM,SSk"，k 为 N/S/E/W
+func parseXMPCoordinate(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return 0, false
+	}
+
+	sign := 1.0
+	switch value[len(value)-1] {
+	case 'S', 's', 'W', 'w':
+		sign = -1
+	case 'N', 'n', 'E', 'e':
+	default:
+		return 0, false
+	}
+
+	parts := strings.Split(value[:len(value)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	result := 0.0
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		result += v / []float64{1, 60, 3600}[i]
+	}
+	return sign * result, true
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrBlockedAddress 远程地址解析到内网、回环、链路本地等非公网地址
+	ErrBlockedAddress = errors.New("不允许访问内网地址")
+	// ErrRemoteTooLarge 远程文件超过大小上限
+	ErrRemoteTooLarge = errors.New("远程文件超过大小上限")
+	// ErrInvalidURL 不是 http 或 https 地址，或者地址中包含用户名和密码
+	ErrInvalidURL = errors.New("只支持不包含用户名和密码的 http 和 https 地址")
+)
+
+// reservedNetworks IsPrivate、IsLoopback 等方法没有覆盖的保留地址段
+var reservedNetworks = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",       // 本网络
+		"100.64.0.0/10",   // 运营商级 NAT
+		"192.0.0.0/24",    // IETF 协议分配
+		"192.0.2.0/24",    // 文档示例
+		"198.18.0.0/15",   // 基准测试
+		"198.51.100.0/24", // 文档示例
+		"203.0.113.0/24",  // 文档示例
+		"240.0.0.0/4",     // 保留及广播
+		"64:ff9b::/96",    // NAT64，可以映射到任意 IPv4 地址
+		"2001:db8::/32",   // 文档示例
+	}
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// IsPublicIP 判断是否为公网地址，内网、回环、链路本地、组播和保留地址返回 false
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoteFile 下载的远程文件
+type RemoteFile struct {
+	Data        []byte
+	ContentType string // 响应头中的类型，不可信，只作参考
+	FileName    string // 最终地址路径中的文件名，可能为空
+	URL         string // 跟随重定向后的最终地址
+}
+
+// RemoteFetcher 下载远程文件，默认只允许访问公网地址
+// 每次建立连接时都检查实际连接的 IP，重定向和 DNS 重新绑定也无法绕过
+type RemoteFetcher struct {
+	MaxSize      int64             // 文件大小上限，下载时边读边检查
+	Timeout      time.Duration     // 整个请求（包括读取响应内容）的超时时间
+	MaxRedirects int               // 最多跟随的重定向次数
+	AllowIP      func(net.IP) bool // 允许连接的地址，为空时使用 IsPublicIP
+}
+
+// NewRemoteFetcher 创建只允许访问公网地址的下载器
+func NewRemoteFetcher(maxSize int64, timeout time.Duration) *RemoteFetcher {
+	return &RemoteFetcher{
+		MaxSize:      maxSize,
+		Timeout:      timeout,
+		MaxRedirects: 5,
+	}
+}
+
+// client 创建在建立连接时检查目标地址的 HTTP 客户端，不使用环境变量中的代理
+func (f *RemoteFetcher) client() *http.Client {
+	allow := f.AllowIP
+	if allow == nil {
+		allow = IsPublicIP
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !allow(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: f.Timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: f.Timeout,
+			DisableKeepAlives:     true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.MaxRedirects {
+				return fmt.Errorf("重定向次数超过 %d 次", f.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("不支持重定向到 %s 地址", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// Fetch 下载远程文件，只支持 http 和 https 地址，地址无效时返回 ErrInvalidURL
+// 目标地址不允许访问时返回 ErrBlockedAddress，超过大小上限时返回 ErrRemoteTooLarge
+func (f *RemoteFetcher) Fetch(ctx context.Context, rawURL string) (*RemoteFile, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" || target.User != nil {
+		return nil, ErrInvalidURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client().Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载失败: 远程服务器返回 HTTP %d", resp.StatusCode)
+	}
+	if f.MaxSize > 0 && resp.ContentLength > f.MaxSize {
+		return nil, ErrRemoteTooLarge
+	}
+
+	reader := io.Reader(resp.Body)
+	if f.MaxSize > 0 {
+		reader = io.LimitReader(resp.Body, f.MaxSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	if f.MaxSize > 0 && int64(len(data)) > f.MaxSize {
+		return nil, ErrRemoteTooLarge
+	}
+
+	fileName := path.Base(resp.Request.URL.Path)
+	if fileName == "/" || fileName == "." {
+		fileName = ""
+	}
+
+	return &RemoteFile{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		FileName:    fileName,
+		URL:         resp.Request.URL.String(),
+	}, nil
+}
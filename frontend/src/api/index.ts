@@ -1,5 +1,7 @@
+import axios from 'axios'
 import request from '@/utils/request'
-import type { Album, Image, Statistics, ApiResponse, PaginatedResponse } from '@/types'
+import { getRefreshToken } from '@/utils/config'
+import type { Album, AlbumMember, AlbumRole, Image, ImageMetadata, SimilarImage, DuplicateReport, DuplicateResolution, ProcessingProfile, ProcessingProfileInput, UploadSession, UploadSessionInput, DirectUpload, PresignedUpload, Tag, Statistics, ApiResponse, PaginatedResponse, SearchParams, SearchResponse } from '@/types'
 
 // ========== 相册相关 API ==========
 
@@ -28,6 +30,31 @@ export const deleteAlbum = (id: number) => {
   return request.delete<ApiResponse>(`/albums/${id}`)
 }
 
+// 获取共享给我的相册
+export const getSharedAlbums = () => {
+  return request.get<ApiResponse<Album[]>>('/albums', { params: { shared: true } })
+}
+
+// 获取相册成员
+export const getAlbumMembers = (id: number) => {
+  return request.get<ApiResponse<AlbumMember[]>>(`/albums/${id}/members`)
+}
+
+// 添加相册成员（已存在时更新角色）
+export const addAlbumMember = (id: number, userId: number, role: AlbumRole = 'viewer') => {
+  return request.post<ApiResponse<AlbumMember>>(`/albums/${id}/members`, { userId, role })
+}
+
+// 修改成员角色
+export const updateAlbumMember = (id: number, userId: number, role: AlbumRole) => {
+  return request.put<ApiResponse<AlbumMember>>(`/albums/${id}/members/${userId}`, { role })
+}
+
+// 移除相册成员
+export const removeAlbumMember = (id: number, userId: number) => {
+  return request.delete<ApiResponse>(`/albums/${id}/members/${userId}`)
+}
+
 // ========== 图片相关 API ==========
 
 // 获取图片列表
@@ -67,6 +94,72 @@ export const batchUpload = (formData: FormData) => {
   })
 }
 
+// 从远程地址导入图片，服务器下载后按普通上传处理
+export const importImageFromURL = (url: string, albumId?: number) => {
+  return request.post<ApiResponse<Image>>('/images/import-url', { url, albumId })
+}
+
+// 批量从远程地址导入图片
+export const batchImportFromURL = (urls: string[], albumId?: number) => {
+  return request.post<ApiResponse<Image[]> & { errors: string[], total: number, success: number }>('/images/import-url', { urls, albumId })
+}
+
+// 创建分片上传会话
+export const initUploadSession = (data: UploadSessionInput) => {
+  return request.post<ApiResponse<UploadSession>>('/uploads', data)
+}
+
+// 查询分片上传会话已收到的分片
+export const getUploadSession = (id: string) => {
+  return request.get<ApiResponse<UploadSession>>(`/uploads/${id}`)
+}
+
+// 上传分片，checksum 为分片内容的 SHA-256（通过查询参数传递，不需要额外的跨域请求头）
+export const uploadChunk = (id: string, index: number, chunk: Blob, checksum: string) => {
+  return request.put<ApiResponse<{ index: number, size: number, received: number, chunkCount: number }>>(`/uploads/${id}/chunks/${index}`, chunk, {
+    params: { checksum },
+    headers: {
+      'Content-Type': 'application/octet-stream'
+    }
+  })
+}
+
+// 合并分片并创建图片
+export const completeUploadSession = (id: string) => {
+  return request.post<ApiResponse<Image>>(`/uploads/${id}/complete`)
+}
+
+// 取消分片上传
+export const abortUploadSession = (id: string) => {
+  return request.delete<ApiResponse>(`/uploads/${id}`)
+}
+
+// 申请直传存储的上传凭证（仅云存储支持）
+export const createDirectUpload = (data: UploadSessionInput) => {
+  return request.post<ApiResponse<DirectUpload>>('/direct-uploads', data)
+}
+
+// 按凭证将文件直接上传到云存储，不经过服务器
+export const uploadToStorage = (upload: PresignedUpload, file: Blob) => {
+  if (upload.method === 'PUT') {
+    return axios.put(upload.url, file, { headers: upload.headers })
+  }
+  const form = new FormData()
+  Object.entries(upload.formData || {}).forEach(([key, value]) => form.append(key, value))
+  form.append(upload.fileField || 'file', file)
+  return axios.post(upload.url, form)
+}
+
+// 确认直传完成并创建图片
+export const completeDirectUpload = (id: string) => {
+  return request.post<ApiResponse<Image>>(`/direct-uploads/${id}/complete`)
+}
+
+// 取消直传
+export const abortDirectUpload = (id: string) => {
+  return request.delete<ApiResponse>(`/direct-uploads/${id}`)
+}
+
 // 删除图片
 export const deleteImage = (id: number) => {
   return request.delete<ApiResponse>(`/images/${id}`)
@@ -106,14 +199,48 @@ export const getImageStats = (id: number) => {
 
 // ========== 标签相关 API ==========
 
-// 获取所有标签
+// 获取我的标签及使用次数
 export const getAllTags = () => {
-  return request.get<ApiResponse<string[]>>('/tags')
+  return request.get<ApiResponse<Tag[]>>('/tags')
+}
+
+// 按标签搜索图片，mode 为 and（包含全部标签）或 or（包含任一标签），exclude 为需要排除的标签
+export const searchImagesByTag = (
+  tag: string,
+  albumId?: number,
+  options?: { tags?: string[]; mode?: 'and' | 'or'; exclude?: string[]; page?: number; pageSize?: number }
+) => {
+  return request.get<PaginatedResponse<Image>>('/tags/search', {
+    params: {
+      tag,
+      albumId,
+      tags: options?.tags?.join(','),
+      mode: options?.mode,
+      exclude: options?.exclude?.join(','),
+      page: options?.page,
+      pageSize: options?.pageSize ?? 100
+    }
+  })
+}
+
+// 重命名标签
+export const renameTag = (id: number, name: string) => {
+  return request.put<ApiResponse<Tag>>(`/tags/${id}`, { name })
+}
+
+// 删除标签
+export const deleteTag = (id: number) => {
+  return request.delete<ApiResponse>(`/tags/${id}`)
+}
+
+// 合并标签，源标签合并到目标标签后被删除
+export const mergeTags = (sourceIds: number[], targetId: number) => {
+  return request.post<ApiResponse<Tag>>('/tags/merge', { sourceIds, targetId })
 }
 
-// 按标签搜索图片
-export const searchImagesByTag = (tag: string, albumId?: number) => {
-  return request.get<PaginatedResponse<Image>>('/tags/search', { params: { tag, albumId } })
+// 批量添加、移除图片标签
+export const batchUpdateImageTags = (imageIds: number[], add: string[] = [], remove: string[] = []) => {
+  return request.post<ApiResponse>('/images/batch-tags', { imageIds, add, remove })
 }
 
 // 更新图片标签
@@ -128,6 +255,88 @@ export const renameImage = (id: number, fileName: string) => {
   return request.put<ApiResponse<Image>>(`/images/${id}/rename`, { fileName })
 }
 
+// 更新图片描述和替代文本
+export const updateImageDescription = (id: number, description: string, altText: string) => {
+  return request.put<ApiResponse<Image>>(`/images/${id}/description`, { description, altText })
+}
+
+// 获取图片的 EXIF/XMP 元数据
+export const getImageMetadata = (id: number) => {
+  return request.get<ApiResponse<ImageMetadata>>(`/images/${id}/metadata`)
+}
+
+// 查找视觉相似的图片，按汉明距离从小到大排序
+export const getSimilarImages = (id: number, maxDistance?: number, limit?: number) => {
+  return request.get<{
+    data: SimilarImage[],
+    perceptualHash: string,
+    maxDistance: number
+  }>(`/images/${id}/similar`, {
+    params: { maxDistance, limit }
+  })
+}
+
+// ========== 重复图片相关 API（管理员） ==========
+
+// 获取相似图片分组报告
+export const getDuplicateImages = (page = 1, pageSize = 20, maxDistance?: number) => {
+  return request.get<DuplicateReport>('/duplicates', {
+    params: { page, pageSize, maxDistance }
+  })
+}
+
+// 每组保留一张，其余移入回收站
+export const resolveDuplicates = (clusters: DuplicateResolution[]) => {
+  return request.post<{
+    deleted: number[],
+    errors: string[]
+  }>('/duplicates/resolve', { clusters })
+}
+
+// 为尚未计算感知哈希的旧图片提交后台计算任务
+export const backfillPerceptualHashes = () => {
+  return request.post<ApiResponse<{ id: number, status: string }>>('/duplicates/backfill')
+}
+
+// ========== 处理配置 API（管理员） ==========
+
+// 获取处理配置列表，builtin 为没有设置默认配置时使用的内置配置
+export const getProcessingProfiles = () => {
+  return request.get<{ data: ProcessingProfile[], builtin: ProcessingProfile }>('/processing-profiles')
+}
+
+// 创建处理配置
+export const createProcessingProfile = (data: ProcessingProfileInput) => {
+  return request.post<ApiResponse<ProcessingProfile>>('/processing-profiles', data)
+}
+
+// 修改处理配置，只影响之后上传和处理的图片
+export const updateProcessingProfile = (id: number, data: ProcessingProfileInput) => {
+  return request.put<ApiResponse<ProcessingProfile>>(`/processing-profiles/${id}`, data)
+}
+
+// 删除处理配置，使用该配置的相册改用默认配置
+export const deleteProcessingProfile = (id: number) => {
+  return request.delete<ApiResponse>(`/processing-profiles/${id}`)
+}
+
+// 按相册当前的处理配置重新处理已有图片
+export const reprocessAlbum = (albumId: number) => {
+  return request.post<ApiResponse<{ id: number, status: string }>>(`/albums/${albumId}/reprocess`)
+}
+
+// 全文搜索图片（文件名、标签、相册、描述、EXIF），翻页时传入上一页的 nextCursor
+export const searchImages = (params: SearchParams) => {
+  return request.get<SearchResponse>('/search', {
+    params: {
+      ...params,
+      format: params.format?.join(','),
+      tags: params.tags?.join(','),
+      facets: params.facets === false ? 'false' : undefined
+    }
+  })
+}
+
 // 更新图片文件（裁剪、滤镜等）
 export const updateImageFile = (id: number, file: Blob) => {
   const formData = new FormData()
@@ -197,9 +406,9 @@ export const getCurrentUser = () => {
   return request.get<ApiResponse<User>>('/auth/me')
 }
 
-// 登出
+// 登出，同时吊销刷新令牌
 export const logout = () => {
-  return request.post<ApiResponse<void>>('/auth/logout')
+  return request.post<ApiResponse<void>>('/auth/logout', { refreshToken: getRefreshToken() || undefined })
 }
 
 // ========== 用户管理相关 API ==========
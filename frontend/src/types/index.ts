@@ -5,10 +5,44 @@ export interface Album {
   description: string
   coverImage: string
   imageCount: number
+  ownerId?: number
+  isPrivate?: boolean
+  isPublic?: boolean
+  allowShare?: boolean
+  enableShortLink?: boolean
+  stripMetadata?: boolean | null // 访问原图时删除 GPS 等敏感元数据，为空时使用所有者的设置
+  processingProfileId?: number | null // 处理配置，为空时使用系统默认配置
+  watermark?: WatermarkSettings | null // 水印，为空时使用处理配置中的水印
+  memberCount?: number
+  myRole?: AlbumRole // 当前用户作为成员的角色
   createdAt: string
   updatedAt: string
 }
 
+// 相册成员角色
+export type AlbumRole = 'viewer' | 'contributor' | 'editor'
+
+// 相册成员
+export interface AlbumMember {
+  id: number
+  albumId: number
+  userId: number
+  role: AlbumRole
+  user?: {
+    id: number
+    username: string
+    avatar?: string
+  }
+  createdAt: string
+}
+
+// 标签
+export interface Tag {
+  id: number
+  name: string
+  count?: number // 使用次数
+}
+
 // 图片类型
 export interface Image {
   id: number
@@ -27,6 +61,12 @@ export interface Image {
   downloadCount: number
   lastViewAt: string | null
   tags: string
+  description?: string
+  altText?: string
+  metadata?: ImageMetadata
+  perceptualHash?: string // 感知哈希（dHash），用于查找相似图片
+  frameCount?: number // 动图帧数，静态图片为 1
+  duration?: number // 动图播放一遍的时长（毫秒）
   createdAt: string
   updatedAt: string
   // 短链字段
@@ -34,6 +74,197 @@ export interface Image {
   shortLinkUrl?: string
 }
 
+// 图片 EXIF/XMP 元数据
+export interface ImageMetadata {
+  imageId: number
+  cameraMake?: string
+  cameraModel?: string
+  lensMake?: string
+  lensModel?: string
+  software?: string
+  artist?: string
+  copyright?: string
+  exposureTime?: string
+  fNumber?: number
+  iso?: number
+  focalLength?: number
+  focalLength35mm?: number
+  exposureBias?: number
+  flash: boolean
+  takenAt?: string
+  orientation?: number
+  // GPS 位置，没有权限时不返回
+  latitude?: number
+  longitude?: number
+  altitude?: number
+  hasXmp: boolean
+  title?: string
+  description?: string
+  keywords?: string
+  rating?: number
+  hasSensitive: boolean // 原图包含 GPS、设备序列号等敏感信息
+  createdAt: string
+  updatedAt: string
+}
+
+// 相似图片，distance 为与参照图片的汉明距离（0-64）
+export interface SimilarImage extends Image {
+  distance: number
+}
+
+// 重复图片分组，建议保留分辨率最高的一张
+export interface DuplicateCluster {
+  suggestedKeepId: number
+  images: SimilarImage[] // 距离相对于建议保留的图片
+}
+
+export interface DuplicateReport extends PaginatedResponse<DuplicateCluster> {
+  maxDistance: number
+  duplicateCount: number // 每组保留一张时可以删除的图片数量
+  unhashed: number // 尚未计算感知哈希的图片数量
+}
+
+export interface DuplicateResolution {
+  keepId: number
+  deleteIds: number[]
+}
+
+// 水印设置，所有者、管理员和签名URL访问时输出无水印的原图
+export type WatermarkPosition =
+  | 'center'
+  | 'north'
+  | 'south'
+  | 'east'
+  | 'west'
+  | 'northeast'
+  | 'northwest'
+  | 'southeast'
+  | 'southwest'
+
+export interface WatermarkSettings {
+  enabled: boolean
+  type: 'text' | 'image'
+  text?: string
+  color?: string // #RRGGBB 或 #RRGGBBAA
+  imageId?: number // 图片水印使用的图片
+  position: WatermarkPosition
+  opacity: number // 不透明度 0-1
+  scale: number // 水印宽度占图片宽度的比例 0-1
+  tile: boolean // 平铺整张图片
+}
+
+// 图片处理配置
+export interface ProfileThumbnail {
+  name: string // 尺寸名称，通过缩略图接口的 size 参数获取
+  width: number
+  height: number
+}
+
+export interface ProcessingProfile {
+  id: number
+  name: string
+  description: string
+  isDefault: boolean
+  thumbnailSizes: ProfileThumbnail[]
+  formats: string[] // 预生成的格式版本，目前支持 webp
+  quality: number
+  maxWidth: number // 上传时原图超过最大尺寸则等比缩小，0 表示不限制
+  maxHeight: number
+  stripMetadata: boolean // 上传时删除原图中的敏感元数据
+  watermark?: WatermarkSettings | null // 输出图片时叠加的水印
+  createdAt: string
+  updatedAt: string
+}
+
+export type ProcessingProfileInput = Omit<ProcessingProfile, 'id' | 'createdAt' | 'updatedAt'>
+
+// 分片上传会话，断线后按 uploadedChunks 继续上传缺少的分片
+export interface UploadSession {
+  id: string
+  albumId: number
+  fileName: string
+  contentType: string
+  fileSize: number
+  chunkSize: number
+  chunkCount: number
+  checksum: string // 整个文件的 SHA-256，为空时不校验
+  expiresAt: string
+  uploadedChunks: number[]
+  createdAt: string
+  updatedAt: string
+}
+
+export interface UploadSessionInput {
+  fileName: string
+  fileSize: number
+  contentType?: string
+  albumId?: number
+  checksum?: string
+}
+
+// 直传存储的上传凭证：PUT 时以文件为请求体并携带 headers，POST 时以表单提交 formData 后把文件放在 fileField 字段
+export interface PresignedUpload {
+  method: 'PUT' | 'POST'
+  url: string
+  headers?: Record<string, string>
+  formData?: Record<string, string>
+  fileField?: string
+  expiresAt: string
+}
+
+// 直传记录，文件直接上传到云存储，完成后确认创建图片
+export interface DirectUpload {
+  id: string
+  albumId: number
+  fileName: string
+  contentType: string // 上传时必须使用的 Content-Type
+  fileSize: number
+  checksum: string
+  expiresAt: string
+  createdAt: string
+  upload: PresignedUpload
+}
+
+// 全文搜索
+export type SearchSort = 'relevance' | 'date' | 'views' | 'size'
+
+export interface SearchParams {
+  q?: string
+  albumId?: number
+  format?: string[] // png、jpg 或 MIME 类型
+  tags?: string[] // 需要包含全部标签
+  dateFrom?: string // YYYY-MM-DD
+  dateTo?: string
+  minSize?: number
+  maxSize?: number
+  sort?: SearchSort
+  order?: 'asc' | 'desc'
+  limit?: number
+  cursor?: string
+  facets?: boolean
+}
+
+export interface SearchFacet {
+  value: string
+  label?: string
+  count: number
+}
+
+export interface SearchResponse {
+  data: Image[]
+  total: number
+  hasMore: boolean
+  nextCursor: string
+  engine: string
+  facets?: {
+    album: SearchFacet[]
+    format: SearchFacet[]
+    tag: SearchFacet[]
+    date: SearchFacet[]
+    size: SearchFacet[]
+  }
+}
+
 // 统计数据类型
 export interface StatisticsOverview {
   totalImages: number
@@ -92,6 +323,7 @@ export interface User {
   status?: string
   avatar?: string
   bio?: string
+  stripMetadata?: boolean // 访问原图时删除 GPS 等敏感元数据
   lastLogin?: string
   loginIP?: string
   createdAt: string
@@ -121,6 +353,8 @@ export interface RegisterRequest {
 
 export interface AuthResponse {
   token: string
+  refreshToken: string
+  expiresIn: number // 访问令牌有效期（秒）
   user: User
 }
 
@@ -147,6 +381,7 @@ export interface PaginatedResponse<T> {
 export interface ProfileUpdateRequest {
   avatar?: string
   bio?: string
+  stripMetadata?: boolean
 }
 
 // 修改密码请求
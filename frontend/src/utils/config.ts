@@ -2,6 +2,7 @@ import type { SystemConfig } from '@/types'
 
 const CONFIG_KEY = 'imagebed_config'
 const TOKEN_KEY = 'imagebed_token'
+const REFRESH_TOKEN_KEY = 'imagebed_refresh_token'
 const USER_KEY = 'imagebed_user'
 
 // 默认配置
@@ -44,6 +45,27 @@ export const removeToken = (): void => {
   localStorage.removeItem(TOKEN_KEY)
 }
 
+// 刷新令牌，访问令牌过期后用于换取新的令牌
+export const getRefreshToken = (): string | null => {
+  return localStorage.getItem(REFRESH_TOKEN_KEY)
+}
+
+export const setRefreshToken = (token: string): void => {
+  localStorage.setItem(REFRESH_TOKEN_KEY, token)
+}
+
+export const removeRefreshToken = (): void => {
+  localStorage.removeItem(REFRESH_TOKEN_KEY)
+}
+
+// 保存登录、注册或刷新后返回的令牌
+export const setAuthTokens = (auth: { token: string; refreshToken?: string }): void => {
+  setToken(auth.token)
+  if (auth.refreshToken) {
+    setRefreshToken(auth.refreshToken)
+  }
+}
+
 // 用户信息管理
 export const getUser = (): any => {
   const saved = localStorage.getItem(USER_KEY)
@@ -68,5 +90,6 @@ export const removeUser = (): void => {
 // 清除所有认证信息
 export const clearAuth = (): void => {
   removeToken()
+  removeRefreshToken()
   removeUser()
 }
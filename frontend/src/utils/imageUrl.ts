@@ -0,0 +1,20 @@
+/**
+ * 图片地址工具函数
+ */
+
+/**
+ * 获取图片地址中的查询参数
+ * 私有图片的地址带有签名参数（token、expires），访问缩略图等其他地址时需要带上
+ */
+export const getSignatureQuery = (url: string): string => {
+  const index = url.indexOf('?')
+  return index >= 0 ? url.slice(index + 1) : ''
+}
+
+/**
+ * 向地址追加查询参数
+ */
+export const appendQuery = (url: string, query: string): string => {
+  if (!query) return url
+  return url + (url.includes('?') ? '&' : '?') + query
+}
@@ -1,10 +1,44 @@
 import axios, { type AxiosInstance, type InternalAxiosRequestConfig, type AxiosResponse } from 'axios'
 import { ElMessage } from 'element-plus'
-import { getConfig, getToken } from './config'
+import { getConfig, getToken, getRefreshToken, setAuthTokens, clearAuth } from './config'
+
+// 不需要刷新令牌重试的认证接口
+const AUTH_PATHS = ['/auth/login', '/auth/register', '/auth/refresh']
+
+// 正在进行的刷新请求，并发的 401 请求共用同一次刷新，避免刷新令牌被重复使用
+let refreshing: Promise<string> | null = null
+
+// 使用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+// 不经过 request 实例，避免刷新失败的 401 再次触发刷新
+const refreshAccessToken = (baseURL: string): Promise<string> => {
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${baseURL}/auth/refresh`, { refreshToken: getRefreshToken() || undefined }, { withCredentials: true })
+      .then((res) => {
+        const data = res.data.data
+        setAuthTokens(data)
+        return data.token as string
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
+// 登录已失效，清除认证信息并跳转到登录页
+const redirectToLogin = async () => {
+  clearAuth()
+  ElMessage.error('登录已过期，请重新登录')
+  const { default: router } = await import('@/router')
+  if (router.currentRoute.value.path !== '/login') {
+    router.push('/login')
+  }
+}
 
 const createRequest = (): AxiosInstance => {
   const config = getConfig()
-  
+
   const request = axios.create({
     baseURL: `${config.backendUrl}/api`,
     timeout: 30000
@@ -31,22 +65,37 @@ const createRequest = (): AxiosInstance => {
     (response: AxiosResponse) => {
       return response.data
     },
-    (error: any) => {
-      const message = error.response?.data?.error || '请求失败'
-      
-      // 401 未授权，跳转到登录页
+    async (error: any) => {
+      const message = error.response?.data?.error || error.response?.data?.message || '请求失败'
+      const original = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined
+
+      // 401 未授权，访问令牌过期时先刷新令牌并重试一次，刷新失败时跳转到登录页
       if (error.response?.status === 401) {
-        ElMessage.error('请先登录')
-        // 可以在这里跳转到登录页
-        // router.push('/login')
+        const isAuthRequest = AUTH_PATHS.some((path) => original?.url?.startsWith(path))
+        if (original && !original._retried && !isAuthRequest && getToken()) {
+          original._retried = true
+          try {
+            const token = await refreshAccessToken(request.defaults.baseURL || '')
+            original.headers.Authorization = `Bearer ${token}`
+            return request(original)
+          } catch {
+            await redirectToLogin()
+            return Promise.reject(error)
+          }
+        }
+
+        // 登录、注册失败的提示由页面显示
+        if (!isAuthRequest) {
+          await redirectToLogin()
+        }
       } else {
         ElMessage.error(message)
       }
-      
+
       return Promise.reject(error)
     }
   )
-  
+
   return request
 }
 